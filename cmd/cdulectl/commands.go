@@ -0,0 +1,702 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gagasdiv/cdule/pkg"
+	"github.com/gagasdiv/cdule/pkg/admin"
+	"github.com/gagasdiv/cdule/pkg/cdule"
+	"github.com/gagasdiv/cdule/pkg/model"
+)
+
+// timeLayout layout of the times in the tables, in the local time zone
+const timeLayout = "2006-01-02 15:04:05 MST"
+
+// jobRow a job of jobs list, with its next run
+type jobRow struct {
+	admin.Job
+	NextRun *time.Time `json:"next_run,omitempty"`
+}
+
+func listJobs(c *ctl, args []string) error {
+	flags := flag.NewFlagSet("jobs list", flag.ContinueOnError)
+	name := flags.String("name", "", "only the jobs of this job name")
+	if args, err := parseFlags(flags, args); nil != err {
+		return err
+	} else if len(args) != 0 {
+		return usageError("unexpected arguments %v", args)
+	}
+	jobs, err := c.repo.GetJobs()
+	if nil != err {
+		return err
+	}
+	// the pending schedules by execution time, so the first one of a job is its next run
+	schedules, err := c.repo.GetPendingSchedules(0, 0)
+	if nil != err {
+		return err
+	}
+	nextRuns := make(map[int64]time.Time, len(jobs))
+	for _, schedule := range schedules {
+		if _, ok := nextRuns[schedule.JobID]; !ok {
+			nextRuns[schedule.JobID] = time.Unix(0, schedule.ExecutionID)
+		}
+	}
+	items := make([]jobRow, 0, len(jobs))
+	rows := make([][]string, 0, len(jobs))
+	for _, job := range jobs {
+		if *name != pkg.EMPTYSTRING && job.JobName != *name {
+			continue
+		}
+		item := jobRow{Job: admin.NewJob(job)}
+		if nextRun, ok := nextRuns[job.ID]; ok {
+			item.NextRun = &nextRun
+		}
+		items = append(items, item)
+		rows = append(rows, []string{strconv.FormatInt(job.ID, 10), job.JobName, job.SubName, cron(job), state(job), formatTimePtr(item.NextRun)})
+	}
+	if c.json {
+		return c.printJSON(items)
+	}
+	return c.printTable([]string{"ID", "NAME", "SUB NAME", "CRON", "STATE", "NEXT RUN"}, rows)
+}
+
+// jobDescription a job of jobs describe, with its upcoming schedules and latest runs
+type jobDescription struct {
+	Job        admin.Job          `json:"job"`
+	Schedules  []admin.Schedule   `json:"schedules"`
+	JobHistory []admin.JobHistory `json:"history"`
+}
+
+func describeJob(c *ctl, args []string) error {
+	flags := flag.NewFlagSet("jobs describe", flag.ContinueOnError)
+	next := flags.Int("next", 5, "number of next fire times")
+	limit := flags.Int("limit", 10, "number of upcoming schedules and latest runs")
+	args, err := parseFlags(flags, args)
+	if nil != err {
+		return err
+	}
+	job, err := c.findJob(args)
+	if nil != err {
+		return err
+	}
+	description := jobDescription{Job: admin.NewJob(*job)}
+	if !job.Expired && !job.Paused {
+		if description.Job.NextRuns, err = cdule.NextRuns(job, time.Now(), *next); nil != err {
+			return err
+		}
+	}
+	schedules, err := c.repo.GetPendingSchedules(job.ID, *limit)
+	if nil != err {
+		return err
+	}
+	jobHistories, err := c.repo.FindJobHistory(model.JobHistoryFilter{JobID: job.ID, Limit: *limit})
+	if nil != err {
+		return err
+	}
+	description.Schedules = make([]admin.Schedule, 0, len(schedules))
+	for _, schedule := range schedules {
+		description.Schedules = append(description.Schedules, admin.NewSchedule(schedule))
+	}
+	description.JobHistory = make([]admin.JobHistory, 0, len(jobHistories))
+	for _, jobHistory := range jobHistories {
+		description.JobHistory = append(description.JobHistory, admin.NewJobHistory(jobHistory))
+	}
+	if c.json {
+		return c.printJSON(description)
+	}
+
+	view := description.Job
+	nextRuns := make([]string, 0, len(view.NextRuns))
+	for _, nextRun := range view.NextRuns {
+		nextRuns = append(nextRuns, nextRun.Local().Format(timeLayout))
+	}
+	rows := [][]string{
+		{"ID:", strconv.FormatInt(view.ID, 10)},
+		{"Name:", view.JobName},
+		{"Sub name:", view.SubName},
+		{"Cron:", cron(*job)},
+		{"Time zone:", view.TimeZone},
+		{"State:", state(*job)},
+		{"Created:", formatTime(view.CreatedAt)},
+		{"Job data:", string(view.JobData)},
+		{"Timeout:", view.Timeout},
+		{"Overlap policy:", string(view.OverlapPolicy)},
+		{"Misfire policy:", string(view.MisfirePolicy)},
+		{"Misfire threshold:", view.MisfireThreshold},
+		{"Next fire times:", strings.Join(nextRuns, ", ")},
+	}
+	if nil != view.RetryPolicy {
+		rows = append(rows, []string{"Retry policy:", fmt.Sprintf("%d attempts, %s backoff from %s to %s, jitter %v", view.RetryPolicy.MaxAttempts,
+			view.RetryPolicy.Backoff, view.RetryPolicy.Interval, view.RetryPolicy.MaxInterval, view.RetryPolicy.Jitter)})
+	}
+	if err = c.printTable(nil, rows); nil != err {
+		return err
+	}
+	fmt.Fprintln(c.out, "\nUpcoming schedules:")
+	if err = c.printTable(scheduleHeader, scheduleRows(schedules, map[int64]string{job.ID: jobName(*job)})); nil != err {
+		return err
+	}
+	fmt.Fprintln(c.out, "\nLatest runs:")
+	return c.printTable(jobHistoryHeader, jobHistoryRows(jobHistories, map[int64]string{job.ID: jobName(*job)}))
+}
+
+func cancelJob(c *ctl, args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return usageError("expected a job name and an optional sub name")
+	}
+	subName := pkg.EMPTYSTRING
+	if len(args) == 2 {
+		subName = args[1]
+	}
+	schedules, err := c.repo.GetSchedulesForJobName(args[0], subName)
+	if nil != err {
+		return err
+	}
+	if err = c.client.CancelJob(args[0], subName); nil != err {
+		return err
+	}
+	if c.json {
+		return c.printJSON(map[string]int{"cancelled": len(schedules)})
+	}
+	fmt.Fprintf(c.out, "Cancelled %d schedule(s) of %s %s\n", len(schedules), args[0], subName)
+	return nil
+}
+
+func triggerJob(c *ctl, args []string) error {
+	job, err := c.findJob(args)
+	if nil != err {
+		return err
+	}
+	schedule, err := c.client.TriggerJob(job.ID)
+	if nil != err {
+		return err
+	}
+	if c.json {
+		return c.printJSON(admin.NewSchedule(*schedule))
+	}
+	fmt.Fprintf(c.out, "Triggered job %d %s, schedule %d on worker %s\n", job.ID, jobName(*job), schedule.ID, schedule.WorkerID)
+	return nil
+}
+
+func pauseJob(c *ctl, args []string) error {
+	return setJobPaused(c, "jobs pause", args, c.client.PauseJob, c.client.PauseAll, "Paused")
+}
+
+func resumeJob(c *ctl, args []string) error {
+	return setJobPaused(c, "jobs resume", args, c.client.ResumeJob, c.client.ResumeAll, "Resumed")
+}
+
+func setJobPaused(c *ctl, name string, args []string, set func(jobID int64) (*model.Job, error), setAll func() ([]model.Job, error), done string) error {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	all := flags.Bool("all", false, "all the jobs")
+	args, err := parseFlags(flags, args)
+	if nil != err {
+		return err
+	}
+	var jobs []model.Job
+	if *all {
+		if len(args) != 0 {
+			return usageError("unexpected arguments %v with -all", args)
+		}
+		if jobs, err = setAll(); nil != err {
+			return err
+		}
+	} else {
+		job, err := c.findJob(args)
+		if nil != err {
+			return err
+		}
+		if job, err = set(job.ID); nil != err {
+			return err
+		}
+		jobs = append(jobs, *job)
+	}
+	if c.json {
+		items := make([]admin.Job, 0, len(jobs))
+		for _, job := range jobs {
+			items = append(items, admin.NewJob(job))
+		}
+		if *all {
+			return c.printJSON(items)
+		}
+		return c.printJSON(items[0])
+	}
+	for _, job := range jobs {
+		fmt.Fprintf(c.out, "%s job %d %s\n", done, job.ID, jobName(job))
+	}
+	if *all {
+		fmt.Fprintf(c.out, "%s %d job(s)\n", done, len(jobs))
+	}
+	return nil
+}
+
+// jobDataFlag the -data flags of jobs update, each a key=value pair of the new job data
+type jobDataFlag cdule.JobData
+
+func (f *jobDataFlag) String() string {
+	return fmt.Sprint(map[string]string(*f))
+}
+
+func (f *jobDataFlag) Set(value string) error {
+	key, val, ok := strings.Cut(value, "=")
+	if !ok || key == pkg.EMPTYSTRING {
+		return fmt.Errorf("expected key=value, got %q", value)
+	}
+	if nil == *f {
+		*f = make(jobDataFlag)
+	}
+	(*f)[key] = val
+	return nil
+}
+
+func updateJob(c *ctl, args []string) error {
+	flags := flag.NewFlagSet("jobs update", flag.ContinueOnError)
+	cronExpression := flags.String("cron", "", "new cron expression, with seconds")
+	timeZone := flags.String("tz", "", "new IANA time zone of the cron expression")
+	var jobData jobDataFlag
+	flags.Var(&jobData, "data", "key=value of the new job data, replacing all of it, repeated for each key")
+	timeout := flags.String("timeout", "", "new timeout of a run, 0 for the global one")
+	overlap := flags.String("overlap", "", "new overlap policy, ALLOW, SKIP, QUEUE or REPLACE")
+	misfire := flags.String("misfire", "", "new misfire policy, FIRE_NOW, FIRE_ALL, SKIP or FIRE_IF_WITHIN")
+	misfireWithin := flags.String("misfire-within", "", "new misfire threshold of FIRE_IF_WITHIN")
+	args, err := parseFlags(flags, args)
+	if nil != err {
+		return err
+	}
+	var update cdule.JobUpdate
+	var set []string
+	flags.Visit(func(f *flag.Flag) { set = append(set, f.Name) })
+	for _, name := range set {
+		switch name {
+		case "cron":
+			update.CronExpression = cronExpression
+		case "tz":
+			update.TimeZone = timeZone
+		case "data":
+			update.JobData = cdule.JobData(jobData)
+		case "timeout":
+			d, err := parseDuration(name, *timeout)
+			if nil != err {
+				return err
+			}
+			update.Timeout = &d
+		case "overlap":
+			policy := model.OverlapPolicy(strings.ToUpper(*overlap))
+			update.OverlapPolicy = &policy
+		case "misfire":
+			policy := model.MisfirePolicy(strings.ToUpper(*misfire))
+			update.MisfirePolicy = &policy
+		case "misfire-within":
+			d, err := parseDuration(name, *misfireWithin)
+			if nil != err {
+				return err
+			}
+			update.MisfireThreshold = &d
+		}
+	}
+	if len(set) == 0 {
+		return usageError("nothing to update, expected at least one of the flags")
+	}
+	job, err := c.findJob(args)
+	if nil != err {
+		return err
+	}
+	if job, err = c.client.UpdateJob(job.ID, update); nil != err {
+		return err
+	}
+	if c.json {
+		return c.printJSON(admin.NewJob(*job))
+	}
+	fmt.Fprintf(c.out, "Updated job %d %s, cron %s\n", job.ID, jobName(*job), cron(*job))
+	return nil
+}
+
+func jobChanges(c *ctl, args []string) error {
+	job, err := c.findJob(args)
+	if nil != err {
+		return err
+	}
+	changes, err := c.client.GetJobChanges(job.ID)
+	if nil != err {
+		return err
+	}
+	if c.json {
+		items := make([]admin.JobChange, 0, len(changes))
+		for _, jobChange := range changes {
+			items = append(items, admin.NewJobChange(jobChange))
+		}
+		return c.printJSON(items)
+	}
+	rows := make([][]string, 0, len(changes))
+	for _, jobChange := range changes {
+		rows = append(rows, []string{formatTime(jobChange.CreatedAt), jobChange.WorkerID, jobChange.Changes})
+	}
+	return c.printTable([]string{"CHANGED AT", "BY", "CHANGES"}, rows)
+}
+
+var scheduleHeader = []string{"SCHEDULE", "EXECUTION TIME", "JOB ID", "JOB", "WORKER", "ATTEMPT", "TRIGGERED"}
+
+func scheduleRows(schedules []model.Schedule, jobNames map[int64]string) [][]string {
+	rows := make([][]string, 0, len(schedules))
+	for _, schedule := range schedules {
+		rows = append(rows, []string{strconv.FormatInt(schedule.ID, 10), formatTime(time.Unix(0, schedule.ExecutionID)),
+			strconv.FormatInt(schedule.JobID, 10), jobNames[schedule.JobID], schedule.WorkerID, strconv.Itoa(schedule.Attempt),
+			strconv.FormatBool(schedule.Triggered)})
+	}
+	return rows
+}
+
+func upcomingSchedules(c *ctl, args []string) error {
+	flags := flag.NewFlagSet("schedules upcoming", flag.ContinueOnError)
+	jobID := flags.Int64("job", 0, "only the schedules of this job id")
+	limit := flags.Int("limit", 20, "maximum number of schedules, 0 for all")
+	if args, err := parseFlags(flags, args); nil != err {
+		return err
+	} else if len(args) != 0 {
+		return usageError("unexpected arguments %v", args)
+	}
+	schedules, err := c.repo.GetPendingSchedules(*jobID, *limit)
+	if nil != err {
+		return err
+	}
+	if c.json {
+		items := make([]admin.Schedule, 0, len(schedules))
+		for _, schedule := range schedules {
+			items = append(items, admin.NewSchedule(schedule))
+		}
+		return c.printJSON(items)
+	}
+	jobNames, err := c.jobNames()
+	if nil != err {
+		return err
+	}
+	return c.printTable(scheduleHeader, scheduleRows(schedules, jobNames))
+}
+
+var jobHistoryHeader = []string{"RUN", "STARTED", "JOB ID", "JOB", "STATUS", "WORKER", "RETRIES", "ERROR"}
+
+func jobHistoryRows(jobHistories []model.JobHistory, jobNames map[int64]string) [][]string {
+	rows := make([][]string, 0, len(jobHistories))
+	for _, jobHistory := range jobHistories {
+		rows = append(rows, []string{strconv.FormatInt(jobHistory.ID, 10), formatTime(jobHistory.CreatedAt),
+			strconv.FormatInt(jobHistory.JobID, 10), jobNames[jobHistory.JobID], string(jobHistory.Status), jobHistory.WorkerID,
+			strconv.Itoa(jobHistory.RetryCount), firstLine(jobHistory.ErrorMessage)})
+	}
+	return rows
+}
+
+func history(c *ctl, args []string) error {
+	flags := flag.NewFlagSet("history", flag.ContinueOnError)
+	job := flags.String("job", "", "only the runs of this job id or job name")
+	subName := flags.String("sub", "", "sub name of the job given by name with -job")
+	workerID := flags.String("worker", "", "only the runs on this worker")
+	statuses := flags.String("status", "", "only the runs in these comma separated statuses")
+	since := flags.String("since", "", "only the runs started since this duration ago (e.g. 24h) or RFC 3339 time")
+	until := flags.String("until", "", "only the runs started before this duration ago or RFC 3339 time")
+	offset := flags.Int("offset", 0, "number of runs skipped")
+	limit := flags.Int("limit", 50, "maximum number of runs, 0 for all")
+	if args, err := parseFlags(flags, args); nil != err {
+		return err
+	} else if len(args) != 0 {
+		return usageError("unexpected arguments %v", args)
+	}
+	filter := model.JobHistoryFilter{WorkerID: *workerID, Offset: *offset, Limit: *limit}
+	if *job != pkg.EMPTYSTRING {
+		jobArgs := []string{*job}
+		if *subName != pkg.EMPTYSTRING {
+			jobArgs = append(jobArgs, *subName)
+		}
+		found, err := c.findJob(jobArgs)
+		if nil != err {
+			return err
+		}
+		filter.JobID = found.ID
+	}
+	for _, status := range strings.Split(*statuses, ",") {
+		if status = strings.ToUpper(strings.TrimSpace(status)); status != pkg.EMPTYSTRING {
+			filter.Statuses = append(filter.Statuses, model.JobStatus(status))
+		}
+	}
+	var err error
+	if filter.Since, err = parseSince("since", *since); nil != err {
+		return err
+	}
+	if filter.Until, err = parseSince("until", *until); nil != err {
+		return err
+	}
+	jobHistories, err := c.repo.FindJobHistory(filter)
+	if nil != err {
+		return err
+	}
+	if c.json {
+		items := make([]admin.JobHistory, 0, len(jobHistories))
+		for _, jobHistory := range jobHistories {
+			items = append(items, admin.NewJobHistory(jobHistory))
+		}
+		return c.printJSON(items)
+	}
+	jobNames, err := c.jobNames()
+	if nil != err {
+		return err
+	}
+	return c.printTable(jobHistoryHeader, jobHistoryRows(jobHistories, jobNames))
+}
+
+func listWorkers(c *ctl, args []string) error {
+	if len(args) != 0 {
+		return usageError("unexpected arguments %v", args)
+	}
+	workers, err := c.repo.GetWorkers()
+	if nil != err {
+		return err
+	}
+	aliveWorkers, err := c.repo.GetAliveWorkers()
+	if nil != err {
+		return err
+	}
+	alive := make(map[string]bool, len(aliveWorkers))
+	for _, worker := range aliveWorkers {
+		alive[worker.WorkerID] = true
+	}
+	items := make([]admin.Worker, 0, len(workers))
+	rows := make([][]string, 0, len(workers))
+	for _, worker := range workers {
+		items = append(items, admin.NewWorker(worker, alive[worker.WorkerID]))
+		rows = append(rows, []string{worker.WorkerID, strconv.FormatBool(alive[worker.WorkerID]), formatTime(worker.UpdatedAt), formatTime(worker.CreatedAt)})
+	}
+	if c.json {
+		return c.printJSON(items)
+	}
+	return c.printTable([]string{"WORKER", "ALIVE", "LAST HEARTBEAT", "CREATED"}, rows)
+}
+
+func migrate(c *ctl, args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	printSQL := flags.Bool("print", false, "print the SQL of the migrations not applied yet rather than applying them")
+	if args, err := parseFlags(flags, args); nil != err {
+		return err
+	} else if len(args) != 0 {
+		return usageError("unexpected arguments %v", args)
+	}
+	if nil == c.db {
+		return fmt.Errorf("%s has no schema to migrate", c.config.Cduletype)
+	}
+	from, err := model.SchemaVersion(c.db)
+	if nil != err {
+		return err
+	}
+	if *printSQL {
+		script, err := model.MigrationSQL(pkg.Dialect(c.db.Dialector.Name()), c.config.TablePrefix, from)
+		if nil != err {
+			return err
+		}
+		_, err = fmt.Fprint(c.out, script)
+		return err
+	}
+	if err = model.Migrate(c.db); nil != err {
+		return err
+	}
+	to, err := model.SchemaVersion(c.db)
+	if nil != err {
+		return err
+	}
+	if c.json {
+		return c.printJSON(map[string]int{"from_version": from, "to_version": to})
+	}
+	fmt.Fprintf(c.out, "Migrated the schema from version %d to %d\n", from, to)
+	return nil
+}
+
+func purge(c *ctl, args []string) error {
+	flags := flag.NewFlagSet("purge", flag.ContinueOnError)
+	olderThan := flags.String("older-than", c.config.HistoryRetention, "purge the runs older than this duration, e.g. 720h")
+	keep := flags.Int("keep", c.config.HistoryRetentionCount, "purge the runs which are not among the latest ones of their job")
+	statuses := flags.String("status", strings.Join(c.config.HistoryRetentionStatuses, ","), "comma separated statuses of the runs purged, all the statuses of the finished runs by default")
+	deletedOlderThan := flags.String("deleted-older-than", c.config.PurgeDeletedAfter, "delete for good the rows soft-deleted more than this duration ago")
+	if args, err := parseFlags(flags, args); nil != err {
+		return err
+	} else if len(args) != 0 {
+		return usageError("unexpected arguments %v", args)
+	}
+	retention, err := parseDuration("older-than", *olderThan)
+	if nil != err {
+		return err
+	}
+	purgeDeletedAfter, err := parseDuration("deleted-older-than", *deletedOlderThan)
+	if nil != err {
+		return err
+	}
+	if retention == 0 && *keep <= 0 && purgeDeletedAfter == 0 {
+		return usageError("nothing to purge, expected -older-than, -keep or -deleted-older-than")
+	}
+	policy := model.RetentionPolicy{KeepPerJob: *keep, Statuses: model.FinishedJobStatuses}
+	if retention > 0 {
+		policy.Before = time.Now().Add(-retention)
+	}
+	if *statuses != pkg.EMPTYSTRING {
+		if policy.Statuses, err = finishedStatuses(*statuses); nil != err {
+			return err
+		}
+	}
+	var purged, deleted int64
+	if retention > 0 || *keep > 0 {
+		if purged, err = c.repo.PurgeJobHistory(policy); nil != err {
+			return err
+		}
+	}
+	if purgeDeletedAfter > 0 {
+		if deleted, err = c.repo.PurgeDeleted(time.Now().Add(-purgeDeletedAfter)); nil != err {
+			return err
+		}
+	}
+	if c.json {
+		return c.printJSON(map[string]int64{"job_histories": purged, "deleted_rows": deleted})
+	}
+	fmt.Fprintf(c.out, "Purged %d job histories and %d soft-deleted rows\n", purged, deleted)
+	return nil
+}
+
+// findJob to get the job given by its id, or by its name and sub name, the latest one when the name was built
+// several times and preferably one which is not expired
+func (c *ctl) findJob(args []string) (*model.Job, error) {
+	if len(args) < 1 || len(args) > 2 {
+		return nil, usageError("expected a job id, or a job name and an optional sub name")
+	}
+	if jobID, err := strconv.ParseInt(args[0], 10, 64); nil == err && len(args) == 1 {
+		job, err := c.repo.GetJob(jobID)
+		if nil != err {
+			return nil, err
+		}
+		if nil == job {
+			return nil, fmt.Errorf("job %d: %w", jobID, model.ErrNotFound)
+		}
+		return job, nil
+	}
+	subName := pkg.EMPTYSTRING
+	if len(args) == 2 {
+		subName = args[1]
+	}
+	jobs, err := c.repo.GetJobs()
+	if nil != err {
+		return nil, err
+	}
+	var found *model.Job
+	for i := range jobs {
+		job := &jobs[i]
+		if job.JobName != args[0] || job.SubName != subName {
+			continue
+		}
+		if nil == found || !job.Expired || found.Expired {
+			found = job
+		}
+	}
+	if nil == found {
+		return nil, fmt.Errorf("job %s %s: %w", args[0], subName, model.ErrNotFound)
+	}
+	return found, nil
+}
+
+// jobNames to get the names of the jobs by job id
+func (c *ctl) jobNames() (map[int64]string, error) {
+	jobs, err := c.repo.GetJobs()
+	if nil != err {
+		return nil, err
+	}
+	names := make(map[int64]string, len(jobs))
+	for _, job := range jobs {
+		names[job.ID] = jobName(job)
+	}
+	return names, nil
+}
+
+func jobName(job model.Job) string {
+	if job.SubName == pkg.EMPTYSTRING {
+		return job.JobName
+	}
+	return job.JobName + " " + job.SubName
+}
+
+func cron(job model.Job) string {
+	if job.Once {
+		return "once"
+	}
+	return job.CronExpression
+}
+
+func state(job model.Job) string {
+	switch {
+	case job.Expired:
+		return "expired"
+	case job.Paused:
+		return "paused"
+	}
+	return "active"
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format(timeLayout)
+}
+
+func formatTimePtr(t *time.Time) string {
+	if nil == t {
+		return "-"
+	}
+	return formatTime(*t)
+}
+
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i != -1 {
+		return s[:i] + " ..."
+	}
+	return s
+}
+
+// parseSince to parse a time, given as a duration ago or in RFC 3339
+func parseSince(name string, value string) (time.Time, error) {
+	if value == pkg.EMPTYSTRING {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(value); nil == err {
+		return time.Now().Add(-d), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if nil != err {
+		return time.Time{}, usageError("invalid -%s %q, expected a duration such as 24h or an RFC 3339 time", name, value)
+	}
+	return t, nil
+}
+
+func parseDuration(name string, value string) (time.Duration, error) {
+	if value == pkg.EMPTYSTRING {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if nil != err || d < 0 {
+		return 0, usageError("invalid -%s %q, expected a duration such as 720h", name, value)
+	}
+	return d, nil
+}
+
+// finishedStatuses to parse comma separated statuses of finished runs
+func finishedStatuses(value string) ([]model.JobStatus, error) {
+	finished := make(map[model.JobStatus]bool, len(model.FinishedJobStatuses))
+	for _, status := range model.FinishedJobStatuses {
+		finished[status] = true
+	}
+	var statuses []model.JobStatus
+	for _, s := range strings.Split(value, ",") {
+		status := model.JobStatus(strings.ToUpper(strings.TrimSpace(s)))
+		if !finished[status] {
+			return nil, usageError("invalid -status %s, expected statuses of finished runs %v", s, model.FinishedJobStatuses)
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
@@ -0,0 +1,247 @@
+// Command cdulectl operates a cluster of cdule workers from the command line: it lists, describes, triggers, pauses,
+// updates and cancels jobs, shows the upcoming schedules, the job histories and the workers, migrates the schema and purges
+// old rows. It connects to the database of the workers with the same configuration file, without joining them as
+// a worker.
+//
+//	cdulectl -config resources/config.yml jobs list
+//	cdulectl -config resources/config.yml -o json history -status FAILED -since 24h
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/gagasdiv/cdule/pkg"
+	"github.com/gagasdiv/cdule/pkg/cdule"
+	"github.com/gagasdiv/cdule/pkg/model"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const usage = `Usage: cdulectl [flags] <command> [arguments]
+
+Commands:
+  jobs list [-name name]                       the jobs and their next run
+  jobs describe <id | name [sub]> [-next n]    a job, its next fire times, upcoming schedules and latest runs
+  jobs cancel <name> [sub]                     delete the schedules of a job
+  jobs trigger <id | name [sub]>               run a job now
+  jobs pause <id | name [sub] | -all>          hold the schedules of a job, or of all the jobs
+  jobs resume <id | name [sub] | -all>         run the schedules of a paused job again, or of all of them
+  jobs update <id | name [sub]> [-cron expr] [-tz zone] [-data key=value ...] [-timeout d]
+              [-overlap policy] [-misfire policy] [-misfire-within d]
+                                               change a job in place, keeping its id and its runs
+  jobs changes <id | name [sub]>               the changes made to a job in place
+  schedules upcoming [-job id] [-limit n]      the schedules which have not started to run
+  history [-job id] [-worker id] [-status s,...] [-since 24h | time] [-until time] [-offset n] [-limit n]
+                                               the job histories, the latest first
+  workers list                                 the workers and whether they are alive
+  migrate [-print]                             apply the schema migrations, or print their SQL
+  purge [-older-than d] [-keep n] [-status s,...] [-deleted-older-than d]
+                                               delete the old job histories and soft-deleted rows
+
+Flags:
+`
+
+// ctl what the commands work with
+type ctl struct {
+	repo model.CduleRepository
+	// db the connection of the repository, nil for REDIS and MEMORY
+	db     *gorm.DB
+	client *cdule.Cdule
+	config *pkg.CduleConfig
+	out    io.Writer
+	json   bool
+}
+
+// command a command, given the arguments after its name
+type command func(c *ctl, args []string) error
+
+var commands = map[string]command{
+	"jobs list":          listJobs,
+	"jobs describe":      describeJob,
+	"jobs cancel":        cancelJob,
+	"jobs trigger":       triggerJob,
+	"jobs pause":         pauseJob,
+	"jobs resume":        resumeJob,
+	"jobs update":        updateJob,
+	"jobs changes":       jobChanges,
+	"schedules upcoming": upcomingSchedules,
+	"history":            history,
+	"workers list":       listWorkers,
+	"migrate":            migrate,
+	"purge":              purge,
+}
+
+// errUsage error of a command line which is not valid, the usage is printed with it
+var errUsage = errors.New("invalid command line")
+
+func usageError(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", errUsage, fmt.Sprintf(format, args...))
+}
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout, os.Stderr); nil != err {
+		fmt.Fprintln(os.Stderr, "cdulectl:", err.Error())
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdout io.Writer, stderr io.Writer) error {
+	flags := flag.NewFlagSet("cdulectl", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		flags.PrintDefaults()
+	}
+	configFile := flags.String("config", os.Getenv("CDULE_CONFIG"), "configuration file of the workers, e.g. resources/config.yml, $CDULE_CONFIG by default")
+	cduleType := flags.String("cduletype", "", "DATABASE, SQLITE or REDIS, overriding the configuration file")
+	dbURL := flags.String("dburl", "", "url of the database, overriding the configuration file")
+	tablePrefix := flags.String("tableprefix", "", "prefix of the cdule tables, overriding the configuration file")
+	output := flags.String("o", "table", "output format, table or json")
+	verbose := flags.Bool("v", false, "log the SQL statements and the debug messages")
+	if err := flags.Parse(args); nil != err {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+	if *output != "table" && *output != "json" {
+		flags.Usage()
+		return usageError("unknown output format %s, expected table or json", *output)
+	}
+	name, command, commandArgs := findCommand(flags.Args())
+	if nil == command {
+		flags.Usage()
+		return usageError("unknown command %q", strings.Join(flags.Args(), " "))
+	}
+
+	config, err := loadConfig(*configFile)
+	if nil != err {
+		return err
+	}
+	if *cduleType != pkg.EMPTYSTRING {
+		config.Cduletype = *cduleType
+	}
+	if *dbURL != pkg.EMPTYSTRING {
+		config.Dburl = *dbURL
+	}
+	if *tablePrefix != pkg.EMPTYSTRING {
+		config.TablePrefix = *tablePrefix
+	}
+	if config.Dburl == pkg.EMPTYSTRING {
+		flags.Usage()
+		return usageError("no database, expected -config or -dburl")
+	}
+	config.Loglevel = logger.Silent
+	log.SetLevel(log.WarnLevel)
+	if *verbose {
+		config.Loglevel = logger.Info
+		log.SetLevel(log.DebugLevel)
+	}
+	// the schema is only changed by the migrate command
+	config.SkipMigration = true
+	repos, err := model.ConnectDataBase(config)
+	if nil != err {
+		return err
+	}
+	c := &ctl{
+		repo:   repos.CduleRepository,
+		db:     repos.DB,
+		client: cdule.NewClient(repos.CduleRepository),
+		config: config,
+		out:    stdout,
+		json:   *output == "json",
+	}
+	if err = command(c, commandArgs); errors.Is(err, errUsage) {
+		flags.Usage()
+	}
+	if nil != err {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+// findCommand to get the command named by the first one or two arguments, and the arguments after its name
+func findCommand(args []string) (string, command, []string) {
+	if len(args) >= 2 {
+		name := args[0] + " " + args[1]
+		if command, ok := commands[name]; ok {
+			return name, command, args[2:]
+		}
+	}
+	if len(args) >= 1 {
+		if command, ok := commands[args[0]]; ok {
+			return args[0], command, args[1:]
+		}
+	}
+	return pkg.EMPTYSTRING, nil, nil
+}
+
+// loadConfig to read the configuration file of the workers, an empty configuration when there is none
+func loadConfig(configFile string) (*pkg.CduleConfig, error) {
+	config := &pkg.CduleConfig{Cduletype: string(pkg.DATABASE)}
+	if configFile == pkg.EMPTYSTRING {
+		return config, nil
+	}
+	v := viper.New()
+	v.SetConfigFile(configFile)
+	if err := v.ReadInConfig(); nil != err {
+		return nil, fmt.Errorf("failed to read the configuration %s: %w", configFile, err)
+	}
+	if err := v.Unmarshal(config); nil != err {
+		return nil, fmt.Errorf("failed to read the configuration %s: %w", configFile, err)
+	}
+	return config, nil
+}
+
+// parseFlags to parse the flags of a command, which can come before or after its positional arguments
+func parseFlags(flags *flag.FlagSet, args []string) ([]string, error) {
+	flags.SetOutput(io.Discard)
+	var positional []string
+	for {
+		if err := flags.Parse(args); nil != err {
+			return nil, usageError("%s", err.Error())
+		}
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// printJSON to print v as indented JSON
+func (c *ctl) printJSON(v interface{}) error {
+	encoder := json.NewEncoder(c.out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// printTable to print rows under a header, if any, aligned in columns
+func (c *ctl) printTable(header []string, rows [][]string) error {
+	w := tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+	if nil != header {
+		fmt.Fprintln(w, strings.Join(header, "\t"))
+	}
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	return w.Flush()
+}
+
+// printf to print a message, only in table output so that the JSON output stays parseable
+func (c *ctl) printf(format string, args ...interface{}) {
+	if !c.json {
+		fmt.Fprintf(c.out, format, args...)
+	}
+}
@@ -0,0 +1,117 @@
+//go:build cgo
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/gagasdiv/cdule/pkg"
+	"github.com/gagasdiv/cdule/pkg/admin"
+	"github.com/gagasdiv/cdule/pkg/model"
+
+	"github.com/stretchr/testify/require"
+)
+
+// runTest to run cdulectl on the sqlite database dbURL, returning what it printed
+func runTest(t *testing.T, dbURL string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	err := run(append([]string{"-cduletype", "SQLITE", "-dburl", dbURL}, args...), &stdout, &stderr)
+	return stdout.String(), err
+}
+
+func Test_Cdulectl(t *testing.T) {
+	dbURL := filepath.Join(t.TempDir(), "cdulectl.db")
+	out, err := runTest(t, dbURL, "migrate")
+	require.NoError(t, err)
+	require.Contains(t, out, "Migrated the schema from version 0 to ")
+
+	repos, err := model.ConnectDataBase(&pkg.CduleConfig{Cduletype: string(pkg.SQLITE), Dburl: dbURL, SkipMigration: true})
+	require.NoError(t, err)
+	repo := repos.CduleRepository
+	_, err = repo.CreateWorker(&model.Worker{WorkerID: "cdulectl-test-worker"})
+	require.NoError(t, err)
+	job, err := repo.CreateJob(&model.Job{JobName: "job.CdulectlTestJob", SubName: "sub", CronExpression: "0 0 0 * * *", JobData: `{"key":"value"}`})
+	require.NoError(t, err)
+	_, err = repo.CreateSchedule(&model.Schedule{ExecutionID: 1, JobID: job.ID, WorkerID: "cdulectl-test-worker"})
+	require.NoError(t, err)
+	for _, status := range []model.JobStatus{model.JobStatusCompleted, model.JobStatusFailed, model.JobStatusCompleted} {
+		_, err = repo.CreateJobHistory(&model.JobHistory{JobID: job.ID, Status: status, WorkerID: "cdulectl-test-worker"})
+		require.NoError(t, err)
+	}
+
+	out, err = runTest(t, dbURL, "jobs", "list")
+	require.NoError(t, err)
+	require.Contains(t, out, "job.CdulectlTestJob")
+	require.Contains(t, out, "active")
+
+	out, err = runTest(t, dbURL, "jobs", "describe", "job.CdulectlTestJob", "sub", "-next", "2")
+	require.NoError(t, err)
+	require.Contains(t, out, "Next fire times:")
+	require.Contains(t, out, "Latest runs:")
+
+	out, err = runTest(t, dbURL, "-o", "json", "jobs", "pause", "job.CdulectlTestJob", "sub")
+	require.NoError(t, err)
+	var paused admin.Job
+	require.NoError(t, json.Unmarshal([]byte(out), &paused), out)
+	require.True(t, paused.Paused)
+
+	out, err = runTest(t, dbURL, "jobs", "resume", "-all")
+	require.NoError(t, err)
+	require.Contains(t, out, "Resumed 1 job(s)")
+	out, err = runTest(t, dbURL, "jobs", "pause", "-all")
+	require.NoError(t, err)
+	require.Contains(t, out, "Paused job 1 job.CdulectlTestJob sub")
+
+	out, err = runTest(t, dbURL, "jobs", "update", "job.CdulectlTestJob", "sub", "-cron", "0 30 9 * * *", "-data", "key=new")
+	require.NoError(t, err)
+	require.Contains(t, out, "Updated job 1 job.CdulectlTestJob sub, cron 0 30 9 * * *")
+	out, err = runTest(t, dbURL, "-o", "json", "jobs", "changes", "1")
+	require.NoError(t, err)
+	var jobChanges []admin.JobChange
+	require.NoError(t, json.Unmarshal([]byte(out), &jobChanges), out)
+	require.Len(t, jobChanges, 1)
+	require.Contains(t, string(jobChanges[0].Changes), `"cron"`)
+	_, err = runTest(t, dbURL, "jobs", "update", "1")
+	require.ErrorIs(t, err, errUsage)
+
+	out, err = runTest(t, dbURL, "jobs", "trigger", "1")
+	require.NoError(t, err)
+	require.Contains(t, out, "on worker cdulectl-test-worker")
+
+	out, err = runTest(t, dbURL, "-o", "json", "history", "-status", "failed", "-since", "1h")
+	require.NoError(t, err)
+	var jobHistories []admin.JobHistory
+	require.NoError(t, json.Unmarshal([]byte(out), &jobHistories), out)
+	require.Len(t, jobHistories, 1)
+	require.Equal(t, model.JobStatusFailed, jobHistories[0].Status)
+
+	out, err = runTest(t, dbURL, "schedules", "upcoming", "-job", "1")
+	require.NoError(t, err)
+	require.Contains(t, out, "job.CdulectlTestJob sub")
+
+	out, err = runTest(t, dbURL, "workers", "list")
+	require.NoError(t, err)
+	require.Contains(t, out, "cdulectl-test-worker  true")
+
+	out, err = runTest(t, dbURL, "jobs", "cancel", "job.CdulectlTestJob", "sub")
+	require.NoError(t, err)
+	require.Contains(t, out, "Cancelled 2 schedule(s)")
+
+	_, err = runTest(t, dbURL, "purge")
+	require.ErrorIs(t, err, errUsage)
+	out, err = runTest(t, dbURL, "purge", "-keep", "1")
+	require.NoError(t, err)
+	require.Contains(t, out, "Purged 2 job histories")
+
+	out, err = runTest(t, dbURL, "migrate", "-print")
+	require.NoError(t, err)
+	require.Contains(t, out, "-- cdule schema migrations for sqlite after version")
+
+	_, err = runTest(t, dbURL, "jobs", "explode")
+	require.ErrorIs(t, err, errUsage)
+	_, err = runTest(t, dbURL, "jobs", "describe", "42")
+	require.ErrorIs(t, err, model.ErrNotFound)
+}
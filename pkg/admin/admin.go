@@ -0,0 +1,616 @@
+// Package admin serves a JSON REST API to inspect and manage the jobs, schedules, job histories and workers of a
+// cdule scheduler. It has no authentication of its own, it is meant to be mounted in the router of a service behind
+// its auth middleware:
+//
+//	mux.Handle("/admin/", http.StripPrefix("/admin", admin.NewHandler(c)))
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gagasdiv/cdule/pkg"
+	"github.com/gagasdiv/cdule/pkg/cdule"
+	"github.com/gagasdiv/cdule/pkg/model"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultLimit number of the job histories and schedules listed when the request has no limit
+	DefaultLimit = 50
+	// MaxLimit largest limit a request can ask for
+	MaxLimit = 500
+	// MaxNextRuns largest number of next fire times a request can ask for
+	MaxNextRuns = 100
+)
+
+// Handler the http.Handler of the admin API of a Cdule:
+//
+//	GET    /jobs                   the jobs, ?name= filters by job name, ?next= adds the next fire times of each job
+//	POST   /jobs                   build a job of a registered job type, see CreateJobRequest
+//	POST   /jobs/pause             hold the schedules of all the jobs, e.g. during a maintenance window
+//	POST   /jobs/resume            run the schedules of all the paused jobs again
+//	GET    /jobs/{id}              a job, ?next= adds its next fire times
+//	PATCH  /jobs/{id}              update the job in place, see UpdateJobRequest
+//	DELETE /jobs/{id}              cancel the job, see cdule.Cdule.CancelJob
+//	POST   /jobs/{id}/pause        hold the schedules of the job
+//	POST   /jobs/{id}/resume       run the schedules of the job again
+//	POST   /jobs/{id}/trigger      run the job now
+//	GET    /jobs/{id}/history      the job histories of the job, filtered and paged like /history
+//	GET    /jobs/{id}/schedules    the upcoming schedules of the job
+//	GET    /jobs/{id}/changes      the changes made to the job, in the order they were made
+//	GET    /history                the job histories, the latest first, ?job_id= ?worker_id= ?status= (repeated or
+//	                               comma separated) ?since= ?until= (RFC 3339) ?offset= ?limit=
+//	GET    /schedules              the schedules which have not started to run, by execution time, ?job_id= ?limit=
+//	GET    /workers                the workers and whether they are alive
+//	GET    /types                  the names of the job types registered in the Cdule, which POST /jobs can build
+type Handler struct {
+	cdule *cdule.Cdule
+}
+
+// NewHandler to get the admin API of c. c can be started before or after.
+func NewHandler(c *cdule.Cdule) *Handler {
+	return &Handler{cdule: c}
+}
+
+// errBadRequest error of a request with an invalid parameter or body
+var errBadRequest = errors.New("bad request")
+
+func badRequest(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", errBadRequest, fmt.Sprintf(format, args...))
+}
+
+// errConflict error of a request creating a record which already exists
+var errConflict = errors.New("conflict")
+
+// route a handler of the requests with a method and a path
+type route func(h *Handler, r *http.Request, jobID int64) (int, interface{}, error)
+
+// ServeHTTP to serve a request of the admin API, see Handler
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	routes, jobID, err := h.routes(r.URL.Path)
+	if nil != err {
+		writeError(w, err)
+		return
+	}
+	if nil == routes {
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: "not found"})
+		return
+	}
+	handle, ok := routes[r.Method]
+	if !ok {
+		methods := make([]string, 0, len(routes))
+		for method := range routes {
+			methods = append(methods, method)
+		}
+		w.Header().Set("Allow", strings.Join(methods, ", "))
+		writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: "method not allowed"})
+		return
+	}
+	if nil == h.cdule.Repository() {
+		writeError(w, cdule.ErrNotStarted)
+		return
+	}
+	status, body, err := handle(h, r, jobID)
+	if nil != err {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, status, body)
+}
+
+// routes to get the routes of path by method, and the job ID of the path if any; nil when no route matches
+func (h *Handler) routes(path string) (map[string]route, int64, error) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	switch {
+	case len(parts) == 1 && parts[0] == "jobs":
+		return map[string]route{http.MethodGet: (*Handler).listJobs, http.MethodPost: (*Handler).createJob}, 0, nil
+	case len(parts) == 1 && parts[0] == "history":
+		return map[string]route{http.MethodGet: (*Handler).listJobHistory}, 0, nil
+	case len(parts) == 1 && parts[0] == "schedules":
+		return map[string]route{http.MethodGet: (*Handler).listSchedules}, 0, nil
+	case len(parts) == 1 && parts[0] == "workers":
+		return map[string]route{http.MethodGet: (*Handler).listWorkers}, 0, nil
+	case len(parts) == 1 && parts[0] == "types":
+		return map[string]route{http.MethodGet: (*Handler).listTypes}, 0, nil
+	case len(parts) < 2 || len(parts) > 3 || parts[0] != "jobs":
+		return nil, 0, nil
+	case len(parts) == 2 && parts[1] == "pause":
+		return map[string]route{http.MethodPost: (*Handler).pauseAll}, 0, nil
+	case len(parts) == 2 && parts[1] == "resume":
+		return map[string]route{http.MethodPost: (*Handler).resumeAll}, 0, nil
+	}
+	jobID, err := strconv.ParseInt(parts[1], 10, 64)
+	if nil != err {
+		return nil, 0, badRequest("invalid job id %q", parts[1])
+	}
+	if len(parts) == 2 {
+		return map[string]route{
+			http.MethodGet:    (*Handler).getJob,
+			http.MethodPatch:  (*Handler).updateJob,
+			http.MethodDelete: (*Handler).cancelJob,
+		}, jobID, nil
+	}
+	switch parts[2] {
+	case "pause":
+		return map[string]route{http.MethodPost: (*Handler).pauseJob}, jobID, nil
+	case "resume":
+		return map[string]route{http.MethodPost: (*Handler).resumeJob}, jobID, nil
+	case "trigger":
+		return map[string]route{http.MethodPost: (*Handler).triggerJob}, jobID, nil
+	case "history":
+		return map[string]route{http.MethodGet: (*Handler).listJobHistory}, jobID, nil
+	case "schedules":
+		return map[string]route{http.MethodGet: (*Handler).listSchedules}, jobID, nil
+	case "changes":
+		return map[string]route{http.MethodGet: (*Handler).listJobChanges}, jobID, nil
+	}
+	return nil, 0, nil
+}
+
+func (h *Handler) listJobs(r *http.Request, _ int64) (int, interface{}, error) {
+	jobs, err := h.cdule.Repository().GetJobs()
+	if nil != err {
+		return 0, nil, err
+	}
+	name := r.URL.Query().Get("name")
+	next, err := parseNext(r.URL.Query().Get("next"))
+	if nil != err {
+		return 0, nil, err
+	}
+	now := time.Now()
+	items := make([]Job, 0, len(jobs))
+	for _, job := range jobs {
+		if name != pkg.EMPTYSTRING && job.JobName != name {
+			continue
+		}
+		view := NewJob(job)
+		if view.NextRuns, err = nextRuns(&job, now, next); nil != err {
+			return 0, nil, err
+		}
+		items = append(items, view)
+	}
+	return http.StatusOK, List{Items: items}, nil
+}
+
+// CreateJobRequest body of POST /jobs, to build a job of a type registered in the Cdule with RegisterType or
+// RegisterTypeV2. Either Cron or RunAt is set. The durations are strings such as "30s" or "5m".
+type CreateJobRequest struct {
+	JobName string            `json:"job_name"`
+	SubName string            `json:"sub_name"`
+	JobData map[string]string `json:"job_data"`
+	// Cron expression of a repeating job
+	Cron string `json:"cron"`
+	// RunAt time a job run only once runs at
+	RunAt            *time.Time          `json:"run_at"`
+	TimeZone         string              `json:"time_zone"`
+	Timeout          string              `json:"timeout"`
+	OverlapPolicy    model.OverlapPolicy `json:"overlap_policy"`
+	MisfirePolicy    model.MisfirePolicy `json:"misfire_policy"`
+	MisfireThreshold string              `json:"misfire_threshold"`
+	RetryPolicy      *RetryPolicy        `json:"retry_policy"`
+}
+
+func (h *Handler) createJob(r *http.Request, _ int64) (int, interface{}, error) {
+	var request CreateJobRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&request); nil != err {
+		return 0, nil, badRequest("invalid body: %s", err.Error())
+	}
+	if (request.Cron == pkg.EMPTYSTRING) == (nil == request.RunAt) {
+		return 0, nil, badRequest("exactly one of cron and run_at is required")
+	}
+	if request.Cron != pkg.EMPTYSTRING {
+		if _, err := cdule.ParseCron(&model.Job{CronExpression: request.Cron, TimeZone: request.TimeZone}); nil != err {
+			return 0, nil, badRequest("invalid cron %q: %s", request.Cron, err.Error())
+		}
+	}
+	abstractJob, err := h.cdule.NewJobByName(request.JobName, request.JobData, request.SubName)
+	if nil != err {
+		return 0, nil, err
+	}
+	// building a repeating job again would update it in place, which is left to PATCH
+	if request.Cron != pkg.EMPTYSTRING {
+		existing, err := h.cdule.GetRepeatingJob(request.JobName, request.SubName)
+		if nil != err {
+			return 0, nil, err
+		}
+		if nil != existing {
+			return 0, nil, fmt.Errorf("%w: job %s with sub name %q already exists as job %d, update it with PATCH /jobs/%d",
+				errConflict, existing.JobName, existing.SubName, existing.ID, existing.ID)
+		}
+	}
+	if abstractJob.Timeout, err = parseDuration("timeout", request.Timeout); nil != err {
+		return 0, nil, err
+	}
+	if abstractJob.MisfireThreshold, err = parseDuration("misfire_threshold", request.MisfireThreshold); nil != err {
+		return 0, nil, err
+	}
+	if nil != request.RetryPolicy {
+		if abstractJob.RetryPolicy, err = request.RetryPolicy.policy(); nil != err {
+			return 0, nil, err
+		}
+	}
+	abstractJob.TimeZone = request.TimeZone
+	abstractJob.Overlap = request.OverlapPolicy
+	abstractJob.Misfire = request.MisfirePolicy
+
+	var job *model.Job
+	if nil != request.RunAt {
+		job, err = abstractJob.BuildToRunAt(*request.RunAt)
+	} else {
+		job, err = abstractJob.Build(request.Cron)
+	}
+	if nil != err {
+		return 0, nil, err
+	}
+	return http.StatusCreated, NewJob(*job), nil
+}
+
+func (h *Handler) getJob(r *http.Request, jobID int64) (int, interface{}, error) {
+	next, err := parseNext(r.URL.Query().Get("next"))
+	if nil != err {
+		return 0, nil, err
+	}
+	job, err := h.job(jobID)
+	if nil != err {
+		return 0, nil, err
+	}
+	view := NewJob(*job)
+	if view.NextRuns, err = nextRuns(job, time.Now(), next); nil != err {
+		return 0, nil, err
+	}
+	return http.StatusOK, view, nil
+}
+
+// UpdateJobRequest body of PATCH /jobs/{id}, to change a job in place so that it keeps its ID and its job histories.
+// The absent fields are left unchanged, the durations are strings such as "30s" or "5m".
+type UpdateJobRequest struct {
+	// Cron expression of a repeating job
+	Cron             *string              `json:"cron"`
+	TimeZone         *string              `json:"time_zone"`
+	JobData          map[string]string    `json:"job_data"`
+	Timeout          *string              `json:"timeout"`
+	OverlapPolicy    *model.OverlapPolicy `json:"overlap_policy"`
+	MisfirePolicy    *model.MisfirePolicy `json:"misfire_policy"`
+	MisfireThreshold *string              `json:"misfire_threshold"`
+	RetryPolicy      *RetryPolicy         `json:"retry_policy"`
+}
+
+// update to get the cdule.JobUpdate of the request
+func (request UpdateJobRequest) update() (cdule.JobUpdate, error) {
+	update := cdule.JobUpdate{
+		CronExpression: request.Cron,
+		TimeZone:       request.TimeZone,
+		JobData:        request.JobData,
+		OverlapPolicy:  request.OverlapPolicy,
+		MisfirePolicy:  request.MisfirePolicy,
+	}
+	if nil != request.Timeout {
+		timeout, err := parseDuration("timeout", *request.Timeout)
+		if nil != err {
+			return update, err
+		}
+		update.Timeout = &timeout
+	}
+	if nil != request.MisfireThreshold {
+		threshold, err := parseDuration("misfire_threshold", *request.MisfireThreshold)
+		if nil != err {
+			return update, err
+		}
+		update.MisfireThreshold = &threshold
+	}
+	if nil != request.RetryPolicy {
+		retryPolicy, err := request.RetryPolicy.policy()
+		if nil != err {
+			return update, err
+		}
+		update.RetryPolicy = &retryPolicy
+	}
+	return update, nil
+}
+
+func (h *Handler) updateJob(r *http.Request, jobID int64) (int, interface{}, error) {
+	var request UpdateJobRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&request); nil != err {
+		return 0, nil, badRequest("invalid body: %s", err.Error())
+	}
+	update, err := request.update()
+	if nil != err {
+		return 0, nil, err
+	}
+	job, err := h.cdule.UpdateJob(jobID, update)
+	if nil != err {
+		return 0, nil, err
+	}
+	return http.StatusOK, NewJob(*job), nil
+}
+
+func (h *Handler) listJobChanges(_ *http.Request, jobID int64) (int, interface{}, error) {
+	if _, err := h.job(jobID); nil != err {
+		return 0, nil, err
+	}
+	jobChanges, err := h.cdule.GetJobChanges(jobID)
+	if nil != err {
+		return 0, nil, err
+	}
+	items := make([]JobChange, 0, len(jobChanges))
+	for _, jobChange := range jobChanges {
+		items = append(items, NewJobChange(jobChange))
+	}
+	return http.StatusOK, List{Items: items}, nil
+}
+
+func (h *Handler) cancelJob(_ *http.Request, jobID int64) (int, interface{}, error) {
+	job, err := h.job(jobID)
+	if nil != err {
+		return 0, nil, err
+	}
+	if err = h.cdule.CancelJob(job.JobName, job.SubName); nil != err {
+		return 0, nil, err
+	}
+	return http.StatusNoContent, nil, nil
+}
+
+func (h *Handler) pauseJob(_ *http.Request, jobID int64) (int, interface{}, error) {
+	job, err := h.cdule.PauseJob(jobID)
+	if nil != err {
+		return 0, nil, err
+	}
+	return http.StatusOK, NewJob(*job), nil
+}
+
+func (h *Handler) resumeJob(_ *http.Request, jobID int64) (int, interface{}, error) {
+	job, err := h.cdule.ResumeJob(jobID)
+	if nil != err {
+		return 0, nil, err
+	}
+	return http.StatusOK, NewJob(*job), nil
+}
+
+func (h *Handler) pauseAll(_ *http.Request, _ int64) (int, interface{}, error) {
+	return jobList(h.cdule.PauseAll())
+}
+
+func (h *Handler) resumeAll(_ *http.Request, _ int64) (int, interface{}, error) {
+	return jobList(h.cdule.ResumeAll())
+}
+
+// jobList to answer with the jobs paused or resumed
+func jobList(jobs []model.Job, err error) (int, interface{}, error) {
+	if nil != err {
+		return 0, nil, err
+	}
+	items := make([]Job, 0, len(jobs))
+	for _, job := range jobs {
+		items = append(items, NewJob(job))
+	}
+	return http.StatusOK, List{Items: items}, nil
+}
+
+func (h *Handler) triggerJob(_ *http.Request, jobID int64) (int, interface{}, error) {
+	schedule, err := h.cdule.TriggerJob(jobID)
+	if nil != err {
+		return 0, nil, err
+	}
+	return http.StatusAccepted, NewSchedule(*schedule), nil
+}
+
+func (h *Handler) listJobHistory(r *http.Request, jobID int64) (int, interface{}, error) {
+	query := r.URL.Query()
+	filter := model.JobHistoryFilter{JobID: jobID, WorkerID: query.Get("worker_id")}
+	var err error
+	if jobID == 0 {
+		if filter.JobID, err = parseInt("job_id", query.Get("job_id")); nil != err {
+			return 0, nil, err
+		}
+	}
+	for _, statuses := range query["status"] {
+		for _, status := range strings.Split(statuses, ",") {
+			filter.Statuses = append(filter.Statuses, model.JobStatus(strings.ToUpper(strings.TrimSpace(status))))
+		}
+	}
+	if filter.Since, err = parseTime("since", query.Get("since")); nil != err {
+		return 0, nil, err
+	}
+	if filter.Until, err = parseTime("until", query.Get("until")); nil != err {
+		return 0, nil, err
+	}
+	offset, err := parseInt("offset", query.Get("offset"))
+	if nil != err {
+		return 0, nil, err
+	}
+	limit, err := parseLimit(query.Get("limit"))
+	if nil != err {
+		return 0, nil, err
+	}
+	// one more than the page, to tell whether there is a next page
+	filter.Offset = int(offset)
+	filter.Limit = limit + 1
+	jobHistories, err := h.cdule.Repository().FindJobHistory(filter)
+	if nil != err {
+		return 0, nil, err
+	}
+	page := Page{Offset: filter.Offset, Limit: limit, HasMore: len(jobHistories) > limit}
+	if page.HasMore {
+		jobHistories = jobHistories[:limit]
+	}
+	items := make([]JobHistory, 0, len(jobHistories))
+	for _, jobHistory := range jobHistories {
+		items = append(items, NewJobHistory(jobHistory))
+	}
+	page.Items = items
+	return http.StatusOK, page, nil
+}
+
+func (h *Handler) listSchedules(r *http.Request, jobID int64) (int, interface{}, error) {
+	query := r.URL.Query()
+	var err error
+	if jobID == 0 {
+		if jobID, err = parseInt("job_id", query.Get("job_id")); nil != err {
+			return 0, nil, err
+		}
+	}
+	limit, err := parseLimit(query.Get("limit"))
+	if nil != err {
+		return 0, nil, err
+	}
+	schedules, err := h.cdule.Repository().GetPendingSchedules(jobID, limit)
+	if nil != err {
+		return 0, nil, err
+	}
+	items := make([]Schedule, 0, len(schedules))
+	for _, schedule := range schedules {
+		items = append(items, NewSchedule(schedule))
+	}
+	return http.StatusOK, List{Items: items}, nil
+}
+
+func (h *Handler) listWorkers(_ *http.Request, _ int64) (int, interface{}, error) {
+	workers, err := h.cdule.Repository().GetWorkers()
+	if nil != err {
+		return 0, nil, err
+	}
+	aliveWorkers, err := h.cdule.Repository().GetAliveWorkers()
+	if nil != err {
+		return 0, nil, err
+	}
+	alive := make(map[string]bool, len(aliveWorkers))
+	for _, worker := range aliveWorkers {
+		alive[worker.WorkerID] = true
+	}
+	items := make([]Worker, 0, len(workers))
+	for _, worker := range workers {
+		items = append(items, NewWorker(worker, alive[worker.WorkerID]))
+	}
+	return http.StatusOK, List{Items: items}, nil
+}
+
+func (h *Handler) listTypes(_ *http.Request, _ int64) (int, interface{}, error) {
+	return http.StatusOK, List{Items: h.cdule.RegisteredJobNames()}, nil
+}
+
+// job to get a job by ID, model.ErrNotFound when there is none
+func (h *Handler) job(jobID int64) (*model.Job, error) {
+	job, err := h.cdule.Repository().GetJob(jobID)
+	if nil != err {
+		return nil, err
+	}
+	if nil == job {
+		return nil, model.ErrNotFound
+	}
+	return job, nil
+}
+
+func parseInt(name string, value string) (int64, error) {
+	if value == pkg.EMPTYSTRING {
+		return 0, nil
+	}
+	i, err := strconv.ParseInt(value, 10, 64)
+	if nil != err || i < 0 {
+		return 0, badRequest("invalid %s %q", name, value)
+	}
+	return i, nil
+}
+
+func parseLimit(value string) (int, error) {
+	limit, err := parseInt("limit", value)
+	if nil != err {
+		return 0, err
+	}
+	if limit == 0 {
+		return DefaultLimit, nil
+	}
+	if limit > MaxLimit {
+		return 0, badRequest("limit %d exceeds %d", limit, MaxLimit)
+	}
+	return int(limit), nil
+}
+
+func parseNext(value string) (int, error) {
+	next, err := parseInt("next", value)
+	if nil != err {
+		return 0, err
+	}
+	if next > MaxNextRuns {
+		return 0, badRequest("next %d exceeds %d", next, MaxNextRuns)
+	}
+	return int(next), nil
+}
+
+// nextRuns the next n fire times of a job which is neither expired nor paused, none when n is 0
+func nextRuns(job *model.Job, from time.Time, n int) ([]time.Time, error) {
+	if n == 0 || job.Expired || job.Paused {
+		return nil, nil
+	}
+	return cdule.NextRuns(job, from, n)
+}
+
+// checkPolicies to check the overlap and misfire policies of a request, the empty ones are the defaults
+func parseTime(name string, value string) (time.Time, error) {
+	if value == pkg.EMPTYSTRING {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if nil != err {
+		return time.Time{}, badRequest("invalid %s %q, expected RFC 3339", name, value)
+	}
+	return t, nil
+}
+
+func parseDuration(name string, value string) (time.Duration, error) {
+	if value == pkg.EMPTYSTRING {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if nil != err || d < 0 {
+		return 0, badRequest("invalid %s %q", name, value)
+	}
+	return d, nil
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// writeError to answer with the status of err: 404 for a missing record, 400 for an invalid request, 409 for a job
+// which already exists, 503 when the Cdule is not started and 500 otherwise, whose error is logged rather than returned
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	message := "internal error"
+	switch {
+	case errors.Is(err, model.ErrNotFound):
+		status, message = http.StatusNotFound, "not found"
+	case errors.Is(err, errBadRequest), errors.Is(err, cdule.ErrUnregisteredJob), errors.Is(err, cdule.ErrInvalidJob),
+		errors.Is(err, cdule.ErrInvalidJobUpdate):
+		status, message = http.StatusBadRequest, err.Error()
+	case errors.Is(err, errConflict):
+		status, message = http.StatusConflict, err.Error()
+	case errors.Is(err, cdule.ErrNotStarted):
+		status, message = http.StatusServiceUnavailable, err.Error()
+	default:
+		log.Errorf("Error in admin request %s ", err.Error())
+	}
+	writeJSON(w, status, errorResponse{Error: message})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	if status == http.StatusNoContent {
+		w.WriteHeader(status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); nil != err {
+		log.Errorf("Error writing admin response %s ", err.Error())
+	}
+}
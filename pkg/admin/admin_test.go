@@ -0,0 +1,263 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gagasdiv/cdule/pkg"
+	"github.com/gagasdiv/cdule/pkg/cdule"
+	"github.com/gagasdiv/cdule/pkg/model"
+
+	"github.com/stretchr/testify/require"
+)
+
+type adminTestJob struct{}
+
+func (a *adminTestJob) Execute(ctx context.Context, data cdule.JobData) (cdule.JobResult, error) {
+	return cdule.JobResult{}, nil
+}
+
+func (a *adminTestJob) JobName() string {
+	return "job.AdminTestJob"
+}
+
+func newTestHandler(t *testing.T) (*cdule.Cdule, *Handler) {
+	c, err := cdule.NewWithRepository(model.NewMemoryRepository(), "admin-test-worker", &pkg.CduleConfig{TickDuration: "1h"})
+	require.NoError(t, err)
+	t.Cleanup(c.StopWatcher)
+	c.RegisterTypeV2(&adminTestJob{})
+	return c, NewHandler(c)
+}
+
+// serve to send a request to h and decode the JSON response into out, if any
+func serve(t *testing.T, h http.Handler, method string, path string, body string, out interface{}) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, httptest.NewRequest(method, path, strings.NewReader(body)))
+	if nil != out {
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), out), recorder.Body.String())
+	}
+	return recorder
+}
+
+func createTestJob(t *testing.T, h http.Handler, subName string) Job {
+	var job Job
+	body := fmt.Sprintf(`{"job_name": "job.AdminTestJob", "sub_name": %q, "job_data": {"key": "value"}, "cron": "0 0 0 * * *", "timeout": "30s"}`, subName)
+	recorder := serve(t, h, http.MethodPost, "/jobs", body, &job)
+	require.Equal(t, http.StatusCreated, recorder.Code, recorder.Body.String())
+	return job
+}
+
+func Test_AdminCreateJob(t *testing.T) {
+	_, h := newTestHandler(t)
+	job := createTestJob(t, h, "a")
+	require.NotZero(t, job.ID)
+	require.Equal(t, "job.AdminTestJob", job.JobName)
+	require.Equal(t, "0 0 0 * * *", job.Cron)
+	require.Equal(t, "30s", job.Timeout)
+	require.JSONEq(t, `{"key": "value"}`, string(job.JobData))
+
+	// a repeating job with the same name and sub name is updated with PATCH, not created again
+	var conflict errorResponse
+	body := `{"job_name": "job.AdminTestJob", "sub_name": "a", "cron": "0 30 0 * * *"}`
+	recorder := serve(t, h, http.MethodPost, "/jobs", body, &conflict)
+	require.Equal(t, http.StatusConflict, recorder.Code)
+	require.Contains(t, conflict.Error, fmt.Sprintf("PATCH /jobs/%d", job.ID))
+	var unchanged Job
+	serve(t, h, http.MethodGet, fmt.Sprintf("/jobs/%d", job.ID), "", &unchanged)
+	require.Equal(t, "0 0 0 * * *", unchanged.Cron)
+
+	for _, body := range []string{
+		`{"job_name": "job.UnknownJob", "cron": "0 0 0 * * *"}`,
+		`{"job_name": "job.AdminTestJob", "cron": "not a cron"}`,
+		`{"job_name": "job.AdminTestJob"}`,
+		`{"job_name": "job.AdminTestJob", "cron": "0 0 0 * * *", "run_at": "2030-01-01T00:00:00Z"}`,
+		`{"job_name": "job.AdminTestJob", "cron": "0 0 0 * * *", "overlap_policy": "SOMETIMES"}`,
+		`{"job_name": "job.AdminTestJob", "cron": "0 0 0 * * *", "retry_policy": {"jitter": 2}}`,
+		`{"job_name": "job.AdminTestJob", "cron": "0 0 0 * * *", "timeout": "soon"}`,
+		`{"job_name": "job.AdminTestJob", "cron": "0 0 0 * * *", "unknown": true}`,
+	} {
+		var response errorResponse
+		recorder := serve(t, h, http.MethodPost, "/jobs", body, &response)
+		require.Equal(t, http.StatusBadRequest, recorder.Code, body)
+		require.NotEmpty(t, response.Error)
+	}
+}
+
+func Test_AdminGetJobs(t *testing.T) {
+	_, h := newTestHandler(t)
+	first := createTestJob(t, h, "a")
+	createTestJob(t, h, "b")
+
+	var list struct{ Items []Job }
+	require.Equal(t, http.StatusOK, serve(t, h, http.MethodGet, "/jobs", "", &list).Code)
+	require.Len(t, list.Items, 2)
+	require.Equal(t, http.StatusOK, serve(t, h, http.MethodGet, "/jobs?name=job.UnknownJob", "", &list).Code)
+	require.Empty(t, list.Items)
+
+	var job Job
+	require.Equal(t, http.StatusOK, serve(t, h, http.MethodGet, fmt.Sprintf("/jobs/%d", first.ID), "", &job).Code)
+	require.Equal(t, first.ID, job.ID)
+	require.Equal(t, "a", job.SubName)
+	require.Empty(t, job.NextRuns)
+	require.Equal(t, http.StatusOK, serve(t, h, http.MethodGet, fmt.Sprintf("/jobs/%d?next=3", first.ID), "", &job).Code)
+	require.Len(t, job.NextRuns, 3)
+	require.Equal(t, 24*time.Hour, job.NextRuns[1].Sub(job.NextRuns[0]))
+	require.Equal(t, http.StatusOK, serve(t, h, http.MethodGet, "/jobs?next=2", "", &list).Code)
+	require.Len(t, list.Items[1].NextRuns, 2)
+	require.Equal(t, http.StatusBadRequest, serve(t, h, http.MethodGet, "/jobs?next=1000", "", nil).Code)
+
+	var types struct{ Items []string }
+	require.Equal(t, http.StatusOK, serve(t, h, http.MethodGet, "/types", "", &types).Code)
+	require.Equal(t, []string{"job.AdminTestJob"}, types.Items)
+
+	require.Equal(t, http.StatusNotFound, serve(t, h, http.MethodGet, "/jobs/999", "", nil).Code)
+	require.Equal(t, http.StatusBadRequest, serve(t, h, http.MethodGet, "/jobs/abc", "", nil).Code)
+	require.Equal(t, http.StatusNotFound, serve(t, h, http.MethodGet, "/unknown", "", nil).Code)
+	recorder := serve(t, h, http.MethodPut, "/jobs", "", nil)
+	require.Equal(t, http.StatusMethodNotAllowed, recorder.Code)
+	require.Contains(t, recorder.Header().Get("Allow"), http.MethodPost)
+}
+
+func Test_AdminPauseTriggerCancelJob(t *testing.T) {
+	c, h := newTestHandler(t)
+	created := createTestJob(t, h, "a")
+	path := fmt.Sprintf("/jobs/%d", created.ID)
+
+	var job Job
+	require.Equal(t, http.StatusOK, serve(t, h, http.MethodPost, path+"/pause", "", &job).Code)
+	require.True(t, job.Paused)
+	require.Equal(t, http.StatusOK, serve(t, h, http.MethodPost, path+"/resume", "", &job).Code)
+	require.False(t, job.Paused)
+
+	var list struct{ Items []Job }
+	require.Equal(t, http.StatusOK, serve(t, h, http.MethodPost, "/jobs/pause", "", &list).Code)
+	require.Len(t, list.Items, 1)
+	require.True(t, list.Items[0].Paused)
+	require.Equal(t, http.StatusOK, serve(t, h, http.MethodPost, "/jobs/pause", "", &list).Code)
+	require.Empty(t, list.Items)
+	require.Equal(t, http.StatusOK, serve(t, h, http.MethodPost, "/jobs/resume", "", &list).Code)
+	require.Len(t, list.Items, 1)
+	require.False(t, list.Items[0].Paused)
+
+	var schedule Schedule
+	require.Equal(t, http.StatusAccepted, serve(t, h, http.MethodPost, path+"/trigger", "", &schedule).Code)
+	require.True(t, schedule.Triggered)
+	require.Equal(t, created.ID, schedule.JobID)
+
+	var schedules struct{ Items []Schedule }
+	require.Equal(t, http.StatusOK, serve(t, h, http.MethodGet, path+"/schedules", "", &schedules).Code)
+	require.Len(t, schedules.Items, 2)
+	require.True(t, schedules.Items[0].Triggered)
+	require.Equal(t, http.StatusOK, serve(t, h, http.MethodGet, "/schedules?limit=1", "", &schedules).Code)
+	require.Len(t, schedules.Items, 1)
+
+	require.Equal(t, http.StatusNoContent, serve(t, h, http.MethodDelete, path, "", nil).Code)
+	remaining, err := c.Repository().GetSchedulesForJob(created.ID)
+	require.NoError(t, err)
+	require.Empty(t, remaining)
+	require.Equal(t, http.StatusNotFound, serve(t, h, http.MethodPost, "/jobs/999/trigger", "", nil).Code)
+}
+
+func Test_AdminUpdateJob(t *testing.T) {
+	_, h := newTestHandler(t)
+	job := createTestJob(t, h, "a")
+	path := fmt.Sprintf("/jobs/%d", job.ID)
+
+	var updated Job
+	recorder := serve(t, h, http.MethodPatch, path, `{"cron": "0 30 9 * * *", "job_data": {"key": "new"}, "misfire_policy": "SKIP"}`, &updated)
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	require.Equal(t, job.ID, updated.ID)
+	require.Equal(t, "0 30 9 * * *", updated.Cron)
+	require.Equal(t, model.MisfireSkip, updated.MisfirePolicy)
+	require.Equal(t, "30s", updated.Timeout)
+	require.JSONEq(t, `{"key": "new"}`, string(updated.JobData))
+
+	var schedules List
+	serve(t, h, http.MethodGet, path+"/schedules", "", &schedules)
+	require.Len(t, schedules.Items, 1)
+	schedule := schedules.Items.([]interface{})[0].(map[string]interface{})
+	require.Equal(t, map[string]interface{}{"key": "new"}, schedule["job_data"])
+
+	var jobChanges struct {
+		Items []JobChange `json:"items"`
+	}
+	recorder = serve(t, h, http.MethodGet, path+"/changes", "", &jobChanges)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Len(t, jobChanges.Items, 1)
+	require.Equal(t, "admin-test-worker", jobChanges.Items[0].WorkerID)
+	var changes map[string]struct {
+		From interface{} `json:"from"`
+		To   interface{} `json:"to"`
+	}
+	require.NoError(t, json.Unmarshal(jobChanges.Items[0].Changes, &changes))
+	require.Equal(t, "0 0 0 * * *", changes["cron"].From)
+	require.Equal(t, "0 30 9 * * *", changes["cron"].To)
+	require.Contains(t, changes, "misfire_policy")
+
+	for _, body := range []string{`{"cron": "not a cron"}`, `{"timeout": "soon"}`, `{"overlap_policy": "SOMETIMES"}`, `{"unknown": true}`} {
+		recorder = serve(t, h, http.MethodPatch, path, body, nil)
+		require.Equal(t, http.StatusBadRequest, recorder.Code, body)
+	}
+	recorder = serve(t, h, http.MethodPatch, "/jobs/42", `{"timeout": "1m"}`, nil)
+	require.Equal(t, http.StatusNotFound, recorder.Code)
+	recorder = serve(t, h, http.MethodGet, "/jobs/42/changes", "", nil)
+	require.Equal(t, http.StatusNotFound, recorder.Code)
+}
+
+func Test_AdminJobHistory(t *testing.T) {
+	c, h := newTestHandler(t)
+	job := createTestJob(t, h, "a")
+	for i := 0; i < 5; i++ {
+		status := model.JobStatusCompleted
+		if i%2 == 1 {
+			status = model.JobStatusFailed
+		}
+		_, err := c.Repository().CreateJobHistory(&model.JobHistory{JobID: job.ID, ScheduleID: int64(i + 1), Status: status, WorkerID: "admin-test-worker"})
+		require.NoError(t, err)
+	}
+
+	var page struct {
+		Items   []JobHistory
+		Offset  int
+		Limit   int
+		HasMore bool `json:"has_more"`
+	}
+	require.Equal(t, http.StatusOK, serve(t, h, http.MethodGet, "/history?limit=2", "", &page).Code)
+	require.Len(t, page.Items, 2)
+	require.True(t, page.HasMore)
+	require.Equal(t, int64(5), page.Items[0].ScheduleID)
+
+	require.Equal(t, http.StatusOK, serve(t, h, http.MethodGet, "/history?limit=2&offset=4", "", &page).Code)
+	require.Len(t, page.Items, 1)
+	require.False(t, page.HasMore)
+	require.Equal(t, 4, page.Offset)
+
+	path := fmt.Sprintf("/jobs/%d/history?status=failed", job.ID)
+	require.Equal(t, http.StatusOK, serve(t, h, http.MethodGet, path, "", &page).Code)
+	require.Len(t, page.Items, 2)
+	require.Equal(t, http.StatusOK, serve(t, h, http.MethodGet, "/history?job_id=999", "", &page).Code)
+	require.Empty(t, page.Items)
+
+	require.Equal(t, http.StatusBadRequest, serve(t, h, http.MethodGet, "/history?since=yesterday", "", nil).Code)
+	require.Equal(t, http.StatusBadRequest, serve(t, h, http.MethodGet, "/history?limit=1000", "", nil).Code)
+}
+
+func Test_AdminWorkers(t *testing.T) {
+	_, h := newTestHandler(t)
+	var list struct{ Items []Worker }
+	require.Equal(t, http.StatusOK, serve(t, h, http.MethodGet, "/workers", "", &list).Code)
+	require.Len(t, list.Items, 1)
+	require.Equal(t, "admin-test-worker", list.Items[0].WorkerID)
+	require.True(t, list.Items[0].Alive)
+}
+
+func Test_AdminNotStarted(t *testing.T) {
+	h := NewHandler(&cdule.Cdule{})
+	require.Equal(t, http.StatusServiceUnavailable, serve(t, h, http.MethodGet, "/jobs", "", nil).Code)
+}
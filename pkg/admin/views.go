@@ -0,0 +1,216 @@
+package admin
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/gagasdiv/cdule/pkg"
+	"github.com/gagasdiv/cdule/pkg/model"
+)
+
+// List body of the responses listing records
+type List struct {
+	Items interface{} `json:"items"`
+}
+
+// Page body of the responses listing a page of records, HasMore tells whether there is a next page
+type Page struct {
+	Items   interface{} `json:"items"`
+	Offset  int         `json:"offset"`
+	Limit   int         `json:"limit"`
+	HasMore bool        `json:"has_more"`
+}
+
+// Job a job, as returned by the API
+type Job struct {
+	ID               int64               `json:"id"`
+	CreatedAt        time.Time           `json:"created_at"`
+	UpdatedAt        time.Time           `json:"updated_at"`
+	JobName          string              `json:"job_name"`
+	SubName          string              `json:"sub_name"`
+	Cron             string              `json:"cron,omitempty"`
+	TimeZone         string              `json:"time_zone,omitempty"`
+	Once             bool                `json:"once"`
+	Expired          bool                `json:"expired"`
+	Paused           bool                `json:"paused"`
+	JobData          json.RawMessage     `json:"job_data,omitempty"`
+	Timeout          string              `json:"timeout,omitempty"`
+	OverlapPolicy    model.OverlapPolicy `json:"overlap_policy,omitempty"`
+	MisfirePolicy    model.MisfirePolicy `json:"misfire_policy,omitempty"`
+	MisfireThreshold string              `json:"misfire_threshold,omitempty"`
+	RetryPolicy      *RetryPolicy        `json:"retry_policy,omitempty"`
+	// NextRuns next fire times of the job, when asked for with ?next=
+	NextRuns []time.Time `json:"next_runs,omitempty"`
+}
+
+// RetryPolicy the retry policy of a job, with the durations as strings such as "30s"
+type RetryPolicy struct {
+	MaxAttempts int           `json:"max_attempts"`
+	Backoff     model.Backoff `json:"backoff,omitempty"`
+	Interval    string        `json:"interval,omitempty"`
+	MaxInterval string        `json:"max_interval,omitempty"`
+	Jitter      float64       `json:"jitter,omitempty"`
+}
+
+// Schedule a schedule, as returned by the API
+type Schedule struct {
+	ID             int64           `json:"id"`
+	JobID          int64           `json:"job_id"`
+	ExecutionTime  time.Time       `json:"execution_time"`
+	ExecutionID    int64           `json:"execution_id"`
+	WorkerID       string          `json:"worker_id"`
+	Attempt        int             `json:"attempt"`
+	Triggered      bool            `json:"triggered"`
+	ClaimedBy      string          `json:"claimed_by,omitempty"`
+	ReassignedFrom string          `json:"reassigned_from,omitempty"`
+	JobData        json.RawMessage `json:"job_data,omitempty"`
+}
+
+// JobHistory a run of a job, as returned by the API
+type JobHistory struct {
+	ID           int64           `json:"id"`
+	CreatedAt    time.Time       `json:"created_at"`
+	UpdatedAt    time.Time       `json:"updated_at"`
+	JobID        int64           `json:"job_id"`
+	ScheduleID   int64           `json:"schedule_id"`
+	Status       model.JobStatus `json:"status"`
+	WorkerID     string          `json:"worker_id"`
+	RetryCount   int             `json:"retry_count"`
+	Output       string          `json:"output,omitempty"`
+	ErrorMessage string          `json:"error_message,omitempty"`
+}
+
+// JobChange a change made to a job in place, Changes maps the changed fields to their "from" and "to" values
+type JobChange struct {
+	ID        int64           `json:"id"`
+	CreatedAt time.Time       `json:"created_at"`
+	JobID     int64           `json:"job_id"`
+	WorkerID  string          `json:"worker_id"`
+	Changes   json.RawMessage `json:"changes"`
+}
+
+// Worker a worker, alive while it sends heartbeats
+type Worker struct {
+	WorkerID  string    `json:"worker_id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Alive     bool      `json:"alive"`
+}
+
+// NewJob to get the view of a job
+func NewJob(job model.Job) Job {
+	view := Job{
+		ID:               job.ID,
+		CreatedAt:        job.CreatedAt,
+		UpdatedAt:        job.UpdatedAt,
+		JobName:          job.JobName,
+		SubName:          job.SubName,
+		Cron:             job.CronExpression,
+		TimeZone:         job.TimeZone,
+		Once:             job.Once,
+		Expired:          job.Expired,
+		Paused:           job.Paused,
+		JobData:          jobData(job.JobData),
+		Timeout:          formatDuration(job.Timeout),
+		OverlapPolicy:    job.OverlapPolicy,
+		MisfirePolicy:    job.MisfirePolicy,
+		MisfireThreshold: formatDuration(job.MisfireThreshold),
+	}
+	if job.RetryPolicy.MaxAttempts > 1 {
+		view.RetryPolicy = &RetryPolicy{
+			MaxAttempts: job.RetryPolicy.MaxAttempts,
+			Backoff:     job.RetryPolicy.Backoff,
+			Interval:    formatDuration(job.RetryPolicy.Interval),
+			MaxInterval: formatDuration(job.RetryPolicy.MaxInterval),
+			Jitter:      job.RetryPolicy.Jitter,
+		}
+	}
+	return view
+}
+
+// policy to get the model.RetryPolicy of the request
+func (p RetryPolicy) policy() (model.RetryPolicy, error) {
+	interval, err := parseDuration("retry_policy interval", p.Interval)
+	if nil != err {
+		return model.RetryPolicy{}, err
+	}
+	maxInterval, err := parseDuration("retry_policy max_interval", p.MaxInterval)
+	if nil != err {
+		return model.RetryPolicy{}, err
+	}
+	return model.RetryPolicy{
+		MaxAttempts: p.MaxAttempts,
+		Backoff:     p.Backoff,
+		Interval:    interval,
+		MaxInterval: maxInterval,
+		Jitter:      p.Jitter,
+	}, nil
+}
+
+// NewSchedule to get the view of a schedule
+func NewSchedule(schedule model.Schedule) Schedule {
+	return Schedule{
+		ID:             schedule.ID,
+		JobID:          schedule.JobID,
+		ExecutionTime:  time.Unix(0, schedule.ExecutionID),
+		ExecutionID:    schedule.ExecutionID,
+		WorkerID:       schedule.WorkerID,
+		Attempt:        schedule.Attempt,
+		Triggered:      schedule.Triggered,
+		ClaimedBy:      schedule.ClaimedBy,
+		ReassignedFrom: schedule.ReassignedFrom,
+		JobData:        jobData(schedule.JobData),
+	}
+}
+
+// NewJobHistory to get the view of a job history
+func NewJobHistory(jobHistory model.JobHistory) JobHistory {
+	return JobHistory{
+		ID:           jobHistory.ID,
+		CreatedAt:    jobHistory.CreatedAt,
+		UpdatedAt:    jobHistory.UpdatedAt,
+		JobID:        jobHistory.JobID,
+		ScheduleID:   jobHistory.ScheduleID,
+		Status:       jobHistory.Status,
+		WorkerID:     jobHistory.WorkerID,
+		RetryCount:   jobHistory.RetryCount,
+		Output:       jobHistory.Output,
+		ErrorMessage: jobHistory.ErrorMessage,
+	}
+}
+
+// NewJobChange to get the view of a job change
+func NewJobChange(jobChange model.JobChange) JobChange {
+	return JobChange{
+		ID:        jobChange.ID,
+		CreatedAt: jobChange.CreatedAt,
+		JobID:     jobChange.JobID,
+		WorkerID:  jobChange.WorkerID,
+		Changes:   jobData(jobChange.Changes),
+	}
+}
+
+// NewWorker to get the view of a worker
+func NewWorker(worker model.Worker, alive bool) Worker {
+	return Worker{
+		WorkerID:  worker.WorkerID,
+		CreatedAt: worker.CreatedAt,
+		UpdatedAt: worker.UpdatedAt,
+		Alive:     alive,
+	}
+}
+
+// jobData the job data, stored as JSON, nil when there is none or it is not valid JSON
+func jobData(jobDataStr string) json.RawMessage {
+	if jobDataStr == pkg.EMPTYSTRING || jobDataStr == "null" || !json.Valid([]byte(jobDataStr)) {
+		return nil
+	}
+	return json.RawMessage(jobDataStr)
+}
+
+func formatDuration(d time.Duration) string {
+	if d == 0 {
+		return pkg.EMPTYSTRING
+	}
+	return d.String()
+}
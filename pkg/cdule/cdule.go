@@ -1,77 +1,363 @@
 package cdule
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/gagasdiv/cdule/pkg"
 	"github.com/gagasdiv/cdule/pkg/model"
 
 	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
 	"gorm.io/gorm"
 )
 
-// WorkerID string
-var WorkerID string
-
-// Cdule holds watcher objects
+// Cdule a scheduler. It owns its repository, job registry, worker identity and watchers, so that several
+// Cdules (e.g. on two databases or table prefixes) can run in the same process.
 type Cdule struct {
 	*WorkerWatcher
 	*ScheduleWatcher
 	PastScheduleWatcher *PastScheduleWatcher
+	// Janitor purging the old job histories, nil when no retention is configured
+	Janitor *Janitor
+
+	// WorkerID identity of this worker in the cluster, the host name when not set
+	WorkerID string
+
+	repo model.CduleRepository
+	// client whether the Cdule only manages the jobs of the workers, see NewClient
+	client       bool
+	registry     *jobRegistry
+	registryOnce sync.Once
+
+	listeners     *listenerSet
+	listenersOnce sync.Once
+
+	// tracer of the spans of the runs, the one of the global tracer provider when nil
+	tracer trace.Tracer
+}
+
+// ErrNotStarted error of a job built or cancelled in a Cdule which has not been started with NewCdule
+var ErrNotStarted = errors.New("cdule is not started")
+
+// ErrNoAliveWorker error of a schedule created by a client when no worker is alive to run it, see NewClient
+var ErrNoAliveWorker = errors.New("no alive worker")
+
+// defaultCdule the Cdule used by the package functions NewJob, NewJobV2, CancelJob, PauseJob, RegisterType, AddListener, ...
+var defaultCdule struct {
+	sync.Mutex
+	cdule *Cdule
+}
+
+// Default to get the Cdule used by the package functions NewJob, NewJobV2, CancelJob, PauseJob, RegisterType, AddListener, ...,
+// which is the first Cdule started. The jobs and listeners added before any Cdule is started are given to it.
+func Default() *Cdule {
+	defaultCdule.Lock()
+	defer defaultCdule.Unlock()
+	if nil == defaultCdule.cdule {
+		defaultCdule.cdule = &Cdule{}
+	}
+	return defaultCdule.cdule
+}
+
+// setDefault to make the started cdule the default one, unless another started Cdule already is
+func setDefault(cdule *Cdule) {
+	defaultCdule.Lock()
+	defer defaultCdule.Unlock()
+	current := defaultCdule.cdule
+	if nil != current && nil != current.repo {
+		return
+	}
+	if nil != current && current != cdule {
+		cdule.jobRegistry().merge(current.jobRegistry())
+		cdule.listenerSet().merge(current.listenerSet())
+	}
+	defaultCdule.cdule = cdule
+}
+
+// unsetDefault to let the next started Cdule become the default one, when the stopped cdule is the default one
+func unsetDefault(cdule *Cdule) {
+	defaultCdule.Lock()
+	defer defaultCdule.Unlock()
+	if defaultCdule.cdule == cdule {
+		defaultCdule.cdule = nil
+	}
+}
+
+// newCdule to create a Cdule on the repository, without starting it
+func newCdule(workerID string, repo model.CduleRepository) *Cdule {
+	return &Cdule{
+		WorkerID: workerID,
+		repo:     repo,
+		registry: newJobRegistry(),
+	}
+}
+
+// jobRegistry to get the job registry of the cdule, created on first use
+func (cdule *Cdule) jobRegistry() *jobRegistry {
+	cdule.registryOnce.Do(func() {
+		if nil == cdule.registry {
+			cdule.registry = newJobRegistry()
+		}
+	})
+	return cdule.registry
+}
+
+// WithDB to get a copy of the cdule storing its jobs and schedules with db, e.g. a transaction of the caller so that
+// they are committed or rolled back with the caller's data. The copy shares the job registry, listeners and worker
+// of the cdule but runs no watchers, it is meant for NewJob, NewJobV2 and CancelJob only.
+func (cdule *Cdule) WithDB(db *gorm.DB) *Cdule {
+	return &Cdule{
+		WorkerID:  cdule.WorkerID,
+		repo:      model.NewCduleRepository(db),
+		registry:  cdule.jobRegistry(),
+		listeners: cdule.listenerSet(),
+		tracer:    cdule.tracer,
+	}
+}
+
+// Repository to get the repository of the cdule, nil until it is started
+func (cdule *Cdule) Repository() model.CduleRepository {
+	return cdule.repo
+}
+
+// StartStage step of the start of a Cdule
+type StartStage string
+
+const (
+	// StageConfig validating the configuration
+	StageConfig StartStage = "config"
+	// StageDatabase connecting and migrating the database
+	StageDatabase StartStage = "database"
+	// StageWorker registering the worker
+	StageWorker StartStage = "worker"
+)
+
+// StartError error of a Cdule which could not be started, it has not started any watcher
+type StartError struct {
+	Stage StartStage
+	Err   error
+}
+
+func (e *StartError) Error() string {
+	return fmt.Sprintf("cdule failed to start at %s: %s", e.Stage, e.Err.Error())
+}
+
+func (e *StartError) Unwrap() error {
+	return e.Err
+}
+
+// New to create and start a new scheduler with default worker name as hostname.
+// Returns a *StartError when the configuration is invalid or the database or worker could not be set up.
+func New(config ...*pkg.CduleConfig) (*Cdule, error) {
+	return NewWithWorker("", config...)
+}
+
+// NewWithWorker to create and start a new scheduler with worker, see New
+func NewWithWorker(workerName string, config ...*pkg.CduleConfig) (*Cdule, error) {
+	cdule := &Cdule{}
+	if err := cdule.NewCduleWithWorker(workerName, config...); nil != err {
+		return nil, err
+	}
+	return cdule, nil
 }
 
-func init() {
-	WorkerID = getWorkerID()
+// NewWithRepository to create and start a new scheduler storing its jobs and schedules in repo, e.g.
+// model.NewMemoryRepository() or another implementation of model.CduleRepository. The database fields of
+// the config are not used.
+func NewWithRepository(repo model.CduleRepository, workerName string, config ...*pkg.CduleConfig) (*Cdule, error) {
+	cdule := &Cdule{repo: repo}
+	if err := cdule.NewCduleWithWorker(workerName, config...); nil != err {
+		return nil, err
+	}
+	return cdule, nil
+}
+
+// NewClient to manage the jobs of the workers storing their jobs and schedules in repo, e.g. from a tool, without
+// joining them as a worker: it runs no job, has no watcher to stop, and the schedules it creates are given to the
+// alive worker with the smallest worker id.
+func NewClient(repo model.CduleRepository) *Cdule {
+	return &Cdule{repo: repo, client: true}
+}
+
+// scheduleWorkerID to get the worker the schedules created by the cdule are given to, itself unless it is a client
+func (cdule *Cdule) scheduleWorkerID() (string, error) {
+	if !cdule.client {
+		return cdule.WorkerID, nil
+	}
+	workers, err := cdule.repo.GetAliveWorkers()
+	if nil != err {
+		return pkg.EMPTYSTRING, err
+	}
+	workerID := pkg.EMPTYSTRING
+	for _, worker := range workers {
+		if workerID == pkg.EMPTYSTRING || worker.WorkerID < workerID {
+			workerID = worker.WorkerID
+		}
+	}
+	if workerID == pkg.EMPTYSTRING {
+		return pkg.EMPTYSTRING, ErrNoAliveWorker
+	}
+	return workerID, nil
 }
 
-// NewCduleWithWorker to create new scheduler with worker
-func (cdule *Cdule) NewCduleWithWorker(workerName string, config ...*pkg.CduleConfig) {
-	WorkerID = workerName
-	cdule.NewCdule(config...)
+// NewCduleWithWorker to create new scheduler with worker, see NewCdule
+func (cdule *Cdule) NewCduleWithWorker(workerName string, config ...*pkg.CduleConfig) error {
+	cdule.WorkerID = workerName
+	return cdule.NewCdule(config...)
 }
 
-// NewCdule to create new scheduler with default worker name as hostname
-func (cdule *Cdule) NewCdule(config ...*pkg.CduleConfig) {
+// NewCdule to create new scheduler with default worker name as hostname.
+// Returns a *StartError when the configuration is invalid or the database or worker could not be set up,
+// in which case nothing has been started.
+func (cdule *Cdule) NewCdule(config ...*pkg.CduleConfig) error {
 	cfg := pkg.ResolveConfig(config...)
+	validate := cfg.Validate
+	if nil != cdule.repo {
+		validate = cfg.ValidateScheduling
+	}
+	if err := validate(); nil != err {
+		return &StartError{Stage: StageConfig, Err: err}
+	}
+	statuses, err := retentionStatuses(cfg)
+	if nil != err {
+		return &StartError{Stage: StageConfig, Err: err}
+	}
+	consistency, _ := pkg.ParseConsistency(cfg.Cduleconsistency)
+	if nil != cfg.TracerProvider {
+		cdule.tracer = cfg.TracerProvider.Tracer(tracerName)
+	}
+	if cdule.WorkerID == "" {
+		workerID, err := getWorkerID()
+		if nil != err {
+			return &StartError{Stage: StageWorker, Err: err}
+		}
+		cdule.WorkerID = workerID
+	}
 
-	model.ConnectDataBase(cfg)
-	worker, err := model.CduleRepos.CduleRepository.GetWorker(WorkerID)
+	repos := &model.Repositories{CduleRepository: cdule.repo}
+	if nil == repos.CduleRepository {
+		var err error
+		if repos, err = model.ConnectDataBase(cfg); nil != err {
+			return &StartError{Stage: StageDatabase, Err: err}
+		}
+	}
+	worker, err := registerWorker(repos.CduleRepository, cdule.WorkerID)
+	if nil != err {
+		// the connection or repository of the caller is left open
+		if nil == cfg.DB && nil == cfg.SQLDB && nil != repos.DB {
+			if sqlDB, dbErr := repos.DB.DB(); nil == dbErr {
+				sqlDB.Close()
+			}
+		}
+		return &StartError{Stage: StageWorker, Err: err}
+	}
+
+	cdule.repo = repos.CduleRepository
+	setDefault(cdule)
+	workerEvent := cdule.workerEvent(*worker)
+	cdule.notify(func(listener Listener) { listener.OnWorkerJoined(workerEvent) })
+	interrupted := cdule.recoverInterruptedJobs(consistency)
+	cdule.createWatcherAndWaitForSignal(cfg, consistency, interrupted)
+	if cfg.RetentionEnabled() {
+		cdule.Janitor = cdule.createJanitor(cfg, statuses)
+	}
+	return nil
+}
+
+// registerWorker to create the worker, or update its health check when it already exists
+func registerWorker(repo model.CduleRepository, workerID string) (*model.Worker, error) {
+	worker, err := repo.GetWorker(workerID)
 	if nil != err {
 		log.Errorf("Error getting worker %s ", err.Error())
-		return
+		return nil, err
 	}
 	if nil != worker {
 		worker.UpdatedAt = time.Now()
-		model.CduleRepos.CduleRepository.UpdateWorker(worker)
-	} else {
-		// First time cdule started on a worker node
-		worker := model.Worker{
-			WorkerID:  WorkerID,
-			CreatedAt: time.Time{},
-			UpdatedAt: time.Time{},
-			DeletedAt: gorm.DeletedAt{},
+		return repo.UpdateWorker(worker)
+	}
+	// First time cdule started on a worker node
+	return repo.CreateWorker(&model.Worker{
+		WorkerID:  workerID,
+		CreatedAt: time.Time{},
+		UpdatedAt: time.Time{},
+		DeletedAt: gorm.DeletedAt{},
+	})
+}
+
+// recoverInterruptedJobs to handle the runs left unfinished by a previous process of this worker.
+// Claimed schedules and runs which never started are always re-run, runs which were IN_PROGRESS are marked FAILED
+// for AT_MOST_ONCE and re-run otherwise. Returns the schedules to re-run.
+func (cdule *Cdule) recoverInterruptedJobs(consistency pkg.Consistency) []model.Schedule {
+	jobHistories, err := cdule.repo.GetJobHistoryForWorker(cdule.WorkerID,
+		[]model.JobStatus{model.JobStatusNew, model.JobStatusInProgress})
+	if nil != err {
+		log.Errorf("Error getting interrupted job histories for worker %s : %s", cdule.WorkerID, err.Error())
+		return nil
+	}
+	schedules := make([]model.Schedule, 0)
+	for i := range jobHistories {
+		jobHistory := &jobHistories[i]
+		from := jobHistory.Status
+		if from == model.JobStatusInProgress {
+			if consistency == pkg.AT_MOST_ONCE {
+				jobHistory.Status = model.JobStatusFailed
+				cdule.repo.UpdateJobHistoryStatus(jobHistory, from)
+				log.Warnf("Job history %d of schedule %d was interrupted, marked as %s", jobHistory.ID, jobHistory.ScheduleID, jobHistory.Status)
+				continue
+			}
+			jobHistory.Status = model.JobStatusNew
+			jobHistory.RetryCount++
+		}
+		schedule, err := cdule.repo.GetScheduleByID(jobHistory.ScheduleID)
+		if nil != err || schedule.ID == 0 {
+			// the schedule has been cancelled in the meantime
+			jobHistory.Status = model.JobStatusFailed
 		}
-		model.CduleRepos.CduleRepository.CreateWorker(&worker)
+		cdule.repo.UpdateJobHistoryStatus(jobHistory, from)
+		if jobHistory.Status == model.JobStatusFailed {
+			continue
+		}
+		log.Warnf("Job history %d of schedule %d was interrupted, it will be re-run", jobHistory.ID, jobHistory.ScheduleID)
+		schedules = append(schedules, *schedule)
 	}
 
-	cdule.createWatcherAndWaitForSignal(cfg)
+	// schedules claimed right before the crash never started, so they can be run whatever the consistency
+	claimed, err := cdule.repo.GetClaimedScheduleWithoutHistory(cdule.WorkerID)
+	if nil != err {
+		log.Errorf("Error getting claimed schedules for worker %s : %s", cdule.WorkerID, err.Error())
+		return schedules
+	}
+	for _, schedule := range claimed {
+		log.Warnf("Schedule %d was claimed but never started, it will be run", schedule.ID)
+		schedules = append(schedules, schedule)
+	}
+	return schedules
 }
 
-func (cdule *Cdule) createWatcherAndWaitForSignal(config *pkg.CduleConfig) {
+func (cdule *Cdule) createWatcherAndWaitForSignal(config *pkg.CduleConfig, consistency pkg.Consistency, interrupted []model.Schedule) {
 	/*
 		schedule watcher stop logic to abort program with signal like ctrl + c
 		c := make(chan os.Signal)
 		signal.Notify(c, os.Interrupt)*/
 
-	workerWatcher := createWorkerWatcher()
-	schedulerWatcher := createSchedulerWatcher(config)
+	// the durations have been validated by NewCdule
+	tick, _ := time.ParseDuration(config.TickDuration)
+	pool := newExecutionPool(config.MaxConcurrency, config.JobConcurrency, !config.UnorderedSchedules)
+	workerWatcher := cdule.createWorkerWatcher(consistency)
+	schedulerWatcher := cdule.createSchedulerWatcher(config, tick, consistency, interrupted, pool)
 	cdule.WorkerWatcher = workerWatcher
 	cdule.ScheduleWatcher = schedulerWatcher
 
+	// schedules missed at their execution time are handled when watching the past, the misfire policy of their job
+	// decides whether they run
 	var pastScheduleWatcher *PastScheduleWatcher
 	if config.WatchPast {
-		pastScheduleWatcher = createPastSchedulerWatcher(config)
+		pastScheduleWatcher = cdule.createPastSchedulerWatcher(config, tick, consistency, pool)
 		cdule.PastScheduleWatcher = pastScheduleWatcher
 	}
 	/*select {
@@ -86,19 +372,30 @@ func (cdule *Cdule) createWatcherAndWaitForSignal(config *pkg.CduleConfig) {
 }
 
 // StopWatcher to stop watchers
-func (cdule Cdule) StopWatcher() {
+func (cdule *Cdule) StopWatcher() {
 	cdule.WorkerWatcher.Stop()
 	cdule.ScheduleWatcher.Stop()
 
 	if cdule.PastScheduleWatcher != nil {
 		cdule.PastScheduleWatcher.Stop()
 	}
+	// the pool is shared by the watchers, it is waited for once none of them submits anymore. In-flight runs are
+	// waited for, queued ones are skipped and re-run by the next start of this worker
+	cdule.ScheduleWatcher.pool.wait()
+	if cdule.Janitor != nil {
+		cdule.Janitor.Stop()
+	}
+	unsetDefault(cdule)
 }
-func createWorkerWatcher() *WorkerWatcher {
+func (cdule *Cdule) createWorkerWatcher(consistency pkg.Consistency) *WorkerWatcher {
 	workerWatcher := &WorkerWatcher{
-		Closed: make(chan struct{}),
-		Ticker: time.NewTicker(time.Second * 30), // used for worker health check update in db.
+		cdule:       cdule,
+		Closed:      make(chan struct{}),
+		Ticker:      time.NewTicker(time.Second * 30), // used for worker health check update in db and dead worker failover.
+		Consistency: consistency,
 	}
+	// the workers alive at start are not reported as joined
+	workerWatcher.watchWorkers()
 
 	workerWatcher.WG.Add(1)
 	go func() {
@@ -108,17 +405,19 @@ func createWorkerWatcher() *WorkerWatcher {
 	return workerWatcher
 }
 
-func createSchedulerWatcher(config *pkg.CduleConfig) *ScheduleWatcher {
-	tick, err := time.ParseDuration(config.TickDuration)
-	if err != nil {
-		panic(err)
-	}
+func (cdule *Cdule) createSchedulerWatcher(config *pkg.CduleConfig, tick time.Duration, consistency pkg.Consistency, interrupted []model.Schedule, pool *executionPool) *ScheduleWatcher {
 	scheduleWatcher := &ScheduleWatcher{
-		Closed: make(chan struct{}),
-		TickDuration: tick,
-		Ticker: time.NewTicker(tick),
+		cdule:          cdule,
+		Closed:         make(chan struct{}),
+		TickDuration:   tick,
+		Ticker:         time.NewTicker(tick),
 		RunImmediately: config.RunImmediately,
+		Consistency:    consistency,
+		JobTimeout:     jobTimeout(config),
+		interrupted:    interrupted,
+		pool:           pool,
 	}
+	scheduleWatcher.ctx, scheduleWatcher.cancel = context.WithCancel(context.Background())
 
 	scheduleWatcher.WG.Add(1)
 	go func() {
@@ -128,19 +427,21 @@ func createSchedulerWatcher(config *pkg.CduleConfig) *ScheduleWatcher {
 	return scheduleWatcher
 }
 
-func createPastSchedulerWatcher(config *pkg.CduleConfig) *PastScheduleWatcher {
-	tick, err := time.ParseDuration(config.TickDuration)
-	if err != nil {
-		panic(err)
-	}
+func (cdule *Cdule) createPastSchedulerWatcher(config *pkg.CduleConfig, tick time.Duration, consistency pkg.Consistency, pool *executionPool) *PastScheduleWatcher {
 	pastScheduleWatcher := &PastScheduleWatcher{
 		ScheduleWatcher: ScheduleWatcher{
-			Closed: make(chan struct{}),
-			TickDuration: tick,
-			Ticker: time.NewTicker(tick),
+			cdule:          cdule,
+			Closed:         make(chan struct{}),
+			TickDuration:   tick,
+			Ticker:         time.NewTicker(tick),
 			RunImmediately: config.RunImmediately,
+			Consistency:    consistency,
+			JobTimeout:     jobTimeout(config),
+			pool:           pool,
+			catchUp:        true,
 		},
 	}
+	pastScheduleWatcher.ctx, pastScheduleWatcher.cancel = context.WithCancel(context.Background())
 
 	pastScheduleWatcher.WG.Add(1)
 	go func() {
@@ -150,10 +451,16 @@ func createPastSchedulerWatcher(config *pkg.CduleConfig) *PastScheduleWatcher {
 	return pastScheduleWatcher
 }
 
-func getWorkerID() string {
+// jobTimeout to parse the global job timeout validated by NewCdule, 0 when not configured
+func jobTimeout(config *pkg.CduleConfig) time.Duration {
+	timeout, _ := time.ParseDuration(config.JobTimeout)
+	return timeout
+}
+
+func getWorkerID() (string, error) {
 	hostname, err := os.Hostname()
 	if err != nil {
-		os.Exit(1)
+		return "", fmt.Errorf("failed to get the host name as worker id: %w", err)
 	}
-	return hostname
+	return hostname, nil
 }
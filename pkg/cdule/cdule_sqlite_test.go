@@ -0,0 +1,52 @@
+//go:build cgo
+
+package cdule
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/gagasdiv/cdule/pkg"
+	"github.com/gagasdiv/cdule/pkg/model"
+	"github.com/gagasdiv/cdule/pkg/utils"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func Test_NewWithDB(t *testing.T) {
+	repos, err := model.ConnectDataBase(&pkg.CduleConfig{
+		Cduletype: string(pkg.SQLITE),
+		Dburl:     filepath.Join(t.TempDir(), "cdule.db"),
+		Loglevel:  logger.Silent,
+	})
+	require.NoError(t, err)
+	c, err := NewWithWorker("db-worker", &pkg.CduleConfig{DB: repos.DB})
+	require.NoError(t, err)
+	defer c.StopWatcher()
+
+	// jobs built in a transaction of the caller are rolled back with it
+	rollback := errors.New("rollback")
+	err = repos.DB.Transaction(func(tx *gorm.DB) error {
+		_, err := c.WithDB(tx).NewJob(&watcherTestJob{}, nil).Build(utils.EveryMinute)
+		require.NoError(t, err)
+		return rollback
+	})
+	require.ErrorIs(t, err, rollback)
+	job, err := c.Repository().GetJobByName((&watcherTestJob{}).JobName())
+	require.NoError(t, err)
+	require.Nil(t, job)
+
+	err = repos.DB.Transaction(func(tx *gorm.DB) error {
+		_, err := c.WithDB(tx).NewJob(&watcherTestJob{}, nil).Build(utils.EveryMinute)
+		return err
+	})
+	require.NoError(t, err)
+	job, err = c.Repository().GetJobByName((&watcherTestJob{}).JobName())
+	require.NoError(t, err)
+	require.NotNil(t, job)
+	_, ok := c.jobRegistry().get(job.JobName)
+	require.True(t, ok)
+}
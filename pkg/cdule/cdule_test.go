@@ -0,0 +1,147 @@
+package cdule
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gagasdiv/cdule/pkg"
+	"github.com/gagasdiv/cdule/pkg/model"
+	"github.com/gagasdiv/cdule/pkg/utils"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm/logger"
+)
+
+func Test_TwoCdulesInOneProcess(t *testing.T) {
+	first := newTestCdule(t, "first-worker")
+	second := newTestCdule(t, "second-worker")
+	watcherTestJobRuns = 0
+
+	firstJob, err := first.NewJob(&watcherTestJob{}, nil).BuildToRunNow()
+	require.NoError(t, err)
+	_, err = second.NewJobV2(&failingTestJob{}, nil).Build(utils.EveryMinute)
+	require.NoError(t, err)
+
+	// each cdule only knows its own jobs
+	_, ok := first.jobRegistry().get(firstJob.JobName)
+	require.True(t, ok)
+	_, ok = second.jobRegistry().get(firstJob.JobName)
+	require.False(t, ok)
+	job, err := second.repo.GetJobByName(firstJob.JobName)
+	require.NoError(t, err)
+	require.Nil(t, job)
+
+	schedules, err := first.repo.GetSchedulesForJob(firstJob.ID)
+	require.NoError(t, err)
+	require.Equal(t, first.WorkerID, schedules[0].WorkerID)
+	runTestSchedules(newTestWatcher(first, pkg.AT_LEAST_ONCE), schedules)
+	require.Equal(t, 1, watcherTestJobRuns)
+	jobHistory, err := first.repo.GetJobHistoryForSchedule(schedules[0].ID)
+	require.NoError(t, err)
+	require.Equal(t, model.JobStatusCompleted, jobHistory.Status)
+	require.Equal(t, first.WorkerID, jobHistory.WorkerID)
+}
+
+func Test_DefaultCdule(t *testing.T) {
+	defer unsetDefault(Default())
+
+	// the jobs registered before a cdule is started are given to the first one started
+	placeholder := Default()
+	RegisterType(&watcherTestJob{})
+	_, err := NewJob(&watcherTestJob{}, nil).Build(utils.EveryMinute)
+	require.ErrorIs(t, err, ErrNotStarted)
+
+	first := newTestCdule(t, "first-worker")
+	setDefault(first)
+	require.Same(t, first, Default())
+	require.NotSame(t, placeholder, Default())
+	_, ok := first.jobRegistry().get((&watcherTestJob{}).JobName())
+	require.True(t, ok)
+
+	// the next cdule started does not replace the default one
+	second := newTestCdule(t, "second-worker")
+	setDefault(second)
+	require.Same(t, first, Default())
+
+	job, err := NewJob(&watcherTestJob{}, nil).BuildToRunNow()
+	require.NoError(t, err)
+	schedules, err := first.repo.GetSchedulesForJob(job.ID)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(schedules))
+}
+
+func Test_NewErrors(t *testing.T) {
+	memory := func(configure func(*pkg.CduleConfig)) *pkg.CduleConfig {
+		config := &pkg.CduleConfig{
+			Cduletype: string(pkg.MEMORY),
+			Loglevel:  logger.Silent,
+		}
+		configure(config)
+		return config
+	}
+	for name, test := range map[string]struct {
+		config *pkg.CduleConfig
+		stage  StartStage
+		field  string
+	}{
+		"cduletype":    {config: memory(func(c *pkg.CduleConfig) { c.Cduletype = "CASSANDRA" }), stage: StageConfig, field: "Cduletype"},
+		"dburl":        {config: memory(func(c *pkg.CduleConfig) { c.Cduletype = string(pkg.SQLITE) }), stage: StageConfig, field: "Dburl"},
+		"memory dburl": {config: memory(func(c *pkg.CduleConfig) { c.Dburl = "cdule.db" }), stage: StageConfig, field: "Dburl"},
+		"consistency":  {config: memory(func(c *pkg.CduleConfig) { c.Cduleconsistency = "ONCE" }), stage: StageConfig, field: "Cduleconsistency"},
+		"tick":         {config: memory(func(c *pkg.CduleConfig) { c.TickDuration = "often" }), stage: StageConfig, field: "TickDuration"},
+		"timeout":      {config: memory(func(c *pkg.CduleConfig) { c.JobTimeout = "-1m" }), stage: StageConfig, field: "JobTimeout"},
+		"database":     {config: memory(func(c *pkg.CduleConfig) { c.Cduletype, c.Dburl = string(pkg.SQLITE), "///" }), stage: StageDatabase},
+	} {
+		t.Run(name, func(t *testing.T) {
+			c, err := New(test.config)
+			require.Nil(t, c)
+			var startErr *StartError
+			require.True(t, errors.As(err, &startErr))
+			require.Equal(t, test.stage, startErr.Stage)
+			var configErr *pkg.ConfigError
+			require.Equal(t, test.field != "", errors.As(err, &configErr))
+			if test.field != "" {
+				require.Equal(t, test.field, configErr.Field)
+			}
+		})
+	}
+}
+
+func Test_New(t *testing.T) {
+	c, err := NewWithWorker("new-worker", &pkg.CduleConfig{
+		Cduletype: string(pkg.MEMORY),
+		Loglevel:  logger.Silent,
+	})
+	require.NoError(t, err)
+	defer c.StopWatcher()
+	require.Equal(t, "new-worker", c.WorkerID)
+	worker, err := c.Repository().GetWorker(c.WorkerID)
+	require.NoError(t, err)
+	require.NotNil(t, worker)
+	// the missed schedules are left as they are unless watching the past
+	require.Nil(t, c.PastScheduleWatcher)
+}
+
+func Test_NewWatchPast(t *testing.T) {
+	repo := model.NewMemoryRepository()
+	c, err := NewWithRepository(repo, "past-worker", &pkg.CduleConfig{WatchPast: true})
+	require.NoError(t, err)
+	defer c.StopWatcher()
+	require.NotNil(t, c.PastScheduleWatcher)
+}
+
+func Test_NewWithRepository(t *testing.T) {
+	repo := model.NewMemoryRepository()
+	// the database fields are not needed with a repository
+	c, err := NewWithRepository(repo, "repository-worker", &pkg.CduleConfig{Cduletype: string(pkg.DATABASE)})
+	require.NoError(t, err)
+	defer c.StopWatcher()
+	require.Equal(t, repo, c.Repository())
+
+	job, err := c.NewJob(&watcherTestJob{}, nil).Build(utils.EveryMinute)
+	require.NoError(t, err)
+	schedules, err := repo.GetSchedulesForJob(job.ID)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(schedules))
+	require.Equal(t, "repository-worker", schedules[0].WorkerID)
+}
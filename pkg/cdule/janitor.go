@@ -0,0 +1,133 @@
+package cdule
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gagasdiv/cdule/pkg"
+	"github.com/gagasdiv/cdule/pkg/model"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Janitor purges the job histories past their retention and the soft-deleted rows. All the workers run one,
+// only the alive worker with the smallest worker id purges so that they do not delete the same rows.
+type Janitor struct {
+	cdule  *Cdule
+	Closed chan struct{}
+	WG     sync.WaitGroup
+	Ticker *time.Ticker
+	// Age after which the job histories are purged, not purged by age when 0
+	HistoryRetention time.Duration
+	// Number of the latest job histories kept per job, not purged by count when 0
+	HistoryRetentionCount int
+	// Statuses of the job histories which are purged
+	HistoryRetentionStatuses []model.JobStatus
+	// Age after which the soft-deleted rows are deleted for good, kept forever when 0
+	PurgeDeletedAfter time.Duration
+}
+
+// Run to run janitor in a continuous loop
+func (t *Janitor) Run() {
+	for {
+		select {
+		case <-t.Closed:
+			return
+		case <-t.Ticker.C:
+			if t.isLeader() {
+				t.purge()
+			}
+		}
+	}
+}
+
+// Stop to stop janitor
+func (t *Janitor) Stop() {
+	t.Ticker.Stop()
+	close(t.Closed)
+	t.WG.Wait()
+}
+
+// isLeader whether this worker is the alive worker with the smallest worker id
+func (t *Janitor) isLeader() bool {
+	workers, err := t.cdule.repo.GetAliveWorkers()
+	if nil != err {
+		log.Errorf("Error getting alive workers %s ", err.Error())
+		return false
+	}
+	for _, worker := range workers {
+		if worker.WorkerID < t.cdule.WorkerID {
+			return false
+		}
+	}
+	return true
+}
+
+// purge to delete the job histories past their retention and the rows soft-deleted for longer than PurgeDeletedAfter
+func (t *Janitor) purge() {
+	now := time.Now()
+	policy := model.RetentionPolicy{KeepPerJob: t.HistoryRetentionCount, Statuses: t.HistoryRetentionStatuses}
+	if t.HistoryRetention > 0 {
+		policy.Before = now.Add(-t.HistoryRetention)
+	}
+	if !policy.Before.IsZero() || policy.KeepPerJob > 0 {
+		purged, err := t.cdule.repo.PurgeJobHistory(policy)
+		if nil != err {
+			log.Errorf("Error purging job histories %s ", err.Error())
+		} else if purged > 0 {
+			log.Infof("Purged %d job histories", purged)
+		}
+	}
+	if t.PurgeDeletedAfter > 0 {
+		purged, err := t.cdule.repo.PurgeDeleted(now.Add(-t.PurgeDeletedAfter))
+		if nil != err {
+			log.Errorf("Error purging deleted rows %s ", err.Error())
+		} else if purged > 0 {
+			log.Infof("Purged %d deleted rows", purged)
+		}
+	}
+}
+
+// retentionStatuses to get the job statuses purged by the retention of config, the ones of the finished runs when not set
+func retentionStatuses(config *pkg.CduleConfig) ([]model.JobStatus, error) {
+	if len(config.HistoryRetentionStatuses) == 0 {
+		return model.FinishedJobStatuses, nil
+	}
+	finished := make(map[model.JobStatus]bool, len(model.FinishedJobStatuses))
+	for _, status := range model.FinishedJobStatuses {
+		finished[status] = true
+	}
+	statuses := make([]model.JobStatus, 0, len(config.HistoryRetentionStatuses))
+	for _, status := range config.HistoryRetentionStatuses {
+		if !finished[model.JobStatus(status)] {
+			return nil, &pkg.ConfigError{Field: "HistoryRetentionStatuses", Value: config.HistoryRetentionStatuses,
+				Err: fmt.Errorf("expected statuses of finished runs %v, got %s", model.FinishedJobStatuses, status)}
+		}
+		statuses = append(statuses, model.JobStatus(status))
+	}
+	return statuses, nil
+}
+
+func (cdule *Cdule) createJanitor(config *pkg.CduleConfig, statuses []model.JobStatus) *Janitor {
+	// the durations have been validated by NewCdule
+	interval, _ := time.ParseDuration(config.JanitorInterval)
+	retention, _ := time.ParseDuration(config.HistoryRetention)
+	purgeDeletedAfter, _ := time.ParseDuration(config.PurgeDeletedAfter)
+	janitor := &Janitor{
+		cdule:                    cdule,
+		Closed:                   make(chan struct{}),
+		Ticker:                   time.NewTicker(interval),
+		HistoryRetention:         retention,
+		HistoryRetentionCount:    config.HistoryRetentionCount,
+		HistoryRetentionStatuses: statuses,
+		PurgeDeletedAfter:        purgeDeletedAfter,
+	}
+
+	janitor.WG.Add(1)
+	go func() {
+		defer janitor.WG.Done()
+		janitor.Run()
+	}()
+	return janitor
+}
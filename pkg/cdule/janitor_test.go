@@ -0,0 +1,53 @@
+package cdule
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gagasdiv/cdule/pkg"
+	"github.com/gagasdiv/cdule/pkg/model"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_JanitorIsLeader(t *testing.T) {
+	c := newTestCdule(t, "worker-b")
+	janitor := &Janitor{cdule: c}
+	require.True(t, janitor.isLeader())
+
+	_, err := c.repo.CreateWorker(&model.Worker{WorkerID: "worker-a"})
+	require.NoError(t, err)
+	require.False(t, janitor.isLeader())
+}
+
+func Test_JanitorPurge(t *testing.T) {
+	c := newTestCdule(t, "janitor-test-worker")
+	for i := 0; i < 3; i++ {
+		_, err := c.repo.CreateJobHistory(&model.JobHistory{JobID: 1, ScheduleID: int64(i + 1), Status: model.JobStatusCompleted})
+		require.NoError(t, err)
+	}
+	_, err := c.repo.CreateJobHistory(&model.JobHistory{JobID: 1, ScheduleID: 4, Status: model.JobStatusFailed})
+	require.NoError(t, err)
+
+	(&Janitor{cdule: c, HistoryRetentionCount: 1, HistoryRetentionStatuses: []model.JobStatus{model.JobStatusCompleted}}).purge()
+
+	jobHistories, err := c.repo.GetJobHistoryWithLimit(1, 10)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(jobHistories))
+	require.Equal(t, model.JobStatusFailed, jobHistories[0].Status)
+}
+
+func Test_RetentionStatuses(t *testing.T) {
+	statuses, err := retentionStatuses(&pkg.CduleConfig{})
+	require.NoError(t, err)
+	require.Equal(t, model.FinishedJobStatuses, statuses)
+
+	statuses, err = retentionStatuses(&pkg.CduleConfig{HistoryRetentionStatuses: []string{"FAILED"}})
+	require.NoError(t, err)
+	require.Equal(t, []model.JobStatus{model.JobStatusFailed}, statuses)
+
+	_, err = retentionStatuses(&pkg.CduleConfig{HistoryRetentionStatuses: []string{"IN_PROGRESS"}})
+	var configErr *pkg.ConfigError
+	require.True(t, errors.As(err, &configErr))
+	require.Equal(t, "HistoryRetentionStatuses", configErr.Field)
+}
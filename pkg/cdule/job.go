@@ -1,5 +1,7 @@
 package cdule
 
+import "context"
+
 // Job interface
 type Job interface {
 	Execute(map[string]string)
@@ -9,3 +11,41 @@ type Job interface {
 type JobSub interface {
 	SubName() string
 }
+
+// JobData data given to a run of a job
+type JobData map[string]string
+
+// JobResult result of a run of a JobV2
+type JobResult struct {
+	// Data given to the next run of a repeating job, the data of the current run is kept when nil
+	Data JobData
+	// Output persisted on the job history of the run
+	Output string
+}
+
+// JobV2 interface, a run is FAILED when Execute returns an error and the error message is persisted
+// on the job history. The context is cancelled when the scheduler is stopped.
+type JobV2 interface {
+	Execute(ctx context.Context, data JobData) (JobResult, error)
+	JobName() string
+}
+
+// jobAdapter to run a Job through the JobV2 interface
+type jobAdapter struct {
+	job Job
+}
+
+// AsJobV2 to wrap a Job into a JobV2, a panic in Execute is returned as the error of the run
+func AsJobV2(job Job) JobV2 {
+	return jobAdapter{job: job}
+}
+
+func (a jobAdapter) Execute(ctx context.Context, data JobData) (result JobResult, err error) {
+	defer panicRecovery(&err)
+	a.job.Execute(data)
+	return JobResult{Data: a.job.GetJobData()}, nil
+}
+
+func (a jobAdapter) JobName() string {
+	return a.job.JobName()
+}
@@ -2,8 +2,15 @@ package cdule
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"reflect"
+	"sort"
+	"strings"
+	"sync"
 	"time"
+	// embedded time zone database, for the workers running without one (e.g. in minimal containers)
+	_ "time/tzdata"
 
 	"github.com/gagasdiv/cdule/pkg"
 	"github.com/gagasdiv/cdule/pkg/model"
@@ -12,29 +19,204 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
-// JobRegistry job registry
-var JobRegistry = make(map[string]reflect.Type)
+// jobRegistry the job types by job name, so that a worker can create instances of them to run their schedules
+type jobRegistry struct {
+	mu    sync.RWMutex
+	types map[string]reflect.Type
+}
+
+func newJobRegistry() *jobRegistry {
+	return &jobRegistry{types: make(map[string]reflect.Type)}
+}
+
+func (r *jobRegistry) register(name string, t reflect.Type) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.types[name] = t
+}
+
+func (r *jobRegistry) get(name string) (reflect.Type, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	t, ok := r.types[name]
+	return t, ok
+}
+
+func (r *jobRegistry) names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.types))
+	for name := range r.types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
 
-// ScheduleParser cron parser
-var ScheduleParser cron.Parser
+// merge to add the job types of other, without replacing the ones already registered
+func (r *jobRegistry) merge(other *jobRegistry) {
+	other.mu.RLock()
+	defer other.mu.RUnlock()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for name, t := range other.types {
+		if _, ok := r.types[name]; !ok {
+			r.types[name] = t
+		}
+	}
+}
 
+// ScheduleParser cron parser, for cron expressions with seconds
+var ScheduleParser = cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow)
+
+// ParseCron to parse the cron expression of a job, in the time zone of the job when it has one
+func ParseCron(job *model.Job) (cron.Schedule, error) {
+	expression := job.CronExpression
+	if job.TimeZone != "" {
+		expression = "CRON_TZ=" + job.TimeZone + " " + expression
+	}
+	return ScheduleParser.Parse(expression)
+}
+
+// NextRuns to get the next n fire times of a repeating job after from, none for a job run only once
+func NextRuns(job *model.Job, from time.Time, n int) ([]time.Time, error) {
+	if job.Once || job.CronExpression == pkg.EMPTYSTRING {
+		return nil, nil
+	}
+	schedule, err := ParseCron(job)
+	if nil != err {
+		return nil, err
+	}
+	runs := make([]time.Time, 0, n)
+	for next := schedule.Next(from); len(runs) < n && !next.IsZero(); next = schedule.Next(next) {
+		runs = append(runs, next)
+	}
+	return runs, nil
+}
+
+// splitTimeZone to separate the CRON_TZ= or TZ= prefix of a cron expression from the expression
+func splitTimeZone(cronExpression string) (string, string) {
+	cronExpression = strings.TrimSpace(cronExpression)
+	for _, prefix := range []string{"CRON_TZ=", "TZ="} {
+		if !strings.HasPrefix(cronExpression, prefix) {
+			continue
+		}
+		i := strings.Index(cronExpression, " ")
+		if i == -1 {
+			return cronExpression[len(prefix):], ""
+		}
+		return cronExpression[len(prefix):i], strings.TrimSpace(cronExpression[i:])
+	}
+	return "", cronExpression
+}
+
+// RegisterType to register a Job in the Default cdule, so that a worker can create instances of it to run its schedules
 func RegisterType(job Job) {
-	t := reflect.TypeOf(job).Elem()
-	JobRegistry[job.JobName()] = t
+	Default().RegisterType(job)
+}
+
+// RegisterTypeV2 to register a JobV2 in the Default cdule, so that a worker can create instances of it to run its schedules
+func RegisterTypeV2(job JobV2) {
+	Default().RegisterTypeV2(job)
 }
 
+// RegisterType to register a Job, so that a worker can create instances of it to run its schedules
+func (cdule *Cdule) RegisterType(job Job) {
+	cdule.jobRegistry().register(job.JobName(), reflect.TypeOf(job).Elem())
+}
+
+// RegisterTypeV2 to register a JobV2, so that a worker can create instances of it to run its schedules
+func (cdule *Cdule) RegisterTypeV2(job JobV2) {
+	cdule.jobRegistry().register(job.JobName(), reflect.TypeOf(job).Elem())
+}
+
+// newJobInstance to create a new instance of a registered job type, as a JobV2
+func newJobInstance(t reflect.Type) (JobV2, error) {
+	switch job := reflect.New(t).Interface().(type) {
+	case JobV2:
+		return job, nil
+	case Job:
+		return AsJobV2(job), nil
+	}
+	return nil, fmt.Errorf("type %s implements neither Job nor JobV2", t)
+}
+
+// ErrUnregisteredJob error of a job built by name whose job type is not registered
+var ErrUnregisteredJob = errors.New("unregistered job")
+
+// ErrInvalidJob error of a job which cannot be built, e.g. with an unknown overlap policy
+var ErrInvalidJob = errors.New("invalid job")
+
 // AbstractJob for holding job and jobdata
 type AbstractJob struct {
-	Job     Job
-	JobData map[string]string
-	SubName string
+	Job         Job
+	JobV2       JobV2
+	JobData     map[string]string
+	SubName     string
+	RetryPolicy RetryPolicy
+	Timeout     time.Duration
+	Overlap     model.OverlapPolicy
+	Misfire     model.MisfirePolicy
+	TimeZone    string
+	// lateness up to which a missed schedule is still run, for model.MisfireFireIfWithin
+	MisfireThreshold time.Duration
+
+	// cdule the job is built in, the Default cdule when nil
+	cdule *Cdule
 }
 
-// NewJob to create new abstract job
+// NewJob to create new abstract job in the Default cdule
 func NewJob(job Job, jobData map[string]string, subName ...string) *AbstractJob {
+	return newAbstractJob(nil, job, nil, jobData, subName)
+}
+
+// NewJobV2 to create new abstract job from a JobV2 in the Default cdule
+func NewJobV2(job JobV2, jobData JobData, subName ...string) *AbstractJob {
+	return newAbstractJob(nil, nil, job, jobData, subName)
+}
+
+// NewJobByName to create new abstract job in the Default cdule from a registered job type, see Cdule.NewJobByName
+func NewJobByName(jobName string, jobData JobData, subName ...string) (*AbstractJob, error) {
+	return Default().NewJobByName(jobName, jobData, subName...)
+}
+
+// RegisteredJobNames to get the names of the job types registered in the cdule, sorted
+func (cdule *Cdule) RegisteredJobNames() []string {
+	return cdule.jobRegistry().names()
+}
+
+// NewJob to create new abstract job in the cdule
+func (cdule *Cdule) NewJob(job Job, jobData map[string]string, subName ...string) *AbstractJob {
+	return newAbstractJob(cdule, job, nil, jobData, subName)
+}
+
+// NewJobV2 to create new abstract job from a JobV2 in the cdule
+func (cdule *Cdule) NewJobV2(job JobV2, jobData JobData, subName ...string) *AbstractJob {
+	return newAbstractJob(cdule, nil, job, jobData, subName)
+}
+
+// NewJobByName to create new abstract job in the cdule from the job type registered as jobName, e.g. to build a job
+// requested through an API. ErrUnregisteredJob when no job type is registered as jobName.
+func (cdule *Cdule) NewJobByName(jobName string, jobData JobData, subName ...string) (*AbstractJob, error) {
+	t, ok := cdule.jobRegistry().get(jobName)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnregisteredJob, jobName)
+	}
+	switch job := reflect.New(t).Interface().(type) {
+	case JobV2:
+		return newAbstractJob(cdule, nil, job, jobData, subName), nil
+	case Job:
+		return newAbstractJob(cdule, job, nil, jobData, subName), nil
+	}
+	return nil, fmt.Errorf("type %s implements neither Job nor JobV2", t)
+}
+
+func newAbstractJob(cdule *Cdule, job Job, jobV2 JobV2, jobData map[string]string, subName []string) *AbstractJob {
 	aj := &AbstractJob{
 		Job:     job,
+		JobV2:   jobV2,
 		JobData: jobData,
+		cdule:   cdule,
 	}
 	if len(subName) > 0 {
 		aj.SubName = subName[0]
@@ -42,26 +224,103 @@ func NewJob(job Job, jobData map[string]string, subName ...string) *AbstractJob
 	return aj
 }
 
+// WithRetry to retry the failed runs of the job according to policy, to be called before Build
+func (j *AbstractJob) WithRetry(policy RetryPolicy) *AbstractJob {
+	j.RetryPolicy = policy
+	return j
+}
+
+// WithTimeout to cancel the runs of the job exceeding timeout, to be called before Build
+func (j *AbstractJob) WithTimeout(timeout time.Duration) *AbstractJob {
+	j.Timeout = timeout
+	return j
+}
+
+// WithOverlap to decide what happens to a run of the job while its previous run is in progress on any worker,
+// to be called before Build
+func (j *AbstractJob) WithOverlap(policy model.OverlapPolicy) *AbstractJob {
+	j.Overlap = policy
+	return j
+}
+
+// WithMisfire to decide what happens to the schedules of the job missed at their execution time, to be called before Build.
+// See WithMisfireWithin for model.MisfireFireIfWithin.
+func (j *AbstractJob) WithMisfire(policy model.MisfirePolicy) *AbstractJob {
+	j.Misfire = policy
+	return j
+}
+
+// WithMisfireWithin to run the missed schedules of the job only if they are late by at most threshold, to be called before Build
+func (j *AbstractJob) WithMisfireWithin(threshold time.Duration) *AbstractJob {
+	j.Misfire = model.MisfireFireIfWithin
+	j.MisfireThreshold = threshold
+	return j
+}
+
+// WithTimeZone to evaluate the cron expression of the job in the IANA time zone, e.g. "Europe/Berlin",
+// to be called before Build. A CRON_TZ= or TZ= prefix of the cron expression does the same.
+func (j *AbstractJob) WithTimeZone(timeZone string) *AbstractJob {
+	j.TimeZone = timeZone
+	return j
+}
+
+func (j *AbstractJob) jobName() string {
+	if nil != j.JobV2 {
+		return j.JobV2.JobName()
+	}
+	return j.Job.JobName()
+}
+
+func (j *AbstractJob) getCdule() *Cdule {
+	if nil != j.cdule {
+		return j.cdule
+	}
+	return Default()
+}
+
+func (j *AbstractJob) registerType(cdule *Cdule) {
+	if nil != j.JobV2 {
+		cdule.RegisterTypeV2(j.JobV2)
+		return
+	}
+	cdule.RegisterType(j.Job)
+}
+
 // Build to build job and store in the database
 func (j *AbstractJob) Build(cronExpression string) (*model.Job, error) {
 	jobDataBytes, err := json.Marshal(j.JobData)
 	/*if nil != err {
-		log.Errorf("Error %s for JobName %s", err.Error(), j.Job.JobName())
+		log.Errorf("Error %s for JobName %s", err.Error(), j.jobName())
 		return nil, fmt.Errorf("invalid Job Data %v", j.JobData)
 	}*/
 	var jobDataStr = ""
 	if string(jobDataBytes) != pkg.EMPTYSTRING {
 		jobDataStr = string(jobDataBytes)
 	}
+	timeZone, cronExpression := splitTimeZone(cronExpression)
+	if timeZone == "" {
+		timeZone = j.TimeZone
+	} else if j.TimeZone != "" && j.TimeZone != timeZone {
+		return nil, fmt.Errorf("time zone %s of the cron expression differs from the time zone %s of the job", timeZone, j.TimeZone)
+	}
 	newJob := &model.Job{
-		JobName:        j.Job.JobName(),
-		SubName:        j.SubName,
-		CronExpression: cronExpression,
-		Expired:        false,
-		JobData:        jobDataStr,
-		Once:           false,
-	}
-	SchedulerParser, err := cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow).Parse(newJob.CronExpression)
+		JobName:          j.jobName(),
+		SubName:          j.SubName,
+		CronExpression:   cronExpression,
+		TimeZone:         timeZone,
+		Expired:          false,
+		JobData:          jobDataStr,
+		Once:             false,
+		RetryPolicy:      j.RetryPolicy,
+		Timeout:          j.Timeout,
+		OverlapPolicy:    j.Overlap,
+		MisfirePolicy:    j.Misfire,
+		MisfireThreshold: j.MisfireThreshold,
+	}
+	if err = validateJob(newJob); nil != err {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidJob, err.Error())
+	}
+	SchedulerParser, err := ParseCron(newJob)
 	if err != nil {
 		log.Error(err.Error())
 		return nil, err
@@ -69,7 +328,6 @@ func (j *AbstractJob) Build(cronExpression string) (*model.Job, error) {
 	nextRunTime := SchedulerParser.Next(time.Now()).UnixNano()
 	firstSchedule := &model.Schedule{
 		ExecutionID: nextRunTime,
-		WorkerID:    WorkerID,
 		JobData:     newJob.JobData,
 	}
 	job, _, err := j.buildFirstSchedule(newJob, firstSchedule)
@@ -80,7 +338,7 @@ func (j *AbstractJob) Build(cronExpression string) (*model.Job, error) {
 func (j *AbstractJob) BuildToRunAt(t time.Time) (*model.Job, error) {
 	jobDataBytes, err := json.Marshal(j.JobData)
 	/*if nil != err {
-		log.Errorf("Error %s for JobName %s", err.Error(), j.Job.JobName())
+		log.Errorf("Error %s for JobName %s", err.Error(), j.jobName())
 		return nil, fmt.Errorf("invalid Job Data %v", j.JobData)
 	}*/
 	var jobDataStr = ""
@@ -88,23 +346,64 @@ func (j *AbstractJob) BuildToRunAt(t time.Time) (*model.Job, error) {
 		jobDataStr = string(jobDataBytes)
 	}
 	newJob := &model.Job{
-		JobName:        j.Job.JobName(),
-		SubName:        j.SubName,
-		CronExpression: "",
-		Expired:        false,
-		JobData:        jobDataStr,
-		Once:           true,
+		JobName:          j.jobName(),
+		SubName:          j.SubName,
+		CronExpression:   "",
+		TimeZone:         j.TimeZone,
+		Expired:          false,
+		JobData:          jobDataStr,
+		Once:             true,
+		RetryPolicy:      j.RetryPolicy,
+		Timeout:          j.Timeout,
+		OverlapPolicy:    j.Overlap,
+		MisfirePolicy:    j.Misfire,
+		MisfireThreshold: j.MisfireThreshold,
+	}
+	if err = validateJob(newJob); nil != err {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidJob, err.Error())
 	}
 	nextRunTime := t.UnixNano()
 	firstSchedule := &model.Schedule{
 		ExecutionID: nextRunTime,
-		WorkerID:    WorkerID,
 		JobData:     newJob.JobData,
 	}
 	job, _, err := j.buildFirstSchedule(newJob, firstSchedule)
 	return job, err
 }
 
+// validateJob to check the policies and durations of a job, those of the builder and of JobUpdate
+func validateJob(job *model.Job) error {
+	switch job.OverlapPolicy {
+	case pkg.EMPTYSTRING, model.OverlapAllow, model.OverlapSkip, model.OverlapQueue, model.OverlapReplace:
+	default:
+		return fmt.Errorf("unknown overlap policy %q", job.OverlapPolicy)
+	}
+	switch job.MisfirePolicy {
+	case pkg.EMPTYSTRING, model.MisfireFireNow, model.MisfireFireAll, model.MisfireSkip, model.MisfireFireIfWithin:
+	default:
+		return fmt.Errorf("unknown misfire policy %q", job.MisfirePolicy)
+	}
+	if job.MisfireThreshold < 0 {
+		return fmt.Errorf("negative misfire threshold %s", job.MisfireThreshold)
+	}
+	if job.Timeout < 0 {
+		return fmt.Errorf("negative timeout %s", job.Timeout)
+	}
+	retry := job.RetryPolicy
+	switch retry.Backoff {
+	case pkg.EMPTYSTRING, model.BackoffFixed, model.BackoffExponential:
+	default:
+		return fmt.Errorf("unknown retry backoff %q", retry.Backoff)
+	}
+	if retry.MaxAttempts < 0 || retry.Interval < 0 || retry.MaxInterval < 0 {
+		return fmt.Errorf("negative retry policy %+v", retry)
+	}
+	if retry.Jitter < 0 || retry.Jitter > 1 {
+		return fmt.Errorf("retry jitter %v out of 0 to 1", retry.Jitter)
+	}
+	return nil
+}
+
 // BuildToRunIn to build job to run only once and store in the database
 func (j *AbstractJob) BuildToRunIn(n time.Duration) (*model.Job, error) {
 	return j.BuildToRunAt(time.Now().Add(n))
@@ -117,21 +416,44 @@ func (j *AbstractJob) BuildToRunNow() (*model.Job, error) {
 
 // Build to build job and store in the database
 func (j *AbstractJob) buildFirstSchedule(job *model.Job, schedule *model.Schedule) (*model.Job, *model.Schedule, error) {
+	cdule := j.getCdule()
+	if nil == cdule.repo {
+		return nil, nil, ErrNotStarted
+	}
 	// register job, this is used later to get the type of a job
-	RegisterType(j.Job)
-
-	existingJob, err := model.CduleRepos.CduleRepository.GetRepeatingJobByName(j.Job.JobName())
-	if err != nil {
+	j.registerType(cdule)
+	workerID, err := cdule.scheduleWorkerID()
+	if nil != err {
 		log.Error(err.Error())
 		return nil, nil, err
 	}
-	if nil != existingJob && !job.Once {
-		log.Debugf("Found a non-once Job with the same Name: %s", existingJob.JobName)
-		CancelJob(existingJob.JobName, existingJob.SubName)
+
+	if !job.Once {
+		// built again, e.g. at every start of a config-driven deployment: updated in place to keep its job histories
+		existingJob, err := cdule.repeatingJob(job.JobName, job.SubName)
+		if nil != err {
+			log.Error(err.Error())
+			return nil, nil, err
+		}
+		if nil != existingJob {
+			log.Debugf("Updating the Job with the same Name: %s and SubName: %s", existingJob.JobName, existingJob.SubName)
+			schedule.WorkerID = workerID
+			return cdule.updateJob(existingJob.ID, func(existing *model.Job) error {
+				existing.CronExpression = job.CronExpression
+				existing.TimeZone = job.TimeZone
+				existing.JobData = job.JobData
+				existing.RetryPolicy = job.RetryPolicy
+				existing.Timeout = job.Timeout
+				existing.OverlapPolicy = job.OverlapPolicy
+				existing.MisfirePolicy = job.MisfirePolicy
+				existing.MisfireThreshold = job.MisfireThreshold
+				return nil
+			}, schedule)
+		}
 	}
 
 	log.Debugf("Making new Job with Name: %s", job.JobName)
-	job, err = model.CduleRepos.CduleRepository.CreateJob(job)
+	job, err = cdule.repo.CreateJob(job)
 	if err != nil {
 		log.Error(err.Error())
 		return nil, nil, err
@@ -139,23 +461,217 @@ func (j *AbstractJob) buildFirstSchedule(job *model.Job, schedule *model.Schedul
 
 	// Make first schedule
 	schedule.JobID = job.ID
-	_, err = model.CduleRepos.CduleRepository.CreateSchedule(schedule)
+	schedule.WorkerID = workerID
+	_, err = cdule.repo.CreateSchedule(schedule)
 	if err != nil {
 		log.Error(err.Error())
 		return job, nil, err
 	}
 	log.Debugf("*** Job Scheduled Info ***\n JobName: %s,\n Schedule Cron: %s,\n Job Scheduled Time: %d,\n Worker: %s ",
 		job.JobName, job.CronExpression, schedule.ExecutionID, schedule.WorkerID)
+	cdule.notifyScheduled(job, schedule)
 	return job, schedule, err
 }
 
+// CancelJob to delete schedules for a job in the database of the Default cdule by jobName and subName
+func CancelJob(jobName string, subName string) error {
+	return Default().CancelJob(jobName, subName)
+}
+
 // CancelJob to delete schedules for a job in the database by jobName and subName
-func CancelJob(jobName string, subName string) (error) {
-	schedules, err := model.CduleRepos.CduleRepository.DeleteScheduleForJobName(jobName, subName)
+func (cdule *Cdule) CancelJob(jobName string, subName string) error {
+	if nil == cdule.repo {
+		return ErrNotStarted
+	}
+	schedules, err := cdule.repo.DeleteScheduleForJobName(jobName, subName)
 	if err == nil {
 		log.Debugf("Cancelled schedule(s) based on jobName: %#v and subName: %#v ; %d schedule(s) ", jobName, subName, len(schedules))
+		cancelled := make(map[int64]bool)
+		for _, schedule := range schedules {
+			if cancelled[schedule.JobID] {
+				continue
+			}
+			cancelled[schedule.JobID] = true
+			job := &model.Job{Model: model.Model{ID: schedule.JobID}, JobName: jobName, SubName: subName}
+			event := cdule.jobEvent(job, nil, nil, nil)
+			cdule.notify(func(listener Listener) { listener.OnJobCancelled(event) })
+		}
 	} else {
 		log.Warnf("Failed cancelling schedule(s) based on jobName: %#v and subName: %#v ; err: %s ", jobName, subName, err.Error())
 	}
 	return err
 }
+
+// PauseJob to pause a job of the Default cdule, see Cdule.PauseJob
+func PauseJob(jobID int64) (*model.Job, error) {
+	return Default().PauseJob(jobID)
+}
+
+// ResumeJob to resume a job of the Default cdule, see Cdule.ResumeJob
+func ResumeJob(jobID int64) (*model.Job, error) {
+	return Default().ResumeJob(jobID)
+}
+
+// PauseAll to pause all the jobs of the Default cdule, see Cdule.PauseAll
+func PauseAll() ([]model.Job, error) {
+	return Default().PauseAll()
+}
+
+// ResumeAll to resume all the paused jobs of the Default cdule, see Cdule.ResumeAll
+func ResumeAll() ([]model.Job, error) {
+	return Default().ResumeAll()
+}
+
+// TriggerJob to run a job of the Default cdule now, see Cdule.TriggerJob
+func TriggerJob(jobID int64) (*model.Schedule, error) {
+	return Default().TriggerJob(jobID)
+}
+
+// PauseJob to hold the schedules of a job until it is resumed, a run in progress is not stopped.
+// model.ErrNotFound when there is no such job.
+func (cdule *Cdule) PauseJob(jobID int64) (*model.Job, error) {
+	return cdule.setJobPaused(jobID, true)
+}
+
+// ResumeJob to run the schedules of a paused job again. The misfire policy of the job applies right away to the
+// schedules missed while it was paused, whether the past schedules are watched or not: those to run are moved to now,
+// the runs triggered meanwhile included, and those skipped are recorded as SKIPPED with the next run scheduled from
+// now. The missed occurrences of a MisfireFireAll job are caught up by the past schedule watcher when it runs, and
+// run once otherwise. model.ErrNotFound when there is no such job.
+func (cdule *Cdule) ResumeJob(jobID int64) (*model.Job, error) {
+	return cdule.setJobPaused(jobID, false)
+}
+
+// PauseAll to pause the jobs which are neither expired nor paused, e.g. during a maintenance window, see PauseJob.
+// The jobs built afterwards are not paused. Returns the jobs paused.
+func (cdule *Cdule) PauseAll() ([]model.Job, error) {
+	return cdule.setAllPaused(true)
+}
+
+// ResumeAll to resume the paused jobs which are not expired, see ResumeJob. Returns the jobs resumed.
+func (cdule *Cdule) ResumeAll() ([]model.Job, error) {
+	return cdule.setAllPaused(false)
+}
+
+func (cdule *Cdule) setAllPaused(paused bool) ([]model.Job, error) {
+	if nil == cdule.repo {
+		return nil, ErrNotStarted
+	}
+	jobs, err := cdule.repo.GetJobs()
+	if nil != err {
+		return nil, err
+	}
+	changed := make([]model.Job, 0, len(jobs))
+	for _, job := range jobs {
+		if job.Expired || job.Paused == paused {
+			continue
+		}
+		updated, err := cdule.setJobPaused(job.ID, paused)
+		if errors.Is(err, model.ErrNotFound) {
+			// deleted in the meantime
+			continue
+		}
+		if nil != err {
+			return changed, err
+		}
+		changed = append(changed, *updated)
+	}
+	return changed, nil
+}
+
+func (cdule *Cdule) setJobPaused(jobID int64, paused bool) (*model.Job, error) {
+	if nil == cdule.repo {
+		return nil, ErrNotStarted
+	}
+	var job *model.Job
+	var misfires []*misfireOutcome
+	err := cdule.repo.Transaction(func(repo model.CduleRepository) error {
+		var err error
+		job, err = repo.LockJob(jobID)
+		if nil != err {
+			return err
+		}
+		if nil == job {
+			return model.ErrNotFound
+		}
+		if job.Paused == paused {
+			return nil
+		}
+		job.Paused = paused
+		if _, err = repo.SaveJob(job); nil != err || paused {
+			return err
+		}
+		misfires, err = cdule.resumeMissedSchedules(repo, job)
+		return err
+	})
+	if nil != err {
+		return nil, err
+	}
+	for _, outcome := range misfires {
+		cdule.notifyMisfire(outcome)
+	}
+	log.Infof("JobName %s JobID %d paused: %t", job.JobName, job.ID, paused)
+	return job, nil
+}
+
+// resumeMissedSchedules to apply the misfire policy of a resumed job to its schedules held while it was paused, those
+// to run are moved to now and the next occurrence of the job is scheduled from now
+func (cdule *Cdule) resumeMissedSchedules(repo model.CduleRepository, job *model.Job) ([]*misfireOutcome, error) {
+	schedules, err := repo.GetPendingSchedules(job.ID, 0)
+	if nil != err {
+		return nil, err
+	}
+	now := time.Now()
+	var workers []model.Worker
+	misfires := make([]*misfireOutcome, 0)
+	for _, schedule := range schedules {
+		if schedule.ExecutionID >= now.UnixNano() || schedule.ClaimedBy != pkg.EMPTYSTRING {
+			continue
+		}
+		if nil == workers {
+			if workers, err = repo.GetAliveWorkers(); nil != err {
+				return nil, err
+			}
+		}
+		outcome, err := applyMisfire(repo, job, schedule, workers, cdule.WorkerID, now, nil != cdule.PastScheduleWatcher)
+		if nil != err {
+			return nil, err
+		}
+		if nil != outcome {
+			misfires = append(misfires, outcome)
+		}
+	}
+	return misfires, nil
+}
+
+// TriggerJob to run a job now with the job data it was built with, in addition to its schedules which are unchanged.
+// The run is held like the others while the job is paused. model.ErrNotFound when there is no such job.
+func (cdule *Cdule) TriggerJob(jobID int64) (*model.Schedule, error) {
+	if nil == cdule.repo {
+		return nil, ErrNotStarted
+	}
+	job, err := cdule.repo.GetJob(jobID)
+	if nil != err {
+		return nil, err
+	}
+	if nil == job {
+		return nil, model.ErrNotFound
+	}
+	workerID, err := cdule.scheduleWorkerID()
+	if nil != err {
+		return nil, err
+	}
+	schedule := &model.Schedule{
+		ExecutionID: time.Now().UnixNano(),
+		JobID:       job.ID,
+		WorkerID:    workerID,
+		JobData:     job.JobData,
+		Triggered:   true,
+	}
+	if _, err = cdule.repo.CreateSchedule(schedule); nil != err {
+		return nil, err
+	}
+	log.Debugf("Triggered JobName %s JobID %d, Job Scheduled Time: %d", job.JobName, job.ID, schedule.ExecutionID)
+	cdule.notifyScheduled(job, schedule)
+	return schedule, nil
+}
@@ -1,7 +1,289 @@
 package cdule
 
-import "testing"
+import (
+	"testing"
+	"time"
+
+	"github.com/gagasdiv/cdule/pkg"
+	"github.com/gagasdiv/cdule/pkg/model"
+	"github.com/gagasdiv/cdule/pkg/utils"
+
+	"github.com/stretchr/testify/require"
+)
 
 func Test_JobRegistry(t *testing.T) {
 
 }
+
+func Test_ParseCronTimeZone(t *testing.T) {
+	job := &model.Job{CronExpression: "0 0 9 * * *", TimeZone: "Europe/Berlin"}
+	schedule, err := ParseCron(job)
+	require.NoError(t, err)
+
+	// 9am in Berlin is 8am UTC in winter and 7am UTC in summer, the clocks change on 29 March 2026
+	next := schedule.Next(time.Date(2026, 3, 27, 12, 0, 0, 0, time.UTC))
+	require.Equal(t, time.Date(2026, 3, 28, 8, 0, 0, 0, time.UTC), next.UTC())
+	next = schedule.Next(next)
+	require.Equal(t, time.Date(2026, 3, 29, 7, 0, 0, 0, time.UTC), next.UTC())
+
+	job.TimeZone = "Mars/Olympus_Mons"
+	_, err = ParseCron(job)
+	require.Error(t, err)
+}
+
+func Test_SplitTimeZone(t *testing.T) {
+	timeZone, expression := splitTimeZone("CRON_TZ=Asia/Tokyo 0 30 8 * * *")
+	require.Equal(t, "Asia/Tokyo", timeZone)
+	require.Equal(t, "0 30 8 * * *", expression)
+	timeZone, expression = splitTimeZone("TZ=UTC 0 * * ? * *")
+	require.Equal(t, "UTC", timeZone)
+	require.Equal(t, "0 * * ? * *", expression)
+	timeZone, expression = splitTimeZone(" 0 * * ? * *")
+	require.Equal(t, "", timeZone)
+	require.Equal(t, "0 * * ? * *", expression)
+}
+
+func Test_BuildWithTimeZone(t *testing.T) {
+	c, _, _ := setupWatcherTest(t)
+
+	job, err := c.NewJob(&watcherTestJob{}, nil).Build("CRON_TZ=America/New_York 0 0 9 * * *")
+	require.NoError(t, err)
+	require.Equal(t, "America/New_York", job.TimeZone)
+	require.Equal(t, "0 0 9 * * *", job.CronExpression)
+	schedules, err := c.repo.GetSchedulesForJob(job.ID)
+	require.NoError(t, err)
+	location, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+	next := time.Unix(0, schedules[0].ExecutionID).In(location)
+	require.Equal(t, 9, next.Hour())
+	require.Equal(t, 0, next.Minute())
+
+	job, err = c.NewJob(&watcherTestJob{}, nil).WithTimeZone("Europe/Berlin").Build("0 0 9 * * *")
+	require.NoError(t, err)
+	require.Equal(t, "Europe/Berlin", job.TimeZone)
+
+	_, err = c.NewJob(&watcherTestJob{}, nil).WithTimeZone("Europe/Berlin").Build("TZ=Asia/Tokyo 0 0 9 * * *")
+	require.Error(t, err)
+}
+
+func Test_BuildInvalidJob(t *testing.T) {
+	c := newTestCdule(t, "builder-test-worker")
+	for name, configure := range map[string]func(*AbstractJob) *AbstractJob{
+		"overlap":   func(j *AbstractJob) *AbstractJob { return j.WithOverlap("SOMETIMES") },
+		"misfire":   func(j *AbstractJob) *AbstractJob { return j.WithMisfire("LATER") },
+		"threshold": func(j *AbstractJob) *AbstractJob { return j.WithMisfireWithin(-time.Minute) },
+		"timeout":   func(j *AbstractJob) *AbstractJob { return j.WithTimeout(-time.Second) },
+		"backoff":   func(j *AbstractJob) *AbstractJob { return j.WithRetry(RetryPolicy{Backoff: "LINEAR"}) },
+		"jitter":    func(j *AbstractJob) *AbstractJob { return j.WithRetry(RetryPolicy{Jitter: 2}) },
+		"interval":  func(j *AbstractJob) *AbstractJob { return j.WithRetry(RetryPolicy{Interval: -time.Second}) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := configure(c.NewJob(&watcherTestJob{}, nil)).Build(utils.EveryMinute)
+			require.ErrorIs(t, err, ErrInvalidJob)
+			_, err = configure(c.NewJob(&watcherTestJob{}, nil)).BuildToRunNow()
+			require.ErrorIs(t, err, ErrInvalidJob)
+		})
+	}
+	jobs, err := c.repo.GetJobs()
+	require.NoError(t, err)
+	require.Empty(t, jobs)
+}
+
+func Test_NewJobByName(t *testing.T) {
+	c := newTestCdule(t, "job-builder-test-worker")
+	_, err := c.NewJobByName("job.WatcherTestJob", nil)
+	require.ErrorIs(t, err, ErrUnregisteredJob)
+
+	c.RegisterType(&watcherTestJob{})
+	c.RegisterTypeV2(&failingTestJob{})
+	require.Equal(t, []string{"job.FailingTestJob", "job.WatcherTestJob"}, c.RegisteredJobNames())
+	for _, name := range []string{"job.WatcherTestJob", "job.FailingTestJob"} {
+		job, err := c.NewJobByName(name, JobData{"key": "value"}, "sub")
+		require.NoError(t, err)
+		built, err := job.BuildToRunNow()
+		require.NoError(t, err)
+		require.Equal(t, name, built.JobName)
+		require.Equal(t, "sub", built.SubName)
+		require.Equal(t, `{"key":"value"}`, built.JobData)
+	}
+}
+
+func Test_NextRuns(t *testing.T) {
+	from := time.Date(2030, 1, 1, 10, 30, 0, 0, time.UTC)
+	runs, err := NextRuns(&model.Job{CronExpression: "0 0 12 * * *", TimeZone: "Asia/Kolkata"}, from, 3)
+	require.NoError(t, err)
+	require.Len(t, runs, 3)
+	for i, run := range runs {
+		require.True(t, run.Equal(time.Date(2030, 1, 2+i, 6, 30, 0, 0, time.UTC)), run.String())
+	}
+
+	runs, err = NextRuns(&model.Job{CronExpression: "0 0 12 * * *", Once: true}, from, 3)
+	require.NoError(t, err)
+	require.Empty(t, runs)
+	_, err = NextRuns(&model.Job{CronExpression: "not a cron"}, from, 3)
+	require.Error(t, err)
+}
+
+func Test_PauseJob(t *testing.T) {
+	c, job, schedule := setupWatcherTest(t)
+	_, err := c.PauseJob(job.ID + 1)
+	require.ErrorIs(t, err, model.ErrNotFound)
+	paused, err := c.PauseJob(job.ID)
+	require.NoError(t, err)
+	require.True(t, paused.Paused)
+
+	// the schedule is held as it is
+	runTestSchedules(newTestWatcher(c, pkg.AT_LEAST_ONCE), []model.Schedule{*schedule})
+	require.Equal(t, 0, watcherTestJobRuns)
+	held, err := c.repo.GetScheduleByID(schedule.ID)
+	require.NoError(t, err)
+	require.Equal(t, schedule.ExecutionID, held.ExecutionID)
+	require.Equal(t, "", held.ClaimedBy)
+	jobHistory, err := c.repo.GetJobHistoryForSchedule(schedule.ID)
+	require.NoError(t, err)
+	require.Nil(t, jobHistory)
+
+	resumed, err := c.ResumeJob(job.ID)
+	require.NoError(t, err)
+	require.False(t, resumed.Paused)
+	runTestSchedules(newTestWatcher(c, pkg.AT_LEAST_ONCE), []model.Schedule{*held})
+	require.Equal(t, 1, watcherTestJobRuns)
+}
+
+func Test_PauseJobMisfire(t *testing.T) {
+	c, job, schedule := setupMisfireTest(t, misfire(model.MisfireSkip), false)
+	_, err := c.PauseJob(job.ID)
+	require.NoError(t, err)
+
+	runTestPassedSchedules(newTestPastWatcher(c))
+	jobHistory, err := c.repo.GetJobHistoryForSchedule(schedule.ID)
+	require.NoError(t, err)
+	require.Nil(t, jobHistory)
+
+	// the misfire policy of the job applies on resume to the schedule missed while it was paused
+	_, err = c.ResumeJob(job.ID)
+	require.NoError(t, err)
+	jobHistory, err = c.repo.GetJobHistoryForSchedule(schedule.ID)
+	require.NoError(t, err)
+	require.Equal(t, model.JobStatusSkipped, jobHistory.Status)
+}
+
+func Test_ResumeJobMisfire(t *testing.T) {
+	for name, test := range map[string]struct {
+		job       func(*AbstractJob) *AbstractJob
+		triggered bool
+		expected  model.JobStatus
+	}{
+		"default":    {job: misfire(""), expected: model.JobStatusCompleted},
+		"skip":       {job: misfire(model.MisfireSkip), expected: model.JobStatusSkipped},
+		"within":     {job: misfireWithin(time.Hour), expected: model.JobStatusCompleted},
+		"not within": {job: misfireWithin(time.Minute), expected: model.JobStatusSkipped},
+		"triggered":  {job: misfire(model.MisfireSkip), triggered: true, expected: model.JobStatusCompleted},
+	} {
+		t.Run(name, func(t *testing.T) {
+			// no past schedule watcher runs, as when the past schedules are not watched
+			c, job, schedule := setupMisfireTest(t, test.job, false)
+			_, err := c.PauseJob(job.ID)
+			require.NoError(t, err)
+			if test.triggered {
+				triggered, err := c.TriggerJob(job.ID)
+				require.NoError(t, err)
+				triggered.ExecutionID = schedule.ExecutionID
+				_, err = c.repo.UpdateSchedule(triggered)
+				require.NoError(t, err)
+				// the triggered run is checked, the missed schedule of the job is skipped
+				schedule = *triggered
+			}
+
+			start := time.Now().UnixNano()
+			_, err = c.ResumeJob(job.ID)
+			require.NoError(t, err)
+			runTestNextSchedules(newTestWatcher(c, pkg.AT_LEAST_ONCE), start, time.Now().UnixNano())
+			jobHistory, err := c.repo.GetJobHistoryForSchedule(schedule.ID)
+			require.NoError(t, err)
+			require.NotNil(t, jobHistory)
+			require.Equal(t, test.expected, jobHistory.Status)
+
+			// the next occurrence of the job is scheduled from now
+			pending, err := c.repo.GetPendingSchedules(job.ID, 0)
+			require.NoError(t, err)
+			require.Equal(t, 1, len(pending))
+			require.Greater(t, pending[0].ExecutionID, time.Now().UnixNano())
+		})
+	}
+}
+
+func Test_PauseAll(t *testing.T) {
+	c, job, schedule := setupMisfireTest(t, misfire(model.MisfireFireNow), false)
+	expired, err := c.repo.CreateJob(&model.Job{JobName: "job.FailingTestJob", CronExpression: utils.EveryMinute, Expired: true})
+	require.NoError(t, err)
+	paused, err := c.PauseAll()
+	require.NoError(t, err)
+	pausedIDs := make([]int64, 0, len(paused))
+	for _, pausedJob := range paused {
+		require.True(t, pausedJob.Paused)
+		pausedIDs = append(pausedIDs, pausedJob.ID)
+	}
+	require.Contains(t, pausedIDs, job.ID)
+	require.NotContains(t, pausedIDs, expired.ID)
+	again, err := c.PauseAll()
+	require.NoError(t, err)
+	require.Empty(t, again)
+
+	runTestPassedSchedules(newTestPastWatcher(c))
+	require.Equal(t, 0, watcherTestJobRuns)
+
+	// on resume the missed schedule is moved to now to run once, and the next one is calculated from now
+	start := time.Now().UnixNano()
+	resumed, err := c.ResumeAll()
+	require.NoError(t, err)
+	require.Len(t, resumed, len(paused))
+	runTestNextSchedules(newTestWatcher(c, pkg.AT_LEAST_ONCE), start, time.Now().UnixNano())
+	require.Equal(t, 1, watcherTestJobRuns)
+	jobHistory, err := c.repo.GetJobHistoryForSchedule(schedule.ID)
+	require.NoError(t, err)
+	require.Equal(t, model.JobStatusCompleted, jobHistory.Status)
+	pending, err := c.repo.GetPendingSchedules(job.ID, 0)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(pending))
+	require.Greater(t, pending[0].ExecutionID, time.Now().UnixNano())
+}
+
+func Test_TriggerJob(t *testing.T) {
+	c, job, schedule := setupWatcherTest(t)
+	_, err := c.TriggerJob(job.ID + 1)
+	require.ErrorIs(t, err, model.ErrNotFound)
+
+	triggered, err := c.TriggerJob(job.ID)
+	require.NoError(t, err)
+	require.True(t, triggered.Triggered)
+	require.Equal(t, c.WorkerID, triggered.WorkerID)
+	runTestSchedules(newTestWatcher(c, pkg.AT_LEAST_ONCE), []model.Schedule{*triggered})
+	require.Equal(t, 1, watcherTestJobRuns)
+
+	// the next schedule of the job is still the one it had
+	pending, err := c.repo.GetPendingSchedules(job.ID, 0)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(pending))
+	require.Equal(t, schedule.ID, pending[0].ID)
+}
+
+func Test_NewClient(t *testing.T) {
+	repo := model.NewMemoryRepository()
+	job, err := repo.CreateJob(&model.Job{JobName: "job.WatcherTestJob", CronExpression: "0 * * * * *"})
+	require.NoError(t, err)
+	client := NewClient(repo)
+	_, err = client.TriggerJob(job.ID)
+	require.ErrorIs(t, err, ErrNoAliveWorker)
+
+	for _, workerID := range []string{"client-test-worker-b", "client-test-worker-a"} {
+		_, err = repo.CreateWorker(&model.Worker{WorkerID: workerID})
+		require.NoError(t, err)
+	}
+	triggered, err := client.TriggerJob(job.ID)
+	require.NoError(t, err)
+	require.Equal(t, "client-test-worker-a", triggered.WorkerID)
+	workers, err := repo.GetWorkers()
+	require.NoError(t, err)
+	require.Len(t, workers, 2)
+}
@@ -0,0 +1,290 @@
+package cdule
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/gagasdiv/cdule/pkg"
+	"github.com/gagasdiv/cdule/pkg/model"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrInvalidJobUpdate error of a job update which cannot be applied, e.g. an invalid cron expression
+var ErrInvalidJobUpdate = errors.New("invalid job update")
+
+// JobUpdate changes of a job made by UpdateJob, the nil fields are left unchanged
+type JobUpdate struct {
+	// Cron expression of a repeating job, it may start with a CRON_TZ= or TZ= prefix
+	CronExpression *string
+	TimeZone       *string
+	// Data of the next runs, an empty JobData clears it
+	JobData          JobData
+	RetryPolicy      *RetryPolicy
+	Timeout          *time.Duration
+	OverlapPolicy    *model.OverlapPolicy
+	MisfirePolicy    *model.MisfirePolicy
+	MisfireThreshold *time.Duration
+}
+
+// apply to make the changes of the update to job
+func (update JobUpdate) apply(job *model.Job) error {
+	if nil != update.CronExpression {
+		if job.Once {
+			return fmt.Errorf("%w: job %d runs only once, it has no cron expression", ErrInvalidJobUpdate, job.ID)
+		}
+		timeZone, cronExpression := splitTimeZone(*update.CronExpression)
+		if timeZone != "" && nil != update.TimeZone && *update.TimeZone != timeZone {
+			return fmt.Errorf("%w: time zone %s of the cron expression differs from the time zone %s",
+				ErrInvalidJobUpdate, timeZone, *update.TimeZone)
+		}
+		job.CronExpression = cronExpression
+		if timeZone != "" {
+			job.TimeZone = timeZone
+		}
+	}
+	if nil != update.TimeZone {
+		job.TimeZone = *update.TimeZone
+	}
+	if nil != update.JobData {
+		jobData, err := json.Marshal(update.JobData)
+		if nil != err {
+			return fmt.Errorf("%w: %s", ErrInvalidJobUpdate, err.Error())
+		}
+		job.JobData = string(jobData)
+	}
+	if nil != update.RetryPolicy {
+		job.RetryPolicy = *update.RetryPolicy
+	}
+	if nil != update.Timeout {
+		job.Timeout = *update.Timeout
+	}
+	if nil != update.OverlapPolicy {
+		job.OverlapPolicy = *update.OverlapPolicy
+	}
+	if nil != update.MisfirePolicy {
+		job.MisfirePolicy = *update.MisfirePolicy
+	}
+	if nil != update.MisfireThreshold {
+		job.MisfireThreshold = *update.MisfireThreshold
+	}
+	if err := validateJob(job); nil != err {
+		return fmt.Errorf("%w: %s", ErrInvalidJobUpdate, err.Error())
+	}
+	return nil
+}
+
+// jobFieldChange the former and the new value of a changed field of a job
+type jobFieldChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// jobChanges to get the fields UpdateJob may change which differ between before and after, by their JSON name
+func jobChanges(before, after *model.Job) map[string]jobFieldChange {
+	changes := make(map[string]jobFieldChange)
+	for _, field := range []struct {
+		name     string
+		from, to interface{}
+	}{
+		{"cron", before.CronExpression, after.CronExpression},
+		{"time_zone", before.TimeZone, after.TimeZone},
+		{"job_data", before.JobData, after.JobData},
+		{"retry_policy", before.RetryPolicy, after.RetryPolicy},
+		{"timeout", before.Timeout, after.Timeout},
+		{"overlap_policy", before.OverlapPolicy, after.OverlapPolicy},
+		{"misfire_policy", before.MisfirePolicy, after.MisfirePolicy},
+		{"misfire_threshold", before.MisfireThreshold, after.MisfireThreshold},
+	} {
+		if !reflect.DeepEqual(field.from, field.to) {
+			changes[field.name] = jobFieldChange{From: field.from, To: field.to}
+		}
+	}
+	return changes
+}
+
+// UpdateJob to update a job of the Default cdule in place, see Cdule.UpdateJob
+func UpdateJob(jobID int64, update JobUpdate) (*model.Job, error) {
+	return Default().UpdateJob(jobID, update)
+}
+
+// GetJobChanges to get the changes of a job of the Default cdule, see Cdule.GetJobChanges
+func GetJobChanges(jobID int64) ([]model.JobChange, error) {
+	return Default().GetJobChanges(jobID)
+}
+
+// UpdateJob to change the cron expression, time zone, job data or policies of a job in place, so that it keeps its
+// ID and its job histories. The schedules which have not started to run get the new job data, and the next one of a
+// repeating job is moved to the next fire time of the new cron expression or time zone, all in one transaction.
+// The change is recorded as a model.JobChange. model.ErrNotFound when there is no such job, ErrInvalidJobUpdate
+// when the update cannot be applied.
+func (cdule *Cdule) UpdateJob(jobID int64, update JobUpdate) (*model.Job, error) {
+	job, _, err := cdule.updateJob(jobID, update.apply, nil)
+	return job, err
+}
+
+// GetJobChanges to get the changes made to a job by UpdateJob, or by building it again, in the order they were made
+func (cdule *Cdule) GetJobChanges(jobID int64) ([]model.JobChange, error) {
+	if nil == cdule.repo {
+		return nil, ErrNotStarted
+	}
+	return cdule.repo.GetJobChanges(jobID)
+}
+
+// updateJob to update a job with apply, reschedule its pending schedules and record the change. The next schedule
+// of the job is returned, firstSchedule is created as it when the job has none and it is not nil.
+func (cdule *Cdule) updateJob(jobID int64, apply func(job *model.Job) error,
+	firstSchedule *model.Schedule) (*model.Job, *model.Schedule, error) {
+	if nil == cdule.repo {
+		return nil, nil, ErrNotStarted
+	}
+	var job *model.Job
+	var next *model.Schedule
+	var changed, moved bool
+	err := cdule.repo.Transaction(func(repo model.CduleRepository) error {
+		var err error
+		job, err = repo.LockJob(jobID)
+		if nil != err {
+			return err
+		}
+		if nil == job {
+			return model.ErrNotFound
+		}
+		before := *job
+		if err = apply(job); nil != err {
+			return err
+		}
+		if !job.Once {
+			if _, err = ParseCron(job); nil != err {
+				return fmt.Errorf("%w: %s", ErrInvalidJobUpdate, err.Error())
+			}
+		}
+		changes := jobChanges(&before, job)
+		changed = len(changes) > 0
+		if changed {
+			if _, err = repo.SaveJob(job); nil != err {
+				return err
+			}
+			if err = cdule.recordJobChange(repo, job, changes); nil != err {
+				return err
+			}
+		}
+		next, moved, err = rescheduleJob(repo, job, changes)
+		if nil != err || nil != next || nil == firstSchedule {
+			return err
+		}
+		firstSchedule.JobID = job.ID
+		next, err = repo.CreateSchedule(firstSchedule)
+		moved = true
+		return err
+	})
+	if nil != err {
+		return nil, nil, err
+	}
+	if changed {
+		log.Infof("JobName %s JobID %d updated", job.JobName, job.ID)
+	}
+	if moved {
+		cdule.notifyScheduled(job, next)
+	}
+	return job, next, nil
+}
+
+func (cdule *Cdule) recordJobChange(repo model.CduleRepository, job *model.Job, changes map[string]jobFieldChange) error {
+	changesJSON, err := json.Marshal(changes)
+	if nil != err {
+		return err
+	}
+	_, err = repo.CreateJobChange(&model.JobChange{JobID: job.ID, WorkerID: cdule.WorkerID, Changes: string(changesJSON)})
+	return err
+}
+
+// rescheduleJob to give the new job data to the schedules of job which have not started to run, and to move the next
+// one to the next fire time when the cron expression or the time zone changed. The next schedule is returned, with
+// whether it was moved, nil when the job has none.
+func rescheduleJob(repo model.CduleRepository, job *model.Job, changes map[string]jobFieldChange) (*model.Schedule, bool, error) {
+	schedules, err := repo.GetPendingSchedules(job.ID, 0)
+	if nil != err {
+		return nil, false, err
+	}
+	_, dataChanged := changes["job_data"]
+	_, cronChanged := changes["cron"]
+	_, timeZoneChanged := changes["time_zone"]
+	var next *model.Schedule
+	var moved bool
+	for i := range schedules {
+		schedule := &schedules[i]
+		// the retries and the runs triggered by hand are not fire times of the cron expression
+		isNext := nil == next && !schedule.Triggered && schedule.Attempt == 0
+		if isNext {
+			next = schedule
+		}
+		if schedule.ClaimedBy != pkg.EMPTYSTRING {
+			// about to run, left as it is
+			continue
+		}
+		update := false
+		if dataChanged {
+			schedule.JobData = job.JobData
+			update = true
+		}
+		if isNext && !job.Once && (cronChanged || timeZoneChanged) {
+			nextRuns, err := NextRuns(job, time.Now(), 1)
+			if nil != err {
+				return nil, false, err
+			}
+			schedule.ExecutionID = nextRuns[0].UnixNano()
+			update, moved = true, true
+		}
+		if update {
+			if _, err = repo.UpdateSchedule(schedule); nil != err {
+				return nil, false, err
+			}
+		}
+	}
+	return next, moved, nil
+}
+
+// GetRepeatingJob to get the repeating job which building a job with jobName and subName again updates in place,
+// nil when there is none
+func (cdule *Cdule) GetRepeatingJob(jobName, subName string) (*model.Job, error) {
+	if nil == cdule.repo {
+		return nil, ErrNotStarted
+	}
+	return cdule.repeatingJob(jobName, subName)
+}
+
+// repeatingJob to get the repeating job built with jobName and subName which is not expired, nil when there is none.
+// The earlier versions created a new job every time one was built, the latest one having schedules is preferred.
+func (cdule *Cdule) repeatingJob(jobName, subName string) (*model.Job, error) {
+	schedules, err := cdule.repo.GetSchedulesForJobName(jobName, subName)
+	if nil != err {
+		return nil, err
+	}
+	jobIDs := make([]int64, 0, len(schedules))
+	for _, schedule := range schedules {
+		jobIDs = append(jobIDs, schedule.JobID)
+	}
+	sort.Slice(jobIDs, func(i, j int) bool { return jobIDs[i] > jobIDs[j] })
+	for i, jobID := range jobIDs {
+		if i > 0 && jobIDs[i-1] == jobID {
+			continue
+		}
+		job, err := cdule.repo.GetJob(jobID)
+		if nil != err && !errors.Is(err, model.ErrNotFound) {
+			return nil, err
+		}
+		if nil != job && !job.Once && !job.Expired {
+			return job, nil
+		}
+	}
+	job, err := cdule.repo.GetRepeatingJobByName(jobName)
+	if nil != err || nil == job || job.SubName != subName || job.Expired {
+		return nil, err
+	}
+	return job, nil
+}
@@ -0,0 +1,110 @@
+package cdule
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/gagasdiv/cdule/pkg/model"
+	"github.com/gagasdiv/cdule/pkg/utils"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_UpdateJob(t *testing.T) {
+	c, job, schedule := setupWatcherTest(t)
+	_, err := c.UpdateJob(job.ID+1, JobUpdate{})
+	require.ErrorIs(t, err, model.ErrNotFound)
+	invalid := "not a cron"
+	_, err = c.UpdateJob(job.ID, JobUpdate{CronExpression: &invalid})
+	require.ErrorIs(t, err, ErrInvalidJobUpdate)
+
+	cronExpression := "CRON_TZ=Europe/Berlin 0 0 9 * * *"
+	timeout := time.Minute
+	updated, err := c.UpdateJob(job.ID, JobUpdate{
+		CronExpression: &cronExpression,
+		JobData:        JobData{"key": "value"},
+		Timeout:        &timeout,
+	})
+	require.NoError(t, err)
+	require.Equal(t, job.ID, updated.ID)
+	require.Equal(t, "0 0 9 * * *", updated.CronExpression)
+	require.Equal(t, "Europe/Berlin", updated.TimeZone)
+	require.Equal(t, time.Minute, updated.Timeout)
+
+	// the pending schedule is moved to the next fire time of the new cron expression, with the new job data
+	pending, err := c.repo.GetPendingSchedules(job.ID, 0)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(pending))
+	require.Equal(t, schedule.ID, pending[0].ID)
+	require.Equal(t, `{"key":"value"}`, pending[0].JobData)
+	nextRuns, err := NextRuns(updated, time.Now(), 1)
+	require.NoError(t, err)
+	require.Equal(t, nextRuns[0].UnixNano(), pending[0].ExecutionID)
+
+	jobChanges, err := c.GetJobChanges(job.ID)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(jobChanges))
+	require.Equal(t, c.WorkerID, jobChanges[0].WorkerID)
+	var changes map[string]jobFieldChange
+	require.NoError(t, json.Unmarshal([]byte(jobChanges[0].Changes), &changes))
+	require.Equal(t, jobFieldChange{From: utils.EveryMinute, To: "0 0 9 * * *"}, changes["cron"])
+	require.Contains(t, changes, "time_zone")
+	require.Contains(t, changes, "job_data")
+	require.Contains(t, changes, "timeout")
+	require.NotContains(t, changes, "retry_policy")
+
+	// nothing changed, nothing recorded
+	_, err = c.UpdateJob(job.ID, JobUpdate{Timeout: &timeout})
+	require.NoError(t, err)
+	jobChanges, err = c.GetJobChanges(job.ID)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(jobChanges))
+
+	once, err := c.NewJob(&watcherTestJob{}, nil, "once").BuildToRunIn(time.Hour)
+	require.NoError(t, err)
+	_, err = c.UpdateJob(once.ID, JobUpdate{CronExpression: &cronExpression})
+	require.ErrorIs(t, err, ErrInvalidJobUpdate)
+}
+
+func Test_BuildUpdatesJobInPlace(t *testing.T) {
+	c, job, schedule := setupWatcherTest(t)
+	_, err := c.repo.CreateJobHistory(&model.JobHistory{JobID: job.ID, ScheduleID: schedule.ID, Status: model.JobStatusCompleted})
+	require.NoError(t, err)
+
+	rebuilt, err := c.NewJob(&watcherTestJob{}, map[string]string{"key": "value"}).Build("0 0 9 * * *")
+	require.NoError(t, err)
+	require.Equal(t, job.ID, rebuilt.ID)
+	require.Equal(t, "0 0 9 * * *", rebuilt.CronExpression)
+	jobHistories, err := c.repo.FindJobHistory(model.JobHistoryFilter{JobID: job.ID})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(jobHistories))
+	jobChanges, err := c.GetJobChanges(job.ID)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(jobChanges))
+
+	// built again as it is, e.g. at the next deployment
+	_, err = c.NewJob(&watcherTestJob{}, map[string]string{"key": "value"}).Build("0 0 9 * * *")
+	require.NoError(t, err)
+	jobChanges, err = c.GetJobChanges(job.ID)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(jobChanges))
+
+	// a cancelled job gets a new first schedule
+	require.NoError(t, c.CancelJob(job.JobName, job.SubName))
+	rebuilt, err = c.NewJob(&watcherTestJob{}, map[string]string{"key": "value"}).Build("0 0 9 * * *")
+	require.NoError(t, err)
+	require.Equal(t, job.ID, rebuilt.ID)
+	pending, err := c.repo.GetPendingSchedules(job.ID, 0)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(pending))
+	require.Equal(t, c.WorkerID, pending[0].WorkerID)
+
+	// another sub name is another job, which leaves this one scheduled
+	other, err := c.NewJob(&watcherTestJob{}, nil, "other").Build("0 0 9 * * *")
+	require.NoError(t, err)
+	require.NotEqual(t, job.ID, other.ID)
+	pending, err = c.repo.GetPendingSchedules(job.ID, 0)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(pending))
+}
@@ -0,0 +1,207 @@
+package cdule
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gagasdiv/cdule/pkg/model"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Listener observes the lifecycle of the jobs and workers of a Cdule, e.g. to raise alerts or keep an audit trail.
+// The callbacks are called synchronously by the watchers once the change is stored, so they should return quickly.
+// A panic of a callback is recovered and logged. Embed NopListener to implement only some of them.
+type Listener interface {
+	// OnJobScheduled a schedule was created: the first one of a job, the next one of a repeating job or a retry
+	OnJobScheduled(event JobEvent)
+	// OnJobStarted a run was claimed by this worker and is about to start
+	OnJobStarted(event JobEvent)
+	// OnJobCompleted a run succeeded
+	OnJobCompleted(event JobEvent)
+	// OnJobFailed a run failed, timed out or was cancelled; its JobHistory status tells which, RETRYING when a retry is scheduled
+	OnJobFailed(event JobEvent)
+	// OnJobSkipped a run was not started, because its previous run was still in progress or it misfired
+	OnJobSkipped(event JobEvent)
+	// OnJobCancelled the schedules of a job were deleted by CancelJob
+	OnJobCancelled(event JobEvent)
+	// OnMisfire a schedule was not run at its execution time, the misfire policy of its job decides whether it runs now
+	OnMisfire(event JobEvent)
+	// OnWorkerJoined a worker started, this one when it starts or another one seen alive by this one
+	OnWorkerJoined(event WorkerEvent)
+	// OnWorkerLost a worker seen alive by this one stopped sending heartbeats
+	OnWorkerLost(event WorkerEvent)
+}
+
+// JobEvent a change of a job, its schedules or its runs
+type JobEvent struct {
+	// Time the event happened
+	Time time.Time
+	// WorkerID worker reporting the event
+	WorkerID string
+	// Job of the event, with only its ID, JobName and SubName set for OnJobCancelled
+	Job *model.Job
+	// Schedule of the event, the created one for OnJobScheduled, nil for OnJobCancelled
+	Schedule *model.Schedule
+	// JobHistory of the run, nil for OnJobScheduled, OnJobCancelled and OnMisfire
+	JobHistory *model.JobHistory
+	// Err error returned by a failed run
+	Err error
+	// Duration of the run, for OnJobCompleted and OnJobFailed
+	Duration time.Duration
+}
+
+// WorkerEvent a change of the workers of the cluster
+type WorkerEvent struct {
+	// Time the event happened
+	Time time.Time
+	// WorkerID worker reporting the event
+	WorkerID string
+	// Worker which joined or was lost
+	Worker model.Worker
+}
+
+// TickListener a Listener also notified of every tick of the watchers, e.g. to measure how long they take
+type TickListener interface {
+	OnTick(event TickEvent)
+}
+
+// TickEvent a tick of a watcher
+type TickEvent struct {
+	// Time the tick started
+	Time time.Time
+	// WorkerID worker of the watcher
+	WorkerID string
+	// Watcher "schedule" for the ScheduleWatcher, "past_schedule" for the PastScheduleWatcher
+	Watcher string
+	// Duration of the tick, claiming the due schedules and handing them to the pool, not running them
+	Duration time.Duration
+}
+
+// NopListener a Listener doing nothing, to embed in the listeners interested in some of the events only
+type NopListener struct{}
+
+// OnJobScheduled does nothing
+func (NopListener) OnJobScheduled(JobEvent) {}
+
+// OnJobStarted does nothing
+func (NopListener) OnJobStarted(JobEvent) {}
+
+// OnJobCompleted does nothing
+func (NopListener) OnJobCompleted(JobEvent) {}
+
+// OnJobFailed does nothing
+func (NopListener) OnJobFailed(JobEvent) {}
+
+// OnJobSkipped does nothing
+func (NopListener) OnJobSkipped(JobEvent) {}
+
+// OnJobCancelled does nothing
+func (NopListener) OnJobCancelled(JobEvent) {}
+
+// OnMisfire does nothing
+func (NopListener) OnMisfire(JobEvent) {}
+
+// OnWorkerJoined does nothing
+func (NopListener) OnWorkerJoined(WorkerEvent) {}
+
+// OnWorkerLost does nothing
+func (NopListener) OnWorkerLost(WorkerEvent) {}
+
+// listenerSet the listeners of a Cdule, shared with its copies made by WithDB
+type listenerSet struct {
+	sync.RWMutex
+	listeners []Listener
+}
+
+func (s *listenerSet) add(listener Listener) {
+	s.Lock()
+	defer s.Unlock()
+	s.listeners = append(s.listeners, listener)
+}
+
+// merge to add the listeners of other, e.g. the ones added to Default before a Cdule is started
+func (s *listenerSet) merge(other *listenerSet) {
+	other.RLock()
+	listeners := append([]Listener(nil), other.listeners...)
+	other.RUnlock()
+	s.Lock()
+	defer s.Unlock()
+	s.listeners = append(s.listeners, listeners...)
+}
+
+// AddListener to add a listener to the Default cdule, see Cdule.AddListener
+func AddListener(listener Listener) {
+	Default().AddListener(listener)
+}
+
+// AddListener to have listener notified of the events of the cdule, in addition to its other listeners
+func (cdule *Cdule) AddListener(listener Listener) {
+	cdule.listenerSet().add(listener)
+}
+
+// listenerSet to get the listeners of the cdule, created on first use
+func (cdule *Cdule) listenerSet() *listenerSet {
+	cdule.listenersOnce.Do(func() {
+		if nil == cdule.listeners {
+			cdule.listeners = &listenerSet{}
+		}
+	})
+	return cdule.listeners
+}
+
+// notify to call fn with every listener of the cdule, recovering their panics
+func (cdule *Cdule) notify(fn func(listener Listener)) {
+	set := cdule.listenerSet()
+	set.RLock()
+	listeners := set.listeners
+	set.RUnlock()
+	for _, listener := range listeners {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					log.Warning("Recovered in listener ", r)
+				}
+			}()
+			fn(listener)
+		}()
+	}
+}
+
+// jobEvent to make the event of a job reported by this worker
+func (cdule *Cdule) jobEvent(job *model.Job, schedule *model.Schedule, jobHistory *model.JobHistory, err error) JobEvent {
+	return JobEvent{
+		Time:       time.Now(),
+		WorkerID:   cdule.WorkerID,
+		Job:        job,
+		Schedule:   schedule,
+		JobHistory: jobHistory,
+		Err:        err,
+	}
+}
+
+// notifyScheduled to report the created schedules of job, ignoring the nil ones
+func (cdule *Cdule) notifyScheduled(job *model.Job, schedules ...*model.Schedule) {
+	for _, schedule := range schedules {
+		if nil == schedule {
+			continue
+		}
+		event := cdule.jobEvent(job, schedule, nil, nil)
+		cdule.notify(func(listener Listener) { listener.OnJobScheduled(event) })
+	}
+}
+
+// notifyTick to report a tick of watcher which started at start to the TickListeners
+func (cdule *Cdule) notifyTick(watcher string, start time.Time) {
+	event := TickEvent{Time: start, WorkerID: cdule.WorkerID, Watcher: watcher, Duration: time.Since(start)}
+	cdule.notify(func(listener Listener) {
+		if tickListener, ok := listener.(TickListener); ok {
+			tickListener.OnTick(event)
+		}
+	})
+}
+
+// workerEvent to make the event of a worker reported by this worker
+func (cdule *Cdule) workerEvent(worker model.Worker) WorkerEvent {
+	return WorkerEvent{Time: time.Now(), WorkerID: cdule.WorkerID, Worker: worker}
+}
@@ -0,0 +1,184 @@
+package cdule
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gagasdiv/cdule/pkg"
+	"github.com/gagasdiv/cdule/pkg/model"
+	"github.com/gagasdiv/cdule/pkg/utils"
+
+	"github.com/stretchr/testify/require"
+)
+
+// recordingListener records the events it is notified of, as "<callback> <job name or worker id>"
+type recordingListener struct {
+	sync.Mutex
+	events   []string
+	failures []JobEvent
+}
+
+func (l *recordingListener) record(callback string, name string) {
+	l.Lock()
+	defer l.Unlock()
+	l.events = append(l.events, fmt.Sprintf("%s %s", callback, name))
+}
+
+func (l *recordingListener) OnJobScheduled(event JobEvent) {
+	l.record("scheduled", event.Job.JobName)
+}
+
+func (l *recordingListener) OnJobStarted(event JobEvent) {
+	l.record("started", event.Job.JobName)
+}
+
+func (l *recordingListener) OnJobCompleted(event JobEvent) {
+	l.record("completed", event.Job.JobName)
+}
+
+func (l *recordingListener) OnJobFailed(event JobEvent) {
+	l.record("failed", event.Job.JobName)
+	l.failures = append(l.failures, event)
+}
+
+func (l *recordingListener) OnJobSkipped(event JobEvent) {
+	l.record("skipped", event.Job.JobName)
+}
+
+func (l *recordingListener) OnJobCancelled(event JobEvent) {
+	l.record("cancelled", event.Job.JobName)
+}
+
+func (l *recordingListener) OnMisfire(event JobEvent) {
+	l.record("misfire", event.Job.JobName)
+}
+
+func (l *recordingListener) OnWorkerJoined(event WorkerEvent) {
+	l.record("joined", event.Worker.WorkerID)
+}
+
+func (l *recordingListener) OnWorkerLost(event WorkerEvent) {
+	l.record("lost", event.Worker.WorkerID)
+}
+
+func Test_ListenerJobLifecycle(t *testing.T) {
+	c := newTestCdule(t, "listener-test-worker")
+	listener := &recordingListener{}
+	c.AddListener(listener)
+
+	job, err := c.NewJob(&watcherTestJob{}, nil).Build(utils.EveryMinute)
+	require.NoError(t, err)
+	schedules, err := c.repo.GetSchedulesForJob(job.ID)
+	require.NoError(t, err)
+	runTestSchedules(newTestWatcher(c, pkg.AT_LEAST_ONCE), schedules)
+	require.NoError(t, c.CancelJob(job.JobName, job.SubName))
+
+	require.Equal(t, []string{
+		"scheduled job.WatcherTestJob",
+		"started job.WatcherTestJob",
+		"completed job.WatcherTestJob",
+		"scheduled job.WatcherTestJob",
+		"cancelled job.WatcherTestJob",
+	}, listener.events)
+}
+
+func Test_ListenerJobFailed(t *testing.T) {
+	c := newTestCdule(t, "listener-test-worker")
+	listener := &recordingListener{}
+	c.AddListener(listener)
+
+	job, err := c.NewJobV2(&failingTestJob{}, nil).BuildToRunNow()
+	require.NoError(t, err)
+	schedules, err := c.repo.GetSchedulesForJob(job.ID)
+	require.NoError(t, err)
+	runTestSchedules(newTestWatcher(c, pkg.AT_MOST_ONCE), schedules)
+
+	require.Equal(t, []string{
+		"scheduled job.FailingTestJob",
+		"started job.FailingTestJob",
+		"failed job.FailingTestJob",
+	}, listener.events)
+	require.EqualError(t, listener.failures[0].Err, "external service unavailable")
+	require.Equal(t, model.JobStatusFailed, listener.failures[0].JobHistory.Status)
+}
+
+func Test_ListenerMisfire(t *testing.T) {
+	c, _, _ := setupMisfireTest(t, misfire(model.MisfireSkip), true)
+	listener := &recordingListener{}
+	c.AddListener(listener)
+
+	runTestPassedSchedules(newTestPastWatcher(c))
+
+	require.Equal(t, []string{
+		"misfire job.WatcherTestJob",
+		"skipped job.WatcherTestJob",
+	}, listener.events)
+}
+
+func Test_ListenerWorkers(t *testing.T) {
+	c := newTestCdule(t, "listener-test-worker")
+	listener := &recordingListener{}
+	c.AddListener(listener)
+	watcher := &WorkerWatcher{cdule: c}
+	watcher.watchWorkers()
+	require.Empty(t, listener.events)
+
+	other := &model.Worker{WorkerID: "other-worker"}
+	_, err := c.repo.CreateWorker(other)
+	require.NoError(t, err)
+	watcher.watchWorkers()
+	_, err = c.repo.DeleteWorker(other.WorkerID)
+	require.NoError(t, err)
+	watcher.watchWorkers()
+
+	require.Equal(t, []string{"joined other-worker", "lost other-worker"}, listener.events)
+}
+
+type panickingListener struct {
+	NopListener
+}
+
+func (panickingListener) OnJobScheduled(JobEvent) {
+	panic("boom")
+}
+
+func Test_ListenerPanic(t *testing.T) {
+	c := newTestCdule(t, "listener-test-worker")
+	listener := &recordingListener{}
+	c.AddListener(panickingListener{})
+	c.AddListener(listener)
+
+	_, err := c.NewJob(&watcherTestJob{}, nil).BuildToRunNow()
+	require.NoError(t, err)
+	require.Equal(t, []string{"scheduled job.WatcherTestJob"}, listener.events)
+}
+
+type tickListener struct {
+	NopListener
+	ticks []TickEvent
+}
+
+func (l *tickListener) OnTick(event TickEvent) {
+	l.ticks = append(l.ticks, event)
+}
+
+func Test_ListenerTick(t *testing.T) {
+	c := newTestCdule(t, "listener-test-worker")
+	listener := &tickListener{}
+	c.AddListener(listener)
+	c.AddListener(&recordingListener{})
+
+	watcher := newTestWatcher(c, pkg.AT_MOST_ONCE)
+	watcher.TickDuration = time.Minute
+	watcher.RunImmediately = true
+	watcher.Closed = make(chan struct{})
+	close(watcher.Closed)
+	watcher.Ticker = time.NewTicker(time.Hour)
+	watcher.Run()
+
+	require.Equal(t, 1, len(listener.ticks))
+	require.Equal(t, "schedule", listener.ticks[0].Watcher)
+	require.Equal(t, c.WorkerID, listener.ticks[0].WorkerID)
+}
@@ -0,0 +1,97 @@
+package cdule
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gagasdiv/cdule/pkg/model"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// misfireFires whether to run a schedule of job missed at its execution time according to the misfire policy of the
+// job, and whether it misfired. Runs interrupted by a crash are always re-run, their consistency decides, and so are
+// the runs triggered by hand.
+func misfireFires(repo model.CduleRepository, job *model.Job, schedule model.Schedule, now time.Time) (bool, bool, error) {
+	jobHistory, err := repo.GetJobHistoryForSchedule(schedule.ID)
+	if nil != err {
+		return false, false, err
+	}
+	// a run triggered by hand is run however late it is
+	if nil != jobHistory || schedule.Triggered {
+		return true, false, nil
+	}
+	switch job.MisfirePolicy {
+	case model.MisfireSkip:
+		return false, true, nil
+	case model.MisfireFireIfWithin:
+		return now.Sub(time.Unix(0, schedule.ExecutionID)) <= job.MisfireThreshold, true, nil
+	}
+	return true, true, nil
+}
+
+// skipMisfiredSchedule to record a missed schedule as SKIPPED by workerID and schedule the next run of its job from
+// now, returns nil when the schedule ran meanwhile
+func skipMisfiredSchedule(repo model.CduleRepository, job *model.Job, schedule model.Schedule, workers []model.Worker,
+	workerID string, now time.Time) (*model.JobHistory, *model.Schedule, error) {
+	lateness := now.Sub(time.Unix(0, schedule.ExecutionID)).Round(time.Second)
+	jobHistory, err := skipSchedule(repo, schedule, workerID, fmt.Sprintf("misfired, late by %s", lateness))
+	if nil != err || nil == jobHistory {
+		return nil, nil, err
+	}
+	nextSchedule, err := createNextSchedule(repo, job, schedule, workers, schedule.JobData, false)
+	return jobHistory, nextSchedule, err
+}
+
+// misfireOutcome what applying the misfire policy of a job did to one of its schedules, reported to the listeners
+// once the transaction is committed
+type misfireOutcome struct {
+	job      *model.Job
+	schedule model.Schedule
+	misfired bool
+	// job history of the schedule skipped, nil when it runs
+	skipped *model.JobHistory
+	// schedule moved to now to run, or the next one of the job created when it was skipped
+	scheduled *model.Schedule
+}
+
+// applyMisfire to apply the misfire policy of job to a schedule missed at its execution time, without the past
+// schedule watcher: the schedule is moved to now so that the schedule watcher runs it at its next tick, or recorded
+// as SKIPPED with the next run of the job scheduled from now. The occurrences of a FIRE_ALL job are left to the
+// past schedule watcher when watchPast. Returns nil when the schedule is left as it is.
+func applyMisfire(repo model.CduleRepository, job *model.Job, schedule model.Schedule, workers []model.Worker,
+	workerID string, now time.Time, watchPast bool) (*misfireOutcome, error) {
+	fire, misfired, err := misfireFires(repo, job, schedule, now)
+	if nil != err {
+		return nil, err
+	}
+	if !fire {
+		jobHistory, nextSchedule, err := skipMisfiredSchedule(repo, job, schedule, workers, workerID, now)
+		if nil != err || nil == jobHistory {
+			return nil, err
+		}
+		return &misfireOutcome{job: job, schedule: schedule, misfired: true, skipped: jobHistory, scheduled: nextSchedule}, nil
+	}
+	if misfired && watchPast && job.MisfirePolicy == model.MisfireFireAll && schedule.Attempt == 0 {
+		return nil, nil
+	}
+	schedule.ExecutionID = now.UnixNano()
+	if _, err = repo.UpdateSchedule(&schedule); nil != err {
+		return nil, err
+	}
+	return &misfireOutcome{job: job, schedule: schedule, misfired: misfired, scheduled: &schedule}, nil
+}
+
+// notifyMisfire to report to the listeners what applying the misfire policy of a job did
+func (cdule *Cdule) notifyMisfire(outcome *misfireOutcome) {
+	if outcome.misfired {
+		misfireEvent := cdule.jobEvent(outcome.job, &outcome.schedule, nil, nil)
+		cdule.notify(func(listener Listener) { listener.OnMisfire(misfireEvent) })
+	}
+	if nil != outcome.skipped {
+		log.Infof("Schedule %d for JobName %s misfired, skipped", outcome.schedule.ID, outcome.job.JobName)
+		skippedEvent := cdule.jobEvent(outcome.job, &outcome.schedule, outcome.skipped, nil)
+		cdule.notify(func(listener Listener) { listener.OnJobSkipped(skippedEvent) })
+	}
+	cdule.notifyScheduled(outcome.job, outcome.scheduled)
+}
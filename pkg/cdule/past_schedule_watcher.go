@@ -1,6 +1,7 @@
 package cdule
 
 import (
+	"sync"
 	"time"
 
 	"github.com/gagasdiv/cdule/pkg/model"
@@ -8,17 +9,19 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
-// ScheduleWatcher struct
+// PastScheduleWatcher struct, runs the schedules missed at their execution time according to the misfire policy of their job
 type PastScheduleWatcher struct {
 	ScheduleWatcher
 }
 
 // Run to run watcher in a continuous loop
 func (t *PastScheduleWatcher) Run() {
-	runJobs := func () {
+	runJobs := func() {
 		// Adjust with schedule watcher so that there's no collision/duplication/race condition
-		now := time.Now().Add(-1 * t.TickDuration)
-		runPassedScheduleJobs(now.UnixNano())
+		start := time.Now()
+		now := start.Add(-1 * t.TickDuration)
+		t.runPassedScheduleJobs(now.UnixNano())
+		t.cdule.notifyTick("past_schedule", start)
 	}
 
 	if t.RunImmediately {
@@ -35,26 +38,116 @@ func (t *PastScheduleWatcher) Run() {
 	}
 }
 
-func runPassedScheduleJobs(beforeTime int64) {
-	schedules, err := model.CduleRepos.CduleRepository.GetPassedSchedule(beforeTime, WorkerID, true)
+func (t *PastScheduleWatcher) runPassedScheduleJobs(beforeTime int64) {
+	// the run of a missed occurrence of a FIRE_ALL job creates the next one, they are all caught up in the same tick
+	for t.firePassedSchedules(beforeTime) && nil == t.ctx.Err() {
+	}
+	log.Debugf("Passed Schedules Completed Before %d", beforeTime)
+}
+
+// firePassedSchedules to run the schedules passed before beforeTime according to the misfire policy of their job,
+// returns whether missed occurrences of FIRE_ALL jobs ran, in which case their runs are waited for
+func (t *PastScheduleWatcher) firePassedSchedules(beforeTime int64) bool {
+	schedules, err := t.cdule.repo.GetPassedSchedule(beforeTime, t.cdule.WorkerID, false)
 	if nil != err {
 		log.Error(err)
-		return
+		return false
+	}
+	if len(schedules) == 0 {
+		return false
+	}
+	workers, err := t.cdule.repo.GetAliveWorkers()
+	if nil != err {
+		log.Error(err)
+		return false
 	}
 
-	// Filter to only take passed single-run schedules
-	filtered := make([]model.Schedule, 0)
+	// Only take passed schedules which no other watcher claimed yet, and apply the misfire policy of their job
+	now := time.Now()
+	misfired := make([]model.Schedule, 0)
 	for _, s := range schedules {
-		if s.Job.Once {
-			filtered = append(filtered, s)
+		claimed, err := t.cdule.repo.ClaimSchedule(&s, t.cdule.WorkerID)
+		if nil != err {
+			log.Error(err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+		if s.Job.Paused {
+			// paused since the passed schedules were read: released as it is until the job is resumed, its
+			// misfire policy applies then
+			if _, err = t.cdule.repo.PostponeSchedule(&s); nil != err {
+				log.Error(err)
+			}
+			continue
+		}
+		fire, err := t.shouldFireMisfire(s, now)
+		if nil != err {
+			log.Error(err)
+			continue
 		}
+		if fire {
+			misfired = append(misfired, s)
+			continue
+		}
+		t.skipMisfire(s, workers, now)
 	}
 
-	if len(filtered) == 0 {
-		return
+	if len(misfired) == 0 {
+		return false
 	}
 
-	runScheduleJobs(filtered)
+	catchingUp := false
+	for _, s := range misfired {
+		if s.Job.MisfirePolicy == model.MisfireFireAll && !s.Job.Once && !s.Triggered && s.Attempt == 0 {
+			catchingUp = true
+		}
+	}
+	if !catchingUp {
+		t.submitScheduleJobs(misfired, nil)
+		return false
+	}
+	var runs sync.WaitGroup
+	t.submitScheduleJobs(misfired, &runs)
+	runs.Wait()
+	return true
+}
 
-	log.Debugf("Passed Schedules Completed Before %d", beforeTime)
+// shouldFireMisfire whether to run a schedule missed at its execution time, according to the misfire policy of its job,
+// the misfire is reported to the listeners. Runs interrupted by a crash are always re-run, their consistency decides,
+// and so are the runs triggered by hand.
+func (t *PastScheduleWatcher) shouldFireMisfire(schedule model.Schedule, now time.Time) (bool, error) {
+	fire, misfired, err := misfireFires(t.cdule.repo, &schedule.Job, schedule, now)
+	if nil != err {
+		return false, err
+	}
+	if misfired {
+		misfireEvent := t.cdule.jobEvent(&schedule.Job, &schedule, nil, nil)
+		t.cdule.notify(func(listener Listener) { listener.OnMisfire(misfireEvent) })
+	}
+	return fire, nil
+}
+
+// skipMisfire to record a missed schedule as SKIPPED and schedule the next run of its job from now
+func (t *PastScheduleWatcher) skipMisfire(schedule model.Schedule, workers []model.Worker, now time.Time) {
+	var jobHistory *model.JobHistory
+	var nextSchedule *model.Schedule
+	err := t.cdule.repo.Transaction(func(repo model.CduleRepository) error {
+		var err error
+		jobHistory, nextSchedule, err = skipMisfiredSchedule(repo, &schedule.Job, schedule, workers, t.cdule.WorkerID, now)
+		return err
+	})
+	if nil != err {
+		log.Errorf("Error skipping misfired Schedule %d for JobName %s : %s", schedule.ID, schedule.Job.JobName, err.Error())
+		return
+	}
+	if nil == jobHistory {
+		return
+	}
+	lateness := now.Sub(time.Unix(0, schedule.ExecutionID)).Round(time.Second)
+	log.Infof("Schedule %d for JobName %s misfired by %s, skipped", schedule.ID, schedule.Job.JobName, lateness)
+	skippedEvent := t.cdule.jobEvent(&schedule.Job, &schedule, jobHistory, nil)
+	t.cdule.notify(func(listener Listener) { listener.OnJobSkipped(skippedEvent) })
+	t.cdule.notifyScheduled(&schedule.Job, nextSchedule)
 }
@@ -0,0 +1,134 @@
+package cdule
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gagasdiv/cdule/pkg"
+	"github.com/gagasdiv/cdule/pkg/model"
+	"github.com/gagasdiv/cdule/pkg/utils"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestPastWatcher(c *Cdule) *PastScheduleWatcher {
+	return &PastScheduleWatcher{
+		ScheduleWatcher: ScheduleWatcher{
+			cdule:        c,
+			TickDuration: time.Minute,
+			Consistency:  pkg.AT_LEAST_ONCE,
+			ctx:          context.Background(),
+			pool:         newExecutionPool(10, nil, true),
+			catchUp:      true,
+		},
+	}
+}
+
+// setupMisfireTest to build a job configured with the misfire policy whose first schedule was missed 10 minutes ago
+func setupMisfireTest(t *testing.T, configure func(*AbstractJob) *AbstractJob, once bool) (*Cdule, *model.Job, model.Schedule) {
+	c, _, _ := setupWatcherTest(t)
+	job := configure(c.NewJob(&watcherTestJob{}, nil))
+	var built *model.Job
+	var err error
+	if once {
+		built, err = job.BuildToRunNow()
+	} else {
+		built, err = job.Build(utils.EveryMinute)
+	}
+	require.NoError(t, err)
+	schedules, err := c.repo.GetSchedulesForJob(built.ID)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(schedules))
+	schedule := schedules[0]
+	schedule.ExecutionID = time.Now().Add(-10 * time.Minute).Truncate(time.Minute).UnixNano()
+	_, err = c.repo.UpdateSchedule(&schedule)
+	require.NoError(t, err)
+	return c, built, schedule
+}
+
+func misfire(policy model.MisfirePolicy) func(*AbstractJob) *AbstractJob {
+	return func(job *AbstractJob) *AbstractJob {
+		return job.WithMisfire(policy)
+	}
+}
+
+func misfireWithin(threshold time.Duration) func(*AbstractJob) *AbstractJob {
+	return func(job *AbstractJob) *AbstractJob {
+		return job.WithMisfireWithin(threshold)
+	}
+}
+
+func runTestPassedSchedules(watcher *PastScheduleWatcher) {
+	watcher.runPassedScheduleJobs(time.Now().Add(-watcher.TickDuration).UnixNano())
+	watcher.pool.wait()
+}
+
+func Test_MisfirePolicies(t *testing.T) {
+	for name, test := range map[string]struct {
+		job      func(*AbstractJob) *AbstractJob
+		once     bool
+		expected model.JobStatus
+	}{
+		"default":    {job: misfire(""), expected: model.JobStatusCompleted},
+		"skip":       {job: misfire(model.MisfireSkip), expected: model.JobStatusSkipped},
+		"within":     {job: misfireWithin(time.Hour), expected: model.JobStatusCompleted},
+		"not within": {job: misfireWithin(time.Minute), expected: model.JobStatusSkipped},
+		"once":       {job: misfire(""), once: true, expected: model.JobStatusCompleted},
+		"once skip":  {job: misfire(model.MisfireSkip), once: true, expected: model.JobStatusSkipped},
+	} {
+		t.Run(name, func(t *testing.T) {
+			c, job, schedule := setupMisfireTest(t, test.job, test.once)
+			watcherTestJobRuns = 0
+
+			runTestPassedSchedules(newTestPastWatcher(c))
+
+			jobHistory, err := c.repo.GetJobHistoryForSchedule(schedule.ID)
+			require.NoError(t, err)
+			require.NotNil(t, jobHistory)
+			require.Equal(t, test.expected, jobHistory.Status)
+			if test.expected == model.JobStatusCompleted {
+				require.Equal(t, 1, watcherTestJobRuns)
+			} else {
+				require.Equal(t, 0, watcherTestJobRuns)
+			}
+
+			schedules, err := c.repo.GetSchedulesForJob(job.ID)
+			require.NoError(t, err)
+			if test.once {
+				require.Equal(t, 1, len(schedules))
+				return
+			}
+			require.Equal(t, 2, len(schedules))
+			require.Greater(t, schedules[1].ExecutionID, time.Now().UnixNano())
+		})
+	}
+}
+
+func Test_MisfireFireAllCatchesUp(t *testing.T) {
+	c, job, schedule := setupMisfireTest(t, misfire(model.MisfireFireAll), false)
+	watcherTestJobRuns = 0
+
+	// a single tick runs the 10 missed occurrences, the ones of the last tick are left to the schedule watcher
+	runTestPassedSchedules(newTestPastWatcher(c))
+	require.GreaterOrEqual(t, watcherTestJobRuns, 9)
+	require.LessOrEqual(t, watcherTestJobRuns, 10)
+	schedules, err := c.repo.GetSchedulesForJob(job.ID)
+	require.NoError(t, err)
+	require.Equal(t, watcherTestJobRuns+1, len(schedules))
+	for i, s := range schedules {
+		require.Equal(t, schedule.ExecutionID+int64(i)*time.Minute.Nanoseconds(), s.ExecutionID)
+	}
+}
+
+func Test_MisfireFireAllLateRun(t *testing.T) {
+	// a run of the schedule watcher which ended after its next occurrence, e.g. a long run committing its next
+	// schedule afterwards, moves on from now as the past schedule watcher may not be running
+	c, job, schedule := setupMisfireTest(t, misfire(model.MisfireFireAll), false)
+	runTestSchedules(newTestWatcher(c, pkg.AT_LEAST_ONCE), []model.Schedule{schedule})
+
+	schedules, err := c.repo.GetSchedulesForJob(job.ID)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(schedules))
+	require.Greater(t, schedules[1].ExecutionID, time.Now().UnixNano())
+}
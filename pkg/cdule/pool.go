@@ -0,0 +1,142 @@
+package cdule
+
+import (
+	"sync"
+)
+
+// executionPool runs schedules on goroutines, bounded by a global limit and optional per job name limits.
+// When ordered, the schedules of a job run one after the other in the order they were submitted.
+type executionPool struct {
+	slots     chan struct{}
+	jobLimits map[string]int
+	ordered   bool
+
+	mu       sync.Mutex
+	jobSlots map[string]chan struct{}
+	// pending runs per job id, a job has an entry while its runs are being drained
+	lanes map[int64][]func()
+	wg    sync.WaitGroup
+}
+
+// newExecutionPool to create a pool running at most maxConcurrency schedules at a time, and at most
+// jobLimits[jobName] schedules of a job at a time
+func newExecutionPool(maxConcurrency int, jobLimits map[string]int, ordered bool) *executionPool {
+	if maxConcurrency < 1 {
+		maxConcurrency = 1
+	}
+	return &executionPool{
+		slots:     make(chan struct{}, maxConcurrency),
+		jobLimits: jobLimits,
+		ordered:   ordered,
+		jobSlots:  make(map[string]chan struct{}),
+		lanes:     make(map[int64][]func()),
+	}
+}
+
+// submit to run fn for a schedule of the job, without waiting for it
+func (p *executionPool) submit(jobID int64, jobName string, fn func()) {
+	if !p.ordered {
+		p.submitConcurrent(jobName, fn)
+		return
+	}
+	p.wg.Add(1)
+	p.mu.Lock()
+	queue, draining := p.lanes[jobID]
+	p.lanes[jobID] = append(queue, fn)
+	p.mu.Unlock()
+	if !draining {
+		go p.drain(jobID, jobName)
+	}
+}
+
+// submitConcurrent to run fn for a schedule of the job, without waiting for it nor for the previous runs of the job.
+// A global slot is taken before fn gets its goroutine, so that submitting waits while the pool is full, the slot of
+// the job is waited for on that goroutine so that a job at its limit does not hold back the schedules of the others.
+func (p *executionPool) submitConcurrent(jobName string, fn func()) {
+	p.wg.Add(1)
+	p.slots <- struct{}{}
+	go func() {
+		defer p.wg.Done()
+		defer p.acquireJob(jobName)()
+		fn()
+	}()
+}
+
+// wait to wait for every submitted run to return, nothing must be submitted meanwhile
+func (p *executionPool) wait() {
+	p.wg.Wait()
+}
+
+func (p *executionPool) drain(jobID int64, jobName string) {
+	for {
+		p.mu.Lock()
+		queue := p.lanes[jobID]
+		if len(queue) == 0 {
+			delete(p.lanes, jobID)
+			p.mu.Unlock()
+			return
+		}
+		fn := queue[0]
+		p.lanes[jobID] = queue[1:]
+		p.mu.Unlock()
+		p.run(jobName, fn)
+	}
+}
+
+func (p *executionPool) run(jobName string, fn func()) {
+	defer p.wg.Done()
+	defer p.acquire(jobName)()
+	fn()
+}
+
+// acquire to take a slot of the job and a global slot, waiting for them, returns the function releasing them
+func (p *executionPool) acquire(jobName string) func() {
+	// the job slot is taken first so that a run waiting for its job does not hold a global slot
+	jobSlots := p.jobSlotsFor(jobName)
+	if nil != jobSlots {
+		jobSlots <- struct{}{}
+	}
+	p.slots <- struct{}{}
+	return func() {
+		<-p.slots
+		if nil != jobSlots {
+			<-jobSlots
+		}
+	}
+}
+
+// acquireJob to take a slot of the job while holding a global slot, returns the function releasing them. The global
+// slot is given back while waiting for the job, so that the runs waiting for their job do not fill the pool.
+func (p *executionPool) acquireJob(jobName string) func() {
+	jobSlots := p.jobSlotsFor(jobName)
+	if nil != jobSlots {
+		select {
+		case jobSlots <- struct{}{}:
+		default:
+			<-p.slots
+			jobSlots <- struct{}{}
+			p.slots <- struct{}{}
+		}
+	}
+	return func() {
+		<-p.slots
+		if nil != jobSlots {
+			<-jobSlots
+		}
+	}
+}
+
+func (p *executionPool) jobSlotsFor(jobName string) chan struct{} {
+	limit, ok := p.jobLimits[jobName]
+	if !ok || limit < 1 {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	jobSlots, ok := p.jobSlots[jobName]
+	if !ok {
+		jobSlots = make(chan struct{}, limit)
+		p.jobSlots[jobName] = jobSlots
+	}
+	return jobSlots
+}
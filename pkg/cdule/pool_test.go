@@ -0,0 +1,111 @@
+package cdule
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// maxTracker records the highest number of concurrent runs
+type maxTracker struct {
+	running int32
+	max     int32
+}
+
+func (m *maxTracker) run() {
+	running := atomic.AddInt32(&m.running, 1)
+	for {
+		max := atomic.LoadInt32(&m.max)
+		if running <= max || atomic.CompareAndSwapInt32(&m.max, max, running) {
+			break
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	atomic.AddInt32(&m.running, -1)
+}
+
+func Test_ExecutionPoolLimits(t *testing.T) {
+	pool := newExecutionPool(3, map[string]int{"limited": 1}, false)
+	all, limited := &maxTracker{}, &maxTracker{}
+	for i := 0; i < 10; i++ {
+		pool.submit(int64(i), "unlimited", all.run)
+	}
+	for i := 0; i < 3; i++ {
+		pool.submit(100, "limited", limited.run)
+	}
+	pool.wait()
+	require.Equal(t, int32(3), all.max)
+	require.Equal(t, int32(1), limited.max)
+}
+
+func Test_ExecutionPoolOrdered(t *testing.T) {
+	pool := newExecutionPool(10, nil, true)
+	var mu sync.Mutex
+	order := make([]int, 0)
+	tracker := &maxTracker{}
+	for i := 0; i < 5; i++ {
+		i := i
+		pool.submit(1, "ordered", func() {
+			tracker.run()
+			mu.Lock()
+			order = append(order, i)
+			mu.Unlock()
+		})
+	}
+	// another job is not held back by the first one
+	other := make(chan struct{})
+	pool.submit(2, "other", func() { close(other) })
+	select {
+	case <-other:
+	case <-time.After(50 * time.Millisecond):
+		t.Fatal("schedule of another job waited for the ordered job")
+	}
+	pool.wait()
+	require.Equal(t, []int{0, 1, 2, 3, 4}, order)
+	require.Equal(t, int32(1), tracker.max)
+}
+
+func Test_ExecutionPoolSubmitWaitsForSlot(t *testing.T) {
+	pool := newExecutionPool(1, nil, false)
+	release := make(chan struct{})
+	pool.submit(1, "first", func() { <-release })
+	submitted := make(chan struct{})
+	go func() {
+		pool.submit(2, "second", func() {})
+		close(submitted)
+	}()
+	select {
+	case <-submitted:
+		t.Fatal("schedule was submitted to a full pool")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	<-submitted
+	pool.wait()
+}
+
+func Test_ExecutionPoolJobLimitDoesNotBlockOthers(t *testing.T) {
+	pool := newExecutionPool(2, map[string]int{"saturated": 1}, false)
+	release := make(chan struct{})
+	other := make(chan struct{})
+	submitted := make(chan struct{})
+	// the runs waiting for the saturated job neither block submitting nor fill the pool
+	go func() {
+		for i := 0; i < 3; i++ {
+			pool.submit(1, "saturated", func() { <-release })
+		}
+		pool.submit(2, "other", func() { close(other) })
+		close(submitted)
+	}()
+	select {
+	case <-other:
+	case <-time.After(time.Second):
+		t.Fatal("schedule of another job waited for the saturated job")
+	}
+	<-submitted
+	close(release)
+	pool.wait()
+}
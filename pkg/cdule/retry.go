@@ -0,0 +1,89 @@
+package cdule
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/gagasdiv/cdule/pkg/model"
+)
+
+// RetryPolicy how a failed run of a job is retried
+type RetryPolicy = model.RetryPolicy
+
+// RetryClassifier can be implemented by a job to decide which errors are worth a retry
+type RetryClassifier interface {
+	IsRetryable(err error) bool
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e permanentError) Unwrap() error {
+	return e.err
+}
+
+// PermanentError to wrap an error returned by a job which must not be retried
+func PermanentError(err error) error {
+	return permanentError{err: err}
+}
+
+// IsRetryable to check whether a job error can be retried, it can unless it is wrapped with PermanentError
+// or the job implements RetryClassifier and rejects it
+func IsRetryable(job JobV2, err error) bool {
+	if errors.As(err, &permanentError{}) {
+		return false
+	}
+	if adapter, ok := job.(jobAdapter); ok {
+		if classifier, ok := adapter.job.(RetryClassifier); ok {
+			return classifier.IsRetryable(err)
+		}
+	}
+	if classifier, ok := job.(RetryClassifier); ok {
+		return classifier.IsRetryable(err)
+	}
+	return true
+}
+
+func shouldRetry(policy model.RetryPolicy, attempt int, job JobV2, err error) bool {
+	return attempt+1 < policy.MaxAttempts && IsRetryable(job, err)
+}
+
+// maxRetryDelay the longest wait before a retry, about 146 years, that of an exponential backoff without MaxInterval
+// is clamped to it. A power of two, so that it converts to a float64 and back exactly.
+const maxRetryDelay = time.Duration(1 << 62)
+
+// retryDelay to get the wait before the given retry, the first retry being attempt 1
+func retryDelay(policy model.RetryPolicy, attempt int) time.Duration {
+	// calculated as a float64 and clamped before the conversion, which would overflow
+	delay := float64(policy.Interval)
+	if policy.Backoff == model.BackoffExponential {
+		delay *= math.Pow(2, float64(attempt-1))
+	}
+	if policy.MaxInterval > 0 && delay > float64(policy.MaxInterval) {
+		delay = float64(policy.MaxInterval)
+	}
+	delay = math.Min(delay, float64(maxRetryDelay))
+	if policy.Jitter > 0 {
+		delay += delay * policy.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(math.Max(0, math.Min(delay, float64(maxRetryDelay))))
+}
+
+// newRetrySchedule to create the schedule of the next attempt of a failed run on the worker, with the same job data
+func newRetrySchedule(policy model.RetryPolicy, schedule model.Schedule, workerID string) *model.Schedule {
+	attempt := schedule.Attempt + 1
+	return &model.Schedule{
+		ExecutionID: time.Now().Add(retryDelay(policy, attempt)).UnixNano(),
+		WorkerID:    workerID,
+		JobID:       schedule.JobID,
+		JobData:     schedule.JobData,
+		Attempt:     attempt,
+	}
+}
@@ -0,0 +1,77 @@
+package cdule
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gagasdiv/cdule/pkg"
+	"github.com/gagasdiv/cdule/pkg/model"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_RetryDelay(t *testing.T) {
+	policy := RetryPolicy{
+		MaxAttempts: 5,
+		Backoff:     model.BackoffExponential,
+		Interval:    time.Second,
+		MaxInterval: 5 * time.Second,
+	}
+	require.Equal(t, time.Second, retryDelay(policy, 1))
+	require.Equal(t, 2*time.Second, retryDelay(policy, 2))
+	require.Equal(t, 4*time.Second, retryDelay(policy, 3))
+	require.Equal(t, 5*time.Second, retryDelay(policy, 4))
+
+	policy.Backoff = model.BackoffFixed
+	require.Equal(t, time.Second, retryDelay(policy, 4))
+
+	// without MaxInterval a long exponential backoff is clamped rather than overflowing
+	unbounded := RetryPolicy{Backoff: model.BackoffExponential, Interval: time.Hour}
+	require.Equal(t, maxRetryDelay, retryDelay(unbounded, 100))
+	require.Equal(t, maxRetryDelay, retryDelay(unbounded, 2000))
+	unbounded.Jitter = 0.5
+	retry := newRetrySchedule(unbounded, model.Schedule{Attempt: 1999}, "worker")
+	require.Greater(t, retry.ExecutionID, time.Now().UnixNano())
+
+	policy.Jitter = 0.5
+	for i := 0; i < 10; i++ {
+		delay := retryDelay(policy, 1)
+		require.GreaterOrEqual(t, delay, 500*time.Millisecond)
+		require.LessOrEqual(t, delay, 1500*time.Millisecond)
+	}
+}
+
+func Test_IsRetryable(t *testing.T) {
+	err := errors.New("db blip")
+	require.True(t, IsRetryable(&failingTestJob{}, err))
+	require.False(t, IsRetryable(&failingTestJob{}, PermanentError(err)))
+	require.True(t, errors.Is(PermanentError(err), err))
+}
+
+func Test_RunScheduleJobsRetry(t *testing.T) {
+	c, _, _ := setupWatcherTest(t)
+	job, err := c.NewJobV2(&failingTestJob{}, nil).
+		WithRetry(RetryPolicy{MaxAttempts: 3, Backoff: model.BackoffFixed}).
+		BuildToRunNow()
+	require.NoError(t, err)
+	require.Equal(t, 3, job.RetryPolicy.MaxAttempts)
+
+	for attempt, expected := range []model.JobStatus{model.JobStatusRetrying, model.JobStatusRetrying, model.JobStatusFailed} {
+		schedules, err := c.repo.GetSchedulesForJob(job.ID)
+		require.NoError(t, err)
+		require.Equal(t, attempt+1, len(schedules))
+		schedule := schedules[attempt]
+		require.Equal(t, attempt, schedule.Attempt)
+
+		runTestSchedules(newTestWatcher(c, pkg.AT_LEAST_ONCE), []model.Schedule{schedule})
+
+		jobHistory, err := c.repo.GetJobHistoryForSchedule(schedule.ID)
+		require.NoError(t, err)
+		require.Equal(t, expected, jobHistory.Status)
+		require.Equal(t, attempt, jobHistory.RetryCount)
+	}
+	schedules, err := c.repo.GetSchedulesForJob(job.ID)
+	require.NoError(t, err)
+	require.Equal(t, 3, len(schedules))
+}
@@ -1,44 +1,62 @@
 package cdule
 
 import (
+	"context"
 	"encoding/json"
-	"reflect"
+	"errors"
+	"fmt"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gagasdiv/cdule/pkg"
 	"github.com/gagasdiv/cdule/pkg/model"
 
-	"github.com/robfig/cron/v3"
 	log "github.com/sirupsen/logrus"
 )
 
 // ScheduleWatcher struct
 type ScheduleWatcher struct {
+	cdule          *Cdule
 	Closed         chan struct{}
 	WG             sync.WaitGroup
 	TickDuration   time.Duration
 	Ticker         *time.Ticker
 	RunImmediately bool
+	Consistency    pkg.Consistency
+	// Timeout of the jobs which have none of their own, no timeout when 0
+	JobTimeout time.Duration
+	// schedules interrupted by a previous crash of this worker, re-run when the watcher starts
+	interrupted []model.Schedule
+	// pool running the schedules, shared by the watchers of a Cdule
+	pool *executionPool
+	// whether the schedules run are missed ones being caught up, set for the PastScheduleWatcher
+	catchUp bool
+	// ctx given to the jobs, cancelled when the watcher stops
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
-var lastScheduleExecutionTime int64
-var nextScheduleExecutionTime int64
-
 // Run to run watcher in a continuous loop
 func (t *ScheduleWatcher) Run() {
-	runJobs := func () {
+	runJobs := func() {
 		now := time.Now()
-		lastScheduleExecutionTime = now.Add(-1 * t.TickDuration).UnixNano()
-		nextScheduleExecutionTime = now.UnixNano()
+		lastScheduleExecutionTime := now.Add(-1 * t.TickDuration).UnixNano()
+		nextScheduleExecutionTime := now.UnixNano()
 
 		log.Debugf("lastScheduleExecutionTime %d, nextScheduleExecutionTime %d", lastScheduleExecutionTime, nextScheduleExecutionTime)
-		runNextScheduleJobs(lastScheduleExecutionTime, nextScheduleExecutionTime)
+		t.runNextScheduleJobs(lastScheduleExecutionTime, nextScheduleExecutionTime)
+		t.cdule.notifyTick("schedule", now)
+	}
+
+	if len(t.interrupted) > 0 {
+		t.runScheduleJobs(t.interrupted)
+		t.interrupted = nil
 	}
 
 	if t.RunImmediately {
-		runJobs();
+		runJobs()
 	}
 
 	for {
@@ -51,138 +69,492 @@ func (t *ScheduleWatcher) Run() {
 	}
 }
 
-// Stop to stop scheduler watcher
+// Stop to stop scheduler watcher, the runs it submitted to the pool may still be running
 func (t *ScheduleWatcher) Stop() {
 	close(t.Closed)
+	if nil != t.cancel {
+		t.cancel()
+	}
 	t.WG.Wait()
 }
 
-func runNextScheduleJobs(scheduleStart, scheduleEnd int64) {
-	schedules, err := model.CduleRepos.CduleRepository.GetScheduleBetween(scheduleStart, scheduleEnd, WorkerID)
+func (t *ScheduleWatcher) runNextScheduleJobs(scheduleStart, scheduleEnd int64) {
+	schedules, err := t.cdule.repo.ClaimScheduleBetween(scheduleStart, scheduleEnd, t.cdule.WorkerID)
 	if nil != err {
 		log.Error(err)
 		return
 	}
 
-	runScheduleJobs(schedules)
+	t.runScheduleJobs(schedules)
 
 	log.Debugf("Schedules Completed For StartTime %d To EndTime %d", scheduleStart, scheduleEnd)
 }
 
-func runScheduleJobs(schedules []model.Schedule) {
-	defer panicRecoveryForSchedule()
+func (t *ScheduleWatcher) runScheduleJobs(schedules []model.Schedule) {
+	t.submitScheduleJobs(schedules, nil)
+}
 
-	workers, err := model.CduleRepos.CduleRepository.GetAliveWorkers()
+// submitScheduleJobs to submit the runs of schedules to the pool, runs is done with each of them when not nil
+func (t *ScheduleWatcher) submitScheduleJobs(schedules []model.Schedule, runs *sync.WaitGroup) {
+	workers, err := t.cdule.repo.GetAliveWorkers()
 	if nil != err {
 		log.Error(err)
 		return
 	}
+	sort.SliceStable(schedules, func(i, j int) bool {
+		return schedules[i].ExecutionID < schedules[j].ExecutionID
+	})
 	for _, schedule := range schedules {
-		scheduledJob, err := model.CduleRepos.CduleRepository.GetJob(schedule.JobID)
+		schedule := schedule
+		scheduledJob, err := t.cdule.repo.GetJob(schedule.JobID)
 		if nil != err {
 			log.Errorf("Error while running Schedule for %d : %s", schedule.JobID, err.Error())
 			continue
 		}
 		if scheduledJob == nil {
-			log.Debugf("Schedule job is nil for worker_id %s, skipping", WorkerID)
+			log.Debugf("Schedule job is nil for worker_id %s, skipping", t.cdule.WorkerID)
 			continue
 		}
-		log.Debug("====START====")
-		log.Debugf("Schedule for JobName: %s, Exeuction Time %d at Worker %s", scheduledJob.JobName, schedule.ExecutionID, schedule.WorkerID)
-		jobDataStr := schedule.JobData
-		var jobDataMap map[string]string
-		if pkg.EMPTYSTRING != jobDataStr {
-			err = json.Unmarshal([]byte(jobDataStr), &jobDataMap)
-			if nil != err {
-				log.Error(err)
-				continue
+		if nil != runs {
+			runs.Add(1)
+		}
+		run := func() {
+			if nil != runs {
+				defer runs.Done()
 			}
+			if nil != t.ctx.Err() {
+				log.Debugf("Watcher stopped, skipping Schedule %d for JobName %s", schedule.ID, scheduledJob.JobName)
+				return
+			}
+			t.runSchedule(schedule, scheduledJob, workers)
+		}
+		// the overlap policy of a job decides how its runs interact, they must not wait for each other in the pool
+		if allowsOverlap(scheduledJob) {
+			t.pool.submit(scheduledJob.ID, scheduledJob.JobName, run)
+		} else {
+			t.pool.submitConcurrent(scheduledJob.JobName, run)
 		}
-		var jobHistory *model.JobHistory
-		if err == nil {
-			jobHistory, err = model.CduleRepos.CduleRepository.GetJobHistoryForSchedule(schedule.ID)
-			j, ok := JobRegistry[scheduledJob.JobName]
-			if !ok {
-				log.Errorf("Error while running Schedule for %d : unregistered job %s", schedule.JobID, scheduledJob.JobName)
-				// Change run status to failed
-				if err != nil && jobHistory != nil {
-					jobHistory.Status = model.JobStatusFailed
-					model.CduleRepos.CduleRepository.UpdateJobHistory(jobHistory)
+	}
+}
+
+func (t *ScheduleWatcher) runSchedule(schedule model.Schedule, scheduledJob *model.Job, workers []model.Worker) {
+	defer panicRecoveryForSchedule()
+
+	consistency := t.Consistency
+	var jobHistory *model.JobHistory
+	var runErr error
+	// the repository calls of the run are children of its span, which the job can continue from its context
+	runCtx, span := t.cdule.startRunSpan(t.ctx, scheduledJob, schedule)
+	defer func() { endRunSpan(span, jobHistory, runErr) }()
+	repo := t.cdule.traceRepository(runCtx)
+
+	if scheduledJob.Paused {
+		// paused after the schedule was claimed: it is released as it is, the misfire policy of the job applies
+		// to it once the job is resumed
+		if _, err := repo.PostponeSchedule(&schedule); nil != err {
+			log.Errorf("Error holding Schedule %d for JobName %s : %s", schedule.ID, scheduledJob.JobName, err.Error())
+			return
+		}
+		log.Debugf("Schedule %d for JobName %s held, the job is paused", schedule.ID, scheduledJob.JobName)
+		return
+	}
+
+	j, ok := t.cdule.jobRegistry().get(scheduledJob.JobName)
+	if !ok {
+		log.Errorf("Error while running Schedule for %d : unregistered job %s", schedule.JobID, scheduledJob.JobName)
+		return
+	}
+	jobInstance, err := newJobInstance(j)
+	if nil != err {
+		log.Errorf("Error while running Schedule for %d : %s", schedule.JobID, err.Error())
+		return
+	}
+	jobDataMap, err := unmarshalJobData(schedule.JobData)
+	if nil != err {
+		log.Error(err)
+		return
+	}
+
+	var nextSchedule *model.Schedule
+	var overlapping []model.JobHistory
+	err = repo.Transaction(func(repo model.CduleRepository) error {
+		overlapping, err = getOverlappingRuns(repo, scheduledJob, schedule)
+		if nil != err {
+			return err
+		}
+		if len(overlapping) > 0 {
+			switch scheduledJob.OverlapPolicy {
+			case model.OverlapSkip:
+				jobHistory, err = skipSchedule(repo, schedule, t.cdule.WorkerID, "previous run still in progress")
+				if nil != err || nil == jobHistory {
+					return err
 				}
-				continue
-			}
-			jobInstance := reflect.New(j).Interface()
-
-			if err != nil && err.Error() == "record not found" && jobHistory != nil {
-				// if job history was present but not executed
-				if jobHistory.Status == model.JobStatusNew {
-					jobHistory.Status = model.JobStatusInProgress
-					model.CduleRepos.CduleRepository.UpdateJobHistory(jobHistory)
-					jobDataMap = executeJob(jobInstance, jobHistory, &jobDataMap)
+				nextSchedule, err = createNextSchedule(repo, scheduledJob, schedule, workers, schedule.JobData, t.catchUp)
+				return err
+			case model.OverlapQueue:
+				schedule.ExecutionID = time.Now().Add(t.queueDelay()).UnixNano()
+				_, err = repo.PostponeSchedule(&schedule)
+				return err
+			case model.OverlapReplace:
+				ids := make([]int64, 0, len(overlapping))
+				for _, running := range overlapping {
+					ids = append(ids, running.ID)
 				}
-			} else {
-				// if job history is not there for this schedule, so this should be executed.
-				jobHistory = &model.JobHistory{
-					JobID:       schedule.JobID,
-					ScheduleID:  schedule.ID,
-					Status:      model.JobStatusNew,
-					WorkerID:    schedule.WorkerID,
-					RetryCount:  0,
+				if err = repo.RequestJobHistoryCancel(ids); nil != err {
+					return err
 				}
-				model.CduleRepos.CduleRepository.CreateJobHistory(jobHistory)
-				jobHistory.Status = model.JobStatusInProgress
-				model.CduleRepos.CduleRepository.UpdateJobHistory(jobHistory)
-
-				jobDataMap = executeJob(jobInstance, jobHistory, &jobDataMap)
-				log.Debugf("Job Execution Completed For JobName: %s JobID: %d on Worker: %s", scheduledJob.JobName, schedule.JobID, schedule.WorkerID)
-				log.Debug("====END====\n")
 			}
+		}
+		jobHistory, err = claimJobHistory(repo, schedule, t.cdule.WorkerID)
+		if nil != err || nil == jobHistory {
+			return err
+		}
+		// at most once commits the next schedule together with the claim, so that a crash
+		// during the execution can neither re-run this schedule nor stall a repeating job
+		if consistency == pkg.AT_MOST_ONCE {
+			nextSchedule, err = createNextSchedule(repo, scheduledJob, schedule, workers, schedule.JobData, t.catchUp)
+		}
+		return err
+	})
+	if nil != err {
+		log.Errorf("Error claiming Schedule %d for JobName %s : %s", schedule.ID, scheduledJob.JobName, err.Error())
+		return
+	}
+	if len(overlapping) > 0 && scheduledJob.OverlapPolicy == model.OverlapQueue {
+		log.Debugf("Schedule %d for JobName %s queued after its running run, postponed to %d", schedule.ID, scheduledJob.JobName, schedule.ExecutionID)
+		return
+	}
+	if nil == jobHistory {
+		log.Debugf("Schedule %d for JobName %s already claimed, skipping", schedule.ID, scheduledJob.JobName)
+		return
+	}
+	if jobHistory.Status == model.JobStatusSkipped {
+		log.Infof("Schedule %d for JobName %s skipped, its previous run is still in progress", schedule.ID, scheduledJob.JobName)
+		skippedEvent := t.cdule.jobEvent(scheduledJob, &schedule, jobHistory, nil)
+		t.cdule.notify(func(listener Listener) { listener.OnJobSkipped(skippedEvent) })
+		t.cdule.notifyScheduled(scheduledJob, nextSchedule)
+		return
+	}
+	if len(overlapping) > 0 && scheduledJob.OverlapPolicy == model.OverlapReplace {
+		log.Infof("Schedule %d for JobName %s replaces %d run(s) in progress", schedule.ID, scheduledJob.JobName, len(overlapping))
+	}
+
+	t.cdule.notifyScheduled(scheduledJob, nextSchedule)
+	startedEvent := t.cdule.jobEvent(scheduledJob, &schedule, jobHistory, nil)
+	t.cdule.notify(func(listener Listener) { listener.OnJobStarted(startedEvent) })
+
+	log.Debug("====START====")
+	log.Debugf("Schedule for JobName: %s, Exeuction Time %d at Worker %s", scheduledJob.JobName, schedule.ExecutionID, schedule.WorkerID)
+	timeout := t.JobTimeout
+	if scheduledJob.Timeout > 0 {
+		timeout = scheduledJob.Timeout
+	}
+	ctx, stopWatchingCancel := runCtx, func() bool { return false }
+	if scheduledJob.OverlapPolicy == model.OverlapReplace {
+		ctx, stopWatchingCancel = watchCancelRequest(runCtx, t.cdule.repo, jobHistory.ID)
+	}
+	started := time.Now()
+	result, err := executeJob(ctx, jobInstance, jobDataMap, timeout)
+	runErr = err
+	duration := time.Since(started)
+	cancelled := stopWatchingCancel()
+	jobHistory.Status = model.JobStatusCompleted
+	jobHistory.Output = result.Output
+	if nil != err {
+		log.Warnf("Job Execution Failed For JobName: %s JobID: %d on Worker: %s : %s", scheduledJob.JobName, schedule.JobID, schedule.WorkerID, err.Error())
+		jobHistory.Status = model.JobStatusFailed
+		if errors.Is(err, ErrJobTimedOut) {
+			jobHistory.Status = model.JobStatusTimedOut
+		} else if cancelled {
+			jobHistory.Status = model.JobStatusCancelled
+		}
+		jobHistory.ErrorMessage = err.Error()
+	}
+	log.Debugf("Job Execution Completed For JobName: %s JobID: %d on Worker: %s", scheduledJob.JobName, schedule.JobID, schedule.WorkerID)
+	log.Debug("====END====\n")
+
+	jobDataStr := schedule.JobData
+	if nil != result.Data {
+		jobDataBytes, err := json.Marshal(result.Data)
+		if nil != err {
+			log.Errorf("Error %s for JobName %s and Schedule ID %d ", err.Error(), scheduledJob.JobName, schedule.ExecutionID)
+		}
+		if string(jobDataBytes) != pkg.EMPTYSTRING {
+			jobDataStr = string(jobDataBytes)
+		}
+	}
 
-			if scheduledJob.Once || scheduledJob.CronExpression == "" {
-				log.Debugf("Job Only Once For JobName: %s JobID: %d on Worker: %s, skipping calculation for next schedule", scheduledJob.JobName, schedule.JobID, schedule.WorkerID)
-				continue
+	var retrySchedule *model.Schedule
+	if nil != err && !cancelled && shouldRetry(scheduledJob.RetryPolicy, schedule.Attempt, jobInstance, err) {
+		retrySchedule = newRetrySchedule(scheduledJob.RetryPolicy, schedule, t.cdule.WorkerID)
+		if jobHistory.Status == model.JobStatusFailed {
+			jobHistory.Status = model.JobStatusRetrying
+		}
+		log.Debugf("Retry %d of JobName: %s scheduled at %d", retrySchedule.Attempt, scheduledJob.JobName, retrySchedule.ExecutionID)
+	}
+	var createdNext *model.Schedule
+	finishRun := func(repo model.CduleRepository, createNext bool) error {
+		if _, err := repo.UpdateJobHistory(jobHistory); nil != err {
+			return err
+		}
+		if nil != retrySchedule {
+			if _, err := repo.CreateSchedule(retrySchedule); nil != err {
+				return err
 			}
+		}
+		if !createNext {
+			return nil
+		}
+		var err error
+		createdNext, err = createNextSchedule(repo, scheduledJob, schedule, workers, jobDataStr, t.catchUp)
+		return err
+	}
 
-			// Calculate the next schedule for the current job
-			jobDataBytes, err := json.Marshal(jobDataMap)
+	switch consistency {
+	case pkg.AT_MOST_ONCE:
+		err = finishRun(repo, false)
+		if nil == err && nil != nextSchedule && nextSchedule.JobData != jobDataStr {
+			nextSchedule.JobData = jobDataStr
+			_, err = repo.UpdateSchedule(nextSchedule)
+		}
+	case pkg.EXACTLY_ONCE:
+		err = repo.Transaction(func(repo model.CduleRepository) error {
+			// a run taken over meanwhile, by a worker which saw this one miss its heartbeats, is completed by the
+			// re-run only, so that the next schedule of the job is not created twice
+			current, err := repo.GetJobHistoryForSchedule(schedule.ID)
 			if nil != err {
-				log.Errorf("Error %s for JobName %s and Schedule ID %d ", err.Error(), scheduledJob.JobName, schedule.ExecutionID)
+				return err
 			}
-			if string(jobDataBytes) != pkg.EMPTYSTRING {
-				jobDataStr = string(jobDataBytes)
+			if nil == current || current.WorkerID != jobHistory.WorkerID || current.RetryCount != jobHistory.RetryCount {
+				return errRunTakenOver
 			}
-			SchedulerParser, err := cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow).Parse(scheduledJob.CronExpression)
-			if err != nil {
-				log.Error(err.Error())
+			return finishRun(repo, true)
+		})
+	default:
+		err = finishRun(repo, true)
+	}
+	if nil != err {
+		log.Errorf("Error completing Schedule %d for JobName %s : %s", schedule.ID, scheduledJob.JobName, err.Error())
+		return
+	}
+	finishedEvent := t.cdule.jobEvent(scheduledJob, &schedule, jobHistory, runErr)
+	finishedEvent.Duration = duration
+	if jobHistory.Status == model.JobStatusCompleted {
+		t.cdule.notify(func(listener Listener) { listener.OnJobCompleted(finishedEvent) })
+	} else {
+		t.cdule.notify(func(listener Listener) { listener.OnJobFailed(finishedEvent) })
+	}
+	t.cdule.notifyScheduled(scheduledJob, retrySchedule, createdNext)
+}
+
+// claimJobHistory to mark a schedule as running on the worker, committed with the transaction of the claim.
+// Returns a nil JobHistory when the schedule has already been claimed.
+func claimJobHistory(repo model.CduleRepository, schedule model.Schedule, workerID string) (*model.JobHistory, error) {
+	jobHistory, err := repo.GetJobHistoryForSchedule(schedule.ID)
+	if nil != err {
+		return nil, err
+	}
+	if nil != jobHistory {
+		// a run which was created but never started (e.g. recovered after a crash) can be taken over
+		if jobHistory.Status != model.JobStatusNew {
+			return nil, nil
+		}
+		jobHistory.Status = model.JobStatusInProgress
+		jobHistory.WorkerID = workerID
+		claimed, err := repo.UpdateJobHistoryStatus(jobHistory, model.JobStatusNew)
+		if nil != err || !claimed {
+			return nil, err
+		}
+		return jobHistory, nil
+	}
+
+	jobHistory = &model.JobHistory{
+		JobID:      schedule.JobID,
+		ScheduleID: schedule.ID,
+		Status:     model.JobStatusInProgress,
+		WorkerID:   workerID,
+		RetryCount: schedule.Attempt,
+	}
+	_, err = repo.CreateJobHistory(jobHistory)
+	return jobHistory, err
+}
+
+// cancelPollInterval how often a run of a job with the OverlapReplace policy checks whether it has been replaced
+var cancelPollInterval = 5 * time.Second
+
+func allowsOverlap(job *model.Job) bool {
+	return job.OverlapPolicy == "" || job.OverlapPolicy == model.OverlapAllow
+}
+
+// getOverlappingRuns to get the runs of the job in progress on any worker, when its overlap policy is not to allow them.
+// The job row is locked until the end of the transaction so that two workers can not both see no run in progress.
+func getOverlappingRuns(repo model.CduleRepository, scheduledJob *model.Job, schedule model.Schedule) ([]model.JobHistory, error) {
+	if allowsOverlap(scheduledJob) {
+		return nil, nil
+	}
+	if _, err := repo.LockJob(scheduledJob.ID); nil != err {
+		return nil, err
+	}
+	running, err := repo.GetRunningJobHistory(scheduledJob.ID)
+	if nil != err {
+		return nil, err
+	}
+	overlapping := make([]model.JobHistory, 0, len(running))
+	for _, jobHistory := range running {
+		if jobHistory.ScheduleID != schedule.ID {
+			overlapping = append(overlapping, jobHistory)
+		}
+	}
+	return overlapping, nil
+}
+
+// skipSchedule to record a schedule as SKIPPED for the reason. Returns a nil JobHistory when the schedule has already been claimed.
+func skipSchedule(repo model.CduleRepository, schedule model.Schedule, workerID string, reason string) (*model.JobHistory, error) {
+	jobHistory, err := repo.GetJobHistoryForSchedule(schedule.ID)
+	if nil != err {
+		return nil, err
+	}
+	if nil != jobHistory {
+		if jobHistory.Status != model.JobStatusNew {
+			return nil, nil
+		}
+		jobHistory.Status = model.JobStatusSkipped
+		jobHistory.WorkerID = workerID
+		skipped, err := repo.UpdateJobHistoryStatus(jobHistory, model.JobStatusNew)
+		if nil != err || !skipped {
+			return nil, err
+		}
+		jobHistory.ErrorMessage = reason
+		_, err = repo.UpdateJobHistory(jobHistory)
+		return jobHistory, err
+	}
+	jobHistory = &model.JobHistory{
+		JobID:        schedule.JobID,
+		ScheduleID:   schedule.ID,
+		Status:       model.JobStatusSkipped,
+		WorkerID:     workerID,
+		RetryCount:   schedule.Attempt,
+		ErrorMessage: reason,
+	}
+	_, err = repo.CreateJobHistory(jobHistory)
+	return jobHistory, err
+}
+
+// queueDelay how long a queued schedule is postponed, until the next tick
+func (t *ScheduleWatcher) queueDelay() time.Duration {
+	if t.TickDuration > 0 {
+		return t.TickDuration
+	}
+	return time.Second
+}
+
+// watchCancelRequest to cancel the returned context once the cancellation of the job history is requested.
+// The returned func stops watching and reports whether the cancellation was requested.
+func watchCancelRequest(parent context.Context, repo model.CduleRepository, jobHistoryID int64) (context.Context, func() bool) {
+	ctx, cancel := context.WithCancel(parent)
+	done := make(chan struct{})
+	var requested int32
+	go func() {
+		ticker := time.NewTicker(cancelPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
 				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				ok, err := repo.IsJobHistoryCancelRequested(jobHistoryID)
+				if nil != err {
+					log.Errorf("Error checking cancellation of job history %d : %s", jobHistoryID, err.Error())
+					continue
+				}
+				if ok {
+					atomic.StoreInt32(&requested, 1)
+					cancel()
+					return
+				}
 			}
-			nextRunTime := SchedulerParser.Next(time.Now()).UnixNano()
-
-			workerIDForNextRun, _ := findNextAvailableWorker(workers, schedule)
-			newSchedule := model.Schedule{
-				ExecutionID: nextRunTime,
-				WorkerID:    workerIDForNextRun,
-				JobID:       schedule.JobID,
-				JobData:     jobDataStr,
-			}
-			model.CduleRepos.CduleRepository.CreateSchedule(&newSchedule)
-			log.Debugf("*** Next Job Scheduled Info ***\n JobName: %s,\n Schedule Cron: %s,\n Job Scheduled Time: %d,\n Worker: %s ",
-				scheduledJob.JobName, scheduledJob.CronExpression, newSchedule.ExecutionID, newSchedule.WorkerID)
 		}
+	}()
+	return ctx, func() bool {
+		close(done)
+		cancel()
+		return atomic.LoadInt32(&requested) == 1
+	}
+}
+
+// createNextSchedule to calculate and store the next schedule of a repeating job, at the next fire time from now,
+// or right after schedule when catching up the missed occurrences of a FIRE_ALL job
+func createNextSchedule(repo model.CduleRepository, scheduledJob *model.Job, schedule model.Schedule, workers []model.Worker, jobDataStr string, catchUp bool) (*model.Schedule, error) {
+	if scheduledJob.Once || scheduledJob.CronExpression == "" {
+		log.Debugf("Job Only Once For JobName: %s JobID: %d on Worker: %s, skipping calculation for next schedule", scheduledJob.JobName, schedule.JobID, schedule.WorkerID)
+		return nil, nil
+	}
+	if schedule.Attempt > 0 {
+		log.Debugf("Retry %d For JobName: %s JobID: %d, next schedule was created by the first attempt", schedule.Attempt, scheduledJob.JobName, schedule.JobID)
+		return nil, nil
+	}
+	if schedule.Triggered {
+		log.Debugf("Triggered run For JobName: %s JobID: %d, the next schedule is still pending", scheduledJob.JobName, schedule.JobID)
+		return nil, nil
 	}
+	SchedulerParser, err := ParseCron(scheduledJob)
+	if err != nil {
+		log.Error(err.Error())
+		return nil, err
+	}
+	// the occurrences missed since this schedule are run one after the other when all misfires are fired. Only the
+	// past schedule watcher picks up a next schedule which is already late, the schedule watcher moves on from now.
+	from := time.Now()
+	if catchUp && scheduledJob.MisfirePolicy == model.MisfireFireAll {
+		from = time.Unix(0, schedule.ExecutionID)
+	}
+	nextRunTime := SchedulerParser.Next(from).UnixNano()
+
+	workerIDForNextRun, _ := findNextAvailableWorker(repo, workers, schedule)
+	newSchedule := &model.Schedule{
+		ExecutionID: nextRunTime,
+		WorkerID:    workerIDForNextRun,
+		JobID:       schedule.JobID,
+		JobData:     jobDataStr,
+	}
+	if _, err = repo.CreateSchedule(newSchedule); err != nil {
+		log.Error(err.Error())
+		return nil, err
+	}
+	log.Debugf("*** Next Job Scheduled Info ***\n JobName: %s,\n Schedule Cron: %s,\n Job Scheduled Time: %d,\n Worker: %s ",
+		scheduledJob.JobName, scheduledJob.CronExpression, newSchedule.ExecutionID, newSchedule.WorkerID)
+	return newSchedule, nil
 }
 
-func findNextAvailableWorker(workers []model.Worker, schedule model.Schedule) (string, error) {
+func unmarshalJobData(jobDataStr string) (JobData, error) {
+	var jobDataMap JobData
+	if pkg.EMPTYSTRING == jobDataStr {
+		return jobDataMap, nil
+	}
+	err := json.Unmarshal([]byte(jobDataStr), &jobDataMap)
+	return jobDataMap, err
+}
+
+func findNextAvailableWorker(repo model.CduleRepository, workers []model.Worker, schedule model.Schedule) (string, error) {
 	workerName := schedule.WorkerID
-	workerJobCountMetrics, _ := model.CduleRepos.CduleRepository.GetWorkerCountByJobID(schedule.JobID)
+	workerJobCountMetrics, _ := repo.GetWorkerCountByJobID(schedule.JobID)
 	log.Debugf("workerJobCountMetrics %v", workerJobCountMetrics)
-	if len(workerJobCountMetrics) <= 0 {
+	if len(workerJobCountMetrics) <= 0 && isAliveWorker(workers, workerName) {
 		log.Debugf("workerName %s would be used", workerName)
 		return workerName, nil
 	}
+	// only alive workers can take the next run
+	aliveWorkerJobCounts := make([]model.WorkerJobCount, 0, len(workers))
+	for _, v := range workerJobCountMetrics {
+		if isAliveWorker(workers, v.WorkerID) {
+			aliveWorkerJobCounts = append(aliveWorkerJobCounts, v)
+		}
+	}
 	for _, worker := range workers {
 		appendWorker := true
-		for _, v := range workerJobCountMetrics {
+		for _, v := range aliveWorkerJobCounts {
 			if v.WorkerID == worker.WorkerID {
 				appendWorker = false
 				break
@@ -193,43 +565,79 @@ func findNextAvailableWorker(workers []model.Worker, schedule model.Schedule) (s
 				WorkerID: worker.WorkerID,
 				Count:    0,
 			}
-			workerJobCountMetrics = append(workerJobCountMetrics, newWorkerMetric)
+			aliveWorkerJobCounts = append(aliveWorkerJobCounts, newWorkerMetric)
 		}
 	}
-	sort.Slice(workerJobCountMetrics[:], func(i, j int) bool {
-		return workerJobCountMetrics[i].Count < workerJobCountMetrics[j].Count
+	if len(aliveWorkerJobCounts) == 0 {
+		return workerName, nil
+	}
+	sort.SliceStable(aliveWorkerJobCounts[:], func(i, j int) bool {
+		return aliveWorkerJobCounts[i].Count < aliveWorkerJobCounts[j].Count
 	})
-	return workerJobCountMetrics[0].WorkerID, nil
+	return aliveWorkerJobCounts[0].WorkerID, nil
 }
 
-/*
-For go 1.17 following method can be used.
-func executeJob(jobInstance interface{}, jobHistory *model.JobHistory, jobDataMap map[string]string) {
-	defer panicRecovery(jobHistory)
-	jobInstance.(job.Job).Execute(jobDataMap)
+func isAliveWorker(workers []model.Worker, workerID string) bool {
+	for _, worker := range workers {
+		if worker.WorkerID == workerID {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrJobTimedOut error of a run which exceeded its timeout
+var ErrJobTimedOut = errors.New("job timed out")
+
+// errRunTakenOver error completing a run which another worker took over while it was running
+var errRunTakenOver = errors.New("run taken over by another worker")
+
+// executeJob to run a job, when the timeout is exceeded the context of the job is cancelled and ErrJobTimedOut is
+// returned once the job returns. The timeout is cooperative: the run keeps its slot of the pool and its job history
+// IN_PROGRESS until then, so that it is neither overlapped nor retried while it is still running.
+func executeJob(ctx context.Context, job JobV2, jobData JobData, timeout time.Duration) (JobResult, error) {
+	if timeout <= 0 {
+		return runJob(ctx, job, jobData)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type outcome struct {
+		result JobResult
+		err    error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		result, err := runJob(ctx, job, jobData)
+		done <- outcome{result: result, err: err}
+	}()
+
+	select {
+	case o := <-done:
+		return o.result, o.err
+	case <-ctx.Done():
+	}
+	if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		// the watcher is stopping, let the job finish
+		o := <-done
+		return o.result, o.err
+	}
+	log.Warnf("JobName %s exceeded its timeout of %s, its context is cancelled, waiting for it to return", job.JobName(), timeout)
+	o := <-done
+	return o.result, fmt.Errorf("%w after %s", ErrJobTimedOut, timeout)
 }
-*/
 
-/*
-cdule library has been built and developed using go 1.18 (go1.18beta2), if you need to use it for 1.17
-then build from source by uncommenting the above method and comment the following
-*/
-func executeJob(jobInstance any, jobHistory *model.JobHistory, jobDataMap *map[string]string) map[string]string {
-	defer panicRecovery(jobHistory)
-	job := jobInstance.(Job)
-	job.Execute(*jobDataMap)
-	return job.GetJobData()
+func runJob(ctx context.Context, job JobV2, jobData JobData) (result JobResult, err error) {
+	defer panicRecovery(&err)
+	return job.Execute(ctx, jobData)
 }
 
-// If there is any panic from Job Execution, set the JobStatus as FAILED
-func panicRecovery(jobHistory *model.JobHistory) {
-	// TODO should be handled for any panic and set the status as FAILED for job history with error message
-	jobHistory.Status = model.JobStatusCompleted
+// If there is any panic from Job Execution, return it as an error so that the JobStatus is set as FAILED
+func panicRecovery(err *error) {
 	if r := recover(); r != nil {
 		log.Warning("Recovered in panicRecovery for job execution ", r)
-		jobHistory.Status = model.JobStatusFailed
+		*err = fmt.Errorf("job panicked: %v", r)
 	}
-	model.CduleRepos.CduleRepository.UpdateJobHistory(jobHistory)
 }
 
 func panicRecoveryForSchedule() {
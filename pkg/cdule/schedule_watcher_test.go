@@ -0,0 +1,445 @@
+package cdule
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gagasdiv/cdule/pkg"
+	"github.com/gagasdiv/cdule/pkg/model"
+	"github.com/gagasdiv/cdule/pkg/utils"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm/logger"
+)
+
+var watcherTestJobRuns int
+
+type watcherTestJob struct{}
+
+func (m *watcherTestJob) Execute(jobData map[string]string) {
+	watcherTestJobRuns++
+}
+
+func (m *watcherTestJob) JobName() string {
+	return "job.WatcherTestJob"
+}
+
+func (m *watcherTestJob) GetJobData() map[string]string {
+	return nil
+}
+
+func newTestWatcher(c *Cdule, consistency pkg.Consistency) *ScheduleWatcher {
+	return &ScheduleWatcher{
+		cdule:       c,
+		Consistency: consistency,
+		ctx:         context.Background(),
+		pool:        newExecutionPool(10, nil, true),
+	}
+}
+
+// runTestSchedules to run the schedules and wait for them to complete
+func runTestSchedules(watcher *ScheduleWatcher, schedules []model.Schedule) {
+	watcher.runScheduleJobs(schedules)
+	watcher.pool.wait()
+}
+
+// runTestNextSchedules to run the schedules due between start and end and wait for them to complete
+func runTestNextSchedules(watcher *ScheduleWatcher, start, end int64) {
+	watcher.runNextScheduleJobs(start, end)
+	watcher.pool.wait()
+}
+
+// newTestCdule to create a Cdule on a new database, without starting its watchers
+func newTestCdule(t *testing.T, workerID string) *Cdule {
+	repos, err := model.ConnectDataBase(&pkg.CduleConfig{
+		Cduletype: string(pkg.MEMORY),
+		Loglevel:  logger.Silent,
+	})
+	require.NoError(t, err)
+	c := newCdule(workerID, repos.CduleRepository)
+	c.repo.CreateWorker(&model.Worker{WorkerID: c.WorkerID})
+	return c
+}
+
+func setupWatcherTest(t *testing.T) (*Cdule, *model.Job, *model.Schedule) {
+	c := newTestCdule(t, "watcher-test-worker")
+	watcherTestJobRuns = 0
+
+	job, err := c.NewJob(&watcherTestJob{}, nil).Build(utils.EveryMinute)
+	require.NoError(t, err)
+	schedules, err := c.repo.GetSchedulesForJob(job.ID)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(schedules))
+	return c, job, &schedules[0]
+}
+
+func Test_RunScheduleJobsConsistency(t *testing.T) {
+	for _, consistency := range []pkg.Consistency{pkg.AT_MOST_ONCE, pkg.AT_LEAST_ONCE, pkg.EXACTLY_ONCE} {
+		t.Run(string(consistency), func(t *testing.T) {
+			c, job, schedule := setupWatcherTest(t)
+
+			runTestSchedules(newTestWatcher(c, consistency), []model.Schedule{*schedule})
+			require.Equal(t, 1, watcherTestJobRuns)
+			jobHistory, err := c.repo.GetJobHistoryForSchedule(schedule.ID)
+			require.NoError(t, err)
+			require.Equal(t, model.JobStatusCompleted, jobHistory.Status)
+			schedules, err := c.repo.GetSchedulesForJob(job.ID)
+			require.NoError(t, err)
+			require.Equal(t, 2, len(schedules))
+
+			// the same schedule must never run twice
+			runTestSchedules(newTestWatcher(c, consistency), []model.Schedule{*schedule})
+			require.Equal(t, 1, watcherTestJobRuns)
+			schedules, err = c.repo.GetSchedulesForJob(job.ID)
+			require.NoError(t, err)
+			require.Equal(t, 2, len(schedules))
+		})
+	}
+}
+
+// takenOverTestJob runs takenOverTestJobRun while it executes
+type takenOverTestJob struct{}
+
+var takenOverTestJobRun func()
+
+func (m *takenOverTestJob) Execute(ctx context.Context, data JobData) (JobResult, error) {
+	takenOverTestJobRun()
+	return JobResult{}, nil
+}
+
+func (m *takenOverTestJob) JobName() string {
+	return "job.TakenOverTestJob"
+}
+
+func Test_RunScheduleJobsTakenOver(t *testing.T) {
+	c, _, _ := setupWatcherTest(t)
+	job, err := c.NewJobV2(&takenOverTestJob{}, nil).Build(utils.EveryMinute)
+	require.NoError(t, err)
+	schedules, err := c.repo.GetSchedulesForJob(job.ID)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(schedules))
+	alive := model.Worker{WorkerID: "alive-worker"}
+	_, err = c.repo.CreateWorker(&alive)
+	require.NoError(t, err)
+
+	// another worker takes the run over while it is running, e.g. after this one missed its heartbeats
+	takenOverTestJobRun = func() {
+		_, err := takeOverSchedule(c.repo, schedules[0], []model.Worker{alive}, pkg.EXACTLY_ONCE, alive.WorkerID, false)
+		require.NoError(t, err)
+	}
+	runTestSchedules(newTestWatcher(c, pkg.EXACTLY_ONCE), schedules)
+
+	// the run is left to the worker which took it over, the next schedule is not created twice
+	jobHistory, err := c.repo.GetJobHistoryForSchedule(schedules[0].ID)
+	require.NoError(t, err)
+	require.Equal(t, model.JobStatusNew, jobHistory.Status)
+	require.Equal(t, 1, jobHistory.RetryCount)
+	pending, err := c.repo.GetSchedulesForJob(job.ID)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(pending))
+	require.Equal(t, alive.WorkerID, pending[0].WorkerID)
+}
+
+func Test_RecoverInterruptedJobs(t *testing.T) {
+	for consistency, expected := range map[pkg.Consistency]model.JobStatus{
+		pkg.AT_MOST_ONCE:  model.JobStatusFailed,
+		pkg.AT_LEAST_ONCE: model.JobStatusCompleted,
+		pkg.EXACTLY_ONCE:  model.JobStatusCompleted,
+	} {
+		t.Run(string(consistency), func(t *testing.T) {
+			c, job, schedule := setupWatcherTest(t)
+			_, err := c.repo.CreateJobHistory(&model.JobHistory{
+				JobID:      job.ID,
+				ScheduleID: schedule.ID,
+				Status:     model.JobStatusInProgress,
+				WorkerID:   c.WorkerID,
+			})
+			require.NoError(t, err)
+
+			interrupted := c.recoverInterruptedJobs(consistency)
+			runTestSchedules(newTestWatcher(c, consistency), interrupted)
+
+			jobHistory, err := c.repo.GetJobHistoryForSchedule(schedule.ID)
+			require.NoError(t, err)
+			require.Equal(t, expected, jobHistory.Status)
+			if expected == model.JobStatusCompleted {
+				require.Equal(t, 1, watcherTestJobRuns)
+				require.Equal(t, 1, jobHistory.RetryCount)
+			} else {
+				require.Equal(t, 0, watcherTestJobRuns)
+			}
+		})
+	}
+}
+
+func Test_ParseConsistency(t *testing.T) {
+	consistency, err := pkg.ParseConsistency("EXACTLY_ONCE")
+	require.NoError(t, err)
+	require.Equal(t, pkg.EXACTLY_ONCE, consistency)
+
+	_, err = pkg.ParseConsistency("AT_MOST_TWICE")
+	require.Error(t, err)
+
+	cfg := pkg.ResolveConfig(&pkg.CduleConfig{})
+	require.Equal(t, string(pkg.AT_MOST_ONCE), cfg.Cduleconsistency)
+}
+
+func Test_RunNextScheduleJobsClaimsOnce(t *testing.T) {
+	c, _, schedule := setupWatcherTest(t)
+	schedule.ExecutionID = time.Now().Add(-time.Second).UnixNano()
+	_, err := c.repo.UpdateSchedule(schedule)
+	require.NoError(t, err)
+
+	runTestNextSchedules(newTestWatcher(c, pkg.AT_LEAST_ONCE), schedule.ExecutionID, schedule.ExecutionID)
+	runTestNextSchedules(newTestWatcher(c, pkg.AT_LEAST_ONCE), schedule.ExecutionID, schedule.ExecutionID)
+	require.Equal(t, 1, watcherTestJobRuns)
+
+	claimed, err := c.repo.GetScheduleByID(schedule.ID)
+	require.NoError(t, err)
+	require.Equal(t, c.WorkerID, claimed.ClaimedBy)
+}
+
+type failingTestJob struct{}
+
+func (m *failingTestJob) Execute(ctx context.Context, data JobData) (JobResult, error) {
+	return JobResult{Output: "partial"}, errors.New("external service unavailable")
+}
+
+func (m *failingTestJob) JobName() string {
+	return "job.FailingTestJob"
+}
+
+type panickingTestJob struct{}
+
+func (m *panickingTestJob) Execute(jobData map[string]string) {
+	panic("boom")
+}
+
+func (m *panickingTestJob) JobName() string {
+	return "job.PanickingTestJob"
+}
+
+func (m *panickingTestJob) GetJobData() map[string]string {
+	return nil
+}
+
+func Test_RunScheduleJobsFailure(t *testing.T) {
+	c, _, _ := setupWatcherTest(t)
+	for abstractJob, expectedError := range map[*AbstractJob]string{
+		c.NewJobV2(&failingTestJob{}, nil): "external service unavailable",
+		c.NewJob(&panickingTestJob{}, nil): "job panicked: boom",
+	} {
+		job, err := abstractJob.BuildToRunNow()
+		require.NoError(t, err)
+		schedules, err := c.repo.GetSchedulesForJob(job.ID)
+		require.NoError(t, err)
+
+		runTestSchedules(newTestWatcher(c, pkg.AT_MOST_ONCE), schedules)
+
+		jobHistory, err := c.repo.GetJobHistoryForSchedule(schedules[0].ID)
+		require.NoError(t, err)
+		require.Equal(t, model.JobStatusFailed, jobHistory.Status)
+		require.Equal(t, expectedError, jobHistory.ErrorMessage)
+	}
+}
+
+var sleepingTestJobCancelled = make(chan error, 1)
+
+const sleepingTestJobLinger = 100 * time.Millisecond
+
+type sleepingTestJob struct{}
+
+func (m *sleepingTestJob) Execute(ctx context.Context, data JobData) (JobResult, error) {
+	<-ctx.Done()
+	sleepingTestJobCancelled <- ctx.Err()
+	// keeps running after cancellation, the watcher waits for it
+	time.Sleep(sleepingTestJobLinger)
+	return JobResult{}, nil
+}
+
+func (m *sleepingTestJob) JobName() string {
+	return "job.SleepingTestJob"
+}
+
+func Test_ExecuteJobTimeout(t *testing.T) {
+	start := time.Now()
+	_, err := executeJob(context.Background(), &sleepingTestJob{}, nil, 50*time.Millisecond)
+	require.True(t, errors.Is(err, ErrJobTimedOut))
+	require.Equal(t, context.DeadlineExceeded, <-sleepingTestJobCancelled)
+	// the run is not abandoned, it is timed out once the job returns
+	require.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond+sleepingTestJobLinger)
+
+	_, err = executeJob(context.Background(), &failingTestJob{}, nil, time.Second)
+	require.False(t, errors.Is(err, ErrJobTimedOut))
+}
+
+func Test_RunScheduleJobsTimeout(t *testing.T) {
+	c, _, _ := setupWatcherTest(t)
+
+	// per-job timeout
+	job, err := c.NewJobV2(&sleepingTestJob{}, nil).WithTimeout(50 * time.Millisecond).BuildToRunNow()
+	require.NoError(t, err)
+	require.Equal(t, 50*time.Millisecond, job.Timeout)
+	schedules, err := c.repo.GetSchedulesForJob(job.ID)
+	require.NoError(t, err)
+
+	runTestSchedules(newTestWatcher(c, pkg.AT_LEAST_ONCE), schedules)
+	<-sleepingTestJobCancelled
+
+	jobHistory, err := c.repo.GetJobHistoryForSchedule(schedules[0].ID)
+	require.NoError(t, err)
+	require.Equal(t, model.JobStatusTimedOut, jobHistory.Status)
+	require.Contains(t, jobHistory.ErrorMessage, ErrJobTimedOut.Error())
+
+	// global default timeout
+	job, err = c.NewJobV2(&sleepingTestJob{}, nil).BuildToRunNow()
+	require.NoError(t, err)
+	schedules, err = c.repo.GetSchedulesForJob(job.ID)
+	require.NoError(t, err)
+
+	watcher := newTestWatcher(c, pkg.AT_LEAST_ONCE)
+	watcher.JobTimeout = 50 * time.Millisecond
+	runTestSchedules(watcher, schedules)
+	<-sleepingTestJobCancelled
+
+	jobHistory, err = c.repo.GetJobHistoryForSchedule(schedules[0].ID)
+	require.NoError(t, err)
+	require.Equal(t, model.JobStatusTimedOut, jobHistory.Status)
+}
+
+var stoppingTestJobStarted = make(chan struct{}, 1)
+var stoppingTestJobDone int32
+
+type stoppingTestJob struct{}
+
+func (m *stoppingTestJob) Execute(ctx context.Context, data JobData) (JobResult, error) {
+	stoppingTestJobStarted <- struct{}{}
+	<-ctx.Done()
+	time.Sleep(20 * time.Millisecond)
+	atomic.StoreInt32(&stoppingTestJobDone, 1)
+	return JobResult{}, nil
+}
+
+func (m *stoppingTestJob) JobName() string {
+	return "job.StoppingTestJob"
+}
+
+func Test_StopWaitsForRuns(t *testing.T) {
+	c, _, _ := setupWatcherTest(t)
+	job, err := c.NewJobV2(&stoppingTestJob{}, nil).BuildToRunNow()
+	require.NoError(t, err)
+	schedules, err := c.repo.GetSchedulesForJob(job.ID)
+	require.NoError(t, err)
+
+	watcher := newTestWatcher(c, pkg.AT_LEAST_ONCE)
+	watcher.Closed = make(chan struct{})
+	watcher.ctx, watcher.cancel = context.WithCancel(context.Background())
+	watcher.runScheduleJobs(schedules)
+	<-stoppingTestJobStarted
+
+	watcher.Stop()
+	watcher.pool.wait()
+	require.Equal(t, int32(1), atomic.LoadInt32(&stoppingTestJobDone))
+	jobHistory, err := c.repo.GetJobHistoryForSchedule(schedules[0].ID)
+	require.NoError(t, err)
+	require.Equal(t, model.JobStatusCompleted, jobHistory.Status)
+}
+
+var overlapTestJobRuns int32
+var overlapTestJobStarted = make(chan struct{}, 1)
+
+// overlapTestJob blocks its first run until it is cancelled
+type overlapTestJob struct{}
+
+func (m *overlapTestJob) Execute(ctx context.Context, data JobData) (JobResult, error) {
+	if atomic.AddInt32(&overlapTestJobRuns, 1) > 1 {
+		return JobResult{}, nil
+	}
+	overlapTestJobStarted <- struct{}{}
+	<-ctx.Done()
+	return JobResult{}, ctx.Err()
+}
+
+func (m *overlapTestJob) JobName() string {
+	return "job.OverlapTestJob"
+}
+
+// setupOverlapTest to build a repeating job with the overlap policy, and a second schedule of it
+func setupOverlapTest(t *testing.T, policy model.OverlapPolicy) (*Cdule, *model.Job, []model.Schedule) {
+	c, _, _ := setupWatcherTest(t)
+	atomic.StoreInt32(&overlapTestJobRuns, 0)
+	job, err := c.NewJobV2(&overlapTestJob{}, nil).WithOverlap(policy).Build(utils.EveryMinute)
+	require.NoError(t, err)
+	require.Equal(t, policy, job.OverlapPolicy)
+	schedules, err := c.repo.GetSchedulesForJob(job.ID)
+	require.NoError(t, err)
+	second := &model.Schedule{JobID: job.ID, ExecutionID: schedules[0].ExecutionID + 1, WorkerID: c.WorkerID}
+	_, err = c.repo.CreateSchedule(second)
+	require.NoError(t, err)
+	return c, job, append(schedules, *second)
+}
+
+func Test_OverlapSkip(t *testing.T) {
+	c, job, schedules := setupOverlapTest(t, model.OverlapSkip)
+	// the first schedule is running on another worker
+	_, err := c.repo.CreateJobHistory(&model.JobHistory{
+		JobID: job.ID, ScheduleID: schedules[0].ID, Status: model.JobStatusInProgress, WorkerID: "other-worker",
+	})
+	require.NoError(t, err)
+
+	runTestSchedules(newTestWatcher(c, pkg.AT_LEAST_ONCE), schedules[1:])
+
+	require.Equal(t, int32(0), atomic.LoadInt32(&overlapTestJobRuns))
+	jobHistory, err := c.repo.GetJobHistoryForSchedule(schedules[1].ID)
+	require.NoError(t, err)
+	require.Equal(t, model.JobStatusSkipped, jobHistory.Status)
+	// the next run is scheduled as usual
+	all, err := c.repo.GetSchedulesForJob(job.ID)
+	require.NoError(t, err)
+	require.Equal(t, 3, len(all))
+}
+
+func Test_OverlapQueue(t *testing.T) {
+	c, job, schedules := setupOverlapTest(t, model.OverlapQueue)
+	_, err := c.repo.CreateJobHistory(&model.JobHistory{
+		JobID: job.ID, ScheduleID: schedules[0].ID, Status: model.JobStatusInProgress, WorkerID: "other-worker",
+	})
+	require.NoError(t, err)
+	ok, err := c.repo.ClaimSchedule(&schedules[1], c.WorkerID)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	runTestSchedules(newTestWatcher(c, pkg.AT_LEAST_ONCE), schedules[1:])
+
+	require.Equal(t, int32(0), atomic.LoadInt32(&overlapTestJobRuns))
+	jobHistory, err := c.repo.GetJobHistoryForSchedule(schedules[1].ID)
+	require.NoError(t, err)
+	require.Nil(t, jobHistory)
+	postponed, err := c.repo.GetScheduleByID(schedules[1].ID)
+	require.NoError(t, err)
+	require.Greater(t, postponed.ExecutionID, time.Now().UnixNano())
+	require.Equal(t, "", postponed.ClaimedBy)
+}
+
+func Test_OverlapReplace(t *testing.T) {
+	cancelPollInterval = 10 * time.Millisecond
+	defer func() { cancelPollInterval = 5 * time.Second }()
+	c, _, schedules := setupOverlapTest(t, model.OverlapReplace)
+
+	watcher := newTestWatcher(c, pkg.AT_LEAST_ONCE)
+	watcher.runScheduleJobs(schedules[:1])
+	<-overlapTestJobStarted
+	watcher.runScheduleJobs(schedules[1:])
+	watcher.pool.wait()
+
+	require.Equal(t, int32(2), atomic.LoadInt32(&overlapTestJobRuns))
+	jobHistory, err := c.repo.GetJobHistoryForSchedule(schedules[0].ID)
+	require.NoError(t, err)
+	require.Equal(t, model.JobStatusCancelled, jobHistory.Status)
+	jobHistory, err = c.repo.GetJobHistoryForSchedule(schedules[1].ID)
+	require.NoError(t, err)
+	require.Equal(t, model.JobStatusCompleted, jobHistory.Status)
+}
@@ -0,0 +1,302 @@
+package cdule
+
+import (
+	"context"
+	"time"
+
+	"github.com/gagasdiv/cdule/pkg/model"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName name of the tracer of the spans of cdule
+const tracerName = "github.com/gagasdiv/cdule"
+
+// getTracer to get the tracer of the cdule, the one of the global tracer provider when CduleConfig.TracerProvider is not set
+func (cdule *Cdule) getTracer() trace.Tracer {
+	if nil != cdule.tracer {
+		return cdule.tracer
+	}
+	return otel.GetTracerProvider().Tracer(tracerName)
+}
+
+// startRunSpan to start the span of a run of the schedule, its context is the one given to the job
+func (cdule *Cdule) startRunSpan(parent context.Context, job *model.Job, schedule model.Schedule) (context.Context, trace.Span) {
+	return cdule.getTracer().Start(parent, "cdule.run "+job.JobName, trace.WithAttributes(
+		attribute.String("cdule.job.name", job.JobName),
+		attribute.String("cdule.job.sub_name", job.SubName),
+		attribute.Int64("cdule.job.id", job.ID),
+		attribute.Int64("cdule.schedule.id", schedule.ID),
+		attribute.Int64("cdule.schedule.execution_id", schedule.ExecutionID),
+		attribute.String("cdule.worker.id", cdule.WorkerID),
+		attribute.Int("cdule.attempt", schedule.Attempt),
+	))
+}
+
+// endRunSpan to end the span of a run with the status of its job history, when it was claimed, and the error of the job
+func endRunSpan(span trace.Span, jobHistory *model.JobHistory, err error) {
+	if nil != jobHistory {
+		span.SetAttributes(attribute.String("cdule.job.status", string(jobHistory.Status)))
+	}
+	if nil != err {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// tracedRepository a repository recording a span, child of the span in ctx, for every call
+type tracedRepository struct {
+	repo   model.CduleRepository
+	ctx    context.Context
+	tracer trace.Tracer
+}
+
+// traceRepository to get the repository of the cdule recording its calls as children of the span in ctx
+func (cdule *Cdule) traceRepository(ctx context.Context) model.CduleRepository {
+	return tracedRepository{repo: cdule.repo, ctx: ctx, tracer: cdule.getTracer()}
+}
+
+func traced[T any](r tracedRepository, name string, fn func() (T, error)) (T, error) {
+	_, span := r.tracer.Start(r.ctx, "cdule.repository."+name)
+	defer span.End()
+	result, err := fn()
+	if nil != err {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return result, err
+}
+
+func (r tracedRepository) CreateWorker(worker *model.Worker) (*model.Worker, error) {
+	return traced(r, "CreateWorker", func() (*model.Worker, error) { return r.repo.CreateWorker(worker) })
+}
+
+func (r tracedRepository) UpdateWorker(worker *model.Worker) (*model.Worker, error) {
+	return traced(r, "UpdateWorker", func() (*model.Worker, error) { return r.repo.UpdateWorker(worker) })
+}
+
+func (r tracedRepository) GetWorker(workerID string) (*model.Worker, error) {
+	return traced(r, "GetWorker", func() (*model.Worker, error) { return r.repo.GetWorker(workerID) })
+}
+
+func (r tracedRepository) GetWorkers() ([]model.Worker, error) {
+	return traced(r, "GetWorkers", func() ([]model.Worker, error) { return r.repo.GetWorkers() })
+}
+
+func (r tracedRepository) GetAliveWorkers() ([]model.Worker, error) {
+	return traced(r, "GetAliveWorkers", func() ([]model.Worker, error) { return r.repo.GetAliveWorkers() })
+}
+
+func (r tracedRepository) DeleteWorker(workerID string) (*model.Worker, error) {
+	return traced(r, "DeleteWorker", func() (*model.Worker, error) { return r.repo.DeleteWorker(workerID) })
+}
+
+func (r tracedRepository) CreateJob(job *model.Job) (*model.Job, error) {
+	return traced(r, "CreateJob", func() (*model.Job, error) { return r.repo.CreateJob(job) })
+}
+
+func (r tracedRepository) UpdateJob(job *model.Job) (*model.Job, error) {
+	return traced(r, "UpdateJob", func() (*model.Job, error) { return r.repo.UpdateJob(job) })
+}
+
+func (r tracedRepository) SaveJob(job *model.Job) (*model.Job, error) {
+	return traced(r, "SaveJob", func() (*model.Job, error) { return r.repo.SaveJob(job) })
+}
+
+func (r tracedRepository) GetJob(jobID int64) (*model.Job, error) {
+	return traced(r, "GetJob", func() (*model.Job, error) { return r.repo.GetJob(jobID) })
+}
+
+func (r tracedRepository) GetJobs() ([]model.Job, error) {
+	return traced(r, "GetJobs", func() ([]model.Job, error) { return r.repo.GetJobs() })
+}
+
+func (r tracedRepository) GetJobByName(name string) (*model.Job, error) {
+	return traced(r, "GetJobByName", func() (*model.Job, error) { return r.repo.GetJobByName(name) })
+}
+
+func (r tracedRepository) GetRepeatingJobByName(name string) (*model.Job, error) {
+	return traced(r, "GetRepeatingJobByName", func() (*model.Job, error) { return r.repo.GetRepeatingJobByName(name) })
+}
+
+func (r tracedRepository) LockJob(jobID int64) (*model.Job, error) {
+	return traced(r, "LockJob", func() (*model.Job, error) { return r.repo.LockJob(jobID) })
+}
+
+func (r tracedRepository) DeleteJob(jobID int64) (*model.Job, error) {
+	return traced(r, "DeleteJob", func() (*model.Job, error) { return r.repo.DeleteJob(jobID) })
+}
+
+func (r tracedRepository) CreateJobHistory(jobHistory *model.JobHistory) (*model.JobHistory, error) {
+	return traced(r, "CreateJobHistory", func() (*model.JobHistory, error) { return r.repo.CreateJobHistory(jobHistory) })
+}
+
+func (r tracedRepository) UpdateJobHistory(jobHistory *model.JobHistory) (*model.JobHistory, error) {
+	return traced(r, "UpdateJobHistory", func() (*model.JobHistory, error) { return r.repo.UpdateJobHistory(jobHistory) })
+}
+
+func (r tracedRepository) GetJobHistory(jobID int64) ([]model.JobHistory, error) {
+	return traced(r, "GetJobHistory", func() ([]model.JobHistory, error) { return r.repo.GetJobHistory(jobID) })
+}
+
+func (r tracedRepository) GetJobHistoryWithLimit(jobID int64, limit int) ([]model.JobHistory, error) {
+	return traced(r, "GetJobHistoryWithLimit", func() ([]model.JobHistory, error) { return r.repo.GetJobHistoryWithLimit(jobID, limit) })
+}
+
+func (r tracedRepository) FindJobHistory(filter model.JobHistoryFilter) ([]model.JobHistory, error) {
+	return traced(r, "FindJobHistory", func() ([]model.JobHistory, error) { return r.repo.FindJobHistory(filter) })
+}
+
+func (r tracedRepository) GetJobHistoryForSchedule(scheduleID int64) (*model.JobHistory, error) {
+	return traced(r, "GetJobHistoryForSchedule", func() (*model.JobHistory, error) { return r.repo.GetJobHistoryForSchedule(scheduleID) })
+}
+
+func (r tracedRepository) GetJobHistoryForWorker(workerID string, statuses []model.JobStatus) ([]model.JobHistory, error) {
+	return traced(r, "GetJobHistoryForWorker", func() ([]model.JobHistory, error) { return r.repo.GetJobHistoryForWorker(workerID, statuses) })
+}
+
+func (r tracedRepository) GetRunningJobHistory(jobID int64) ([]model.JobHistory, error) {
+	return traced(r, "GetRunningJobHistory", func() ([]model.JobHistory, error) { return r.repo.GetRunningJobHistory(jobID) })
+}
+
+func (r tracedRepository) RequestJobHistoryCancel(jobHistoryIDs []int64) error {
+	_, err := traced(r, "RequestJobHistoryCancel", func() (struct{}, error) { return struct{}{}, r.repo.RequestJobHistoryCancel(jobHistoryIDs) })
+	return err
+}
+
+func (r tracedRepository) IsJobHistoryCancelRequested(jobHistoryID int64) (bool, error) {
+	return traced(r, "IsJobHistoryCancelRequested", func() (bool, error) { return r.repo.IsJobHistoryCancelRequested(jobHistoryID) })
+}
+
+func (r tracedRepository) UpdateJobHistoryStatus(jobHistory *model.JobHistory, from model.JobStatus) (bool, error) {
+	return traced(r, "UpdateJobHistoryStatus", func() (bool, error) { return r.repo.UpdateJobHistoryStatus(jobHistory, from) })
+}
+
+func (r tracedRepository) DeleteJobHistory(jobID int64) ([]model.JobHistory, error) {
+	return traced(r, "DeleteJobHistory", func() ([]model.JobHistory, error) { return r.repo.DeleteJobHistory(jobID) })
+}
+
+func (r tracedRepository) PurgeJobHistory(policy model.RetentionPolicy) (int64, error) {
+	return traced(r, "PurgeJobHistory", func() (int64, error) { return r.repo.PurgeJobHistory(policy) })
+}
+
+func (r tracedRepository) CreateJobChange(jobChange *model.JobChange) (*model.JobChange, error) {
+	return traced(r, "CreateJobChange", func() (*model.JobChange, error) { return r.repo.CreateJobChange(jobChange) })
+}
+
+func (r tracedRepository) GetJobChanges(jobID int64) ([]model.JobChange, error) {
+	return traced(r, "GetJobChanges", func() ([]model.JobChange, error) { return r.repo.GetJobChanges(jobID) })
+}
+
+func (r tracedRepository) CreateSchedule(schedule *model.Schedule) (*model.Schedule, error) {
+	return traced(r, "CreateSchedule", func() (*model.Schedule, error) { return r.repo.CreateSchedule(schedule) })
+}
+
+func (r tracedRepository) UpdateSchedule(schedule *model.Schedule) (*model.Schedule, error) {
+	return traced(r, "UpdateSchedule", func() (*model.Schedule, error) { return r.repo.UpdateSchedule(schedule) })
+}
+
+func (r tracedRepository) GetSchedule(executionID int64) (*model.Schedule, error) {
+	return traced(r, "GetSchedule", func() (*model.Schedule, error) { return r.repo.GetSchedule(executionID) })
+}
+
+func (r tracedRepository) GetScheduleByID(scheduleID int64) (*model.Schedule, error) {
+	return traced(r, "GetScheduleByID", func() (*model.Schedule, error) { return r.repo.GetScheduleByID(scheduleID) })
+}
+
+func (r tracedRepository) GetScheduleBetween(scheduleStart, scheduleEnd int64, workerID string) ([]model.Schedule, error) {
+	return traced(r, "GetScheduleBetween", func() ([]model.Schedule, error) {
+		return r.repo.GetScheduleBetween(scheduleStart, scheduleEnd, workerID)
+	})
+}
+
+func (r tracedRepository) GetScheduleBefore(nanoUnix int64, workerID string) ([]model.Schedule, error) {
+	return traced(r, "GetScheduleBefore", func() ([]model.Schedule, error) { return r.repo.GetScheduleBefore(nanoUnix, workerID) })
+}
+
+func (r tracedRepository) GetPassedSchedule(nanoUnix int64, workerID string, onlyOnces bool) ([]model.Schedule, error) {
+	return traced(r, "GetPassedSchedule", func() ([]model.Schedule, error) { return r.repo.GetPassedSchedule(nanoUnix, workerID, onlyOnces) })
+}
+
+func (r tracedRepository) GetClaimedScheduleWithoutHistory(workerID string) ([]model.Schedule, error) {
+	return traced(r, "GetClaimedScheduleWithoutHistory", func() ([]model.Schedule, error) { return r.repo.GetClaimedScheduleWithoutHistory(workerID) })
+}
+
+func (r tracedRepository) ClaimSchedule(schedule *model.Schedule, workerID string) (bool, error) {
+	return traced(r, "ClaimSchedule", func() (bool, error) { return r.repo.ClaimSchedule(schedule, workerID) })
+}
+
+func (r tracedRepository) ClaimScheduleBetween(scheduleStart, scheduleEnd int64, workerID string) ([]model.Schedule, error) {
+	return traced(r, "ClaimScheduleBetween", func() ([]model.Schedule, error) {
+		return r.repo.ClaimScheduleBetween(scheduleStart, scheduleEnd, workerID)
+	})
+}
+
+func (r tracedRepository) GetOrphanedSchedules(aliveWorkerIDs []string) ([]model.Schedule, error) {
+	return traced(r, "GetOrphanedSchedules", func() ([]model.Schedule, error) { return r.repo.GetOrphanedSchedules(aliveWorkerIDs) })
+}
+
+func (r tracedRepository) ReassignSchedule(schedule *model.Schedule, fromWorkerID string) (bool, error) {
+	return traced(r, "ReassignSchedule", func() (bool, error) { return r.repo.ReassignSchedule(schedule, fromWorkerID) })
+}
+
+func (r tracedRepository) PostponeSchedule(schedule *model.Schedule) (*model.Schedule, error) {
+	return traced(r, "PostponeSchedule", func() (*model.Schedule, error) { return r.repo.PostponeSchedule(schedule) })
+}
+
+func (r tracedRepository) GetSchedulesForJob(jobID int64) ([]model.Schedule, error) {
+	return traced(r, "GetSchedulesForJob", func() ([]model.Schedule, error) { return r.repo.GetSchedulesForJob(jobID) })
+}
+
+func (r tracedRepository) GetPendingSchedules(jobID int64, limit int) ([]model.Schedule, error) {
+	return traced(r, "GetPendingSchedules", func() ([]model.Schedule, error) { return r.repo.GetPendingSchedules(jobID, limit) })
+}
+
+func (r tracedRepository) GetSchedulesForWorker(workerID string) ([]model.Schedule, error) {
+	return traced(r, "GetSchedulesForWorker", func() ([]model.Schedule, error) { return r.repo.GetSchedulesForWorker(workerID) })
+}
+
+func (r tracedRepository) GetSchedulesForJobName(jobName string, subName string) ([]model.Schedule, error) {
+	return traced(r, "GetSchedulesForJobName", func() ([]model.Schedule, error) { return r.repo.GetSchedulesForJobName(jobName, subName) })
+}
+
+func (r tracedRepository) DeleteScheduleForJob(jobID int64) ([]model.Schedule, error) {
+	return traced(r, "DeleteScheduleForJob", func() ([]model.Schedule, error) { return r.repo.DeleteScheduleForJob(jobID) })
+}
+
+func (r tracedRepository) DeleteScheduleForWorker(workerID string) ([]model.Schedule, error) {
+	return traced(r, "DeleteScheduleForWorker", func() ([]model.Schedule, error) { return r.repo.DeleteScheduleForWorker(workerID) })
+}
+
+func (r tracedRepository) DeleteScheduleForJobName(jobName string, subName string) ([]model.Schedule, error) {
+	return traced(r, "DeleteScheduleForJobName", func() ([]model.Schedule, error) { return r.repo.DeleteScheduleForJobName(jobName, subName) })
+}
+
+func (r tracedRepository) GetWorkerCountByJobID(jobID int64) ([]model.WorkerJobCount, error) {
+	return traced(r, "GetWorkerCountByJobID", func() ([]model.WorkerJobCount, error) { return r.repo.GetWorkerCountByJobID(jobID) })
+}
+
+func (r tracedRepository) GetPendingScheduleCount() ([]model.WorkerJobCount, error) {
+	return traced(r, "GetPendingScheduleCount", func() ([]model.WorkerJobCount, error) { return r.repo.GetPendingScheduleCount() })
+}
+
+func (r tracedRepository) PurgeDeleted(before time.Time) (int64, error) {
+	return traced(r, "PurgeDeleted", func() (int64, error) { return r.repo.PurgeDeleted(before) })
+}
+
+func (r tracedRepository) Transaction(fc func(repo model.CduleRepository) error) error {
+	ctx, span := r.tracer.Start(r.ctx, "cdule.repository.Transaction")
+	defer span.End()
+	err := r.repo.Transaction(func(repo model.CduleRepository) error {
+		return fc(tracedRepository{repo: repo, ctx: ctx, tracer: r.tracer})
+	})
+	if nil != err {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
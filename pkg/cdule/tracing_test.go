@@ -0,0 +1,101 @@
+package cdule
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gagasdiv/cdule/pkg"
+	"github.com/gagasdiv/cdule/pkg/model"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracingTestJobSpan trace.SpanContext
+
+type tracingTestJob struct{}
+
+func (m *tracingTestJob) Execute(ctx context.Context, data JobData) (JobResult, error) {
+	tracingTestJobSpan = trace.SpanContextFromContext(ctx)
+	return JobResult{}, nil
+}
+
+func (m *tracingTestJob) JobName() string {
+	return "job.TracingTestJob"
+}
+
+func newTracedTestCdule(t *testing.T) (*Cdule, *tracetest.InMemoryExporter) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	c := newTestCdule(t, "tracing-test-worker")
+	c.tracer = provider.Tracer(tracerName)
+	return c, exporter
+}
+
+// runSpan to get the span of the run of the job, and the spans of its repository calls
+func runSpan(t *testing.T, exporter *tracetest.InMemoryExporter, jobName string) (tracetest.SpanStub, []tracetest.SpanStub) {
+	var run *tracetest.SpanStub
+	spans := exporter.GetSpans()
+	for i := range spans {
+		if spans[i].Name == "cdule.run "+jobName {
+			run = &spans[i]
+		}
+	}
+	require.NotNil(t, run)
+	children := make([]tracetest.SpanStub, 0)
+	for _, span := range spans {
+		if span.Parent.SpanID() == run.SpanContext.SpanID() {
+			children = append(children, span)
+		}
+	}
+	return *run, children
+}
+
+func Test_TracingRunSpan(t *testing.T) {
+	c, exporter := newTracedTestCdule(t)
+	job, err := c.NewJobV2(&tracingTestJob{}, nil).BuildToRunNow()
+	require.NoError(t, err)
+	schedules, err := c.repo.GetSchedulesForJob(job.ID)
+	require.NoError(t, err)
+
+	runTestSchedules(newTestWatcher(c, pkg.AT_MOST_ONCE), schedules)
+
+	run, children := runSpan(t, exporter, "job.TracingTestJob")
+	require.Equal(t, run.SpanContext.TraceID(), tracingTestJobSpan.TraceID())
+	require.Equal(t, run.SpanContext.SpanID(), tracingTestJobSpan.SpanID())
+	require.Subset(t, run.Attributes, []attribute.KeyValue{
+		attribute.String("cdule.job.name", "job.TracingTestJob"),
+		attribute.Int64("cdule.schedule.id", schedules[0].ID),
+		attribute.Int64("cdule.schedule.execution_id", schedules[0].ExecutionID),
+		attribute.String("cdule.worker.id", "tracing-test-worker"),
+		attribute.Int("cdule.attempt", 0),
+		attribute.String("cdule.job.status", string(model.JobStatusCompleted)),
+	})
+	require.Equal(t, codes.Unset, run.Status.Code)
+
+	names := make([]string, 0, len(children))
+	for _, child := range children {
+		names = append(names, child.Name)
+	}
+	require.Contains(t, names, "cdule.repository.Transaction")
+	require.Contains(t, names, "cdule.repository.UpdateJobHistory")
+}
+
+func Test_TracingFailedRun(t *testing.T) {
+	c, exporter := newTracedTestCdule(t)
+	job, err := c.NewJobV2(&failingTestJob{}, nil).BuildToRunNow()
+	require.NoError(t, err)
+	schedules, err := c.repo.GetSchedulesForJob(job.ID)
+	require.NoError(t, err)
+
+	runTestSchedules(newTestWatcher(c, pkg.AT_MOST_ONCE), schedules)
+
+	run, _ := runSpan(t, exporter, "job.FailingTestJob")
+	require.Equal(t, codes.Error, run.Status.Code)
+	require.Equal(t, "external service unavailable", run.Status.Description)
+	require.Contains(t, run.Attributes, attribute.String("cdule.job.status", string(model.JobStatusFailed)))
+}
@@ -4,6 +4,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/gagasdiv/cdule/pkg"
 	"github.com/gagasdiv/cdule/pkg/model"
 
 	log "github.com/sirupsen/logrus"
@@ -11,9 +12,14 @@ import (
 
 // WorkerWatcher struct
 type WorkerWatcher struct {
+	cdule  *Cdule
 	Closed chan struct{}
 	WG     sync.WaitGroup
 	Ticker *time.Ticker
+	// Consistency used to decide what happens to the runs interrupted on a dead worker
+	Consistency pkg.Consistency
+	// workers alive at the previous tick, by worker id, nil until the first one
+	aliveWorkers map[string]model.Worker
 }
 
 // Run to run watcher in a continuous loop
@@ -23,7 +29,9 @@ func (t *WorkerWatcher) Run() {
 		case <-t.Closed:
 			return
 		case <-t.Ticker.C:
-			healthCheckUpdate()
+			t.healthCheckUpdate()
+			t.reapDeadWorkers()
+			t.watchWorkers()
 		}
 	}
 }
@@ -34,16 +42,143 @@ func (t *WorkerWatcher) Stop() {
 	t.WG.Wait()
 }
 
-func healthCheckUpdate() {
-	worker, err := model.CduleRepos.CduleRepository.GetWorker(WorkerID)
+func (t *WorkerWatcher) healthCheckUpdate() {
+	worker, err := t.cdule.repo.GetWorker(t.cdule.WorkerID)
 	if nil != err {
 		log.Errorf("Error getting workder %s ", err.Error())
 	}
 	if nil != worker {
 		worker.UpdatedAt = time.Now()
-		model.CduleRepos.CduleRepository.UpdateWorker(worker)
-		log.Debugf("Health check updated for worker_id %s updated", WorkerID)
+		t.cdule.repo.UpdateWorker(worker)
+		log.Debugf("Health check updated for worker_id %s updated", t.cdule.WorkerID)
 		return
 	}
-	log.Warningf("Health check update failed for worker_id %s", WorkerID)
+	log.Warningf("Health check update failed for worker_id %s", t.cdule.WorkerID)
+}
+
+// watchWorkers to report to the listeners the workers which joined or were lost since the previous tick,
+// the first tick only records the alive workers
+func (t *WorkerWatcher) watchWorkers() {
+	workers, err := t.cdule.repo.GetAliveWorkers()
+	if nil != err {
+		log.Errorf("Error getting alive workers %s ", err.Error())
+		return
+	}
+	alive := make(map[string]model.Worker, len(workers))
+	for _, worker := range workers {
+		alive[worker.WorkerID] = worker
+	}
+	previous := t.aliveWorkers
+	t.aliveWorkers = alive
+	if nil == previous {
+		return
+	}
+	for workerID, worker := range alive {
+		if _, ok := previous[workerID]; !ok {
+			event := t.cdule.workerEvent(worker)
+			t.cdule.notify(func(listener Listener) { listener.OnWorkerJoined(event) })
+		}
+	}
+	for workerID, worker := range previous {
+		if _, ok := alive[workerID]; !ok {
+			log.Warnf("Worker %s stopped sending heartbeats", workerID)
+			event := t.cdule.workerEvent(worker)
+			t.cdule.notify(func(listener Listener) { listener.OnWorkerLost(event) })
+		}
+	}
+}
+
+// reapDeadWorkers to take over the unfinished schedules of workers which stopped sending heartbeats.
+// Every schedule is reassigned to an alive worker. A run which was interrupted on the dead worker is marked
+// FAILED for AT_MOST_ONCE, and re-run right away by the new worker otherwise. The misfire policy of their job
+// applies to the overdue schedules which never ran, whether the past schedules are watched or not.
+func (t *WorkerWatcher) reapDeadWorkers() {
+	workers, err := t.cdule.repo.GetAliveWorkers()
+	if nil != err {
+		log.Errorf("Error getting alive workers %s ", err.Error())
+		return
+	}
+	if len(workers) == 0 {
+		return
+	}
+	aliveWorkerIDs := make([]string, 0, len(workers))
+	for _, worker := range workers {
+		aliveWorkerIDs = append(aliveWorkerIDs, worker.WorkerID)
+	}
+	schedules, err := t.cdule.repo.GetOrphanedSchedules(aliveWorkerIDs)
+	if nil != err {
+		log.Errorf("Error getting schedules of dead workers %s ", err.Error())
+		return
+	}
+	watchPast := nil != t.cdule.PastScheduleWatcher
+	for _, schedule := range schedules {
+		var outcome *misfireOutcome
+		err = t.cdule.repo.Transaction(func(repo model.CduleRepository) error {
+			var err error
+			outcome, err = takeOverSchedule(repo, schedule, workers, t.Consistency, t.cdule.WorkerID, watchPast)
+			return err
+		})
+		if nil != err {
+			log.Errorf("Error taking over schedule %d from worker %s : %s", schedule.ID, schedule.WorkerID, err.Error())
+			continue
+		}
+		if nil != outcome {
+			t.cdule.notifyMisfire(outcome)
+		}
+	}
+}
+
+// takeOverSchedule to reassign a schedule of a dead worker, returns what the misfire policy of its job did when
+// it was overdue and never ran
+func takeOverSchedule(repo model.CduleRepository, schedule model.Schedule, workers []model.Worker,
+	consistency pkg.Consistency, workerID string, watchPast bool) (*misfireOutcome, error) {
+	deadWorkerID := schedule.WorkerID
+	jobHistory, err := repo.GetJobHistoryForSchedule(schedule.ID)
+	if nil != err {
+		return nil, err
+	}
+	if nil != jobHistory {
+		from := jobHistory.Status
+		if from == model.JobStatusInProgress && consistency == pkg.AT_MOST_ONCE {
+			jobHistory.Status = model.JobStatusFailed
+			if _, err = repo.UpdateJobHistoryStatus(jobHistory, from); nil != err {
+				return nil, err
+			}
+			log.Warnf("Job history %d of schedule %d was interrupted on dead worker %s, marked as %s",
+				jobHistory.ID, schedule.ID, deadWorkerID, jobHistory.Status)
+			return nil, nil
+		}
+		if from == model.JobStatusInProgress {
+			jobHistory.RetryCount++
+		}
+		jobHistory.Status = model.JobStatusNew
+		claimed, err := repo.UpdateJobHistoryStatus(jobHistory, from)
+		if nil != err || !claimed {
+			return nil, err
+		}
+	}
+
+	schedule.WorkerID, _ = findNextAvailableWorker(repo, workers, schedule)
+	// an interrupted run is re-run right away
+	now := time.Now()
+	if nil != jobHistory && schedule.ExecutionID < now.UnixNano() {
+		schedule.ExecutionID = now.UnixNano()
+	}
+	reassigned, err := repo.ReassignSchedule(&schedule, deadWorkerID)
+	if nil != err || !reassigned {
+		return nil, err
+	}
+	log.Warnf("Schedule %d of job %d taken over from dead worker %s by worker %s, next execution %d",
+		schedule.ID, schedule.JobID, deadWorkerID, schedule.WorkerID, schedule.ExecutionID)
+	if nil != jobHistory || schedule.ExecutionID >= now.UnixNano() {
+		return nil, nil
+	}
+
+	// a schedule missed by the dead worker is handled by the misfire policy of its job, the schedules of a paused
+	// job are handled when it is resumed
+	job, err := repo.GetJob(schedule.JobID)
+	if nil != err || nil == job || job.Paused {
+		return nil, err
+	}
+	return applyMisfire(repo, job, schedule, workers, workerID, now, watchPast)
 }
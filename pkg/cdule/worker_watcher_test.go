@@ -0,0 +1,110 @@
+package cdule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gagasdiv/cdule/pkg"
+	"github.com/gagasdiv/cdule/pkg/model"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ReapDeadWorkers(t *testing.T) {
+	for consistency, expected := range map[pkg.Consistency]model.JobStatus{
+		pkg.AT_MOST_ONCE:  model.JobStatusFailed,
+		pkg.AT_LEAST_ONCE: model.JobStatusNew,
+	} {
+		t.Run(string(consistency), func(t *testing.T) {
+			c, job, schedule := setupWatcherTest(t)
+			deadWorker := &model.Worker{
+				WorkerID:  "dead-worker",
+				CreatedAt: time.Now().Add(-time.Hour),
+				UpdatedAt: time.Now().Add(-time.Hour),
+			}
+			_, err := c.repo.CreateWorker(deadWorker)
+			require.NoError(t, err)
+
+			// an interrupted run and a pending schedule on the dead worker
+			schedule.WorkerID = deadWorker.WorkerID
+			schedule.ExecutionID = time.Now().Add(-time.Minute).UnixNano()
+			_, err = c.repo.UpdateSchedule(schedule)
+			require.NoError(t, err)
+			_, err = c.repo.CreateJobHistory(&model.JobHistory{
+				JobID:      job.ID,
+				ScheduleID: schedule.ID,
+				Status:     model.JobStatusInProgress,
+				WorkerID:   deadWorker.WorkerID,
+			})
+			require.NoError(t, err)
+			pending := &model.Schedule{
+				ExecutionID: time.Now().Add(time.Hour).UnixNano(),
+				JobID:       job.ID,
+				WorkerID:    deadWorker.WorkerID,
+			}
+			_, err = c.repo.CreateSchedule(pending)
+			require.NoError(t, err)
+
+			(&WorkerWatcher{cdule: c, Consistency: consistency}).reapDeadWorkers()
+
+			pending, err = c.repo.GetScheduleByID(pending.ID)
+			require.NoError(t, err)
+			require.Equal(t, c.WorkerID, pending.WorkerID)
+			require.Equal(t, deadWorker.WorkerID, pending.ReassignedFrom)
+
+			jobHistory, err := c.repo.GetJobHistoryForSchedule(schedule.ID)
+			require.NoError(t, err)
+			require.Equal(t, expected, jobHistory.Status)
+			interrupted, err := c.repo.GetScheduleByID(schedule.ID)
+			require.NoError(t, err)
+			if expected == model.JobStatusNew {
+				require.Equal(t, c.WorkerID, interrupted.WorkerID)
+				require.GreaterOrEqual(t, interrupted.ExecutionID, schedule.ExecutionID)
+				require.Equal(t, 1, jobHistory.RetryCount)
+			} else {
+				require.Equal(t, deadWorker.WorkerID, interrupted.WorkerID)
+			}
+		})
+	}
+}
+
+func Test_ReapDeadWorkersMisfire(t *testing.T) {
+	for name, test := range map[string]struct {
+		job      func(*AbstractJob) *AbstractJob
+		expected model.JobStatus
+	}{
+		"default":    {job: misfire(""), expected: model.JobStatusCompleted},
+		"skip":       {job: misfire(model.MisfireSkip), expected: model.JobStatusSkipped},
+		"not within": {job: misfireWithin(time.Minute), expected: model.JobStatusSkipped},
+	} {
+		t.Run(name, func(t *testing.T) {
+			// no past schedule watcher runs, as when the past schedules are not watched
+			c, job, schedule := setupMisfireTest(t, test.job, false)
+			deadWorker := &model.Worker{
+				WorkerID:  "dead-worker",
+				CreatedAt: time.Now().Add(-time.Hour),
+				UpdatedAt: time.Now().Add(-time.Hour),
+			}
+			_, err := c.repo.CreateWorker(deadWorker)
+			require.NoError(t, err)
+			// an overdue schedule which the dead worker never ran
+			schedule.WorkerID = deadWorker.WorkerID
+			_, err = c.repo.UpdateSchedule(&schedule)
+			require.NoError(t, err)
+
+			start := time.Now().UnixNano()
+			(&WorkerWatcher{cdule: c, Consistency: pkg.AT_LEAST_ONCE}).reapDeadWorkers()
+			runTestNextSchedules(newTestWatcher(c, pkg.AT_LEAST_ONCE), start, time.Now().UnixNano())
+			jobHistory, err := c.repo.GetJobHistoryForSchedule(schedule.ID)
+			require.NoError(t, err)
+			require.NotNil(t, jobHistory)
+			require.Equal(t, test.expected, jobHistory.Status)
+
+			// the next occurrence of the job is scheduled from now
+			pending, err := c.repo.GetPendingSchedules(job.ID, 0)
+			require.NoError(t, err)
+			require.Equal(t, 1, len(pending))
+			require.Greater(t, pending[0].ExecutionID, time.Now().UnixNano())
+		})
+	}
+}
@@ -1,14 +1,61 @@
 package pkg
 
-// CType type MEMORY or DATABASE
+import "fmt"
+
+// CType type MEMORY, DATABASE, SQLITE or REDIS
 type CType string
 
 const (
-	// MEMORY type for in memory based on sqlite
+	// MEMORY type keeping everything in the memory of the process, without any database. It used to be backed
+	// by a sqlite database of the Dburl, a config with a Dburl is rejected, SQLITE is that type now.
 	MEMORY CType = "MEMORY"
 	// DATABASE type based on different types of db
 	DATABASE CType = "DATABASE"
+	// SQLITE type based on a sqlite database file, needs a binary built with cgo
+	SQLITE CType = "SQLITE"
+	// REDIS type based on redis, without a relational database
+	REDIS CType = "REDIS"
 
 	// EMPTYSTRING string
 	EMPTYSTRING = ""
 )
+
+// Dialect SQL dialect of a caller supplied *sql.DB, see CduleConfig.SQLDB
+type Dialect string
+
+const (
+	// DialectPostgres dialect of postgres
+	DialectPostgres Dialect = "postgres"
+	// DialectMySQL dialect of mysql
+	DialectMySQL Dialect = "mysql"
+	// DialectSQLite dialect of sqlite
+	DialectSQLite Dialect = "sqlite"
+)
+
+// Consistency delivery guarantee of a scheduled job execution
+type Consistency string
+
+const (
+	// AT_MOST_ONCE a run is claimed and its next schedule committed before the job executes,
+	// a run interrupted by a crash is marked FAILED and never re-run
+	AT_MOST_ONCE Consistency = "AT_MOST_ONCE"
+	// AT_LEAST_ONCE the run status is committed after the job executes,
+	// a run interrupted by a crash is re-run when the worker comes back
+	AT_LEAST_ONCE Consistency = "AT_LEAST_ONCE"
+	// EXACTLY_ONCE a run is claimed only if no other run exists for its schedule, and its status
+	// and next schedule are committed in one transaction after the job executes, unless another
+	// worker took the run over meanwhile. It is not a guarantee that the job executes once: the
+	// claim is checked then inserted, without a unique constraint, and a run is re-run when its
+	// worker crashes or merely misses its heartbeats, so the job must be idempotent for a schedule
+	EXACTLY_ONCE Consistency = "EXACTLY_ONCE"
+)
+
+// ParseConsistency to validate a cduleconsistency config value
+func ParseConsistency(value string) (Consistency, error) {
+	switch consistency := Consistency(value); consistency {
+	case AT_MOST_ONCE, AT_LEAST_ONCE, EXACTLY_ONCE:
+		return consistency, nil
+	}
+	return EMPTYSTRING, fmt.Errorf("unknown cduleconsistency %#v, expected one of %s, %s or %s",
+		value, AT_MOST_ONCE, AT_LEAST_ONCE, EXACTLY_ONCE)
+}
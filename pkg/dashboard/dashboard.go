@@ -0,0 +1,48 @@
+// Package dashboard serves a web UI to browse the jobs, upcoming runs, job histories and workers of a cdule scheduler
+// and to trigger, pause, resume or cancel its jobs. The page and its scripts are embedded in the binary, and the UI
+// reads and acts through the admin API, which the handler serves under /api. Like the admin API it has no
+// authentication of its own, it is meant to be mounted in the router of a service behind its auth middleware:
+//
+//	mux.Handle("/dashboard/", http.StripPrefix("/dashboard", dashboard.NewHandler(c)))
+package dashboard
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+	"strings"
+
+	"github.com/gagasdiv/cdule/pkg/admin"
+	"github.com/gagasdiv/cdule/pkg/cdule"
+)
+
+//go:embed static
+var static embed.FS
+
+// Handler the http.Handler of the dashboard of a Cdule, the page at / and the admin API at /api
+type Handler struct {
+	api   http.Handler
+	files http.Handler
+}
+
+// NewHandler to get the dashboard of c. c can be started before or after.
+func NewHandler(c *cdule.Cdule) *Handler {
+	files, err := fs.Sub(static, "static")
+	if nil != err {
+		// the directory is embedded, it is always there
+		panic(err)
+	}
+	return &Handler{
+		api:   http.StripPrefix("/api", admin.NewHandler(c)),
+		files: http.FileServer(http.FS(files)),
+	}
+}
+
+// ServeHTTP to serve the page of the dashboard, its scripts, or a request of the admin API
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/api" || strings.HasPrefix(r.URL.Path, "/api/") {
+		h.api.ServeHTTP(w, r)
+		return
+	}
+	h.files.ServeHTTP(w, r)
+}
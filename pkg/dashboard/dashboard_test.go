@@ -0,0 +1,42 @@
+package dashboard
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gagasdiv/cdule/pkg"
+	"github.com/gagasdiv/cdule/pkg/cdule"
+	"github.com/gagasdiv/cdule/pkg/model"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Dashboard(t *testing.T) {
+	c, err := cdule.NewWithRepository(model.NewMemoryRepository(), "dashboard-test-worker", &pkg.CduleConfig{TickDuration: "1h"})
+	require.NoError(t, err)
+	t.Cleanup(c.StopWatcher)
+	mux := http.NewServeMux()
+	mux.Handle("/dashboard/", http.StripPrefix("/dashboard", NewHandler(c)))
+
+	for path, contentType := range map[string]string{
+		"/dashboard/":              "text/html; charset=utf-8",
+		"/dashboard/dashboard.js":  "text/javascript; charset=utf-8",
+		"/dashboard/dashboard.css": "text/css; charset=utf-8",
+		"/dashboard/api/workers":   "application/json",
+	} {
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		require.Equal(t, http.StatusOK, recorder.Code, path)
+		require.Equal(t, contentType, recorder.Header().Get("Content-Type"), path)
+		require.NotEmpty(t, recorder.Body.String(), path)
+	}
+
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/dashboard/missing.js", nil))
+	require.Equal(t, http.StatusNotFound, recorder.Code)
+	recorder = httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/dashboard/api/unknown", nil))
+	require.Equal(t, http.StatusNotFound, recorder.Code)
+	require.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+}
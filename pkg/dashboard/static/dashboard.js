@@ -0,0 +1,202 @@
+// The dashboard of a cdule scheduler, reading and acting through the admin API served under api/
+"use strict";
+
+const STATUSES = ["NEW", "IN_PROGRESS", "COMPLETED", "FAILED", "RETRYING", "TIMED_OUT", "SKIPPED", "CANCELLED"];
+const HISTORY_LIMIT = 100;
+
+let jobNames = new Map();
+let refreshTimer = null;
+
+// api to call the admin API, relative to the page so that the dashboard works under any prefix
+async function api(method, path) {
+  const response = await fetch("api/" + path, {method: method, headers: {Accept: "application/json"}});
+  if (response.status === 204) {
+    return null;
+  }
+  const body = await response.json();
+  if (!response.ok) {
+    throw new Error(method + " " + path + ": " + (body.error || response.statusText));
+  }
+  return body;
+}
+
+// el to create an element with attributes and children, text children are escaped
+function el(tag, attributes, ...children) {
+  const element = document.createElement(tag);
+  for (const [name, value] of Object.entries(attributes || {})) {
+    if (name === "onclick") {
+      element.addEventListener("click", value);
+    } else if (value !== undefined && value !== null && value !== false) {
+      element.setAttribute(name, value);
+    }
+  }
+  for (const child of children) {
+    if (child !== undefined && child !== null) {
+      element.append(child);
+    }
+  }
+  return element;
+}
+
+function formatTime(value) {
+  return new Date(value).toLocaleString();
+}
+
+function jobName(jobID) {
+  return jobNames.get(jobID) || "job " + jobID;
+}
+
+function showError(err) {
+  const error = document.getElementById("error");
+  error.textContent = err ? err.message : "";
+  error.hidden = !err;
+}
+
+function fill(id, rows, columns, empty) {
+  const tbody = document.getElementById(id);
+  tbody.replaceChildren(...rows);
+  if (rows.length === 0) {
+    tbody.append(el("tr", {}, el("td", {colspan: columns, class: "empty"}, empty)));
+  }
+}
+
+// act to run an action on a job or on all the jobs, then reload the dashboard
+async function act(method, path, confirmation) {
+  if (confirmation && !window.confirm(confirmation)) {
+    return;
+  }
+  try {
+    await api(method, path);
+    await load();
+  } catch (err) {
+    showError(err);
+  }
+}
+
+function renderWorkers(workers) {
+  const items = workers.map((worker) => el("li", {
+    class: worker.alive ? "alive" : "dead",
+    title: "last heartbeat " + formatTime(worker.updated_at),
+  }, worker.worker_id + (worker.alive ? " alive" : " dead")));
+  document.getElementById("workers").replaceChildren(...items);
+}
+
+function renderJobs(jobs) {
+  const rows = jobs.map((job) => {
+    const path = "jobs/" + job.id;
+    const state = [];
+    if (job.paused) {
+      state.push(el("span", {class: "badge paused"}, "paused"));
+    }
+    if (job.expired) {
+      state.push(el("span", {class: "badge expired"}, "expired"));
+    }
+    const nextRuns = (job.next_runs || []).map((run) => el("div", {}, formatTime(run)));
+    const actions = [];
+    if (!job.expired) {
+      actions.push(el("button", {type: "button", onclick: () => act("POST", path + "/trigger")}, "Trigger"));
+      actions.push(job.paused
+        ? el("button", {type: "button", onclick: () => act("POST", path + "/resume")}, "Resume")
+        : el("button", {type: "button", onclick: () => act("POST", path + "/pause")}, "Pause"));
+      actions.push(el("button", {
+        type: "button",
+        class: "danger",
+        onclick: () => act("DELETE", path, "Cancel " + job.job_name + " " + job.sub_name + "?"),
+      }, "Cancel"));
+    }
+    return el("tr", {},
+      el("td", {}, String(job.id)),
+      el("td", {}, job.job_name),
+      el("td", {}, job.sub_name),
+      el("td", {}, job.cron ? el("code", {}, job.cron + (job.time_zone ? " (" + job.time_zone + ")" : "")) : "once"),
+      el("td", {}, ...state),
+      el("td", {}, ...nextRuns),
+      el("td", {}, ...actions));
+  });
+  fill("jobs", rows, 7, "No jobs");
+}
+
+function renderJobFilter(jobs) {
+  const select = document.getElementById("history-job");
+  const selected = select.value;
+  const options = jobs.map((job) => el("option", {value: String(job.id)}, job.job_name + " " + job.sub_name));
+  select.replaceChildren(el("option", {value: ""}, "all"), ...options);
+  select.value = jobNames.has(Number(selected)) ? selected : "";
+}
+
+function renderSchedules(schedules) {
+  const rows = schedules.map((schedule) => el("tr", {},
+    el("td", {}, formatTime(schedule.execution_time)),
+    el("td", {}, jobName(schedule.job_id)),
+    el("td", {}, schedule.worker_id),
+    el("td", {}, String(schedule.attempt)),
+    el("td", {}, schedule.triggered ? el("span", {class: "badge status-NEW"}, "triggered") : null)));
+  fill("schedules", rows, 5, "No upcoming runs");
+}
+
+// renderTimeline to place the runs on a line from the oldest to now, coloured by status
+function renderTimeline(jobHistories) {
+  const timeline = document.getElementById("timeline");
+  const now = Date.now();
+  const times = jobHistories.map((jobHistory) => new Date(jobHistory.created_at).getTime());
+  const start = Math.min(now - 60 * 1000, ...times);
+  const marks = jobHistories.map((jobHistory, i) => el("span", {
+    class: "status-" + jobHistory.status,
+    style: "left: " + (100 * (times[i] - start) / (now - start)).toFixed(2) + "%",
+    title: jobName(jobHistory.job_id) + " " + jobHistory.status + " " + formatTime(jobHistory.created_at),
+  }));
+  timeline.replaceChildren(...marks);
+}
+
+function renderHistory(jobHistories) {
+  renderTimeline(jobHistories);
+  const rows = jobHistories.map((jobHistory) => el("tr", {},
+    el("td", {}, formatTime(jobHistory.created_at)),
+    el("td", {}, jobName(jobHistory.job_id)),
+    el("td", {}, el("span", {class: "badge status-" + jobHistory.status}, jobHistory.status)),
+    el("td", {}, jobHistory.worker_id),
+    el("td", {}, String(jobHistory.retry_count)),
+    el("td", {}, jobHistory.error_message || jobHistory.output || "")));
+  fill("history", rows, 6, "No runs");
+}
+
+async function load() {
+  const next = document.getElementById("next").value;
+  const historyJob = document.getElementById("history-job").value;
+  try {
+    const [workers, types, jobs, schedules, history] = await Promise.all([
+      api("GET", "workers"),
+      api("GET", "types"),
+      api("GET", "jobs?next=" + next),
+      api("GET", "schedules"),
+      api("GET", "history?limit=" + HISTORY_LIMIT + (historyJob ? "&job_id=" + historyJob : "")),
+    ]);
+    jobNames = new Map(jobs.items.map((job) => [job.id, job.job_name + " " + job.sub_name]));
+    renderWorkers(workers.items);
+    document.getElementById("types").textContent = types.items.join(", ") || "none";
+    renderJobs(jobs.items);
+    renderJobFilter(jobs.items);
+    renderSchedules(schedules.items);
+    renderHistory(history.items);
+    document.getElementById("updated").textContent = "Updated " + new Date().toLocaleTimeString();
+    showError(null);
+  } catch (err) {
+    showError(err);
+  }
+}
+
+function scheduleRefresh() {
+  clearInterval(refreshTimer);
+  const seconds = Number(document.getElementById("refresh").value);
+  refreshTimer = seconds > 0 ? setInterval(load, seconds * 1000) : null;
+}
+
+document.getElementById("legend").replaceChildren(...STATUSES.map((status) => el("li", {class: "status-" + status}, status)));
+document.getElementById("reload").addEventListener("click", load);
+document.getElementById("pause-all").addEventListener("click", () => act("POST", "jobs/pause", "Pause all the jobs?"));
+document.getElementById("resume-all").addEventListener("click", () => act("POST", "jobs/resume", "Resume all the paused jobs?"));
+document.getElementById("next").addEventListener("change", load);
+document.getElementById("history-job").addEventListener("change", load);
+document.getElementById("refresh").addEventListener("change", scheduleRefresh);
+scheduleRefresh();
+load();
@@ -0,0 +1,135 @@
+// Package metrics exports the metrics of a cdule scheduler to Prometheus: the runs of the jobs by name and status,
+// their duration, the lag of their start behind their execution time, the pending schedules of each worker, the
+// alive workers and the duration of the ticks of the watchers.
+package metrics
+
+import (
+	"time"
+
+	"github.com/gagasdiv/cdule/pkg/cdule"
+	"github.com/gagasdiv/cdule/pkg/model"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// Namespace prefix of the metric names
+const Namespace = "cdule"
+
+// Collector records the metrics of a Cdule, it is a cdule.Listener of the runs and ticks, and reads the pending
+// schedules and alive workers from the repository of the Cdule when the metrics are scraped
+type Collector struct {
+	cdule.NopListener
+
+	cdule      *cdule.Cdule
+	executions *prometheus.CounterVec
+	duration   *prometheus.HistogramVec
+	lag        *prometheus.HistogramVec
+	ticks      *prometheus.HistogramVec
+	pending    *prometheus.Desc
+	alive      *prometheus.Desc
+}
+
+// NewCollector to register the metrics of c with registerer, e.g. prometheus.DefaultRegisterer, and add the collector
+// as a listener of c. c can be started before or after.
+func NewCollector(c *cdule.Cdule, registerer prometheus.Registerer) (*Collector, error) {
+	collector := &Collector{
+		cdule: c,
+		executions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "job_executions_total",
+			Help:      "Number of the finished runs of the jobs, by job name and status.",
+		}, []string{"job_name", "status"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: Namespace,
+			Name:      "job_execution_duration_seconds",
+			Help:      "Duration of the runs of the jobs, by job name and status.",
+			Buckets:   prometheus.ExponentialBuckets(0.01, 4, 10),
+		}, []string{"job_name", "status"}),
+		lag: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: Namespace,
+			Name:      "schedule_lag_seconds",
+			Help:      "Delay between the execution time of a schedule and the start of its run, by job name.",
+			Buckets:   prometheus.ExponentialBuckets(0.1, 4, 10),
+		}, []string{"job_name"}),
+		ticks: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: Namespace,
+			Name:      "tick_duration_seconds",
+			Help:      "Duration of the ticks of the watchers, claiming the due schedules and handing them to the pool.",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 4, 10),
+		}, []string{"watcher"}),
+		pending: prometheus.NewDesc(prometheus.BuildFQName(Namespace, "", "pending_schedules"),
+			"Number of the schedules which have not started to run, by worker.", []string{"worker_id"}, nil),
+		alive: prometheus.NewDesc(prometheus.BuildFQName(Namespace, "", "alive_workers"),
+			"Number of the workers sending heartbeats.", nil, nil),
+	}
+	for _, metric := range []prometheus.Collector{collector.executions, collector.duration, collector.lag, collector.ticks, collector} {
+		if err := registerer.Register(metric); nil != err {
+			return nil, err
+		}
+	}
+	c.AddListener(collector)
+	return collector, nil
+}
+
+// Describe to describe the metrics read from the repository, see prometheus.Collector
+func (m *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- m.pending
+	ch <- m.alive
+}
+
+// Collect to read the pending schedules and alive workers from the repository, see prometheus.Collector
+func (m *Collector) Collect(ch chan<- prometheus.Metric) {
+	repo := m.cdule.Repository()
+	if nil == repo {
+		return
+	}
+	counts, err := repo.GetPendingScheduleCount()
+	if nil != err {
+		log.Errorf("Error counting pending schedules %s ", err.Error())
+	}
+	for _, count := range counts {
+		ch <- prometheus.MustNewConstMetric(m.pending, prometheus.GaugeValue, float64(count.Count), count.WorkerID)
+	}
+	workers, err := repo.GetAliveWorkers()
+	if nil != err {
+		log.Errorf("Error getting alive workers %s ", err.Error())
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(m.alive, prometheus.GaugeValue, float64(len(workers)))
+}
+
+// OnJobStarted to record the lag of the start of the run behind the execution time of its schedule
+func (m *Collector) OnJobStarted(event cdule.JobEvent) {
+	lag := event.Time.Sub(time.Unix(0, event.Schedule.ExecutionID))
+	if lag < 0 {
+		lag = 0
+	}
+	m.lag.WithLabelValues(event.Job.JobName).Observe(lag.Seconds())
+}
+
+// OnJobCompleted to count the run and record its duration
+func (m *Collector) OnJobCompleted(event cdule.JobEvent) {
+	m.observeRun(event)
+}
+
+// OnJobFailed to count the run and record its duration
+func (m *Collector) OnJobFailed(event cdule.JobEvent) {
+	m.observeRun(event)
+}
+
+// OnJobSkipped to count the run, which did not start
+func (m *Collector) OnJobSkipped(event cdule.JobEvent) {
+	m.executions.WithLabelValues(event.Job.JobName, string(model.JobStatusSkipped)).Inc()
+}
+
+// OnTick to record the duration of the tick
+func (m *Collector) OnTick(event cdule.TickEvent) {
+	m.ticks.WithLabelValues(event.Watcher).Observe(event.Duration.Seconds())
+}
+
+func (m *Collector) observeRun(event cdule.JobEvent) {
+	status := string(event.JobHistory.Status)
+	m.executions.WithLabelValues(event.Job.JobName, status).Inc()
+	m.duration.WithLabelValues(event.Job.JobName, status).Observe(event.Duration.Seconds())
+}
@@ -0,0 +1,72 @@
+package metrics
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gagasdiv/cdule/pkg"
+	"github.com/gagasdiv/cdule/pkg/cdule"
+	"github.com/gagasdiv/cdule/pkg/model"
+	"github.com/gagasdiv/cdule/pkg/utils"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+type metricsTestJob struct{}
+
+func (m *metricsTestJob) Execute(ctx context.Context, data cdule.JobData) (cdule.JobResult, error) {
+	return cdule.JobResult{}, nil
+}
+
+func (m *metricsTestJob) JobName() string {
+	return "job.MetricsTestJob"
+}
+
+func newTestCollector(t *testing.T) (*cdule.Cdule, *Collector, *prometheus.Registry) {
+	c, err := cdule.NewWithRepository(model.NewMemoryRepository(), "metrics-test-worker", &pkg.CduleConfig{TickDuration: "1h"})
+	require.NoError(t, err)
+	t.Cleanup(c.StopWatcher)
+	registry := prometheus.NewRegistry()
+	collector, err := NewCollector(c, registry)
+	require.NoError(t, err)
+	return c, collector, registry
+}
+
+func Test_CollectorRepositoryMetrics(t *testing.T) {
+	c, _, registry := newTestCollector(t)
+	_, err := c.NewJobV2(&metricsTestJob{}, nil).Build(utils.EveryMinute)
+	require.NoError(t, err)
+
+	err = testutil.GatherAndCompare(registry, strings.NewReader(`
+# HELP cdule_alive_workers Number of the workers sending heartbeats.
+# TYPE cdule_alive_workers gauge
+cdule_alive_workers 1
+# HELP cdule_pending_schedules Number of the schedules which have not started to run, by worker.
+# TYPE cdule_pending_schedules gauge
+cdule_pending_schedules{worker_id="metrics-test-worker"} 1
+`), "cdule_alive_workers", "cdule_pending_schedules")
+	require.NoError(t, err)
+}
+
+func Test_CollectorRunMetrics(t *testing.T) {
+	_, collector, registry := newTestCollector(t)
+	job := &model.Job{JobName: "job.MetricsTestJob"}
+	schedule := &model.Schedule{ExecutionID: time.Now().Add(-2 * time.Second).UnixNano()}
+
+	collector.OnJobStarted(cdule.JobEvent{Time: time.Now(), Job: job, Schedule: schedule})
+	collector.OnJobCompleted(cdule.JobEvent{Job: job, JobHistory: &model.JobHistory{Status: model.JobStatusCompleted}, Duration: time.Second})
+	collector.OnJobFailed(cdule.JobEvent{Job: job, JobHistory: &model.JobHistory{Status: model.JobStatusTimedOut}, Duration: time.Minute})
+	collector.OnJobSkipped(cdule.JobEvent{Job: job})
+	collector.OnTick(cdule.TickEvent{Watcher: "schedule", Duration: time.Millisecond})
+
+	require.Equal(t, 1.0, testutil.ToFloat64(collector.executions.WithLabelValues("job.MetricsTestJob", "COMPLETED")))
+	require.Equal(t, 1.0, testutil.ToFloat64(collector.executions.WithLabelValues("job.MetricsTestJob", "TIMED_OUT")))
+	require.Equal(t, 1.0, testutil.ToFloat64(collector.executions.WithLabelValues("job.MetricsTestJob", "SKIPPED")))
+	count, err := testutil.GatherAndCount(registry, "cdule_job_execution_duration_seconds", "cdule_schedule_lag_seconds", "cdule_tick_duration_seconds")
+	require.NoError(t, err)
+	require.Equal(t, 4, count)
+}
@@ -18,6 +18,81 @@ const (
 	JobStatusCompleted JobStatus = "COMPLETED"
 	// JobStatusFailed status FAILED
 	JobStatusFailed JobStatus = "FAILED"
+	// JobStatusRetrying status RETRYING, the run failed and a retry is scheduled
+	JobStatusRetrying JobStatus = "RETRYING"
+	// JobStatusTimedOut status TIMED_OUT, the run exceeded its timeout
+	JobStatusTimedOut JobStatus = "TIMED_OUT"
+	// JobStatusSkipped status SKIPPED, the run did not start because the previous run was still in progress
+	JobStatusSkipped JobStatus = "SKIPPED"
+	// JobStatusCancelled status CANCELLED, the run was cancelled by a newer run
+	JobStatusCancelled JobStatus = "CANCELLED"
+)
+
+// FinishedJobStatuses statuses of the runs which are over, the ones the job histories can be purged in
+var FinishedJobStatuses = []JobStatus{JobStatusCompleted, JobStatusFailed, JobStatusTimedOut, JobStatusSkipped, JobStatusCancelled}
+
+// RetentionPolicy which job histories PurgeJobHistory deletes: the ones in one of Statuses which were created
+// before Before, or which are not among the KeepPerJob latest job histories of their job
+type RetentionPolicy struct {
+	// No job history is purged by age when zero
+	Before time.Time
+	// No job history is purged by count when 0
+	KeepPerJob int
+	Statuses   []JobStatus
+}
+
+// JobHistoryFilter which job histories FindJobHistory gets, the latest first. The zero fields do not filter.
+type JobHistoryFilter struct {
+	JobID    int64
+	WorkerID string
+	Statuses []JobStatus
+	// created at or after Since
+	Since time.Time
+	// created before Until
+	Until  time.Time
+	Offset int
+	// No limit when 0
+	Limit int
+}
+
+// MisfirePolicy what to do with a schedule which was not run at its execution time, e.g. while the workers were down.
+// It applies to the schedules held while their job was paused when it is resumed, and to those of a dead worker
+// when they are taken over, otherwise only when the past schedules are watched, see pkg.CduleConfig.WatchPast.
+type MisfirePolicy string
+
+const (
+	// MisfireFireNow runs the missed schedule once as soon as possible, the next run is calculated from now. The default
+	MisfireFireNow MisfirePolicy = "FIRE_NOW"
+	// MisfireFireAll runs every missed occurrence of a repeating job, one after the other
+	MisfireFireAll MisfirePolicy = "FIRE_ALL"
+	// MisfireSkip does not run the missed schedule, it is recorded as SKIPPED and the next run is calculated from now
+	MisfireSkip MisfirePolicy = "SKIP"
+	// MisfireFireIfWithin runs the missed schedule if it is late by at most the misfire threshold of the job, skips it otherwise
+	MisfireFireIfWithin MisfirePolicy = "FIRE_IF_WITHIN"
+)
+
+// OverlapPolicy what to do with a run of a job while its previous run is still in progress
+type OverlapPolicy string
+
+const (
+	// OverlapAllow runs concurrently with the previous run, the default
+	OverlapAllow OverlapPolicy = "ALLOW"
+	// OverlapSkip does not start the run, it is recorded as SKIPPED
+	OverlapSkip OverlapPolicy = "SKIP"
+	// OverlapQueue postpones the run until the previous run has finished
+	OverlapQueue OverlapPolicy = "QUEUE"
+	// OverlapReplace cancels the previous run and starts the new one
+	OverlapReplace OverlapPolicy = "REPLACE"
+)
+
+// Backoff strategy between the attempts of a job
+type Backoff string
+
+const (
+	// BackoffFixed waits RetryPolicy.Interval between attempts
+	BackoffFixed Backoff = "FIXED"
+	// BackoffExponential doubles the wait after every attempt, starting at RetryPolicy.Interval
+	BackoffExponential Backoff = "EXPONENTIAL"
 )
 
 // Model common model
@@ -31,34 +106,85 @@ type Model struct {
 // Job struct
 type Job struct {
 	Model
-	JobName        string `gorm:"index" json:"job_name"`
-	SubName        string `json:"sub_name"`
-	CronExpression string `json:"cron"`
-	Expired        bool   `json:"expired"`
-	Once           bool   `json:"once"`
-	JobData        string `json:"job_data"`
+	JobName        string      `gorm:"index" json:"job_name"`
+	SubName        string      `json:"sub_name"`
+	CronExpression string      `json:"cron"`
+	Expired        bool        `json:"expired"`
+	Once           bool        `json:"once"`
+	JobData        string      `json:"job_data"`
+	RetryPolicy    RetryPolicy `gorm:"embedded;embeddedPrefix:retry_" json:"retry_policy"`
+	// IANA time zone the cron expression is evaluated in, the local time zone of the worker when empty
+	TimeZone string `json:"time_zone"`
+	// Timeout of a run, the global JobTimeout is used when 0
+	Timeout time.Duration `json:"timeout"`
+	// What to do with a run while the previous one is in progress, OverlapAllow when empty
+	OverlapPolicy OverlapPolicy `json:"overlap_policy"`
+	// What to do with a schedule missed at its execution time, MisfireFireNow when empty
+	MisfirePolicy MisfirePolicy `json:"misfire_policy"`
+	// Maximum lateness of a schedule still run with MisfireFireIfWithin
+	MisfireThreshold time.Duration `json:"misfire_threshold"`
+	// Paused jobs keep their schedules, which are held until the job is resumed
+	Paused bool `gorm:"default:false" json:"paused"`
+}
+
+// RetryPolicy how a failed run of a job is retried, no retry when MaxAttempts is 1 or less
+type RetryPolicy struct {
+	// Total number of attempts, including the first run
+	MaxAttempts int           `json:"max_attempts"`
+	Backoff     Backoff       `json:"backoff"`
+	Interval    time.Duration `json:"interval"`
+	// Upper bound of the wait between attempts, no bound when 0
+	MaxInterval time.Duration `json:"max_interval"`
+	// Fraction (0 to 1) of the wait which is randomly added or removed
+	Jitter float64 `json:"jitter"`
 }
 
 // Schedule used by Execution Routine to execute a scheduled job in the evert one minute duration
 type Schedule struct {
 	Model
-	ExecutionID int64 `json:"execution_id"`
-	JobID       int64 `json:"job_id"`
-	Job         Job   `gorm:"foreignKey:job_id;references:id;constraint:OnDelete:CASCADE"`
-	WorkerID    string         `json:"worker_id"`
-	JobData     string         `json:"job_data"`
+	ExecutionID int64  `json:"execution_id"`
+	JobID       int64  `json:"job_id"`
+	Job         Job    `gorm:"foreignKey:job_id;references:id;constraint:OnDelete:CASCADE"`
+	WorkerID    string `json:"worker_id"`
+	JobData     string `json:"job_data"`
+	// Attempt of the run, 0 for the first run and incremented for every retry
+	Attempt int `json:"attempt"`
+	// Worker which claimed the schedule for execution, empty until the schedule is due
+	ClaimedBy string     `gorm:"index;default:''" json:"claimed_by"`
+	ClaimedAt *time.Time `json:"claimed_at"`
+	// Dead worker the schedule was taken over from, if any
+	ReassignedFrom string `json:"reassigned_from"`
+	// Run triggered by hand, the next schedule of a repeating job is not calculated from it
+	Triggered bool `gorm:"default:false" json:"triggered"`
 }
 
 // JobHistory struct
 type JobHistory struct {
 	Model
-	JobID       int64          `json:"job_id"`
-	Job         Job       `gorm:"foreignKey:job_id;references:id;constraint:OnDelete:CASCADE"`
-	ScheduleID  int64          `json:"schedule_id"`
-	Schedule    Schedule  `gorm:"foreignKey:schedule_id;references:id;constraint:OnDelete:CASCADE"`
-	Status      JobStatus      `json:"status"`
-	WorkerID    string         `json:"worker_id"`
-	RetryCount  int            `json:"retry_count"`
+	JobID      int64     `json:"job_id"`
+	Job        Job       `gorm:"foreignKey:job_id;references:id;constraint:OnDelete:CASCADE"`
+	ScheduleID int64     `json:"schedule_id"`
+	Schedule   Schedule  `gorm:"foreignKey:schedule_id;references:id;constraint:OnDelete:CASCADE"`
+	Status     JobStatus `json:"status"`
+	WorkerID   string    `json:"worker_id"`
+	RetryCount int       `json:"retry_count"`
+	// Output of the run, as returned by the job
+	Output string `json:"output"`
+	// Error message of a FAILED run
+	ErrorMessage string `json:"error_message"`
+	// Set by a newer run of a job with the OverlapReplace policy, polled by the worker running this one
+	CancelRequested bool `gorm:"default:false" json:"cancel_requested"`
+}
+
+// JobChange a change of a job made by UpdateJob, the job keeps its ID and its job histories
+type JobChange struct {
+	Model
+	JobID int64 `gorm:"index" json:"job_id"`
+	Job   Job   `gorm:"foreignKey:job_id;references:id;constraint:OnDelete:CASCADE"`
+	// Worker, or client, which made the change
+	WorkerID string `json:"worker_id"`
+	// Changed fields of the job, a JSON object of the field names to their "from" and "to" values
+	Changes string `json:"changes"`
 }
 
 // Worker Node health check via the heartbeat
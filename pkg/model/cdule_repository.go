@@ -2,13 +2,21 @@ package model
 
 import (
 	"fmt"
+	"math"
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"github.com/gagasdiv/cdule/pkg"
 )
 
+// ErrNotFound error of a record which does not exist
+var ErrNotFound = gorm.ErrRecordNotFound
+
+// ErrDuplicateKey error of a record created with the key of an existing one
+var ErrDuplicateKey = gorm.ErrDuplicatedKey
+
 type cduleRepository struct {
 	DB    *gorm.DB
 	Heart time.Duration
@@ -22,7 +30,9 @@ func NewCduleRepository(db *gorm.DB) CduleRepository {
 	}
 }
 
-// CduleRepository cdule repository interface
+// CduleRepository cdule repository interface, the storage of the workers, jobs, schedules and job histories.
+// NewCduleRepository stores them in a SQL database with gorm, NewMemoryRepository in memory. Another storage
+// can be used by implementing it, see Transaction for the atomicity expected from it.
 type CduleRepository interface {
 	CreateWorker(worker *Worker) (*Worker, error)
 	UpdateWorker(worker *Worker) (*Worker, error)
@@ -35,16 +45,29 @@ type CduleRepository interface {
 	UpdateJob(job *Job) (*Job, error)
 	SaveJob(job *Job) (*Job, error)
 	GetJob(jobID int64) (*Job, error)
+	GetJobs() ([]Job, error)
 	GetJobByName(name string) (*Job, error)
 	GetRepeatingJobByName(name string) (*Job, error)
+	LockJob(jobID int64) (*Job, error)
 	DeleteJob(jobID int64) (*Job, error)
 
 	CreateJobHistory(jobHistory *JobHistory) (*JobHistory, error)
 	UpdateJobHistory(jobHistory *JobHistory) (*JobHistory, error)
 	GetJobHistory(jobID int64) ([]JobHistory, error)
 	GetJobHistoryWithLimit(jobID int64, limit int) ([]JobHistory, error)
+	FindJobHistory(filter JobHistoryFilter) ([]JobHistory, error)
 	GetJobHistoryForSchedule(scheduleID int64) (*JobHistory, error)
+	GetJobHistoryForWorker(workerID string, statuses []JobStatus) ([]JobHistory, error)
+	GetRunningJobHistory(jobID int64) ([]JobHistory, error)
+	RequestJobHistoryCancel(jobHistoryIDs []int64) error
+	IsJobHistoryCancelRequested(jobHistoryID int64) (bool, error)
+	UpdateJobHistoryStatus(jobHistory *JobHistory, from JobStatus) (bool, error)
 	DeleteJobHistory(jobID int64) ([]JobHistory, error)
+	PurgeJobHistory(policy RetentionPolicy) (int64, error)
+
+	CreateJobChange(jobChange *JobChange) (*JobChange, error)
+	// GetJobChanges to get the changes of jobID, in the order they were made
+	GetJobChanges(jobID int64) ([]JobChange, error)
 
 	CreateSchedule(schedule *Schedule) (*Schedule, error)
 	UpdateSchedule(schedule *Schedule) (*Schedule, error)
@@ -53,7 +76,16 @@ type CduleRepository interface {
 	GetScheduleBetween(scheduleStart, scheduleEnd int64, workerID string) ([]Schedule, error)
 	GetScheduleBefore(nanoUnix int64, workerID string) ([]Schedule, error)
 	GetPassedSchedule(nanoUnix int64, workerID string, onlyOnces bool) ([]Schedule, error)
+	GetClaimedScheduleWithoutHistory(workerID string) ([]Schedule, error)
+	ClaimSchedule(schedule *Schedule, workerID string) (bool, error)
+	ClaimScheduleBetween(scheduleStart, scheduleEnd int64, workerID string) ([]Schedule, error)
+	GetOrphanedSchedules(aliveWorkerIDs []string) ([]Schedule, error)
+	ReassignSchedule(schedule *Schedule, fromWorkerID string) (bool, error)
+	PostponeSchedule(schedule *Schedule) (*Schedule, error)
 	GetSchedulesForJob(jobID int64) ([]Schedule, error)
+	// GetPendingSchedules to get the schedules of jobID, or of all the jobs when 0, which have not started to run,
+	// by execution time, at most limit of them when it is not 0
+	GetPendingSchedules(jobID int64, limit int) ([]Schedule, error)
 	GetSchedulesForWorker(workerID string) ([]Schedule, error)
 	GetSchedulesForJobName(jobName string, subName string) ([]Schedule, error)
 	DeleteScheduleForJob(jobID int64) ([]Schedule, error)
@@ -61,6 +93,16 @@ type CduleRepository interface {
 	DeleteScheduleForJobName(jobName string, subName string) ([]Schedule, error)
 
 	GetWorkerCountByJobID(jobID int64) ([]WorkerJobCount, error)
+	// GetPendingScheduleCount to count the schedules which have not started to run, by worker
+	GetPendingScheduleCount() ([]WorkerJobCount, error)
+
+	// PurgeDeleted to delete for good the rows which were soft-deleted before, returns how many were
+	PurgeDeleted(before time.Time) (int64, error)
+
+	// Transaction to run fc with a repository whose writes are all kept when fc returns nil and all undone
+	// otherwise. The conditional writes (ClaimSchedule, UpdateJobHistoryStatus, ...) and LockJob must be
+	// atomic across the workers sharing the storage.
+	Transaction(fc func(repo CduleRepository) error) error
 }
 
 // CreateWorker to create a worker
@@ -159,6 +201,15 @@ func (c cduleRepository) GetJob(jobID int64) (*Job, error) {
 	return &job, nil
 }
 
+// GetJobs to get all the jobs, by ID
+func (c cduleRepository) GetJobs() ([]Job, error) {
+	var jobs []Job
+	if err := c.DB.Order("id asc").Find(&jobs).Error; err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
 // GetJobByName to get a job based on Name
 func (c cduleRepository) GetJobByName(jobName string) (*Job, error) {
 	var job Job
@@ -183,6 +234,23 @@ func (c cduleRepository) GetRepeatingJobByName(jobName string) (*Job, error) {
 	return &job, nil
 }
 
+// LockJob to get a job by ID, locking its row until the end of the transaction so that decisions about
+// the runs of the job are serialised across workers
+func (c cduleRepository) LockJob(jobID int64) (*Job, error) {
+	var job Job
+	query := c.DB.Where("id = ?", jobID)
+	if supportsRowLocking(c.DB) {
+		query = query.Clauses(clause.Locking{Strength: "UPDATE"})
+	}
+	if err := query.Find(&job).Error; err != nil {
+		return nil, err
+	}
+	if job.ID == 0 {
+		return nil, nil
+	}
+	return &job, nil
+}
+
 // DeleteJob to get a job based on ID
 func (c cduleRepository) DeleteJob(jobID int64) (*Job, error) {
 	var job Job
@@ -229,27 +297,198 @@ func (c cduleRepository) GetJobHistoryWithLimit(jobID int64, limit int) ([]JobHi
 	return jobHistories, nil
 }
 
-// GetJobHistoryForSchedule to get a JobHistory by scheduleID
+// FindJobHistory to get the job histories matching filter, the latest first
+func (c cduleRepository) FindJobHistory(filter JobHistoryFilter) ([]JobHistory, error) {
+	var jobHistories []JobHistory
+	query := c.DB.Order("id desc")
+	if filter.JobID != 0 {
+		query = query.Where("job_id = ?", filter.JobID)
+	}
+	if filter.WorkerID != pkg.EMPTYSTRING {
+		query = query.Where("worker_id = ?", filter.WorkerID)
+	}
+	if len(filter.Statuses) > 0 {
+		query = query.Where("status in ?", filter.Statuses)
+	}
+	if !filter.Since.IsZero() {
+		query = query.Where("created_at >= ?", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		query = query.Where("created_at < ?", filter.Until)
+	}
+	if filter.Offset > 0 || filter.Limit > 0 {
+		// an offset needs a limit in sqlite and mysql
+		limit := filter.Limit
+		if limit == 0 {
+			limit = math.MaxInt32
+		}
+		query = query.Offset(filter.Offset).Limit(limit)
+	}
+	if err := query.Find(&jobHistories).Error; err != nil {
+		return nil, err
+	}
+	return jobHistories, nil
+}
+
+// GetJobHistoryForSchedule to get the latest JobHistory by scheduleID, nil if the schedule has not run yet
 func (c cduleRepository) GetJobHistoryForSchedule(scheduleID int64) (*JobHistory, error) {
 	var jobHistory JobHistory
-	if err := c.DB.Where("id = ?", scheduleID).First(&jobHistory).Error; err != nil {
+	if err := c.DB.Where("schedule_id = ?", scheduleID).Order("id desc").Limit(1).Find(&jobHistory).Error; err != nil {
 		return nil, err
 	}
+	if jobHistory.ID == 0 {
+		return nil, nil
+	}
 	return &jobHistory, nil
 }
 
-// DeleteJobHistory to delete a JobHistory by jobID
+// GetJobHistoryForWorker to get the JobHistories of a worker having one of the given statuses
+func (c cduleRepository) GetJobHistoryForWorker(workerID string, statuses []JobStatus) ([]JobHistory, error) {
+	var jobHistories []JobHistory
+	if err := c.DB.Where("worker_id = ? and status in ?", workerID, statuses).Order("id asc").Find(&jobHistories).Error; err != nil {
+		return nil, err
+	}
+	return jobHistories, nil
+}
+
+// UpdateJobHistoryStatus to save the status, retry count and worker of a JobHistory only if it is still
+// in the from status, returns false when another worker changed the status first
+func (c cduleRepository) UpdateJobHistoryStatus(jobHistory *JobHistory, from JobStatus) (bool, error) {
+	result := c.DB.Model(jobHistory).Where("status = ?", from).Updates(map[string]interface{}{
+		"status":      jobHistory.Status,
+		"retry_count": jobHistory.RetryCount,
+		"worker_id":   jobHistory.WorkerID,
+	})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// GetRunningJobHistory to get the IN_PROGRESS job histories of a job, on any worker
+func (c cduleRepository) GetRunningJobHistory(jobID int64) ([]JobHistory, error) {
+	var jobHistories []JobHistory
+	if err := c.DB.Where("job_id = ? and status = ?", jobID, JobStatusInProgress).Find(&jobHistories).Error; err != nil {
+		return nil, err
+	}
+	return jobHistories, nil
+}
+
+// RequestJobHistoryCancel to ask the workers running the given job histories to cancel them
+func (c cduleRepository) RequestJobHistoryCancel(jobHistoryIDs []int64) error {
+	if len(jobHistoryIDs) == 0 {
+		return nil
+	}
+	return c.DB.Model(&JobHistory{}).
+		Where("id in ? and status = ?", jobHistoryIDs, JobStatusInProgress).
+		Update("cancel_requested", true).Error
+}
+
+// IsJobHistoryCancelRequested whether the cancellation of a job history has been requested
+func (c cduleRepository) IsJobHistoryCancelRequested(jobHistoryID int64) (bool, error) {
+	var jobHistory JobHistory
+	if err := c.DB.Select("cancel_requested").Where("id = ?", jobHistoryID).Find(&jobHistory).Error; err != nil {
+		return false, err
+	}
+	return jobHistory.CancelRequested, nil
+}
+
+// DeleteJobHistory to delete all the JobHistories of jobID, ErrNotFound when there is none
 func (c cduleRepository) DeleteJobHistory(jobID int64) ([]JobHistory, error) {
-	jobHistories, err := c.GetJobHistory(jobID)
-	if nil != err {
+	var jobHistories []JobHistory
+	if err := c.DB.Where("job_id = ?", jobID).Order("id").Find(&jobHistories).Error; err != nil {
 		return nil, err
 	}
+	if len(jobHistories) == 0 {
+		return nil, ErrNotFound
+	}
 	if err := c.DB.Delete(&jobHistories).Error; err != nil {
 		return nil, err
 	}
 	return jobHistories, nil
 }
 
+// purgeBatchSize how many rows a purge deletes per statement, so that it does not lock a large table for long
+const purgeBatchSize = 10000
+
+// deleteInBatches to delete for good the rows of model matching query, by batches of their primary key column
+func (c cduleRepository) deleteInBatches(model interface{}, primaryKey string, query string, args ...interface{}) (int64, error) {
+	var deleted int64
+	for {
+		var ids []interface{}
+		err := c.DB.Unscoped().Model(model).Where(query, args...).Order(primaryKey).Limit(purgeBatchSize).Pluck(primaryKey, &ids).Error
+		if err != nil {
+			return deleted, err
+		}
+		if len(ids) == 0 {
+			return deleted, nil
+		}
+		result := c.DB.Unscoped().Where(primaryKey+" IN ?", ids).Delete(model)
+		if result.Error != nil {
+			return deleted, result.Error
+		}
+		deleted += result.RowsAffected
+		if len(ids) < purgeBatchSize {
+			return deleted, nil
+		}
+	}
+}
+
+// PurgeJobHistory to delete for good the job histories matching the retention policy, returns how many were
+func (c cduleRepository) PurgeJobHistory(policy RetentionPolicy) (int64, error) {
+	if len(policy.Statuses) == 0 {
+		return 0, nil
+	}
+	var purged int64
+	if !policy.Before.IsZero() {
+		deleted, err := c.deleteInBatches(&JobHistory{}, "id", "status IN ? AND created_at < ?", policy.Statuses, policy.Before)
+		purged += deleted
+		if err != nil {
+			return purged, err
+		}
+	}
+	if policy.KeepPerJob <= 0 {
+		return purged, nil
+	}
+	var jobIDs []int64
+	if err := c.DB.Model(&JobHistory{}).Distinct().Where("status IN ?", policy.Statuses).Pluck("job_id", &jobIDs).Error; err != nil {
+		return purged, err
+	}
+	for _, jobID := range jobIDs {
+		var kept []int64
+		err := c.DB.Model(&JobHistory{}).Where("job_id = ?", jobID).Order("id desc").Limit(policy.KeepPerJob).Pluck("id", &kept).Error
+		if err != nil {
+			return purged, err
+		}
+		if len(kept) < policy.KeepPerJob {
+			continue
+		}
+		deleted, err := c.deleteInBatches(&JobHistory{}, "id", "job_id = ? AND status IN ? AND id < ?", jobID, policy.Statuses, kept[len(kept)-1])
+		purged += deleted
+		if err != nil {
+			return purged, err
+		}
+	}
+	return purged, nil
+}
+
+// CreateJobChange to create a JobChange
+func (c cduleRepository) CreateJobChange(jobChange *JobChange) (*JobChange, error) {
+	if err := c.DB.Create(jobChange).Error; err != nil {
+		return nil, err
+	}
+	return jobChange, nil
+}
+
+// GetJobChanges to get the changes of a job, in the order they were made
+func (c cduleRepository) GetJobChanges(jobID int64) ([]JobChange, error) {
+	var jobChanges []JobChange
+	if err := c.DB.Where("job_id = ?", jobID).Order("id").Find(&jobChanges).Error; err != nil {
+		return nil, err
+	}
+	return jobChanges, nil
+}
+
 // CreateSchedule to create a schedule
 func (c cduleRepository) CreateSchedule(schedule *Schedule) (*Schedule, error) {
 	if err := c.DB.Create(schedule).Error; err != nil {
@@ -302,16 +541,116 @@ func (c cduleRepository) GetScheduleBefore(nanoUnix int64, workerID string) ([]S
 	return schedules, nil
 }
 
-// GetPassedSchedule to get all schedules before nanoUnix and by workerID, and ones that are not done yet
+// GetPassedSchedule to get all schedules before nanoUnix and by workerID which are not claimed yet,
+// and either never ran or are waiting to be re-run (NEW job history). Only the schedules of once jobs when onlyOnces.
+// The schedules of the paused jobs are left out.
 func (c cduleRepository) GetPassedSchedule(nanoUnix int64, workerID string, onlyOnces bool) ([]Schedule, error) {
 	var schedules []Schedule
-	scheduleTableName := getTableName(Schedule{})
-	jobHistoriesTableName := getTableName(JobHistory{})
+	scheduleTableName := getTableName(c.DB, Schedule{})
+	jobHistoriesTableName := getTableName(c.DB, JobHistory{})
 	query := c.DB.
-		InnerJoins("Job", DB.Where(&Job{Once: onlyOnces})).
-		Joins(fmt.Sprintf(`left join %[2]s cjh on %[1]s.id = cjh.schedule_id and not cjh.status = ?`, scheduleTableName, jobHistoriesTableName), JobStatusFailed).
+		InnerJoins("Job", c.DB.Session(&gorm.Session{NewDB: true}).Where(&Job{Once: onlyOnces}).Where("paused = ?", false)).
+		Joins(fmt.Sprintf(`left join %[2]s cjh on %[1]s.id = cjh.schedule_id and not cjh.status = ?`, scheduleTableName, jobHistoriesTableName), JobStatusNew).
 		Where(`cjh.id is null`).
 		Where(fmt.Sprintf(`(%[1]s.execution_id < ? and %[1]s.worker_id = ?)`, scheduleTableName), nanoUnix, workerID).
+		Where(fmt.Sprintf(`(%[1]s.claimed_by = ? or %[1]s.claimed_by is null)`, scheduleTableName), pkg.EMPTYSTRING).
+		Order(fmt.Sprintf(`%[1]s.execution_id asc`, scheduleTableName))
+
+	if err := query.Find(&schedules).Error; err != nil {
+		return nil, err
+	}
+	return schedules, nil
+}
+
+// GetClaimedScheduleWithoutHistory to get the schedules claimed by workerID which never started to run
+func (c cduleRepository) GetClaimedScheduleWithoutHistory(workerID string) ([]Schedule, error) {
+	var schedules []Schedule
+	scheduleTableName := getTableName(c.DB, Schedule{})
+	jobHistoriesTableName := getTableName(c.DB, JobHistory{})
+	query := c.DB.
+		Joins(fmt.Sprintf(`left join %[2]s cjh on %[1]s.id = cjh.schedule_id`, scheduleTableName, jobHistoriesTableName)).
+		Where(`cjh.id is null`).
+		Where(fmt.Sprintf(`%[1]s.claimed_by = ?`, scheduleTableName), workerID).
+		Order(fmt.Sprintf(`%[1]s.execution_id asc`, scheduleTableName))
+
+	if err := query.Find(&schedules).Error; err != nil {
+		return nil, err
+	}
+	return schedules, nil
+}
+
+// ClaimSchedule to claim a schedule for workerID, returns false when the schedule was already claimed
+func (c cduleRepository) ClaimSchedule(schedule *Schedule, workerID string) (bool, error) {
+	now := time.Now()
+	result := c.DB.Model(&Schedule{}).
+		Where("id = ? and (claimed_by = ? or claimed_by is null)", schedule.ID, pkg.EMPTYSTRING).
+		Updates(map[string]interface{}{
+			"claimed_by": workerID,
+			"claimed_at": now,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected != 1 {
+		return false, nil
+	}
+	schedule.ClaimedBy = workerID
+	schedule.ClaimedAt = &now
+	return true, nil
+}
+
+// ClaimScheduleBetween to claim the unclaimed schedules between scheduleStart and scheduleEnd assigned to workerID.
+// Rows are locked with SELECT ... FOR UPDATE SKIP LOCKED where the database supports it, every row is then
+// claimed with a conditional update so that two workers can never claim the same schedule. The schedules of the
+// paused jobs are left unclaimed until their job is resumed.
+func (c cduleRepository) ClaimScheduleBetween(scheduleStart, scheduleEnd int64, workerID string) ([]Schedule, error) {
+	claimed := make([]Schedule, 0)
+	scheduleTableName := getTableName(c.DB, Schedule{})
+	jobTableName := getTableName(c.DB, Job{})
+	err := c.DB.Transaction(func(tx *gorm.DB) error {
+		var schedules []Schedule
+		query := tx.
+			Joins(fmt.Sprintf(`left join %[2]s cj on cj.id = %[1]s.job_id`, scheduleTableName, jobTableName)).
+			Where(`cj.paused = ? or cj.paused is null`, false).
+			Where(fmt.Sprintf(`%[1]s.execution_id >= ? and %[1]s.execution_id <= ? and %[1]s.worker_id = ?`, scheduleTableName),
+				scheduleStart, scheduleEnd, workerID).
+			Where(fmt.Sprintf(`%[1]s.claimed_by = ? or %[1]s.claimed_by is null`, scheduleTableName), pkg.EMPTYSTRING).
+			Order(fmt.Sprintf(`%[1]s.execution_id asc`, scheduleTableName))
+		if supportsRowLocking(tx) {
+			// only the schedules are locked, the jobs are locked by the runs with LockJob
+			query = query.Clauses(clause.Locking{Strength: "UPDATE", Table: clause.Table{Name: scheduleTableName}, Options: "SKIP LOCKED"})
+		}
+		if err := query.Find(&schedules).Error; err != nil {
+			return err
+		}
+		repo := cduleRepository{DB: tx, Heart: c.Heart}
+		for i := range schedules {
+			ok, err := repo.ClaimSchedule(&schedules[i], workerID)
+			if err != nil {
+				return err
+			}
+			if ok {
+				claimed = append(claimed, schedules[i])
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return claimed, nil
+}
+
+// GetOrphanedSchedules to get the schedules which are not finished and belong to workers other than aliveWorkerIDs
+func (c cduleRepository) GetOrphanedSchedules(aliveWorkerIDs []string) ([]Schedule, error) {
+	var schedules []Schedule
+	scheduleTableName := getTableName(c.DB, Schedule{})
+	jobHistoriesTableName := getTableName(c.DB, JobHistory{})
+	query := c.DB.
+		Joins(fmt.Sprintf(`left join %[2]s cjh on %[1]s.id = cjh.schedule_id and cjh.status not in ?`, scheduleTableName, jobHistoriesTableName),
+			[]JobStatus{JobStatusNew, JobStatusInProgress}).
+		Where(`cjh.id is null`).
+		Where(fmt.Sprintf(`%[1]s.worker_id not in ?`, scheduleTableName), aliveWorkerIDs).
 		Order(fmt.Sprintf(`%[1]s.execution_id asc`, scheduleTableName))
 
 	if err := query.Find(&schedules).Error; err != nil {
@@ -320,6 +659,47 @@ func (c cduleRepository) GetPassedSchedule(nanoUnix int64, workerID string, only
 	return schedules, nil
 }
 
+// ReassignSchedule to move an unfinished schedule to schedule.WorkerID and schedule.ExecutionID, releasing its claim.
+// Only succeeds if the schedule still belongs to fromWorkerID, so that a schedule is taken over only once.
+func (c cduleRepository) ReassignSchedule(schedule *Schedule, fromWorkerID string) (bool, error) {
+	result := c.DB.Model(&Schedule{}).
+		Where("id = ? and worker_id = ?", schedule.ID, fromWorkerID).
+		Updates(map[string]interface{}{
+			"worker_id":       schedule.WorkerID,
+			"execution_id":    schedule.ExecutionID,
+			"claimed_by":      pkg.EMPTYSTRING,
+			"claimed_at":      nil,
+			"reassigned_from": fromWorkerID,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected != 1 {
+		return false, nil
+	}
+	schedule.ClaimedBy = pkg.EMPTYSTRING
+	schedule.ClaimedAt = nil
+	schedule.ReassignedFrom = fromWorkerID
+	return true, nil
+}
+
+// PostponeSchedule to move a schedule to schedule.ExecutionID, releasing its claim so that it is claimed again when due
+func (c cduleRepository) PostponeSchedule(schedule *Schedule) (*Schedule, error) {
+	err := c.DB.Model(&Schedule{}).
+		Where("id = ?", schedule.ID).
+		Updates(map[string]interface{}{
+			"execution_id": schedule.ExecutionID,
+			"claimed_by":   pkg.EMPTYSTRING,
+			"claimed_at":   nil,
+		}).Error
+	if err != nil {
+		return nil, err
+	}
+	schedule.ClaimedBy = pkg.EMPTYSTRING
+	schedule.ClaimedAt = nil
+	return schedule, nil
+}
+
 // GetSchedulesForJob to get a schedules by jobID
 func (c cduleRepository) GetSchedulesForJob(jobID int64) ([]Schedule, error) {
 	var schedules []Schedule
@@ -329,6 +709,29 @@ func (c cduleRepository) GetSchedulesForJob(jobID int64) ([]Schedule, error) {
 	return schedules, nil
 }
 
+// GetPendingSchedules to get the schedules which have not started to run, of jobID or of all the jobs when 0,
+// by execution time, at most limit of them when it is not 0
+func (c cduleRepository) GetPendingSchedules(jobID int64, limit int) ([]Schedule, error) {
+	var schedules []Schedule
+	scheduleTableName := getTableName(c.DB, Schedule{})
+	jobHistoriesTableName := getTableName(c.DB, JobHistory{})
+	query := c.DB.
+		Joins(fmt.Sprintf(`left join %[2]s cjh on %[1]s.id = cjh.schedule_id`, scheduleTableName, jobHistoriesTableName)).
+		Where(`cjh.id is null`).
+		Order(fmt.Sprintf(`%[1]s.execution_id asc`, scheduleTableName))
+	if jobID != 0 {
+		query = query.Where(fmt.Sprintf(`%[1]s.job_id = ?`, scheduleTableName), jobID)
+	}
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	if err := query.Find(&schedules).Error; err != nil {
+		return nil, err
+	}
+	return schedules, nil
+}
+
 // GetSchedulesForWorker to get a schedules by workerID
 func (c cduleRepository) GetSchedulesForWorker(workerID string) ([]Schedule, error) {
 	var schedules []Schedule
@@ -342,7 +745,7 @@ func (c cduleRepository) GetSchedulesForWorker(workerID string) ([]Schedule, err
 func (c cduleRepository) GetSchedulesForJobName(jobName string, subName string) ([]Schedule, error) {
 	var schedules []Schedule
 	if err := c.DB.
-		InnerJoins("Job", DB.Where(&Job{JobName: jobName, SubName: subName}, "JobName", "SubName")).
+		InnerJoins("Job", c.DB.Session(&gorm.Session{NewDB: true}).Where(&Job{JobName: jobName, SubName: subName}, "JobName", "SubName")).
 		Find(&schedules).Error; err != nil {
 		return nil, err
 	}
@@ -402,3 +805,70 @@ func (c cduleRepository) GetWorkerCountByJobID(jobID int64) ([]WorkerJobCount, e
 	}
 	return workerCounts, nil
 }
+
+// GetPendingScheduleCount to count the schedules which have not started to run, by worker
+func (c cduleRepository) GetPendingScheduleCount() ([]WorkerJobCount, error) {
+	var workerCounts []WorkerJobCount
+	scheduleTableName := getTableName(c.DB, Schedule{})
+	jobHistoriesTableName := getTableName(c.DB, JobHistory{})
+	query := c.DB.Model(&Schedule{}).
+		Select(fmt.Sprintf(`%[1]s.worker_id, count(1) as count`, scheduleTableName)).
+		Joins(fmt.Sprintf(`left join %[2]s cjh on %[1]s.id = cjh.schedule_id`, scheduleTableName, jobHistoriesTableName)).
+		Where(`cjh.id is null`).
+		Group(fmt.Sprintf(`%[1]s.worker_id`, scheduleTableName))
+
+	if err := query.Find(&workerCounts).Error; err != nil {
+		return nil, err
+	}
+	return workerCounts, nil
+}
+
+// supportsRowLocking whether the dialect supports SELECT ... FOR UPDATE [SKIP LOCKED], sqlite serialises writes instead
+func supportsRowLocking(db *gorm.DB) bool {
+	switch db.Dialector.Name() {
+	case "postgres", "mysql":
+		return true
+	}
+	return false
+}
+
+// PurgeDeleted to delete for good the rows which were soft-deleted before, returns how many were. The jobs and
+// schedules which still have job histories are kept, deleting them would cascade to the job histories, which
+// only the retention policy purges.
+func (c cduleRepository) PurgeDeleted(before time.Time) (int64, error) {
+	scheduleTableName := getTableName(c.DB, Schedule{})
+	jobTableName := getTableName(c.DB, Job{})
+	jobHistoriesTableName := getTableName(c.DB, JobHistory{})
+	var purged int64
+	for _, table := range []struct {
+		model      interface{}
+		primaryKey string
+		query      string
+	}{
+		{&JobHistory{}, "id", "deleted_at < ?"},
+		{&Schedule{}, "id", fmt.Sprintf(`deleted_at < ? AND NOT EXISTS (SELECT 1 FROM %[2]s cjh WHERE cjh.schedule_id = %[1]s.id)`,
+			scheduleTableName, jobHistoriesTableName)},
+		{&Job{}, "id", fmt.Sprintf(`deleted_at < ? AND NOT EXISTS (SELECT 1 FROM %[2]s cjh WHERE cjh.job_id = %[1]s.id)`+
+			` AND NOT EXISTS (SELECT 1 FROM %[3]s cs WHERE cs.job_id = %[1]s.id)`,
+			jobTableName, jobHistoriesTableName, scheduleTableName)},
+		{&Worker{}, "worker_id", "deleted_at < ?"},
+	} {
+		deleted, err := c.deleteInBatches(table.model, table.primaryKey, table.query, before)
+		purged += deleted
+		if err != nil {
+			return purged, err
+		}
+	}
+	return purged, nil
+}
+
+// Transaction to run fc with a repository bound to a single database transaction,
+// the transaction is committed when fc returns nil and rolled back otherwise
+func (c cduleRepository) Transaction(fc func(repo CduleRepository) error) error {
+	return c.DB.Transaction(func(tx *gorm.DB) error {
+		return fc(cduleRepository{
+			DB:    tx,
+			Heart: c.Heart,
+		})
+	})
+}
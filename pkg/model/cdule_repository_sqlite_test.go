@@ -0,0 +1,121 @@
+//go:build cgo
+
+package model
+
+import (
+	l "log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestRepository_SQLite(t *testing.T) {
+	runRepositorySuite(t, func(t *testing.T) CduleRepository {
+		repo, err := DBConn()
+		require.NoError(t, err)
+		return repo
+	})
+}
+
+func Test_PurgeDeleted(t *testing.T) {
+	repo, err := DBConn()
+	require.NoError(t, err)
+	schedule, err := createTestSchedule()
+	require.NoError(t, err)
+	schedule, err = repo.CreateSchedule(schedule)
+	require.NoError(t, err)
+	_, err = repo.DeleteScheduleForJob(schedule.JobID)
+	require.NoError(t, err)
+
+	purged, err := repo.PurgeDeleted(time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, int64(0), purged)
+
+	purged, err = repo.PurgeDeleted(time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	require.Equal(t, int64(1), purged)
+	var count int64
+	require.NoError(t, repo.(cduleRepository).DB.Unscoped().Model(&Schedule{}).Count(&count).Error)
+	require.Equal(t, int64(0), count)
+}
+
+func Test_PurgeDeletedKeepsJobHistories(t *testing.T) {
+	// the foreign keys cascade like on postgres and mysql
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "cdule.db")+"?_foreign_keys=on"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	require.NoError(t, err)
+	var foreignKeys int
+	require.NoError(t, db.Raw("PRAGMA foreign_keys").Scan(&foreignKeys).Error)
+	require.Equal(t, 1, foreignKeys)
+	require.NoError(t, Migrate(db))
+	repo := NewCduleRepository(db)
+
+	jobs := make([]*Job, 0, 2)
+	for _, name := range []string{"job.Run", "job.NeverRun"} {
+		job, err := repo.CreateJob(&Job{JobName: name, CronExpression: "0 * * * * *"})
+		require.NoError(t, err)
+		schedule, err := repo.CreateSchedule(&Schedule{ExecutionID: job.ID, JobID: job.ID, WorkerID: "worker1"})
+		require.NoError(t, err)
+		if name == "job.Run" {
+			_, err = repo.CreateJobHistory(&JobHistory{JobID: job.ID, ScheduleID: schedule.ID, Status: JobStatusCompleted})
+			require.NoError(t, err)
+		}
+		_, err = repo.DeleteScheduleForJob(job.ID)
+		require.NoError(t, err)
+		_, err = repo.DeleteJob(job.ID)
+		require.NoError(t, err)
+		jobs = append(jobs, job)
+	}
+
+	purged, err := repo.PurgeDeleted(time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	require.Equal(t, int64(2), purged)
+	jobHistories, err := repo.FindJobHistory(JobHistoryFilter{JobID: jobs[0].ID})
+	require.NoError(t, err)
+	require.Len(t, jobHistories, 1)
+	var count int64
+	require.NoError(t, db.Unscoped().Model(&Job{}).Where("id = ?", jobs[0].ID).Count(&count).Error)
+	require.Equal(t, int64(1), count)
+	require.NoError(t, db.Unscoped().Model(&Schedule{}).Where("job_id = ?", jobs[0].ID).Count(&count).Error)
+	require.Equal(t, int64(1), count)
+	require.NoError(t, db.Unscoped().Model(&Job{}).Where("id = ?", jobs[1].ID).Count(&count).Error)
+	require.Equal(t, int64(0), count)
+
+	// once the retention policy purged the job histories, the job and its schedule go too
+	_, err = repo.PurgeJobHistory(RetentionPolicy{Before: time.Now().Add(time.Minute), Statuses: FinishedJobStatuses})
+	require.NoError(t, err)
+	purged, err = repo.PurgeDeleted(time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	require.Equal(t, int64(2), purged)
+}
+
+func DBConn() (CduleRepository, error) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+
+	sqlLogger := logger.New(
+		l.New(os.Stdout, "\r\n", l.LstdFlags), // io writer
+		logger.Config{
+			SlowThreshold:             time.Second, // Slow SQL threshold
+			LogLevel:                  logger.Info, // Log level
+			IgnoreRecordNotFoundError: true,        // Ignore ErrRecordNotFound error for logger
+			Colorful:                  true,        // Disable color
+		},
+	)
+
+	db.Logger = sqlLogger
+	MigrateTestTables(db)
+	return NewCduleRepository(db), err
+}
+
+func MigrateTestTables(db *gorm.DB) {
+	if err := Migrate(db); nil != err {
+		panic(err)
+	}
+}
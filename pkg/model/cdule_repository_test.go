@@ -3,8 +3,6 @@ package model
 import (
 	"encoding/json"
 	"errors"
-	l "log"
-	"os"
 	"testing"
 	"time"
 
@@ -15,133 +13,10 @@ import (
 	"github.com/gagasdiv/cdule/pkg"
 	"github.com/gagasdiv/cdule/pkg/utils"
 
-	"github.com/google/go-cmp/cmp"
-	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/stretchr/testify/require"
-	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
-	"gorm.io/gorm/logger"
 )
 
-var (
-	approxTime = cmp.Options{cmpopts.EquateApproxTime(time.Second)}
-)
-
-func TestRepository_Job(t *testing.T) {
-	err := DBConn()
-	require.NoError(t, err)
-	testJob, err := createTestJob()
-	require.NoError(t, err)
-
-	expectedResult, err := CduleRepos.CduleRepository.CreateJob(testJob)
-
-	actualResult, err := CduleRepos.CduleRepository.GetJob(expectedResult.ID)
-
-	if diff := cmp.Diff(expectedResult, actualResult, approxTime); diff != "" {
-		t.Fatalf("mismatch (-expectedResult, +actRes):\n%s", diff)
-	}
-	expectedResult.Expired = true
-	_, err = CduleRepos.CduleRepository.UpdateJob(expectedResult)
-
-	actualResult, err = CduleRepos.CduleRepository.GetJobByName("job.RepoTestJob")
-
-	require.Equal(t, expectedResult.Expired, actualResult.Expired)
-
-	actualResult, err = CduleRepos.CduleRepository.DeleteJob(expectedResult.ID)
-
-	require.Equal(t, expectedResult.JobName, actualResult.JobName)
-}
-
-func TestRepository_JobHistory(t *testing.T) {
-	err := DBConn()
-	require.NoError(t, err)
-	testJobHistory, err := createTestJobHistory()
-	require.NoError(t, err)
-
-	expectedResult, err := CduleRepos.CduleRepository.CreateJobHistory(testJobHistory)
-
-	actualResultJobHistoryArray, err := CduleRepos.CduleRepository.GetJobHistory(expectedResult.JobID)
-
-	require.Equal(t, expectedResult.Status, actualResultJobHistoryArray[0].Status)
-	require.Equal(t, expectedResult.JobID, actualResultJobHistoryArray[0].JobID)
-	require.Equal(t, expectedResult.ScheduleID, actualResultJobHistoryArray[0].ScheduleID)
-
-	actualResultJobHistoryArray, err = CduleRepos.CduleRepository.GetJobHistoryWithLimit(expectedResult.JobID, 2)
-	require.Equal(t, 1, len(actualResultJobHistoryArray))
-
-	expectedResult.Status = JobStatusInProgress
-	_, err = CduleRepos.CduleRepository.UpdateJobHistory(expectedResult)
-
-	actualResult, err := CduleRepos.CduleRepository.GetJobHistoryForSchedule(testJobHistory.ScheduleID)
-
-	require.Equal(t, expectedResult.Status, actualResult.Status)
-
-	actualResultJobHistoryArray, err = CduleRepos.CduleRepository.DeleteJobHistory(expectedResult.JobID)
-
-	require.Equal(t, expectedResult.ScheduleID, actualResultJobHistoryArray[0].ScheduleID)
-}
-
-func TestRepository_Schedule(t *testing.T) {
-	err := DBConn()
-	require.NoError(t, err)
-	schedule, err := createTestSchedule()
-	require.NoError(t, err)
-
-	expectedResult, err := CduleRepos.CduleRepository.CreateSchedule(schedule)
-	actualResult, err := CduleRepos.CduleRepository.GetSchedule(expectedResult.ExecutionID)
-	if diff := cmp.Diff(expectedResult, actualResult, approxTime); diff != "" {
-		t.Fatalf("mismatch (-expectedResult, +actRes):\n%s", diff)
-	}
-
-	actualSchedules, err := CduleRepos.CduleRepository.GetScheduleBetween(schedule.ExecutionID, actualResult.CreatedAt.Add(5*time.Minute).UnixNano(), actualResult.WorkerID)
-	require.EqualValues(t, 1, len(actualSchedules))
-
-	data := make(map[string]string)
-	data["a"] = "xyz"
-	jobDataMapStr, err := mapToString(data)
-	expectedResult.JobData = jobDataMapStr
-
-	_, err = CduleRepos.CduleRepository.UpdateSchedule(expectedResult)
-	actualResultScheduleArray, err := CduleRepos.CduleRepository.GetSchedulesForJob(schedule.JobID)
-	require.Equal(t, expectedResult.JobData, actualResultScheduleArray[0].JobData)
-
-	actualResultScheduleArray, err = CduleRepos.CduleRepository.DeleteScheduleForJob(schedule.JobID)
-	require.Equal(t, expectedResult.ExecutionID, actualResultScheduleArray[0].ExecutionID)
-	schedule.JobID = 3
-	expectedResult, err = CduleRepos.CduleRepository.CreateSchedule(schedule)
-	actualResultScheduleArray, err = CduleRepos.CduleRepository.DeleteScheduleForWorker("dsinghvi-host")
-	require.Equal(t, expectedResult.ExecutionID, actualResultScheduleArray[0].ExecutionID)
-}
-
-func TestRepository_Worker(t *testing.T) {
-	err := DBConn()
-	require.NoError(t, err)
-	testWorker, err := createTestWorker()
-	require.NoError(t, err)
-
-	expectedResult, err := CduleRepos.CduleRepository.CreateWorker(testWorker)
-
-	actualResult, err := CduleRepos.CduleRepository.GetWorker(expectedResult.WorkerID)
-
-	if diff := cmp.Diff(expectedResult, actualResult, approxTime); diff != "" {
-		t.Fatalf("mismatch (-expectedResult, +actRes):\n%s", diff)
-	}
-
-	workers, err := CduleRepos.CduleRepository.GetWorkers()
-	require.EqualValues(t, 1, len(workers))
-
-	expectedResult.UpdatedAt = time.Now()
-	_, err = CduleRepos.CduleRepository.UpdateWorker(expectedResult)
-
-	actualResult, err = CduleRepos.CduleRepository.GetWorker(testWorker.WorkerID)
-
-	require.Equal(t, true, expectedResult.UpdatedAt.Equal(actualResult.UpdatedAt))
-
-	actualResult, err = CduleRepos.CduleRepository.DeleteWorker(expectedResult.WorkerID)
-
-	require.Equal(t, expectedResult.WorkerID, actualResult.WorkerID)
-}
-
 func TestRepository_WithMockDBForErrors(t *testing.T) {
 	DB, mock := NewMock()
 	mock.ExpectQuery(`^INSERT INTO workers`).WillReturnError(errors.New("db error"))
@@ -160,62 +35,59 @@ func TestRepository_WithMockDBForErrors(t *testing.T) {
 	mock.ExpectQuery(`^SELECT * FROM schedules`).WillReturnError(errors.New("db error"))
 	mock.ExpectQuery(`^UPDATE schedules`).WillReturnError(errors.New("db error"))
 
-	CduleRepos = &Repositories{
-		CduleRepository: NewCduleRepository(DB),
-		DB:              DB,
-	}
+	repo := NewCduleRepository(DB)
 	worker, _ := createTestWorker()
-	_, err := CduleRepos.CduleRepository.CreateWorker(worker)
+	_, err := repo.CreateWorker(worker)
 	require.Error(t, err)
-	_, err = CduleRepos.CduleRepository.GetWorker("dummyworker")
+	_, err = repo.GetWorker("dummyworker")
 	require.Error(t, err)
-	_, err = CduleRepos.CduleRepository.UpdateWorker(worker)
+	_, err = repo.UpdateWorker(worker)
 	require.Error(t, err)
-	_, err = CduleRepos.CduleRepository.GetWorkers()
+	_, err = repo.GetWorkers()
 	require.Error(t, err)
-	_, err = CduleRepos.CduleRepository.DeleteWorker("dummyworker")
+	_, err = repo.DeleteWorker("dummyworker")
 	require.Error(t, err)
 
 	job, _ := createTestJob()
-	_, err = CduleRepos.CduleRepository.CreateJob(job)
+	_, err = repo.CreateJob(job)
 	require.Error(t, err)
-	_, err = CduleRepos.CduleRepository.GetJob(1)
+	_, err = repo.GetJob(1)
 	require.Error(t, err)
-	_, err = CduleRepos.CduleRepository.GetJobByName("dummyjob")
+	_, err = repo.GetJobByName("dummyjob")
 	require.Error(t, err)
-	_, err = CduleRepos.CduleRepository.UpdateJob(job)
+	_, err = repo.UpdateJob(job)
 	require.Error(t, err)
-	_, err = CduleRepos.CduleRepository.DeleteJob(1)
+	_, err = repo.DeleteJob(1)
 	require.Error(t, err)
 
 	jHistory, _ := createTestJobHistory()
-	_, err = CduleRepos.CduleRepository.CreateJobHistory(jHistory)
+	_, err = repo.CreateJobHistory(jHistory)
 	require.Error(t, err)
-	_, err = CduleRepos.CduleRepository.GetJobHistory(1)
+	_, err = repo.GetJobHistory(1)
 	require.Error(t, err)
-	_, err = CduleRepos.CduleRepository.GetJobHistoryWithLimit(1, 2)
+	_, err = repo.GetJobHistoryWithLimit(1, 2)
 	require.Error(t, err)
-	_, err = CduleRepos.CduleRepository.GetJobHistoryForSchedule(1)
+	_, err = repo.GetJobHistoryForSchedule(1)
 	require.Error(t, err)
-	_, err = CduleRepos.CduleRepository.UpdateJobHistory(jHistory)
+	_, err = repo.UpdateJobHistory(jHistory)
 	require.Error(t, err)
-	_, err = CduleRepos.CduleRepository.DeleteJobHistory(1)
+	_, err = repo.DeleteJobHistory(1)
 	require.Error(t, err)
 
 	schedule, _ := createTestSchedule()
-	_, err = CduleRepos.CduleRepository.CreateSchedule(schedule)
+	_, err = repo.CreateSchedule(schedule)
 	require.Error(t, err)
-	_, err = CduleRepos.CduleRepository.GetSchedule(1)
+	_, err = repo.GetSchedule(1)
 	require.Error(t, err)
-	_, err = CduleRepos.CduleRepository.GetScheduleBetween(1, 2, "dummyworker")
+	_, err = repo.GetScheduleBetween(1, 2, "dummyworker")
 	require.Error(t, err)
-	_, err = CduleRepos.CduleRepository.GetSchedulesForJob(1)
+	_, err = repo.GetSchedulesForJob(1)
 	require.Error(t, err)
-	_, err = CduleRepos.CduleRepository.UpdateSchedule(schedule)
+	_, err = repo.UpdateSchedule(schedule)
 	require.Error(t, err)
-	_, err = CduleRepos.CduleRepository.DeleteScheduleForJob(1)
+	_, err = repo.DeleteScheduleForJob(1)
 	require.Error(t, err)
-	_, err = CduleRepos.CduleRepository.DeleteScheduleForWorker("dummyworker")
+	_, err = repo.DeleteScheduleForWorker("dummyworker")
 	require.Error(t, err)
 
 }
@@ -284,12 +156,12 @@ func mapToString(data map[string]string) (string, error) {
 
 func createTestJobHistory() (*JobHistory, error) {
 	return &JobHistory{
-		Model:       Model{},
-		JobID:       2,
-		ScheduleID:  2,
-		Status:      "NEW",
-		WorkerID:    "dsinghvi-host",
-		RetryCount:  0,
+		Model:      Model{},
+		JobID:      2,
+		ScheduleID: 2,
+		Status:     "NEW",
+		WorkerID:   "dsinghvi-host",
+		RetryCount: 0,
 	}, nil
 }
 
@@ -306,31 +178,3 @@ func createTestSchedule() (*Schedule, error) {
 	}
 	return schedule, nil
 }
-func DBConn() error {
-	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
-
-	sqlLogger := logger.New(
-		l.New(os.Stdout, "\r\n", l.LstdFlags), // io writer
-		logger.Config{
-			SlowThreshold:             time.Second, // Slow SQL threshold
-			LogLevel:                  logger.Info, // Log level
-			IgnoreRecordNotFoundError: true,        // Ignore ErrRecordNotFound error for logger
-			Colorful:                  true,        // Disable color
-		},
-	)
-
-	db.Logger = sqlLogger
-	MigrateTestTables(db)
-	CduleRepos = &Repositories{
-		CduleRepository: NewCduleRepository(db),
-		DB:              db,
-	}
-	return err
-}
-
-func MigrateTestTables(db *gorm.DB) {
-	db.AutoMigrate(&Job{})
-	db.AutoMigrate(&JobHistory{})
-	db.AutoMigrate(&Schedule{})
-	db.AutoMigrate(&Worker{})
-}
@@ -0,0 +1,864 @@
+package model
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gagasdiv/cdule/pkg"
+)
+
+// memoryRepository a CduleRepository keeping everything in maps, for tests and single process deployments.
+// Every call holds the lock of the store, a transaction holds it until it returns and undoes its writes
+// when it is rolled back.
+type memoryRepository struct {
+	store *memoryStore
+	// transaction the repository is bound to, nil outside of a transaction
+	tx *memoryTx
+}
+
+type memoryStore struct {
+	mu    sync.Mutex
+	Heart time.Duration
+
+	workers   map[string]Worker
+	jobs      map[int64]Job
+	schedules map[int64]Schedule
+	histories map[int64]JobHistory
+	changes   map[int64]JobChange
+
+	// last ids given, like auto increments they are not rolled back
+	jobSeq      int64
+	scheduleSeq int64
+	historySeq  int64
+	changeSeq   int64
+}
+
+// memoryTx undo log of a transaction
+type memoryTx struct {
+	undo []func()
+}
+
+func (tx *memoryTx) rollback() {
+	for i := len(tx.undo) - 1; i >= 0; i-- {
+		tx.undo[i]()
+	}
+	tx.undo = nil
+}
+
+// NewMemoryRepository to create a repository keeping everything in memory, without any database.
+// It is lost when the process exits, so it suits tests and single process deployments.
+func NewMemoryRepository() CduleRepository {
+	return memoryRepository{
+		store: &memoryStore{
+			Heart:     30 * time.Second,
+			workers:   make(map[string]Worker),
+			jobs:      make(map[int64]Job),
+			schedules: make(map[int64]Schedule),
+			histories: make(map[int64]JobHistory),
+			changes:   make(map[int64]JobChange),
+		},
+	}
+}
+
+// lock to lock the store, unless the repository is bound to a transaction which already holds the lock
+func (c memoryRepository) lock() func() {
+	if nil != c.tx {
+		return func() {}
+	}
+	c.store.mu.Lock()
+	return c.store.mu.Unlock
+}
+
+func (c memoryRepository) record(undo func()) {
+	if nil != c.tx {
+		c.tx.undo = append(c.tx.undo, undo)
+	}
+}
+
+func memoryPut[K comparable, V any](c memoryRepository, table map[K]V, key K, value V) {
+	previous, existed := table[key]
+	c.record(func() {
+		if existed {
+			table[key] = previous
+		} else {
+			delete(table, key)
+		}
+	})
+	table[key] = value
+}
+
+func memoryDelete[K comparable, V any](c memoryRepository, table map[K]V, key K) {
+	previous, existed := table[key]
+	if !existed {
+		return
+	}
+	c.record(func() {
+		table[key] = previous
+	})
+	delete(table, key)
+}
+
+// mergeNonZero to copy the non-zero fields of src to dst like an update with a struct does, without the associations
+func mergeNonZero(dst, src reflect.Value) {
+	for i := 0; i < src.NumField(); i++ {
+		field := src.Type().Field(i)
+		value := src.Field(i)
+		switch {
+		case field.Type == reflect.TypeOf(Job{}) || field.Type == reflect.TypeOf(Schedule{}):
+			continue
+		case field.Anonymous || field.Type == reflect.TypeOf(RetryPolicy{}):
+			mergeNonZero(dst.Field(i), value)
+		case !value.IsZero():
+			dst.Field(i).Set(value)
+		}
+	}
+}
+
+func isUnclaimed(schedule Schedule) bool {
+	return schedule.ClaimedBy == pkg.EMPTYSTRING
+}
+
+// sortedSchedules to get the schedules matching filter, ordered by id
+func (c memoryRepository) sortedSchedules(filter func(Schedule) bool) []Schedule {
+	schedules := make([]Schedule, 0)
+	for _, schedule := range c.store.schedules {
+		if filter(schedule) {
+			schedules = append(schedules, schedule)
+		}
+	}
+	sort.Slice(schedules, func(i, j int) bool { return schedules[i].ID < schedules[j].ID })
+	return schedules
+}
+
+func sortByExecutionID(schedules []Schedule) []Schedule {
+	sort.SliceStable(schedules, func(i, j int) bool { return schedules[i].ExecutionID < schedules[j].ExecutionID })
+	return schedules
+}
+
+// sortedJobHistories to get the job histories matching filter, ordered by id
+func (c memoryRepository) sortedJobHistories(filter func(JobHistory) bool) []JobHistory {
+	jobHistories := make([]JobHistory, 0)
+	for _, jobHistory := range c.store.histories {
+		if filter(jobHistory) {
+			jobHistories = append(jobHistories, jobHistory)
+		}
+	}
+	sort.Slice(jobHistories, func(i, j int) bool { return jobHistories[i].ID < jobHistories[j].ID })
+	return jobHistories
+}
+
+// firstJob to get the job with the lowest id matching filter
+func (c memoryRepository) firstJob(filter func(Job) bool) *Job {
+	var first *Job
+	for _, job := range c.store.jobs {
+		if filter(job) && (nil == first || job.ID < first.ID) {
+			job := job
+			first = &job
+		}
+	}
+	return first
+}
+
+// hasJobHistory whether the schedule has a job history matching filter
+func (c memoryRepository) hasJobHistory(scheduleID int64, filter func(JobHistory) bool) bool {
+	for _, jobHistory := range c.store.histories {
+		if jobHistory.ScheduleID == scheduleID && filter(jobHistory) {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateWorker to create a worker
+func (c memoryRepository) CreateWorker(worker *Worker) (*Worker, error) {
+	defer c.lock()()
+	if _, ok := c.store.workers[worker.WorkerID]; ok {
+		return nil, ErrDuplicateKey
+	}
+	now := time.Now()
+	if worker.CreatedAt.IsZero() {
+		worker.CreatedAt = now
+	}
+	if worker.UpdatedAt.IsZero() {
+		worker.UpdatedAt = now
+	}
+	memoryPut(c, c.store.workers, worker.WorkerID, *worker)
+	return worker, nil
+}
+
+// UpdateWorker to update a worker
+func (c memoryRepository) UpdateWorker(worker *Worker) (*Worker, error) {
+	defer c.lock()()
+	worker.UpdatedAt = time.Now()
+	if existing, ok := c.store.workers[worker.WorkerID]; ok {
+		mergeNonZero(reflect.ValueOf(&existing).Elem(), reflect.ValueOf(*worker))
+		memoryPut(c, c.store.workers, worker.WorkerID, existing)
+	}
+	return worker, nil
+}
+
+// GetWorker to get a worker
+func (c memoryRepository) GetWorker(workerID string) (*Worker, error) {
+	defer c.lock()()
+	worker, ok := c.store.workers[workerID]
+	if !ok {
+		return nil, nil
+	}
+	return &worker, nil
+}
+
+// GetWorkers to get a list of workers
+func (c memoryRepository) GetWorkers() ([]Worker, error) {
+	defer c.lock()()
+	return c.workersUpdatedAfter(time.Time{}), nil
+}
+
+// GetAliveWorkers to get a list of alive workers
+func (c memoryRepository) GetAliveWorkers() ([]Worker, error) {
+	defer c.lock()()
+	// updated_at gt 3 heart means alive
+	return c.workersUpdatedAfter(time.Now().Add(-3 * c.store.Heart)), nil
+}
+
+func (c memoryRepository) workersUpdatedAfter(t time.Time) []Worker {
+	workers := make([]Worker, 0)
+	for _, worker := range c.store.workers {
+		if worker.UpdatedAt.After(t) {
+			workers = append(workers, worker)
+		}
+	}
+	sort.Slice(workers, func(i, j int) bool { return workers[i].WorkerID < workers[j].WorkerID })
+	return workers
+}
+
+// DeleteWorker to delete a worker
+func (c memoryRepository) DeleteWorker(workerID string) (*Worker, error) {
+	defer c.lock()()
+	worker, ok := c.store.workers[workerID]
+	if !ok {
+		return nil, nil
+	}
+	memoryDelete(c, c.store.workers, workerID)
+	return &worker, nil
+}
+
+// CreateJob to create a job
+func (c memoryRepository) CreateJob(job *Job) (*Job, error) {
+	defer c.lock()()
+	c.store.jobSeq++
+	job.ID = c.store.jobSeq
+	job.CreatedAt = time.Now()
+	job.UpdatedAt = job.CreatedAt
+	memoryPut(c, c.store.jobs, job.ID, *job)
+	return job, nil
+}
+
+// UpdateJob to update a job
+func (c memoryRepository) UpdateJob(job *Job) (*Job, error) {
+	defer c.lock()()
+	job.UpdatedAt = time.Now()
+	if existing, ok := c.store.jobs[job.ID]; ok {
+		mergeNonZero(reflect.ValueOf(&existing).Elem(), reflect.ValueOf(*job))
+		memoryPut(c, c.store.jobs, job.ID, existing)
+	}
+	return job, nil
+}
+
+// SaveJob to upsert a job (all columns)
+func (c memoryRepository) SaveJob(job *Job) (*Job, error) {
+	defer c.lock()()
+	now := time.Now()
+	if job.ID == 0 {
+		c.store.jobSeq++
+		job.ID = c.store.jobSeq
+	} else if job.ID > c.store.jobSeq {
+		c.store.jobSeq = job.ID
+	}
+	if job.CreatedAt.IsZero() {
+		job.CreatedAt = now
+	}
+	job.UpdatedAt = now
+	memoryPut(c, c.store.jobs, job.ID, *job)
+	return job, nil
+}
+
+// GetJob to get a job based on ID
+func (c memoryRepository) GetJob(jobID int64) (*Job, error) {
+	defer c.lock()()
+	job, ok := c.store.jobs[jobID]
+	if !ok {
+		return nil, nil
+	}
+	return &job, nil
+}
+
+// GetJobs to get all the jobs, by ID
+func (c memoryRepository) GetJobs() ([]Job, error) {
+	defer c.lock()()
+	jobs := make([]Job, 0, len(c.store.jobs))
+	for _, job := range c.store.jobs {
+		jobs = append(jobs, job)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].ID < jobs[j].ID })
+	return jobs, nil
+}
+
+// GetJobByName to get a job based on Name
+func (c memoryRepository) GetJobByName(jobName string) (*Job, error) {
+	defer c.lock()()
+	return c.firstJob(func(job Job) bool { return job.JobName == jobName }), nil
+}
+
+// GetRepeatingJobByName to get a repeating/non-once job based on Name
+func (c memoryRepository) GetRepeatingJobByName(jobName string) (*Job, error) {
+	defer c.lock()()
+	return c.firstJob(func(job Job) bool { return job.JobName == jobName && !job.Once }), nil
+}
+
+// LockJob to get a job by ID, the whole store is locked for the duration of a transaction already
+func (c memoryRepository) LockJob(jobID int64) (*Job, error) {
+	return c.GetJob(jobID)
+}
+
+// DeleteJob to get a job based on ID
+func (c memoryRepository) DeleteJob(jobID int64) (*Job, error) {
+	defer c.lock()()
+	job, ok := c.store.jobs[jobID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	memoryDelete(c, c.store.jobs, jobID)
+	return &job, nil
+}
+
+// CreateJobHistory to create a JobHistory
+func (c memoryRepository) CreateJobHistory(jobHistory *JobHistory) (*JobHistory, error) {
+	defer c.lock()()
+	c.store.historySeq++
+	jobHistory.ID = c.store.historySeq
+	jobHistory.CreatedAt = time.Now()
+	jobHistory.UpdatedAt = jobHistory.CreatedAt
+	stored := *jobHistory
+	stored.Job, stored.Schedule = Job{}, Schedule{}
+	memoryPut(c, c.store.histories, jobHistory.ID, stored)
+	return jobHistory, nil
+}
+
+// UpdateJobHistory to update a JobHistory
+func (c memoryRepository) UpdateJobHistory(jobHistory *JobHistory) (*JobHistory, error) {
+	defer c.lock()()
+	jobHistory.UpdatedAt = time.Now()
+	if existing, ok := c.store.histories[jobHistory.ID]; ok {
+		mergeNonZero(reflect.ValueOf(&existing).Elem(), reflect.ValueOf(*jobHistory))
+		memoryPut(c, c.store.histories, jobHistory.ID, existing)
+	}
+	return jobHistory, nil
+}
+
+// GetJobHistory to get the first JobHistory by JobID, ErrNotFound when there is none
+func (c memoryRepository) GetJobHistory(jobID int64) ([]JobHistory, error) {
+	defer c.lock()()
+	jobHistories := c.sortedJobHistories(func(jobHistory JobHistory) bool { return jobHistory.JobID == jobID })
+	if len(jobHistories) == 0 {
+		return nil, ErrNotFound
+	}
+	return jobHistories[:1], nil
+}
+
+// GetJobHistoryWithLimit to get a JobHistory by JobID and limit
+func (c memoryRepository) GetJobHistoryWithLimit(jobID int64, limit int) ([]JobHistory, error) {
+	defer c.lock()()
+	jobHistories := c.sortedJobHistories(func(jobHistory JobHistory) bool { return jobHistory.JobID == jobID })
+	if limit >= 0 && len(jobHistories) > limit {
+		jobHistories = jobHistories[:limit]
+	}
+	return jobHistories, nil
+}
+
+// GetJobHistoryForSchedule to get the latest JobHistory by scheduleID, nil if the schedule has not run yet
+func (c memoryRepository) GetJobHistoryForSchedule(scheduleID int64) (*JobHistory, error) {
+	defer c.lock()()
+	jobHistories := c.sortedJobHistories(func(jobHistory JobHistory) bool { return jobHistory.ScheduleID == scheduleID })
+	if len(jobHistories) == 0 {
+		return nil, nil
+	}
+	return &jobHistories[len(jobHistories)-1], nil
+}
+
+// GetJobHistoryForWorker to get the JobHistories of a worker having one of the given statuses
+func (c memoryRepository) GetJobHistoryForWorker(workerID string, statuses []JobStatus) ([]JobHistory, error) {
+	defer c.lock()()
+	return c.sortedJobHistories(func(jobHistory JobHistory) bool {
+		if jobHistory.WorkerID != workerID {
+			return false
+		}
+		for _, status := range statuses {
+			if jobHistory.Status == status {
+				return true
+			}
+		}
+		return false
+	}), nil
+}
+
+// UpdateJobHistoryStatus to save the status, retry count and worker of a JobHistory only if it is still
+// in the from status, returns false when another worker changed the status first
+func (c memoryRepository) UpdateJobHistoryStatus(jobHistory *JobHistory, from JobStatus) (bool, error) {
+	defer c.lock()()
+	existing, ok := c.store.histories[jobHistory.ID]
+	if !ok || existing.Status != from {
+		return false, nil
+	}
+	jobHistory.UpdatedAt = time.Now()
+	existing.Status = jobHistory.Status
+	existing.RetryCount = jobHistory.RetryCount
+	existing.WorkerID = jobHistory.WorkerID
+	existing.UpdatedAt = jobHistory.UpdatedAt
+	memoryPut(c, c.store.histories, existing.ID, existing)
+	return true, nil
+}
+
+// GetRunningJobHistory to get the IN_PROGRESS job histories of a job, on any worker
+func (c memoryRepository) GetRunningJobHistory(jobID int64) ([]JobHistory, error) {
+	defer c.lock()()
+	return c.sortedJobHistories(func(jobHistory JobHistory) bool {
+		return jobHistory.JobID == jobID && jobHistory.Status == JobStatusInProgress
+	}), nil
+}
+
+// RequestJobHistoryCancel to ask the workers running the given job histories to cancel them
+func (c memoryRepository) RequestJobHistoryCancel(jobHistoryIDs []int64) error {
+	defer c.lock()()
+	for _, id := range jobHistoryIDs {
+		jobHistory, ok := c.store.histories[id]
+		if !ok || jobHistory.Status != JobStatusInProgress {
+			continue
+		}
+		jobHistory.CancelRequested = true
+		jobHistory.UpdatedAt = time.Now()
+		memoryPut(c, c.store.histories, id, jobHistory)
+	}
+	return nil
+}
+
+// IsJobHistoryCancelRequested whether the cancellation of a job history has been requested
+func (c memoryRepository) IsJobHistoryCancelRequested(jobHistoryID int64) (bool, error) {
+	defer c.lock()()
+	return c.store.histories[jobHistoryID].CancelRequested, nil
+}
+
+// DeleteJobHistory to delete all the JobHistories of jobID, ErrNotFound when there is none
+func (c memoryRepository) DeleteJobHistory(jobID int64) ([]JobHistory, error) {
+	defer c.lock()()
+	jobHistories := c.sortedJobHistories(func(jobHistory JobHistory) bool { return jobHistory.JobID == jobID })
+	if len(jobHistories) == 0 {
+		return nil, ErrNotFound
+	}
+	for _, jobHistory := range jobHistories {
+		memoryDelete(c, c.store.histories, jobHistory.ID)
+	}
+	return jobHistories, nil
+}
+
+// FindJobHistory to get the job histories matching filter, the latest first
+func (c memoryRepository) FindJobHistory(filter JobHistoryFilter) ([]JobHistory, error) {
+	defer c.lock()()
+	return findJobHistories(c.sortedJobHistories(func(JobHistory) bool { return true }), filter), nil
+}
+
+// findJobHistories to get the job histories, ordered by id, which match filter, the latest first
+func findJobHistories(jobHistories []JobHistory, filter JobHistoryFilter) []JobHistory {
+	statuses := make(map[JobStatus]bool, len(filter.Statuses))
+	for _, status := range filter.Statuses {
+		statuses[status] = true
+	}
+	found := make([]JobHistory, 0)
+	skipped := 0
+	for i := len(jobHistories) - 1; i >= 0 && (filter.Limit == 0 || len(found) < filter.Limit); i-- {
+		jobHistory := jobHistories[i]
+		if (filter.JobID != 0 && jobHistory.JobID != filter.JobID) ||
+			(filter.WorkerID != pkg.EMPTYSTRING && jobHistory.WorkerID != filter.WorkerID) ||
+			(len(statuses) > 0 && !statuses[jobHistory.Status]) ||
+			(!filter.Since.IsZero() && jobHistory.CreatedAt.Before(filter.Since)) ||
+			(!filter.Until.IsZero() && !jobHistory.CreatedAt.Before(filter.Until)) {
+			continue
+		}
+		if skipped < filter.Offset {
+			skipped++
+			continue
+		}
+		found = append(found, jobHistory)
+	}
+	return found
+}
+
+// purgeableJobHistories to get the ids of the job histories, ordered by id, which the retention policy purges
+func purgeableJobHistories(jobHistories []JobHistory, policy RetentionPolicy) []int64 {
+	statuses := make(map[JobStatus]bool, len(policy.Statuses))
+	for _, status := range policy.Statuses {
+		statuses[status] = true
+	}
+	// number of the newer job histories of each job
+	newer := make(map[int64]int)
+	ids := make([]int64, 0)
+	for i := len(jobHistories) - 1; i >= 0; i-- {
+		jobHistory := jobHistories[i]
+		rank := newer[jobHistory.JobID]
+		newer[jobHistory.JobID]++
+		if !statuses[jobHistory.Status] {
+			continue
+		}
+		if (!policy.Before.IsZero() && jobHistory.CreatedAt.Before(policy.Before)) || (policy.KeepPerJob > 0 && rank >= policy.KeepPerJob) {
+			ids = append(ids, jobHistory.ID)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// PurgeJobHistory to delete the job histories matching the retention policy, returns how many were
+func (c memoryRepository) PurgeJobHistory(policy RetentionPolicy) (int64, error) {
+	defer c.lock()()
+	ids := purgeableJobHistories(c.sortedJobHistories(func(JobHistory) bool { return true }), policy)
+	for _, id := range ids {
+		memoryDelete(c, c.store.histories, id)
+	}
+	return int64(len(ids)), nil
+}
+
+// CreateJobChange to create a JobChange
+func (c memoryRepository) CreateJobChange(jobChange *JobChange) (*JobChange, error) {
+	defer c.lock()()
+	c.store.changeSeq++
+	jobChange.ID = c.store.changeSeq
+	jobChange.CreatedAt = time.Now()
+	jobChange.UpdatedAt = jobChange.CreatedAt
+	stored := *jobChange
+	stored.Job = Job{}
+	memoryPut(c, c.store.changes, jobChange.ID, stored)
+	return jobChange, nil
+}
+
+// GetJobChanges to get the changes of a job, in the order they were made
+func (c memoryRepository) GetJobChanges(jobID int64) ([]JobChange, error) {
+	defer c.lock()()
+	jobChanges := make([]JobChange, 0)
+	for _, jobChange := range c.store.changes {
+		if jobChange.JobID == jobID {
+			jobChanges = append(jobChanges, jobChange)
+		}
+	}
+	sort.Slice(jobChanges, func(i, j int) bool { return jobChanges[i].ID < jobChanges[j].ID })
+	return jobChanges, nil
+}
+
+// CreateSchedule to create a schedule
+func (c memoryRepository) CreateSchedule(schedule *Schedule) (*Schedule, error) {
+	defer c.lock()()
+	c.store.scheduleSeq++
+	schedule.ID = c.store.scheduleSeq
+	schedule.CreatedAt = time.Now()
+	schedule.UpdatedAt = schedule.CreatedAt
+	stored := *schedule
+	stored.Job = Job{}
+	memoryPut(c, c.store.schedules, schedule.ID, stored)
+	return schedule, nil
+}
+
+// UpdateSchedule to update a schedule
+func (c memoryRepository) UpdateSchedule(schedule *Schedule) (*Schedule, error) {
+	defer c.lock()()
+	schedule.UpdatedAt = time.Now()
+	if existing, ok := c.store.schedules[schedule.ID]; ok {
+		mergeNonZero(reflect.ValueOf(&existing).Elem(), reflect.ValueOf(*schedule))
+		memoryPut(c, c.store.schedules, schedule.ID, existing)
+	}
+	return schedule, nil
+}
+
+// GetSchedule to get a schedule by executionID, an empty schedule when there is none
+func (c memoryRepository) GetSchedule(executionID int64) (*Schedule, error) {
+	defer c.lock()()
+	schedules := c.sortedSchedules(func(schedule Schedule) bool { return schedule.ExecutionID == executionID })
+	if len(schedules) == 0 {
+		return &Schedule{}, nil
+	}
+	return &schedules[0], nil
+}
+
+// GetScheduleByID to get a schedule by ID, an empty schedule when there is none
+func (c memoryRepository) GetScheduleByID(scheduleID int64) (*Schedule, error) {
+	defer c.lock()()
+	schedule := c.store.schedules[scheduleID]
+	return &schedule, nil
+}
+
+// GetScheduleBetween to get a schedule between scheduleStart and scheduleEnd and by workerID
+func (c memoryRepository) GetScheduleBetween(scheduleStart, scheduleEnd int64, workerID string) ([]Schedule, error) {
+	defer c.lock()()
+	return c.sortedSchedules(func(schedule Schedule) bool {
+		return schedule.ExecutionID >= scheduleStart && schedule.ExecutionID <= scheduleEnd && schedule.WorkerID == workerID
+	}), nil
+}
+
+// GetScheduleBefore to get all schedules before nanoUnix and by workerID
+func (c memoryRepository) GetScheduleBefore(nanoUnix int64, workerID string) ([]Schedule, error) {
+	defer c.lock()()
+	schedules := c.sortedSchedules(func(schedule Schedule) bool {
+		return schedule.ExecutionID <= nanoUnix && schedule.WorkerID == workerID
+	})
+	for i := range schedules {
+		schedules[i].Job = c.store.jobs[schedules[i].JobID]
+	}
+	return sortByExecutionID(schedules), nil
+}
+
+// GetPassedSchedule to get all schedules before nanoUnix and by workerID which are not claimed yet,
+// and either never ran or are waiting to be re-run (NEW job history). Only the schedules of once jobs when onlyOnces.
+// The schedules of the paused jobs are left out.
+func (c memoryRepository) GetPassedSchedule(nanoUnix int64, workerID string, onlyOnces bool) ([]Schedule, error) {
+	defer c.lock()()
+	schedules := c.sortedSchedules(func(schedule Schedule) bool {
+		job, ok := c.store.jobs[schedule.JobID]
+		if !ok || job.Paused || (onlyOnces && !job.Once) {
+			return false
+		}
+		return schedule.ExecutionID < nanoUnix && schedule.WorkerID == workerID && isUnclaimed(schedule) &&
+			!c.hasJobHistory(schedule.ID, func(jobHistory JobHistory) bool { return jobHistory.Status != JobStatusNew })
+	})
+	for i := range schedules {
+		schedules[i].Job = c.store.jobs[schedules[i].JobID]
+	}
+	return sortByExecutionID(schedules), nil
+}
+
+// GetClaimedScheduleWithoutHistory to get the schedules claimed by workerID which never started to run
+func (c memoryRepository) GetClaimedScheduleWithoutHistory(workerID string) ([]Schedule, error) {
+	defer c.lock()()
+	return sortByExecutionID(c.sortedSchedules(func(schedule Schedule) bool {
+		return schedule.ClaimedBy == workerID && workerID != pkg.EMPTYSTRING &&
+			!c.hasJobHistory(schedule.ID, func(JobHistory) bool { return true })
+	})), nil
+}
+
+// ClaimSchedule to claim a schedule for workerID, returns false when the schedule was already claimed
+func (c memoryRepository) ClaimSchedule(schedule *Schedule, workerID string) (bool, error) {
+	defer c.lock()()
+	return c.claimSchedule(schedule, workerID), nil
+}
+
+func (c memoryRepository) claimSchedule(schedule *Schedule, workerID string) bool {
+	existing, ok := c.store.schedules[schedule.ID]
+	if !ok || !isUnclaimed(existing) {
+		return false
+	}
+	now := time.Now()
+	existing.ClaimedBy = workerID
+	existing.ClaimedAt = &now
+	existing.UpdatedAt = now
+	memoryPut(c, c.store.schedules, existing.ID, existing)
+	schedule.ClaimedBy = workerID
+	schedule.ClaimedAt = &now
+	return true
+}
+
+// ClaimScheduleBetween to claim the unclaimed schedules between scheduleStart and scheduleEnd assigned to workerID,
+// but the ones of the paused jobs
+func (c memoryRepository) ClaimScheduleBetween(scheduleStart, scheduleEnd int64, workerID string) ([]Schedule, error) {
+	defer c.lock()()
+	schedules := sortByExecutionID(c.sortedSchedules(func(schedule Schedule) bool {
+		return schedule.ExecutionID >= scheduleStart && schedule.ExecutionID <= scheduleEnd &&
+			schedule.WorkerID == workerID && isUnclaimed(schedule) && !c.store.jobs[schedule.JobID].Paused
+	}))
+	claimed := make([]Schedule, 0, len(schedules))
+	for i := range schedules {
+		if c.claimSchedule(&schedules[i], workerID) {
+			claimed = append(claimed, schedules[i])
+		}
+	}
+	return claimed, nil
+}
+
+// GetOrphanedSchedules to get the schedules which are not finished and belong to workers other than aliveWorkerIDs
+func (c memoryRepository) GetOrphanedSchedules(aliveWorkerIDs []string) ([]Schedule, error) {
+	defer c.lock()()
+	alive := make(map[string]bool, len(aliveWorkerIDs))
+	for _, workerID := range aliveWorkerIDs {
+		alive[workerID] = true
+	}
+	if len(alive) == 0 {
+		// like "not in (NULL)", which matches nothing
+		return []Schedule{}, nil
+	}
+	return sortByExecutionID(c.sortedSchedules(func(schedule Schedule) bool {
+		return !alive[schedule.WorkerID] && !c.hasJobHistory(schedule.ID, func(jobHistory JobHistory) bool {
+			return jobHistory.Status != JobStatusNew && jobHistory.Status != JobStatusInProgress
+		})
+	})), nil
+}
+
+// ReassignSchedule to move an unfinished schedule to schedule.WorkerID and schedule.ExecutionID, releasing its claim.
+// Only succeeds if the schedule still belongs to fromWorkerID, so that a schedule is taken over only once.
+func (c memoryRepository) ReassignSchedule(schedule *Schedule, fromWorkerID string) (bool, error) {
+	defer c.lock()()
+	existing, ok := c.store.schedules[schedule.ID]
+	if !ok || existing.WorkerID != fromWorkerID {
+		return false, nil
+	}
+	existing.WorkerID = schedule.WorkerID
+	existing.ExecutionID = schedule.ExecutionID
+	existing.ClaimedBy = pkg.EMPTYSTRING
+	existing.ClaimedAt = nil
+	existing.ReassignedFrom = fromWorkerID
+	existing.UpdatedAt = time.Now()
+	memoryPut(c, c.store.schedules, existing.ID, existing)
+	schedule.ClaimedBy = pkg.EMPTYSTRING
+	schedule.ClaimedAt = nil
+	schedule.ReassignedFrom = fromWorkerID
+	return true, nil
+}
+
+// PostponeSchedule to move a schedule to schedule.ExecutionID, releasing its claim so that it is claimed again when due
+func (c memoryRepository) PostponeSchedule(schedule *Schedule) (*Schedule, error) {
+	defer c.lock()()
+	if existing, ok := c.store.schedules[schedule.ID]; ok {
+		existing.ExecutionID = schedule.ExecutionID
+		existing.ClaimedBy = pkg.EMPTYSTRING
+		existing.ClaimedAt = nil
+		existing.UpdatedAt = time.Now()
+		memoryPut(c, c.store.schedules, existing.ID, existing)
+	}
+	schedule.ClaimedBy = pkg.EMPTYSTRING
+	schedule.ClaimedAt = nil
+	return schedule, nil
+}
+
+// GetSchedulesForJob to get a schedules by jobID
+func (c memoryRepository) GetSchedulesForJob(jobID int64) ([]Schedule, error) {
+	defer c.lock()()
+	return c.sortedSchedules(func(schedule Schedule) bool { return schedule.JobID == jobID }), nil
+}
+
+// GetPendingSchedules to get the schedules which have not started to run, of jobID or of all the jobs when 0,
+// by execution time, at most limit of them when it is not 0
+func (c memoryRepository) GetPendingSchedules(jobID int64, limit int) ([]Schedule, error) {
+	defer c.lock()()
+	schedules := sortByExecutionID(c.sortedSchedules(func(schedule Schedule) bool {
+		return (jobID == 0 || schedule.JobID == jobID) && !c.hasJobHistory(schedule.ID, func(JobHistory) bool { return true })
+	}))
+	if limit > 0 && len(schedules) > limit {
+		schedules = schedules[:limit]
+	}
+	return schedules, nil
+}
+
+// GetSchedulesForWorker to get a schedules by workerID
+func (c memoryRepository) GetSchedulesForWorker(workerID string) ([]Schedule, error) {
+	defer c.lock()()
+	return c.sortedSchedules(func(schedule Schedule) bool { return schedule.WorkerID == workerID }), nil
+}
+
+// GetSchedulesForJobName to get a schedules by jobName and subName
+func (c memoryRepository) GetSchedulesForJobName(jobName string, subName string) ([]Schedule, error) {
+	defer c.lock()()
+	return c.schedulesForJobName(jobName, subName), nil
+}
+
+func (c memoryRepository) schedulesForJobName(jobName string, subName string) []Schedule {
+	schedules := c.sortedSchedules(func(schedule Schedule) bool {
+		job, ok := c.store.jobs[schedule.JobID]
+		return ok && job.JobName == jobName && job.SubName == subName
+	})
+	for i := range schedules {
+		schedules[i].Job = c.store.jobs[schedules[i].JobID]
+	}
+	return schedules
+}
+
+// DeleteScheduleForJob to delete a schedules by jobID
+func (c memoryRepository) DeleteScheduleForJob(jobID int64) ([]Schedule, error) {
+	defer c.lock()()
+	return c.deleteSchedules(c.sortedSchedules(func(schedule Schedule) bool { return schedule.JobID == jobID })), nil
+}
+
+// DeleteScheduleForWorker to delete a schedules by workerID
+func (c memoryRepository) DeleteScheduleForWorker(workerID string) ([]Schedule, error) {
+	defer c.lock()()
+	return c.deleteSchedules(c.sortedSchedules(func(schedule Schedule) bool { return schedule.WorkerID == workerID })), nil
+}
+
+// DeleteScheduleForJobName to delete a schedules by jobName and subName
+func (c memoryRepository) DeleteScheduleForJobName(jobName string, subName string) ([]Schedule, error) {
+	defer c.lock()()
+	return c.deleteSchedules(c.schedulesForJobName(jobName, subName)), nil
+}
+
+func (c memoryRepository) deleteSchedules(schedules []Schedule) []Schedule {
+	for _, schedule := range schedules {
+		memoryDelete(c, c.store.schedules, schedule.ID)
+	}
+	return schedules
+}
+
+// GetWorkerCountByJobID to count number of each worker by jobID
+func (c memoryRepository) GetWorkerCountByJobID(jobID int64) ([]WorkerJobCount, error) {
+	defer c.lock()()
+	counts := make(map[string]int64)
+	for _, jobHistory := range c.store.histories {
+		if jobHistory.JobID == jobID {
+			counts[jobHistory.WorkerID]++
+		}
+	}
+	workerCounts := make([]WorkerJobCount, 0, len(counts))
+	for workerID, count := range counts {
+		workerCounts = append(workerCounts, WorkerJobCount{WorkerID: workerID, Count: count})
+	}
+	sort.Slice(workerCounts, func(i, j int) bool { return workerCounts[i].WorkerID < workerCounts[j].WorkerID })
+	return workerCounts, nil
+}
+
+// GetPendingScheduleCount to count the schedules which have not started to run, by worker
+func (c memoryRepository) GetPendingScheduleCount() ([]WorkerJobCount, error) {
+	defer c.lock()()
+	counts := make(map[string]int64)
+	for _, schedule := range c.store.schedules {
+		if !c.hasJobHistory(schedule.ID, func(JobHistory) bool { return true }) {
+			counts[schedule.WorkerID]++
+		}
+	}
+	workerCounts := make([]WorkerJobCount, 0, len(counts))
+	for workerID, count := range counts {
+		workerCounts = append(workerCounts, WorkerJobCount{WorkerID: workerID, Count: count})
+	}
+	sort.Slice(workerCounts, func(i, j int) bool { return workerCounts[i].WorkerID < workerCounts[j].WorkerID })
+	return workerCounts, nil
+}
+
+// PurgeDeleted nothing is soft-deleted in memory, the deletes remove the records right away
+func (c memoryRepository) PurgeDeleted(before time.Time) (int64, error) {
+	return 0, nil
+}
+
+// Transaction to run fc with a repository holding the lock of the store,
+// the writes of fc are kept when it returns nil and undone otherwise
+func (c memoryRepository) Transaction(fc func(repo CduleRepository) error) (err error) {
+	defer c.lock()()
+	tx := &memoryTx{}
+	defer func() {
+		if r := recover(); r != nil {
+			tx.rollback()
+			panic(r)
+		}
+	}()
+	if err = fc(memoryRepository{store: c.store, tx: tx}); nil != err {
+		tx.rollback()
+		return err
+	}
+	// a nested transaction is undone with its parent
+	if nil != c.tx {
+		c.tx.undo = append(c.tx.undo, tx.undo...)
+	}
+	return nil
+}
@@ -0,0 +1,11 @@
+package model
+
+import (
+	"testing"
+)
+
+func TestRepository_Memory(t *testing.T) {
+	runRepositorySuite(t, func(t *testing.T) CduleRepository {
+		return NewMemoryRepository()
+	})
+}
@@ -0,0 +1,281 @@
+package model
+
+import (
+	"bytes"
+	"database/sql"
+	"embed"
+	"fmt"
+	"hash/fnv"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/gagasdiv/cdule/pkg"
+
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// migrationFiles the versioned migrations of each dialect, migrations/<dialect>/<version>_<name>.sql.
+// A migration is a text/template of the table names, see migrationTables. Its statements end with a ";" line end.
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+// migrationLockWait how long a worker waits for another one to finish migrating, on mysql
+var migrationLockWait = 5 * time.Minute
+
+// migration a versioned change of the schema
+type migration struct {
+	version int
+	name    string
+	sql     *template.Template
+}
+
+// migrationTables names of the tables, with the prefix of the naming strategy
+type migrationTables struct {
+	Jobs         string
+	JobChanges   string
+	JobHistories string
+	Schedules    string
+	Workers      string
+	// Migrations the table of the applied versions
+	Migrations string
+}
+
+// migrationDialect how a dialect quotes names and creates the table of the applied versions
+type migrationDialect struct {
+	quote        string
+	versionTable string
+}
+
+var migrationDialects = map[pkg.Dialect]migrationDialect{
+	pkg.DialectPostgres: {
+		quote:        `"`,
+		versionTable: `CREATE TABLE IF NOT EXISTS "%s" ("version" bigint NOT NULL, "name" text NOT NULL, "applied_at" timestamptz NOT NULL, PRIMARY KEY ("version"))`,
+	},
+	pkg.DialectMySQL: {
+		quote:        "`",
+		versionTable: "CREATE TABLE IF NOT EXISTS `%s` (`version` bigint NOT NULL, `name` varchar(191) NOT NULL, `applied_at` datetime(3) NOT NULL, PRIMARY KEY (`version`))",
+	},
+	pkg.DialectSQLite: {
+		quote:        "`",
+		versionTable: "CREATE TABLE IF NOT EXISTS `%s` (`version` integer NOT NULL, `name` text NOT NULL, `applied_at` datetime NOT NULL, PRIMARY KEY (`version`))",
+	},
+}
+
+func newMigrationTables(namer schema.Namer) (migrationTables, error) {
+	tables := migrationTables{Migrations: namer.TableName("SchemaMigration")}
+	for table, model := range map[*string]interface{}{
+		&tables.Jobs:         &Job{},
+		&tables.JobChanges:   &JobChange{},
+		&tables.JobHistories: &JobHistory{},
+		&tables.Schedules:    &Schedule{},
+		&tables.Workers:      &Worker{},
+	} {
+		s, err := schema.Parse(model, &sync.Map{}, namer)
+		if nil != err {
+			return tables, err
+		}
+		*table = s.Table
+	}
+	return tables, nil
+}
+
+// loadMigrations to get the migrations of the dialect, ordered by version
+func loadMigrations(dialect pkg.Dialect) ([]migration, error) {
+	dir := path.Join("migrations", string(dialect))
+	entries, err := migrationFiles.ReadDir(dir)
+	if nil != err {
+		return nil, fmt.Errorf("unsupported dialect %s, expected %s, %s or %s", dialect, pkg.DialectPostgres, pkg.DialectMySQL, pkg.DialectSQLite)
+	}
+	migrations := make([]migration, 0, len(entries))
+	for _, entry := range entries {
+		fileName := strings.TrimSuffix(entry.Name(), ".sql")
+		parts := strings.SplitN(fileName, "_", 2)
+		version, err := strconv.Atoi(parts[0])
+		if nil != err || len(parts) < 2 {
+			return nil, fmt.Errorf("invalid migration file name %s, expected <version>_<name>.sql", entry.Name())
+		}
+		sqlTemplate, err := template.ParseFS(migrationFiles, path.Join(dir, entry.Name()))
+		if nil != err {
+			return nil, err
+		}
+		migrations = append(migrations, migration{version: version, name: parts[1], sql: sqlTemplate})
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].version < migrations[j].version })
+	return migrations, nil
+}
+
+// statements to get the SQL statements of the migration for the tables
+func (m migration) statements(tables migrationTables) ([]string, error) {
+	var rendered bytes.Buffer
+	if err := m.sql.Execute(&rendered, tables); nil != err {
+		return nil, err
+	}
+	statements := make([]string, 0)
+	var statement strings.Builder
+	for _, line := range strings.Split(rendered.String(), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == pkg.EMPTYSTRING || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		statement.WriteString(line + "\n")
+		if strings.HasSuffix(trimmed, ";") {
+			statements = append(statements, strings.TrimSuffix(strings.TrimSpace(statement.String()), ";"))
+			statement.Reset()
+		}
+	}
+	if rest := strings.TrimSpace(statement.String()); rest != pkg.EMPTYSTRING {
+		statements = append(statements, rest)
+	}
+	return statements, nil
+}
+
+// Migrate to apply the migrations of the dialect of db which have not been applied yet, each in a transaction
+// (mysql commits DDL statements implicitly though), recording their version in the schema_migrations table.
+// The workers starting together wait for the first one to migrate.
+func Migrate(db *gorm.DB) error {
+	dialect := pkg.Dialect(db.Dialector.Name())
+	migrations, err := loadMigrations(dialect)
+	if nil != err {
+		return fmt.Errorf("failed to migrate database schema: %w", err)
+	}
+	tables, err := newMigrationTables(db.NamingStrategy)
+	if nil != err {
+		return fmt.Errorf("failed to migrate database schema: %w", err)
+	}
+	err = withMigrationLock(db, dialect, tables.Migrations, func(conn *gorm.DB) error {
+		if err := conn.Exec(fmt.Sprintf(migrationDialects[dialect].versionTable, tables.Migrations)).Error; nil != err {
+			return err
+		}
+		var applied []int
+		if err := conn.Table(tables.Migrations).Pluck("version", &applied).Error; nil != err {
+			return err
+		}
+		done := make(map[int]bool, len(applied))
+		for _, version := range applied {
+			done[version] = true
+		}
+		for _, m := range migrations {
+			if done[m.version] {
+				continue
+			}
+			if err := applyMigration(conn, m, tables); nil != err {
+				return fmt.Errorf("migration %04d_%s: %w", m.version, m.name, err)
+			}
+			log.Infof("Applied database migration %04d_%s", m.version, m.name)
+		}
+		return nil
+	})
+	if nil != err {
+		return fmt.Errorf("failed to migrate database schema: %w", err)
+	}
+	return nil
+}
+
+func applyMigration(db *gorm.DB, m migration, tables migrationTables) error {
+	statements, err := m.statements(tables)
+	if nil != err {
+		return err
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, statement := range statements {
+			if err := tx.Exec(statement).Error; nil != err {
+				return err
+			}
+		}
+		return tx.Table(tables.Migrations).Create(map[string]interface{}{
+			"version":    m.version,
+			"name":       m.name,
+			"applied_at": time.Now(),
+		}).Error
+	})
+}
+
+// withMigrationLock to run fn on a single connection of db while holding a lock shared by all the workers:
+// an advisory lock on postgres, a named lock on mysql. On sqlite the transaction of each migration
+// takes the write lock of the database.
+func withMigrationLock(db *gorm.DB, dialect pkg.Dialect, name string, fn func(conn *gorm.DB) error) error {
+	locked := func(conn *gorm.DB) error {
+		switch dialect {
+		case pkg.DialectPostgres:
+			key := fnv.New64a()
+			key.Write([]byte(name))
+			if err := conn.Exec("SELECT pg_advisory_lock(?)", int64(key.Sum64())).Error; nil != err {
+				return err
+			}
+			defer conn.Exec("SELECT pg_advisory_unlock(?)", int64(key.Sum64()))
+		case pkg.DialectMySQL:
+			var acquired sql.NullInt64
+			if err := conn.Raw("SELECT GET_LOCK(?, ?)", name, int(migrationLockWait.Seconds())).Scan(&acquired).Error; nil != err {
+				return err
+			}
+			if !acquired.Valid || acquired.Int64 != 1 {
+				return fmt.Errorf("timed out after %s waiting for another worker to migrate", migrationLockWait)
+			}
+			defer conn.Exec("SELECT RELEASE_LOCK(?)", name)
+		}
+		return fn(conn)
+	}
+	if _, err := db.DB(); nil != err {
+		// a transaction of the caller, which is on a single connection already
+		return locked(db)
+	}
+	return db.Connection(locked)
+}
+
+// SchemaVersion to get the version of the last migration applied to db, 0 when none has been
+func SchemaVersion(db *gorm.DB) (int, error) {
+	tables, err := newMigrationTables(db.NamingStrategy)
+	if nil != err {
+		return 0, err
+	}
+	if !db.Migrator().HasTable(tables.Migrations) {
+		return 0, nil
+	}
+	var version sql.NullInt64
+	if err = db.Table(tables.Migrations).Select("MAX(version)").Scan(&version).Error; nil != err {
+		return 0, err
+	}
+	return int(version.Int64), nil
+}
+
+// MigrationSQL to get the SQL of the migrations of the dialect after fromVersion (0 for all of them) with the
+// tables prefixed by tablePrefix, e.g. to review them before applying them by hand with CduleConfig.SkipMigration.
+// It records the versions applied like Migrate does.
+func MigrationSQL(dialect pkg.Dialect, tablePrefix string, fromVersion int) (string, error) {
+	migrations, err := loadMigrations(dialect)
+	if nil != err {
+		return pkg.EMPTYSTRING, err
+	}
+	tables, err := newMigrationTables(schema.NamingStrategy{TablePrefix: tablePrefix})
+	if nil != err {
+		return pkg.EMPTYSTRING, err
+	}
+	q := migrationDialects[dialect].quote
+	var script strings.Builder
+	fmt.Fprintf(&script, "-- cdule schema migrations for %s after version %d\n", dialect, fromVersion)
+	fmt.Fprintf(&script, migrationDialects[dialect].versionTable+";\n", tables.Migrations)
+	for _, m := range migrations {
+		if m.version <= fromVersion {
+			continue
+		}
+		statements, err := m.statements(tables)
+		if nil != err {
+			return pkg.EMPTYSTRING, err
+		}
+		fmt.Fprintf(&script, "\n-- %04d_%s\n", m.version, m.name)
+		for _, statement := range statements {
+			script.WriteString(statement + ";\n")
+		}
+		fmt.Fprintf(&script, "INSERT INTO %[1]s%[2]s%[1]s (%[1]sversion%[1]s, %[1]sname%[1]s, %[1]sapplied_at%[1]s) VALUES (%[3]d, '%[4]s', CURRENT_TIMESTAMP);\n",
+			q, tables.Migrations, m.version, m.name)
+	}
+	return script.String(), nil
+}
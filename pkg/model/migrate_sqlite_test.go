@@ -0,0 +1,162 @@
+//go:build cgo
+
+package model
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/gagasdiv/cdule/pkg"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
+)
+
+func openMigrateTestDB(t *testing.T, tablePrefix string) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "cdule.db")), &gorm.Config{
+		NamingStrategy: schema.NamingStrategy{TablePrefix: tablePrefix},
+		Logger:         logger.Default.LogMode(logger.Silent),
+	})
+	require.NoError(t, err)
+	return db
+}
+
+func latestSchemaVersion(t *testing.T) int {
+	migrations, err := loadMigrations(pkg.DialectSQLite)
+	require.NoError(t, err)
+	return migrations[len(migrations)-1].version
+}
+
+func Test_Migrate(t *testing.T) {
+	db := openMigrateTestDB(t, "app_")
+	version, err := SchemaVersion(db)
+	require.NoError(t, err)
+	require.Equal(t, 0, version)
+
+	require.NoError(t, Migrate(db))
+	// a second start has nothing to apply
+	require.NoError(t, Migrate(db))
+	version, err = SchemaVersion(db)
+	require.NoError(t, err)
+	require.Equal(t, latestSchemaVersion(t), version)
+
+	// the tables have every column of the models
+	for _, model := range []interface{}{&Job{}, &JobChange{}, &JobHistory{}, &Schedule{}, &Worker{}} {
+		s, err := schema.Parse(model, &sync.Map{}, db.NamingStrategy)
+		require.NoError(t, err)
+		require.Contains(t, s.Table, "app_")
+		for _, field := range s.Fields {
+			if field.DBName != "" {
+				require.True(t, db.Migrator().HasColumn(model, field.DBName), "%s.%s", s.Table, field.DBName)
+			}
+		}
+	}
+	require.True(t, db.Migrator().HasIndex(&Schedule{}, "idx_app_schedules_worker_execution"))
+	_, err = NewCduleRepository(db).CreateJob(&Job{JobName: "job.Migrated"})
+	require.NoError(t, err)
+}
+
+// the models of the first release of cdule, whose tables were created by AutoMigrate
+type baselineJob struct {
+	Model
+	JobName        string `gorm:"index"`
+	SubName        string
+	CronExpression string
+	Expired        bool
+	Once           bool
+	JobData        string
+}
+
+func (baselineJob) TableName() string { return "jobs" }
+
+type baselineSchedule struct {
+	Model
+	ExecutionID int64
+	JobID       int64
+	Job         baselineJob `gorm:"foreignKey:job_id;references:id;constraint:OnDelete:CASCADE"`
+	WorkerID    string
+	JobData     string
+}
+
+func (baselineSchedule) TableName() string { return "schedules" }
+
+type baselineJobHistory struct {
+	Model
+	JobID      int64
+	Job        baselineJob `gorm:"foreignKey:job_id;references:id;constraint:OnDelete:CASCADE"`
+	ScheduleID int64
+	Schedule   baselineSchedule `gorm:"foreignKey:schedule_id;references:id;constraint:OnDelete:CASCADE"`
+	Status     JobStatus
+	WorkerID   string
+	RetryCount int
+}
+
+func (baselineJobHistory) TableName() string { return "job_histories" }
+
+func Test_MigrateBaselineDatabase(t *testing.T) {
+	db := openMigrateTestDB(t, "")
+	// a database created by the first release, before the migrations were versioned
+	require.NoError(t, db.AutoMigrate(&baselineJob{}, &baselineSchedule{}, &baselineJobHistory{}, &Worker{}))
+	job := &baselineJob{JobName: "job.Kept", CronExpression: "0 * * * * *"}
+	require.NoError(t, db.Create(job).Error)
+	schedule := &baselineSchedule{ExecutionID: 1, JobID: job.ID, WorkerID: "worker1"}
+	require.NoError(t, db.Omit("Job").Create(schedule).Error)
+	require.NoError(t, db.Omit("Job", "Schedule").Create(&baselineJobHistory{JobID: job.ID, ScheduleID: schedule.ID, Status: JobStatusCompleted}).Error)
+
+	require.NoError(t, Migrate(db))
+	version, err := SchemaVersion(db)
+	require.NoError(t, err)
+	require.Equal(t, latestSchemaVersion(t), version)
+	for _, model := range []interface{}{&Job{}, &JobChange{}, &JobHistory{}, &Schedule{}, &Worker{}} {
+		s, err := schema.Parse(model, &sync.Map{}, db.NamingStrategy)
+		require.NoError(t, err)
+		for _, field := range s.Fields {
+			if field.DBName != "" {
+				require.True(t, db.Migrator().HasColumn(model, field.DBName), "%s.%s", s.Table, field.DBName)
+			}
+		}
+	}
+	require.True(t, db.Migrator().HasIndex(&Schedule{}, "idx_schedules_claimed_by"))
+
+	// the rows of the first release are kept, the existing schedule is unclaimed
+	repo := NewCduleRepository(db)
+	kept, err := repo.GetJob(job.ID)
+	require.NoError(t, err)
+	require.Equal(t, "job.Kept", kept.JobName)
+	require.False(t, kept.Paused)
+	schedules, err := repo.GetScheduleBetween(0, 2, "worker1")
+	require.NoError(t, err)
+	require.Len(t, schedules, 1)
+	claimed, err := repo.ClaimSchedule(&schedules[0], "worker1")
+	require.NoError(t, err)
+	require.True(t, claimed)
+	jobHistories, err := repo.FindJobHistory(JobHistoryFilter{JobID: job.ID})
+	require.NoError(t, err)
+	require.Len(t, jobHistories, 1)
+}
+
+func Test_MigrateWithMigrationSQL(t *testing.T) {
+	db := openMigrateTestDB(t, "")
+	// the script reviewed and applied by hand is recorded, so Migrate has nothing left to apply
+	script, err := MigrationSQL(pkg.DialectSQLite, "", 0)
+	require.NoError(t, err)
+	require.NoError(t, db.Exec(script).Error)
+	version, err := SchemaVersion(db)
+	require.NoError(t, err)
+	require.Equal(t, latestSchemaVersion(t), version)
+	require.NoError(t, Migrate(db))
+}
+
+func Test_MigrateInTransaction(t *testing.T) {
+	db := openMigrateTestDB(t, "")
+	require.NoError(t, db.Transaction(func(tx *gorm.DB) error {
+		return Migrate(tx)
+	}))
+	version, err := SchemaVersion(db)
+	require.NoError(t, err)
+	require.Equal(t, latestSchemaVersion(t), version)
+}
@@ -0,0 +1,47 @@
+package model
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gagasdiv/cdule/pkg"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_MigrationsOfEveryDialect(t *testing.T) {
+	sqlite, err := loadMigrations(pkg.DialectSQLite)
+	require.NoError(t, err)
+	require.NotEmpty(t, sqlite)
+	// every dialect has the same versions, so that a version means the same schema everywhere
+	for _, dialect := range []pkg.Dialect{pkg.DialectPostgres, pkg.DialectMySQL} {
+		migrations, err := loadMigrations(dialect)
+		require.NoError(t, err)
+		require.Equal(t, len(sqlite), len(migrations), dialect)
+		for i, m := range migrations {
+			require.Equal(t, sqlite[i].version, m.version, dialect)
+			require.Equal(t, sqlite[i].name, m.name, dialect)
+		}
+	}
+	_, err = loadMigrations("oracle")
+	require.Error(t, err)
+}
+
+func Test_MigrationSQL(t *testing.T) {
+	script, err := MigrationSQL(pkg.DialectPostgres, "app_", 0)
+	require.NoError(t, err)
+	require.Contains(t, script, `CREATE TABLE IF NOT EXISTS "app_schema_migrations"`)
+	require.Contains(t, script, `CREATE TABLE IF NOT EXISTS "app_jobs"`)
+	require.Contains(t, script, `REFERENCES "app_schedules" ("id")`)
+	require.Contains(t, script, `INSERT INTO "app_schema_migrations" ("version", "name", "applied_at") VALUES (1, 'init', CURRENT_TIMESTAMP);`)
+	require.NotContains(t, script, "{{")
+
+	script, err = MigrationSQL(pkg.DialectMySQL, "", 1)
+	require.NoError(t, err)
+	require.NotContains(t, script, "0001_init")
+	require.Contains(t, script, "-- 0002_composite_indexes")
+	require.Contains(t, script, "CREATE INDEX `idx_schedules_worker_execution` ON `schedules`")
+	require.Contains(t, script, "ALTER TABLE `jobs` ADD COLUMN `paused` boolean DEFAULT false;")
+	require.Contains(t, script, "ALTER TABLE `schedules` ADD COLUMN `claimed_by` varchar(191) DEFAULT '';")
+	require.Equal(t, 4, strings.Count(script, "INSERT INTO `schema_migrations`"))
+}
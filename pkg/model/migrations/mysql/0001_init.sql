@@ -0,0 +1,56 @@
+-- the tables as created by gorm AutoMigrate before the migrations were versioned, so that a database created by an
+-- earlier version is kept as it is. The columns added since are added by the later migrations.
+CREATE TABLE IF NOT EXISTS `{{.Jobs}}` (
+	`id` bigint AUTO_INCREMENT,
+	`created_at` datetime(3) NULL,
+	`updated_at` datetime(3) NULL,
+	`deleted_at` datetime(3) NULL,
+	`job_name` varchar(191),
+	`sub_name` longtext,
+	`cron_expression` longtext,
+	`expired` boolean,
+	`once` boolean,
+	`job_data` longtext,
+	PRIMARY KEY (`id`),
+	INDEX `idx_{{.Jobs}}_job_name` (`job_name`),
+	INDEX `idx_{{.Jobs}}_deleted_at` (`deleted_at`)
+);
+
+CREATE TABLE IF NOT EXISTS `{{.Schedules}}` (
+	`id` bigint AUTO_INCREMENT,
+	`created_at` datetime(3) NULL,
+	`updated_at` datetime(3) NULL,
+	`deleted_at` datetime(3) NULL,
+	`execution_id` bigint,
+	`job_id` bigint,
+	`worker_id` longtext,
+	`job_data` longtext,
+	PRIMARY KEY (`id`),
+	INDEX `idx_{{.Schedules}}_deleted_at` (`deleted_at`),
+	CONSTRAINT `fk_{{.Schedules}}_job` FOREIGN KEY (`job_id`) REFERENCES `{{.Jobs}}` (`id`) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS `{{.JobHistories}}` (
+	`id` bigint AUTO_INCREMENT,
+	`created_at` datetime(3) NULL,
+	`updated_at` datetime(3) NULL,
+	`deleted_at` datetime(3) NULL,
+	`job_id` bigint,
+	`schedule_id` bigint,
+	`status` longtext,
+	`worker_id` longtext,
+	`retry_count` bigint,
+	PRIMARY KEY (`id`),
+	INDEX `idx_{{.JobHistories}}_deleted_at` (`deleted_at`),
+	CONSTRAINT `fk_{{.JobHistories}}_job` FOREIGN KEY (`job_id`) REFERENCES `{{.Jobs}}` (`id`) ON DELETE CASCADE,
+	CONSTRAINT `fk_{{.JobHistories}}_schedule` FOREIGN KEY (`schedule_id`) REFERENCES `{{.Schedules}}` (`id`) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS `{{.Workers}}` (
+	`worker_id` varchar(191),
+	`created_at` datetime(3) NULL,
+	`updated_at` datetime(3) NULL,
+	`deleted_at` datetime(3) NULL,
+	PRIMARY KEY (`worker_id`),
+	INDEX `idx_{{.Workers}}_deleted_at` (`deleted_at`)
+);
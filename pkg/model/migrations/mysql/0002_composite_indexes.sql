@@ -0,0 +1,5 @@
+-- the due schedules of a worker, and the runs of a schedule or in progress for a job.
+-- worker_id and status are longtext, their prefix is indexed
+CREATE INDEX `idx_{{.Schedules}}_worker_execution` ON `{{.Schedules}}` (`worker_id`(191), `execution_id`);
+CREATE INDEX `idx_{{.JobHistories}}_schedule_id` ON `{{.JobHistories}}` (`schedule_id`);
+CREATE INDEX `idx_{{.JobHistories}}_job_status` ON `{{.JobHistories}}` (`job_id`, `status`(32));
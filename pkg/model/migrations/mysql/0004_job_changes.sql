@@ -0,0 +1,14 @@
+-- the changes of the jobs updated in place
+CREATE TABLE IF NOT EXISTS `{{.JobChanges}}` (
+	`id` bigint AUTO_INCREMENT,
+	`created_at` datetime(3) NULL,
+	`updated_at` datetime(3) NULL,
+	`deleted_at` datetime(3) NULL,
+	`job_id` bigint,
+	`worker_id` longtext,
+	`changes` longtext,
+	PRIMARY KEY (`id`),
+	INDEX `idx_{{.JobChanges}}_job_id` (`job_id`),
+	INDEX `idx_{{.JobChanges}}_deleted_at` (`deleted_at`),
+	CONSTRAINT `fk_{{.JobChanges}}_job` FOREIGN KEY (`job_id`) REFERENCES `{{.Jobs}}` (`id`) ON DELETE CASCADE
+);
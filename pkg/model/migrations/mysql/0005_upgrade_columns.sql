@@ -0,0 +1,20 @@
+-- the columns of the retries, timeouts, time zones, overlap and misfire policies and claims of the schedules,
+-- added to the tables created by 0001_init
+ALTER TABLE `{{.Jobs}}` ADD COLUMN `retry_max_attempts` bigint;
+ALTER TABLE `{{.Jobs}}` ADD COLUMN `retry_backoff` longtext;
+ALTER TABLE `{{.Jobs}}` ADD COLUMN `retry_interval` bigint;
+ALTER TABLE `{{.Jobs}}` ADD COLUMN `retry_max_interval` bigint;
+ALTER TABLE `{{.Jobs}}` ADD COLUMN `retry_jitter` double;
+ALTER TABLE `{{.Jobs}}` ADD COLUMN `time_zone` longtext;
+ALTER TABLE `{{.Jobs}}` ADD COLUMN `timeout` bigint;
+ALTER TABLE `{{.Jobs}}` ADD COLUMN `overlap_policy` longtext;
+ALTER TABLE `{{.Jobs}}` ADD COLUMN `misfire_policy` longtext;
+ALTER TABLE `{{.Jobs}}` ADD COLUMN `misfire_threshold` bigint;
+ALTER TABLE `{{.Schedules}}` ADD COLUMN `attempt` bigint;
+ALTER TABLE `{{.Schedules}}` ADD COLUMN `claimed_by` varchar(191) DEFAULT '';
+ALTER TABLE `{{.Schedules}}` ADD COLUMN `claimed_at` datetime(3) NULL;
+ALTER TABLE `{{.Schedules}}` ADD COLUMN `reassigned_from` longtext;
+ALTER TABLE `{{.JobHistories}}` ADD COLUMN `output` longtext;
+ALTER TABLE `{{.JobHistories}}` ADD COLUMN `error_message` longtext;
+ALTER TABLE `{{.JobHistories}}` ADD COLUMN `cancel_requested` boolean DEFAULT false;
+CREATE INDEX `idx_{{.Schedules}}_claimed_by` ON `{{.Schedules}}` (`claimed_by`);
@@ -0,0 +1,56 @@
+-- the tables as created by gorm AutoMigrate before the migrations were versioned, so that a database created by an
+-- earlier version is kept as it is. The columns added since are added by the later migrations.
+CREATE TABLE IF NOT EXISTS "{{.Jobs}}" (
+	"id" bigserial,
+	"created_at" timestamptz,
+	"updated_at" timestamptz,
+	"deleted_at" timestamptz,
+	"job_name" text,
+	"sub_name" text,
+	"cron_expression" text,
+	"expired" boolean,
+	"once" boolean,
+	"job_data" text,
+	PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_{{.Jobs}}_job_name" ON "{{.Jobs}}" ("job_name");
+CREATE INDEX IF NOT EXISTS "idx_{{.Jobs}}_deleted_at" ON "{{.Jobs}}" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "{{.Schedules}}" (
+	"id" bigserial,
+	"created_at" timestamptz,
+	"updated_at" timestamptz,
+	"deleted_at" timestamptz,
+	"execution_id" bigint,
+	"job_id" bigint,
+	"worker_id" text,
+	"job_data" text,
+	PRIMARY KEY ("id"),
+	CONSTRAINT "fk_{{.Schedules}}_job" FOREIGN KEY ("job_id") REFERENCES "{{.Jobs}}" ("id") ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "idx_{{.Schedules}}_deleted_at" ON "{{.Schedules}}" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "{{.JobHistories}}" (
+	"id" bigserial,
+	"created_at" timestamptz,
+	"updated_at" timestamptz,
+	"deleted_at" timestamptz,
+	"job_id" bigint,
+	"schedule_id" bigint,
+	"status" text,
+	"worker_id" text,
+	"retry_count" bigint,
+	PRIMARY KEY ("id"),
+	CONSTRAINT "fk_{{.JobHistories}}_job" FOREIGN KEY ("job_id") REFERENCES "{{.Jobs}}" ("id") ON DELETE CASCADE,
+	CONSTRAINT "fk_{{.JobHistories}}_schedule" FOREIGN KEY ("schedule_id") REFERENCES "{{.Schedules}}" ("id") ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "idx_{{.JobHistories}}_deleted_at" ON "{{.JobHistories}}" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "{{.Workers}}" (
+	"worker_id" text,
+	"created_at" timestamptz,
+	"updated_at" timestamptz,
+	"deleted_at" timestamptz,
+	PRIMARY KEY ("worker_id")
+);
+CREATE INDEX IF NOT EXISTS "idx_{{.Workers}}_deleted_at" ON "{{.Workers}}" ("deleted_at");
@@ -0,0 +1,4 @@
+-- the due schedules of a worker, and the runs of a schedule or in progress for a job
+CREATE INDEX IF NOT EXISTS "idx_{{.Schedules}}_worker_execution" ON "{{.Schedules}}" ("worker_id", "execution_id");
+CREATE INDEX IF NOT EXISTS "idx_{{.JobHistories}}_schedule_id" ON "{{.JobHistories}}" ("schedule_id");
+CREATE INDEX IF NOT EXISTS "idx_{{.JobHistories}}_job_status" ON "{{.JobHistories}}" ("job_id", "status");
@@ -0,0 +1,3 @@
+-- the jobs paused by hand, and the schedules of the runs triggered by hand
+ALTER TABLE "{{.Jobs}}" ADD COLUMN IF NOT EXISTS "paused" boolean DEFAULT false;
+ALTER TABLE "{{.Schedules}}" ADD COLUMN IF NOT EXISTS "triggered" boolean DEFAULT false;
@@ -0,0 +1,20 @@
+-- the columns of the retries, timeouts, time zones, overlap and misfire policies and claims of the schedules,
+-- added to the tables created by 0001_init
+ALTER TABLE "{{.Jobs}}" ADD COLUMN IF NOT EXISTS "retry_max_attempts" bigint;
+ALTER TABLE "{{.Jobs}}" ADD COLUMN IF NOT EXISTS "retry_backoff" text;
+ALTER TABLE "{{.Jobs}}" ADD COLUMN IF NOT EXISTS "retry_interval" bigint;
+ALTER TABLE "{{.Jobs}}" ADD COLUMN IF NOT EXISTS "retry_max_interval" bigint;
+ALTER TABLE "{{.Jobs}}" ADD COLUMN IF NOT EXISTS "retry_jitter" decimal;
+ALTER TABLE "{{.Jobs}}" ADD COLUMN IF NOT EXISTS "time_zone" text;
+ALTER TABLE "{{.Jobs}}" ADD COLUMN IF NOT EXISTS "timeout" bigint;
+ALTER TABLE "{{.Jobs}}" ADD COLUMN IF NOT EXISTS "overlap_policy" text;
+ALTER TABLE "{{.Jobs}}" ADD COLUMN IF NOT EXISTS "misfire_policy" text;
+ALTER TABLE "{{.Jobs}}" ADD COLUMN IF NOT EXISTS "misfire_threshold" bigint;
+ALTER TABLE "{{.Schedules}}" ADD COLUMN IF NOT EXISTS "attempt" bigint;
+ALTER TABLE "{{.Schedules}}" ADD COLUMN IF NOT EXISTS "claimed_by" text DEFAULT '';
+ALTER TABLE "{{.Schedules}}" ADD COLUMN IF NOT EXISTS "claimed_at" timestamptz;
+ALTER TABLE "{{.Schedules}}" ADD COLUMN IF NOT EXISTS "reassigned_from" text;
+ALTER TABLE "{{.JobHistories}}" ADD COLUMN IF NOT EXISTS "output" text;
+ALTER TABLE "{{.JobHistories}}" ADD COLUMN IF NOT EXISTS "error_message" text;
+ALTER TABLE "{{.JobHistories}}" ADD COLUMN IF NOT EXISTS "cancel_requested" boolean DEFAULT false;
+CREATE INDEX IF NOT EXISTS "idx_{{.Schedules}}_claimed_by" ON "{{.Schedules}}" ("claimed_by");
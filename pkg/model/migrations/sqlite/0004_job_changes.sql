@@ -0,0 +1,14 @@
+-- the changes of the jobs updated in place
+CREATE TABLE IF NOT EXISTS `{{.JobChanges}}` (
+	`id` integer,
+	`created_at` datetime,
+	`updated_at` datetime,
+	`deleted_at` datetime,
+	`job_id` integer,
+	`worker_id` text,
+	`changes` text,
+	PRIMARY KEY (`id`),
+	CONSTRAINT `fk_{{.JobChanges}}_job` FOREIGN KEY (`job_id`) REFERENCES `{{.Jobs}}` (`id`) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS `idx_{{.JobChanges}}_job_id` ON `{{.JobChanges}}` (`job_id`);
+CREATE INDEX IF NOT EXISTS `idx_{{.JobChanges}}_deleted_at` ON `{{.JobChanges}}` (`deleted_at`);
@@ -0,0 +1,20 @@
+-- the columns of the retries, timeouts, time zones, overlap and misfire policies and claims of the schedules,
+-- added to the tables created by 0001_init
+ALTER TABLE `{{.Jobs}}` ADD COLUMN `retry_max_attempts` integer;
+ALTER TABLE `{{.Jobs}}` ADD COLUMN `retry_backoff` text;
+ALTER TABLE `{{.Jobs}}` ADD COLUMN `retry_interval` integer;
+ALTER TABLE `{{.Jobs}}` ADD COLUMN `retry_max_interval` integer;
+ALTER TABLE `{{.Jobs}}` ADD COLUMN `retry_jitter` real;
+ALTER TABLE `{{.Jobs}}` ADD COLUMN `time_zone` text;
+ALTER TABLE `{{.Jobs}}` ADD COLUMN `timeout` integer;
+ALTER TABLE `{{.Jobs}}` ADD COLUMN `overlap_policy` text;
+ALTER TABLE `{{.Jobs}}` ADD COLUMN `misfire_policy` text;
+ALTER TABLE `{{.Jobs}}` ADD COLUMN `misfire_threshold` integer;
+ALTER TABLE `{{.Schedules}}` ADD COLUMN `attempt` integer;
+ALTER TABLE `{{.Schedules}}` ADD COLUMN `claimed_by` text DEFAULT "";
+ALTER TABLE `{{.Schedules}}` ADD COLUMN `claimed_at` datetime;
+ALTER TABLE `{{.Schedules}}` ADD COLUMN `reassigned_from` text;
+ALTER TABLE `{{.JobHistories}}` ADD COLUMN `output` text;
+ALTER TABLE `{{.JobHistories}}` ADD COLUMN `error_message` text;
+ALTER TABLE `{{.JobHistories}}` ADD COLUMN `cancel_requested` numeric DEFAULT false;
+CREATE INDEX IF NOT EXISTS `idx_{{.Schedules}}_claimed_by` ON `{{.Schedules}}` (`claimed_by`);
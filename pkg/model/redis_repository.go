@@ -0,0 +1,1366 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/gagasdiv/cdule/pkg"
+)
+
+// redisRepository a CduleRepository storing the records as JSON in redis hashes, one hash per model.
+// Sorted sets index the schedules by ExecutionID and the schedules, job histories and job changes of a job or
+// schedule by id.
+// A worker is alive while its heartbeat key, set with a TTL of 3 hearts when it is created or updated, exists.
+type redisRepository struct {
+	client redis.UniversalClient
+	keys   redisKeys
+	ctx    context.Context
+	Heart  time.Duration
+	// transaction the repository is bound to, nil outside of a transaction
+	tx *redisTx
+}
+
+// redisKeys names of the keys of a repository, all starting with its prefix
+type redisKeys struct {
+	prefix     string
+	workers    string
+	jobs       string
+	histories  string
+	changes    string
+	schedules  string
+	executions string
+	lock       string
+}
+
+func newRedisKeys(prefix string) redisKeys {
+	return redisKeys{
+		prefix:     prefix,
+		workers:    prefix + "workers",
+		jobs:       prefix + "jobs",
+		histories:  prefix + "job_histories",
+		changes:    prefix + "job_changes",
+		schedules:  prefix + "schedules",
+		executions: prefix + "schedules:execution",
+		lock:       prefix + "lock",
+	}
+}
+
+func (k redisKeys) alive(workerID string) string {
+	return k.prefix + "worker:" + workerID + ":alive"
+}
+
+func (k redisKeys) sequence(table string) string {
+	return k.prefix + "seq:" + table
+}
+
+func (k redisKeys) jobSchedules(jobID int64) string {
+	return k.prefix + "job:" + redisID(jobID) + ":schedules"
+}
+
+func (k redisKeys) jobHistories(jobID int64) string {
+	return k.prefix + "job:" + redisID(jobID) + ":job_histories"
+}
+
+func (k redisKeys) jobChanges(jobID int64) string {
+	return k.prefix + "job:" + redisID(jobID) + ":job_changes"
+}
+
+func (k redisKeys) scheduleHistories(scheduleID int64) string {
+	return k.prefix + "schedule:" + redisID(scheduleID) + ":job_histories"
+}
+
+// redisTx undo log of a transaction
+type redisTx struct {
+	undo []func() error
+}
+
+func (tx *redisTx) rollback() error {
+	var err error
+	for i := len(tx.undo) - 1; i >= 0; i-- {
+		if undoErr := tx.undo[i](); nil != undoErr && nil == err {
+			err = undoErr
+		}
+	}
+	tx.undo = nil
+	return err
+}
+
+const (
+	// redisWriteRetries how many times a write is retried when another worker changed the same hash meanwhile
+	redisWriteRetries = 100
+	// redisLockTTL how long the transaction lock is kept when its holder does not release it nor renew it, e.g.
+	// after a crash
+	redisLockTTL = 30 * time.Second
+	// redisLockWait how long a transaction waits for the lock
+	redisLockWait = 30 * time.Second
+)
+
+// redisLockRenewal how often the holder of the transaction lock renews it, so that a transaction longer than
+// redisLockTTL keeps it
+var redisLockRenewal = redisLockTTL / 3
+
+var (
+	errRedisConflict    = errors.New("redis write conflicted with other workers too many times")
+	errRedisLockTimeout = errors.New("timed out waiting for the redis transaction lock")
+)
+
+// redisUnlock deletes the lock only if it is still held with the token of the caller
+var redisUnlock = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+// redisRenew extends the lock only if it is still held with the token of the caller
+var redisRenew = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+
+// redisBumpSequence raises a sequence to an id given by the caller, like an insert with an id does to an auto increment
+var redisBumpSequence = redis.NewScript(`
+if tonumber(redis.call("GET", KEYS[1]) or "0") < tonumber(ARGV[1]) then
+	redis.call("SET", KEYS[1], ARGV[1])
+end
+return 0`)
+
+// NewRedisRepository to create a repository storing everything in redis with client, under keys starting with
+// prefix (e.g. "cdule:"). With a redis cluster the prefix must be a hash tag such as "{cdule}:", so that all the
+// keys are in the same slot.
+// Its transactions are weaker than those of a database: they are serialized by a lock key renewed while they run,
+// but their writes are visible to the reads outside of a transaction before they complete, a transaction which
+// fails restores the records it wrote as they were before it, overwriting the writes made meanwhile outside of a
+// transaction, and a worker crashing in the middle of a transaction leaves its writes so far.
+func NewRedisRepository(client redis.UniversalClient, prefix string) CduleRepository {
+	return redisRepository{
+		client: client,
+		keys:   newRedisKeys(prefix),
+		ctx:    context.Background(),
+		Heart:  30 * time.Second,
+	}
+}
+
+func redisID(id int64) string {
+	return strconv.FormatInt(id, 10)
+}
+
+// redisScore to get the score of an ExecutionID. Scores are float64, so that a range by score can include
+// a few more schedules than the exact range, which are then filtered out.
+func redisScore(executionID int64) string {
+	return strconv.FormatFloat(float64(executionID), 'f', -1, 64)
+}
+
+// redisIndex a sorted set the record is a member of, by its id
+type redisIndex struct {
+	key   string
+	score float64
+}
+
+// redisTable a hash of the records of a model by id, and the indexes of a record
+type redisTable[T any] struct {
+	key     string
+	id      func(record *T) string
+	indexes func(record *T) []redisIndex
+}
+
+func (c redisRepository) workerTable() redisTable[Worker] {
+	return redisTable[Worker]{
+		key:     c.keys.workers,
+		id:      func(worker *Worker) string { return worker.WorkerID },
+		indexes: func(*Worker) []redisIndex { return nil },
+	}
+}
+
+func (c redisRepository) jobTable() redisTable[Job] {
+	return redisTable[Job]{
+		key:     c.keys.jobs,
+		id:      func(job *Job) string { return redisID(job.ID) },
+		indexes: func(*Job) []redisIndex { return nil },
+	}
+}
+
+func (c redisRepository) historyTable() redisTable[JobHistory] {
+	return redisTable[JobHistory]{
+		key: c.keys.histories,
+		id:  func(jobHistory *JobHistory) string { return redisID(jobHistory.ID) },
+		indexes: func(jobHistory *JobHistory) []redisIndex {
+			return []redisIndex{
+				{key: c.keys.jobHistories(jobHistory.JobID), score: float64(jobHistory.ID)},
+				{key: c.keys.scheduleHistories(jobHistory.ScheduleID), score: float64(jobHistory.ID)},
+			}
+		},
+	}
+}
+
+func (c redisRepository) changeTable() redisTable[JobChange] {
+	return redisTable[JobChange]{
+		key: c.keys.changes,
+		id:  func(jobChange *JobChange) string { return redisID(jobChange.ID) },
+		indexes: func(jobChange *JobChange) []redisIndex {
+			return []redisIndex{{key: c.keys.jobChanges(jobChange.JobID), score: float64(jobChange.ID)}}
+		},
+	}
+}
+
+func (c redisRepository) scheduleTable() redisTable[Schedule] {
+	return redisTable[Schedule]{
+		key: c.keys.schedules,
+		id:  func(schedule *Schedule) string { return redisID(schedule.ID) },
+		indexes: func(schedule *Schedule) []redisIndex {
+			return []redisIndex{
+				{key: c.keys.executions, score: float64(schedule.ExecutionID)},
+				{key: c.keys.jobSchedules(schedule.JobID), score: float64(schedule.ID)},
+			}
+		},
+	}
+}
+
+func (table redisTable[T]) get(ctx context.Context, client redis.Cmdable, id string) (*T, error) {
+	data, err := client.HGet(ctx, table.key, id).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if nil != err {
+		return nil, err
+	}
+	var record T
+	if err = json.Unmarshal([]byte(data), &record); nil != err {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// getMany to get the records of ids which exist, in the order of ids
+func (table redisTable[T]) getMany(ctx context.Context, client redis.Cmdable, ids []string) ([]T, error) {
+	records := make([]T, 0, len(ids))
+	if len(ids) == 0 {
+		return records, nil
+	}
+	values, err := client.HMGet(ctx, table.key, ids...).Result()
+	if nil != err {
+		return nil, err
+	}
+	for _, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			continue
+		}
+		var record T
+		if err = json.Unmarshal([]byte(data), &record); nil != err {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func (table redisTable[T]) all(ctx context.Context, client redis.Cmdable) ([]T, error) {
+	values, err := client.HVals(ctx, table.key).Result()
+	if nil != err {
+		return nil, err
+	}
+	records := make([]T, 0, len(values))
+	for _, data := range values {
+		var record T
+		if err = json.Unmarshal([]byte(data), &record); nil != err {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// put to replace old, nil when the record is new, by record
+func (table redisTable[T]) put(ctx context.Context, pipe redis.Pipeliner, old, record *T) error {
+	data, err := json.Marshal(record)
+	if nil != err {
+		return err
+	}
+	id := table.id(record)
+	table.delete(ctx, pipe, old)
+	pipe.HSet(ctx, table.key, id, data)
+	for _, index := range table.indexes(record) {
+		pipe.ZAdd(ctx, index.key, redis.Z{Score: index.score, Member: id})
+	}
+	return nil
+}
+
+func (table redisTable[T]) delete(ctx context.Context, pipe redis.Pipeliner, old *T) {
+	if nil == old {
+		return
+	}
+	id := table.id(old)
+	pipe.HDel(ctx, table.key, id)
+	for _, index := range table.indexes(old) {
+		pipe.ZRem(ctx, index.key, id)
+	}
+}
+
+// write to run fn with the hash watched, fn is retried when another worker changed the hash before its writes
+func (c redisRepository) write(key string, fn func(tx *redis.Tx) error) error {
+	for i := 0; i < redisWriteRetries; i++ {
+		err := c.client.Watch(c.ctx, fn, key)
+		if !errors.Is(err, redis.TxFailedErr) {
+			return err
+		}
+	}
+	return errRedisConflict
+}
+
+func (c redisRepository) record(undo func() error) {
+	if nil != c.tx {
+		c.tx.undo = append(c.tx.undo, undo)
+	}
+}
+
+// redisUpdate to replace the record id of table by the one update returns from a copy of the current one (nil when it
+// does not exist), atomically. Nothing is written when update returns nil. Returns the record written.
+func redisUpdate[T any](c redisRepository, table redisTable[T], id string, update func(current *T) (*T, error)) (*T, error) {
+	var old, updated *T
+	err := c.write(table.key, func(tx *redis.Tx) error {
+		var err error
+		if old, err = table.get(c.ctx, tx, id); nil != err {
+			return err
+		}
+		var current *T
+		if nil != old {
+			copied := *old
+			current = &copied
+		}
+		if updated, err = update(current); nil != err || nil == updated {
+			return err
+		}
+		_, err = tx.TxPipelined(c.ctx, func(pipe redis.Pipeliner) error {
+			return table.put(c.ctx, pipe, old, updated)
+		})
+		return err
+	})
+	if nil != err || nil == updated {
+		return nil, err
+	}
+	c.record(func() error { return redisRestore(c, table, id, old) })
+	return updated, nil
+}
+
+// redisDelete to delete the record id of table, returns nil when it does not exist
+func redisDelete[T any](c redisRepository, table redisTable[T], id string) (*T, error) {
+	var old *T
+	err := c.write(table.key, func(tx *redis.Tx) error {
+		var err error
+		if old, err = table.get(c.ctx, tx, id); nil != err || nil == old {
+			return err
+		}
+		_, err = tx.TxPipelined(c.ctx, func(pipe redis.Pipeliner) error {
+			table.delete(c.ctx, pipe, old)
+			return nil
+		})
+		return err
+	})
+	if nil != err || nil == old {
+		return nil, err
+	}
+	c.record(func() error { return redisRestore(c, table, id, old) })
+	return old, nil
+}
+
+// redisRestore to put back the record id of table as it was before a transaction, nil when it did not exist
+func redisRestore[T any](c redisRepository, table redisTable[T], id string, old *T) error {
+	return c.write(table.key, func(tx *redis.Tx) error {
+		current, err := table.get(c.ctx, tx, id)
+		if nil != err {
+			return err
+		}
+		_, err = tx.TxPipelined(c.ctx, func(pipe redis.Pipeliner) error {
+			if nil == old {
+				table.delete(c.ctx, pipe, current)
+				return nil
+			}
+			return table.put(c.ctx, pipe, current, old)
+		})
+		return err
+	})
+}
+
+// nextID to get the next id of a table, like auto increments they are not rolled back
+func (c redisRepository) nextID(table string) (int64, error) {
+	return c.client.Incr(c.ctx, c.keys.sequence(table)).Result()
+}
+
+// members to get the ids of an index, ordered by score, between the ranks start and stop
+func (c redisRepository) members(key string, start, stop int64) ([]string, error) {
+	return c.client.ZRange(c.ctx, key, start, stop).Result()
+}
+
+func sortSchedulesByID(schedules []Schedule) []Schedule {
+	sort.Slice(schedules, func(i, j int) bool { return schedules[i].ID < schedules[j].ID })
+	return schedules
+}
+
+func sortJobHistoriesByID(jobHistories []JobHistory) []JobHistory {
+	sort.Slice(jobHistories, func(i, j int) bool { return jobHistories[i].ID < jobHistories[j].ID })
+	return jobHistories
+}
+
+func filterSchedules(schedules []Schedule, filter func(Schedule) bool) []Schedule {
+	filtered := make([]Schedule, 0, len(schedules))
+	for _, schedule := range schedules {
+		if filter(schedule) {
+			filtered = append(filtered, schedule)
+		}
+	}
+	return filtered
+}
+
+// CreateWorker to create a worker
+func (c redisRepository) CreateWorker(worker *Worker) (*Worker, error) {
+	now := time.Now()
+	if worker.CreatedAt.IsZero() {
+		worker.CreatedAt = now
+	}
+	if worker.UpdatedAt.IsZero() {
+		worker.UpdatedAt = now
+	}
+	_, err := redisUpdate(c, c.workerTable(), worker.WorkerID, func(current *Worker) (*Worker, error) {
+		if nil != current {
+			return nil, ErrDuplicateKey
+		}
+		return worker, nil
+	})
+	if nil != err {
+		return nil, err
+	}
+	if err = c.client.Set(c.ctx, c.keys.alive(worker.WorkerID), worker.UpdatedAt.UnixNano(), 3*c.Heart).Err(); nil != err {
+		return nil, err
+	}
+	return worker, nil
+}
+
+// UpdateWorker to update a worker
+func (c redisRepository) UpdateWorker(worker *Worker) (*Worker, error) {
+	worker.UpdatedAt = time.Now()
+	updated, err := redisUpdate(c, c.workerTable(), worker.WorkerID, func(current *Worker) (*Worker, error) {
+		if nil != current {
+			mergeNonZero(reflect.ValueOf(current).Elem(), reflect.ValueOf(*worker))
+		}
+		return current, nil
+	})
+	if nil != err {
+		return nil, err
+	}
+	if nil != updated {
+		if err = c.client.Set(c.ctx, c.keys.alive(worker.WorkerID), worker.UpdatedAt.UnixNano(), 3*c.Heart).Err(); nil != err {
+			return nil, err
+		}
+	}
+	return worker, nil
+}
+
+// GetWorker to get a worker
+func (c redisRepository) GetWorker(workerID string) (*Worker, error) {
+	return c.workerTable().get(c.ctx, c.client, workerID)
+}
+
+// GetWorkers to get a list of workers
+func (c redisRepository) GetWorkers() ([]Worker, error) {
+	workers, err := c.workerTable().all(c.ctx, c.client)
+	if nil != err {
+		return nil, err
+	}
+	sort.Slice(workers, func(i, j int) bool { return workers[i].WorkerID < workers[j].WorkerID })
+	return workers, nil
+}
+
+// GetAliveWorkers to get a list of alive workers, whose heartbeat key has not expired
+func (c redisRepository) GetAliveWorkers() ([]Worker, error) {
+	workers, err := c.GetWorkers()
+	if nil != err {
+		return nil, err
+	}
+	exists := make([]*redis.IntCmd, len(workers))
+	_, err = c.client.Pipelined(c.ctx, func(pipe redis.Pipeliner) error {
+		for i, worker := range workers {
+			exists[i] = pipe.Exists(c.ctx, c.keys.alive(worker.WorkerID))
+		}
+		return nil
+	})
+	if nil != err {
+		return nil, err
+	}
+	alive := make([]Worker, 0, len(workers))
+	for i, worker := range workers {
+		if exists[i].Val() > 0 {
+			alive = append(alive, worker)
+		}
+	}
+	return alive, nil
+}
+
+// DeleteWorker to delete a worker
+func (c redisRepository) DeleteWorker(workerID string) (*Worker, error) {
+	worker, err := redisDelete(c, c.workerTable(), workerID)
+	if nil != err || nil == worker {
+		return nil, err
+	}
+	if err = c.client.Del(c.ctx, c.keys.alive(workerID)).Err(); nil != err {
+		return nil, err
+	}
+	return worker, nil
+}
+
+// CreateJob to create a job
+func (c redisRepository) CreateJob(job *Job) (*Job, error) {
+	id, err := c.nextID("jobs")
+	if nil != err {
+		return nil, err
+	}
+	job.ID = id
+	job.CreatedAt = time.Now()
+	job.UpdatedAt = job.CreatedAt
+	return redisUpdate(c, c.jobTable(), redisID(job.ID), func(*Job) (*Job, error) { return job, nil })
+}
+
+// UpdateJob to update a job
+func (c redisRepository) UpdateJob(job *Job) (*Job, error) {
+	job.UpdatedAt = time.Now()
+	_, err := redisUpdate(c, c.jobTable(), redisID(job.ID), func(current *Job) (*Job, error) {
+		if nil != current {
+			mergeNonZero(reflect.ValueOf(current).Elem(), reflect.ValueOf(*job))
+		}
+		return current, nil
+	})
+	if nil != err {
+		return nil, err
+	}
+	return job, nil
+}
+
+// SaveJob to upsert a job (all columns)
+func (c redisRepository) SaveJob(job *Job) (*Job, error) {
+	if job.ID == 0 {
+		id, err := c.nextID("jobs")
+		if nil != err {
+			return nil, err
+		}
+		job.ID = id
+	} else if err := redisBumpSequence.Run(c.ctx, c.client, []string{c.keys.sequence("jobs")}, job.ID).Err(); nil != err {
+		return nil, err
+	}
+	now := time.Now()
+	if job.CreatedAt.IsZero() {
+		job.CreatedAt = now
+	}
+	job.UpdatedAt = now
+	return redisUpdate(c, c.jobTable(), redisID(job.ID), func(*Job) (*Job, error) { return job, nil })
+}
+
+// GetJob to get a job based on ID
+func (c redisRepository) GetJob(jobID int64) (*Job, error) {
+	return c.jobTable().get(c.ctx, c.client, redisID(jobID))
+}
+
+// GetJobs to get all the jobs, by ID
+func (c redisRepository) GetJobs() ([]Job, error) {
+	jobs, err := c.jobTable().all(c.ctx, c.client)
+	if nil != err {
+		return nil, err
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].ID < jobs[j].ID })
+	return jobs, nil
+}
+
+// GetJobByName to get a job based on Name
+func (c redisRepository) GetJobByName(jobName string) (*Job, error) {
+	return c.firstJob(func(job Job) bool { return job.JobName == jobName })
+}
+
+// GetRepeatingJobByName to get a repeating/non-once job based on Name
+func (c redisRepository) GetRepeatingJobByName(jobName string) (*Job, error) {
+	return c.firstJob(func(job Job) bool { return job.JobName == jobName && !job.Once })
+}
+
+// firstJob to get the job with the lowest id matching filter
+func (c redisRepository) firstJob(filter func(Job) bool) (*Job, error) {
+	jobs, err := c.jobTable().all(c.ctx, c.client)
+	if nil != err {
+		return nil, err
+	}
+	var first *Job
+	for _, job := range jobs {
+		if filter(job) && (nil == first || job.ID < first.ID) {
+			job := job
+			first = &job
+		}
+	}
+	return first, nil
+}
+
+// LockJob to get a job by ID, the transactions of all the workers are serialized by the transaction lock already
+func (c redisRepository) LockJob(jobID int64) (*Job, error) {
+	return c.GetJob(jobID)
+}
+
+// DeleteJob to get a job based on ID
+func (c redisRepository) DeleteJob(jobID int64) (*Job, error) {
+	job, err := redisDelete(c, c.jobTable(), redisID(jobID))
+	if nil != err {
+		return nil, err
+	}
+	if nil == job {
+		return nil, ErrNotFound
+	}
+	return job, nil
+}
+
+// CreateJobHistory to create a JobHistory
+func (c redisRepository) CreateJobHistory(jobHistory *JobHistory) (*JobHistory, error) {
+	id, err := c.nextID("job_histories")
+	if nil != err {
+		return nil, err
+	}
+	jobHistory.ID = id
+	jobHistory.CreatedAt = time.Now()
+	jobHistory.UpdatedAt = jobHistory.CreatedAt
+	stored := *jobHistory
+	stored.Job, stored.Schedule = Job{}, Schedule{}
+	if _, err = redisUpdate(c, c.historyTable(), redisID(id), func(*JobHistory) (*JobHistory, error) { return &stored, nil }); nil != err {
+		return nil, err
+	}
+	return jobHistory, nil
+}
+
+// UpdateJobHistory to update a JobHistory
+func (c redisRepository) UpdateJobHistory(jobHistory *JobHistory) (*JobHistory, error) {
+	jobHistory.UpdatedAt = time.Now()
+	_, err := redisUpdate(c, c.historyTable(), redisID(jobHistory.ID), func(current *JobHistory) (*JobHistory, error) {
+		if nil != current {
+			mergeNonZero(reflect.ValueOf(current).Elem(), reflect.ValueOf(*jobHistory))
+		}
+		return current, nil
+	})
+	if nil != err {
+		return nil, err
+	}
+	return jobHistory, nil
+}
+
+// jobHistories to get the job histories of an index between the ranks start and stop, ordered by id
+func (c redisRepository) jobHistories(key string, start, stop int64) ([]JobHistory, error) {
+	ids, err := c.members(key, start, stop)
+	if nil != err {
+		return nil, err
+	}
+	return c.historyTable().getMany(c.ctx, c.client, ids)
+}
+
+// GetJobHistory to get the first JobHistory by JobID, ErrNotFound when there is none
+func (c redisRepository) GetJobHistory(jobID int64) ([]JobHistory, error) {
+	jobHistories, err := c.jobHistories(c.keys.jobHistories(jobID), 0, 0)
+	if nil != err {
+		return nil, err
+	}
+	if len(jobHistories) == 0 {
+		return nil, ErrNotFound
+	}
+	return jobHistories, nil
+}
+
+// GetJobHistoryWithLimit to get a JobHistory by JobID and limit
+func (c redisRepository) GetJobHistoryWithLimit(jobID int64, limit int) ([]JobHistory, error) {
+	if limit == 0 {
+		return []JobHistory{}, nil
+	}
+	return c.jobHistories(c.keys.jobHistories(jobID), 0, int64(limit)-1)
+}
+
+// FindJobHistory to get the job histories matching filter, the latest first
+func (c redisRepository) FindJobHistory(filter JobHistoryFilter) ([]JobHistory, error) {
+	var jobHistories []JobHistory
+	var err error
+	if filter.JobID != 0 {
+		jobHistories, err = c.jobHistories(c.keys.jobHistories(filter.JobID), 0, -1)
+	} else {
+		jobHistories, err = c.historyTable().all(c.ctx, c.client)
+	}
+	if nil != err {
+		return nil, err
+	}
+	return findJobHistories(sortJobHistoriesByID(jobHistories), filter), nil
+}
+
+// GetJobHistoryForSchedule to get the latest JobHistory by scheduleID, nil if the schedule has not run yet
+func (c redisRepository) GetJobHistoryForSchedule(scheduleID int64) (*JobHistory, error) {
+	jobHistories, err := c.jobHistories(c.keys.scheduleHistories(scheduleID), -1, -1)
+	if nil != err || len(jobHistories) == 0 {
+		return nil, err
+	}
+	return &jobHistories[0], nil
+}
+
+// GetJobHistoryForWorker to get the JobHistories of a worker having one of the given statuses
+func (c redisRepository) GetJobHistoryForWorker(workerID string, statuses []JobStatus) ([]JobHistory, error) {
+	all, err := c.historyTable().all(c.ctx, c.client)
+	if nil != err {
+		return nil, err
+	}
+	jobHistories := make([]JobHistory, 0)
+	for _, jobHistory := range all {
+		if jobHistory.WorkerID != workerID {
+			continue
+		}
+		for _, status := range statuses {
+			if jobHistory.Status == status {
+				jobHistories = append(jobHistories, jobHistory)
+				break
+			}
+		}
+	}
+	return sortJobHistoriesByID(jobHistories), nil
+}
+
+// UpdateJobHistoryStatus to save the status, retry count and worker of a JobHistory only if it is still
+// in the from status, returns false when another worker changed the status first
+func (c redisRepository) UpdateJobHistoryStatus(jobHistory *JobHistory, from JobStatus) (bool, error) {
+	now := time.Now()
+	updated, err := redisUpdate(c, c.historyTable(), redisID(jobHistory.ID), func(current *JobHistory) (*JobHistory, error) {
+		if nil == current || current.Status != from {
+			return nil, nil
+		}
+		current.Status = jobHistory.Status
+		current.RetryCount = jobHistory.RetryCount
+		current.WorkerID = jobHistory.WorkerID
+		current.UpdatedAt = now
+		return current, nil
+	})
+	if nil != err || nil == updated {
+		return false, err
+	}
+	jobHistory.UpdatedAt = now
+	return true, nil
+}
+
+// GetRunningJobHistory to get the IN_PROGRESS job histories of a job, on any worker
+func (c redisRepository) GetRunningJobHistory(jobID int64) ([]JobHistory, error) {
+	jobHistories, err := c.jobHistories(c.keys.jobHistories(jobID), 0, -1)
+	if nil != err {
+		return nil, err
+	}
+	running := make([]JobHistory, 0)
+	for _, jobHistory := range jobHistories {
+		if jobHistory.Status == JobStatusInProgress {
+			running = append(running, jobHistory)
+		}
+	}
+	return running, nil
+}
+
+// RequestJobHistoryCancel to ask the workers running the given job histories to cancel them
+func (c redisRepository) RequestJobHistoryCancel(jobHistoryIDs []int64) error {
+	for _, id := range jobHistoryIDs {
+		_, err := redisUpdate(c, c.historyTable(), redisID(id), func(current *JobHistory) (*JobHistory, error) {
+			if nil == current || current.Status != JobStatusInProgress {
+				return nil, nil
+			}
+			current.CancelRequested = true
+			current.UpdatedAt = time.Now()
+			return current, nil
+		})
+		if nil != err {
+			return err
+		}
+	}
+	return nil
+}
+
+// IsJobHistoryCancelRequested whether the cancellation of a job history has been requested
+func (c redisRepository) IsJobHistoryCancelRequested(jobHistoryID int64) (bool, error) {
+	jobHistory, err := c.historyTable().get(c.ctx, c.client, redisID(jobHistoryID))
+	if nil != err || nil == jobHistory {
+		return false, err
+	}
+	return jobHistory.CancelRequested, nil
+}
+
+// DeleteJobHistory to delete all the JobHistories of jobID, ErrNotFound when there is none
+func (c redisRepository) DeleteJobHistory(jobID int64) ([]JobHistory, error) {
+	jobHistories, err := c.jobHistories(c.keys.jobHistories(jobID), 0, -1)
+	if nil != err {
+		return nil, err
+	}
+	if len(jobHistories) == 0 {
+		return nil, ErrNotFound
+	}
+	for _, jobHistory := range jobHistories {
+		if _, err = redisDelete(c, c.historyTable(), redisID(jobHistory.ID)); nil != err {
+			return nil, err
+		}
+	}
+	return jobHistories, nil
+}
+
+// PurgeJobHistory to delete the job histories matching the retention policy, returns how many were
+func (c redisRepository) PurgeJobHistory(policy RetentionPolicy) (int64, error) {
+	if len(policy.Statuses) == 0 {
+		return 0, nil
+	}
+	jobHistories, err := c.historyTable().all(c.ctx, c.client)
+	if nil != err {
+		return 0, err
+	}
+	var purged int64
+	for _, id := range purgeableJobHistories(sortJobHistoriesByID(jobHistories), policy) {
+		if _, err = redisDelete(c, c.historyTable(), redisID(id)); nil != err {
+			return purged, err
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// CreateJobChange to create a JobChange
+func (c redisRepository) CreateJobChange(jobChange *JobChange) (*JobChange, error) {
+	id, err := c.nextID("job_changes")
+	if nil != err {
+		return nil, err
+	}
+	jobChange.ID = id
+	jobChange.CreatedAt = time.Now()
+	jobChange.UpdatedAt = jobChange.CreatedAt
+	stored := *jobChange
+	stored.Job = Job{}
+	if _, err = redisUpdate(c, c.changeTable(), redisID(id), func(*JobChange) (*JobChange, error) { return &stored, nil }); nil != err {
+		return nil, err
+	}
+	return jobChange, nil
+}
+
+// GetJobChanges to get the changes of a job, in the order they were made
+func (c redisRepository) GetJobChanges(jobID int64) ([]JobChange, error) {
+	ids, err := c.members(c.keys.jobChanges(jobID), 0, -1)
+	if nil != err {
+		return nil, err
+	}
+	return c.changeTable().getMany(c.ctx, c.client, ids)
+}
+
+// CreateSchedule to create a schedule
+func (c redisRepository) CreateSchedule(schedule *Schedule) (*Schedule, error) {
+	id, err := c.nextID("schedules")
+	if nil != err {
+		return nil, err
+	}
+	schedule.ID = id
+	schedule.CreatedAt = time.Now()
+	schedule.UpdatedAt = schedule.CreatedAt
+	stored := *schedule
+	stored.Job = Job{}
+	if _, err = redisUpdate(c, c.scheduleTable(), redisID(id), func(*Schedule) (*Schedule, error) { return &stored, nil }); nil != err {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+// UpdateSchedule to update a schedule
+func (c redisRepository) UpdateSchedule(schedule *Schedule) (*Schedule, error) {
+	schedule.UpdatedAt = time.Now()
+	_, err := redisUpdate(c, c.scheduleTable(), redisID(schedule.ID), func(current *Schedule) (*Schedule, error) {
+		if nil != current {
+			mergeNonZero(reflect.ValueOf(current).Elem(), reflect.ValueOf(*schedule))
+		}
+		return current, nil
+	})
+	if nil != err {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+// schedulesBetween to get the schedules whose ExecutionID is between scheduleStart and scheduleEnd, ordered by id
+func (c redisRepository) schedulesBetween(scheduleStart, scheduleEnd int64) ([]Schedule, error) {
+	ids, err := c.client.ZRangeByScore(c.ctx, c.keys.executions, &redis.ZRangeBy{
+		Min: redisScore(scheduleStart),
+		Max: redisScore(scheduleEnd),
+	}).Result()
+	if nil != err {
+		return nil, err
+	}
+	schedules, err := c.scheduleTable().getMany(c.ctx, c.client, ids)
+	if nil != err {
+		return nil, err
+	}
+	return sortSchedulesByID(filterSchedules(schedules, func(schedule Schedule) bool {
+		return schedule.ExecutionID >= scheduleStart && schedule.ExecutionID <= scheduleEnd
+	})), nil
+}
+
+// withJobs to set the job of each schedule
+func (c redisRepository) withJobs(schedules []Schedule) ([]Schedule, error) {
+	for i := range schedules {
+		job, err := c.GetJob(schedules[i].JobID)
+		if nil != err {
+			return nil, err
+		}
+		if nil != job {
+			schedules[i].Job = *job
+		}
+	}
+	return schedules, nil
+}
+
+// GetSchedule to get a schedule by executionID, an empty schedule when there is none
+func (c redisRepository) GetSchedule(executionID int64) (*Schedule, error) {
+	schedules, err := c.schedulesBetween(executionID, executionID)
+	if nil != err {
+		return nil, err
+	}
+	if len(schedules) == 0 {
+		return &Schedule{}, nil
+	}
+	return &schedules[0], nil
+}
+
+// GetScheduleByID to get a schedule by ID, an empty schedule when there is none
+func (c redisRepository) GetScheduleByID(scheduleID int64) (*Schedule, error) {
+	schedule, err := c.scheduleTable().get(c.ctx, c.client, redisID(scheduleID))
+	if nil != err {
+		return nil, err
+	}
+	if nil == schedule {
+		return &Schedule{}, nil
+	}
+	return schedule, nil
+}
+
+// GetScheduleBetween to get a schedule between scheduleStart and scheduleEnd and by workerID
+func (c redisRepository) GetScheduleBetween(scheduleStart, scheduleEnd int64, workerID string) ([]Schedule, error) {
+	schedules, err := c.schedulesBetween(scheduleStart, scheduleEnd)
+	if nil != err {
+		return nil, err
+	}
+	return filterSchedules(schedules, func(schedule Schedule) bool { return schedule.WorkerID == workerID }), nil
+}
+
+// GetScheduleBefore to get all schedules before nanoUnix and by workerID
+func (c redisRepository) GetScheduleBefore(nanoUnix int64, workerID string) ([]Schedule, error) {
+	schedules, err := c.GetScheduleBetween(math.MinInt64, nanoUnix, workerID)
+	if nil != err {
+		return nil, err
+	}
+	schedules, err = c.withJobs(schedules)
+	if nil != err {
+		return nil, err
+	}
+	return sortByExecutionID(schedules), nil
+}
+
+// hasJobHistory whether the schedule has a job history matching filter
+func (c redisRepository) hasJobHistory(scheduleID int64, filter func(JobHistory) bool) (bool, error) {
+	jobHistories, err := c.jobHistories(c.keys.scheduleHistories(scheduleID), 0, -1)
+	if nil != err {
+		return false, err
+	}
+	for _, jobHistory := range jobHistories {
+		if filter(jobHistory) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// filterWithoutJobHistory to keep the schedules having no job history matching filter
+func (c redisRepository) filterWithoutJobHistory(schedules []Schedule, filter func(JobHistory) bool) ([]Schedule, error) {
+	kept := make([]Schedule, 0, len(schedules))
+	for _, schedule := range schedules {
+		found, err := c.hasJobHistory(schedule.ID, filter)
+		if nil != err {
+			return nil, err
+		}
+		if !found {
+			kept = append(kept, schedule)
+		}
+	}
+	return kept, nil
+}
+
+// GetPassedSchedule to get all schedules before nanoUnix and by workerID which are not claimed yet,
+// and either never ran or are waiting to be re-run (NEW job history). Only the schedules of once jobs when onlyOnces.
+// The schedules of the paused jobs are left out.
+func (c redisRepository) GetPassedSchedule(nanoUnix int64, workerID string, onlyOnces bool) ([]Schedule, error) {
+	schedules, err := c.GetScheduleBetween(math.MinInt64, nanoUnix-1, workerID)
+	if nil != err {
+		return nil, err
+	}
+	schedules, err = c.withJobs(filterSchedules(schedules, isUnclaimed))
+	if nil != err {
+		return nil, err
+	}
+	schedules = filterSchedules(schedules, func(schedule Schedule) bool {
+		return schedule.Job.ID != 0 && !schedule.Job.Paused && (!onlyOnces || schedule.Job.Once)
+	})
+	schedules, err = c.filterWithoutJobHistory(schedules, func(jobHistory JobHistory) bool {
+		return jobHistory.Status != JobStatusNew
+	})
+	if nil != err {
+		return nil, err
+	}
+	return sortByExecutionID(schedules), nil
+}
+
+// allSchedules to get every schedule matching filter, ordered by id
+func (c redisRepository) allSchedules(filter func(Schedule) bool) ([]Schedule, error) {
+	schedules, err := c.scheduleTable().all(c.ctx, c.client)
+	if nil != err {
+		return nil, err
+	}
+	return sortSchedulesByID(filterSchedules(schedules, filter)), nil
+}
+
+// GetClaimedScheduleWithoutHistory to get the schedules claimed by workerID which never started to run
+func (c redisRepository) GetClaimedScheduleWithoutHistory(workerID string) ([]Schedule, error) {
+	schedules, err := c.allSchedules(func(schedule Schedule) bool {
+		return schedule.ClaimedBy == workerID && workerID != pkg.EMPTYSTRING
+	})
+	if nil != err {
+		return nil, err
+	}
+	schedules, err = c.filterWithoutJobHistory(schedules, func(JobHistory) bool { return true })
+	if nil != err {
+		return nil, err
+	}
+	return sortByExecutionID(schedules), nil
+}
+
+// ClaimSchedule to claim a schedule for workerID, returns false when the schedule was already claimed
+func (c redisRepository) ClaimSchedule(schedule *Schedule, workerID string) (bool, error) {
+	now := time.Now()
+	claimed, err := redisUpdate(c, c.scheduleTable(), redisID(schedule.ID), func(current *Schedule) (*Schedule, error) {
+		if nil == current || !isUnclaimed(*current) {
+			return nil, nil
+		}
+		current.ClaimedBy = workerID
+		current.ClaimedAt = &now
+		current.UpdatedAt = now
+		return current, nil
+	})
+	if nil != err || nil == claimed {
+		return false, err
+	}
+	schedule.ClaimedBy = workerID
+	schedule.ClaimedAt = &now
+	return true, nil
+}
+
+// ClaimScheduleBetween to claim the unclaimed schedules between scheduleStart and scheduleEnd assigned to workerID,
+// but the ones of the paused jobs
+func (c redisRepository) ClaimScheduleBetween(scheduleStart, scheduleEnd int64, workerID string) ([]Schedule, error) {
+	schedules, err := c.GetScheduleBetween(scheduleStart, scheduleEnd, workerID)
+	if nil != err {
+		return nil, err
+	}
+	schedules, err = c.withJobs(filterSchedules(schedules, isUnclaimed))
+	if nil != err {
+		return nil, err
+	}
+	schedules = sortByExecutionID(filterSchedules(schedules, func(schedule Schedule) bool { return !schedule.Job.Paused }))
+	claimed := make([]Schedule, 0, len(schedules))
+	for i := range schedules {
+		ok, err := c.ClaimSchedule(&schedules[i], workerID)
+		if nil != err {
+			return nil, err
+		}
+		if ok {
+			claimed = append(claimed, schedules[i])
+		}
+	}
+	return claimed, nil
+}
+
+// GetOrphanedSchedules to get the schedules which are not finished and belong to workers other than aliveWorkerIDs
+func (c redisRepository) GetOrphanedSchedules(aliveWorkerIDs []string) ([]Schedule, error) {
+	alive := make(map[string]bool, len(aliveWorkerIDs))
+	for _, workerID := range aliveWorkerIDs {
+		alive[workerID] = true
+	}
+	if len(alive) == 0 {
+		// like "not in (NULL)", which matches nothing
+		return []Schedule{}, nil
+	}
+	schedules, err := c.allSchedules(func(schedule Schedule) bool { return !alive[schedule.WorkerID] })
+	if nil != err {
+		return nil, err
+	}
+	schedules, err = c.filterWithoutJobHistory(schedules, func(jobHistory JobHistory) bool {
+		return jobHistory.Status != JobStatusNew && jobHistory.Status != JobStatusInProgress
+	})
+	if nil != err {
+		return nil, err
+	}
+	return sortByExecutionID(schedules), nil
+}
+
+// ReassignSchedule to move an unfinished schedule to schedule.WorkerID and schedule.ExecutionID, releasing its claim.
+// Only succeeds if the schedule still belongs to fromWorkerID, so that a schedule is taken over only once.
+func (c redisRepository) ReassignSchedule(schedule *Schedule, fromWorkerID string) (bool, error) {
+	reassigned, err := redisUpdate(c, c.scheduleTable(), redisID(schedule.ID), func(current *Schedule) (*Schedule, error) {
+		if nil == current || current.WorkerID != fromWorkerID {
+			return nil, nil
+		}
+		current.WorkerID = schedule.WorkerID
+		current.ExecutionID = schedule.ExecutionID
+		current.ClaimedBy = pkg.EMPTYSTRING
+		current.ClaimedAt = nil
+		current.ReassignedFrom = fromWorkerID
+		current.UpdatedAt = time.Now()
+		return current, nil
+	})
+	if nil != err || nil == reassigned {
+		return false, err
+	}
+	schedule.ClaimedBy = pkg.EMPTYSTRING
+	schedule.ClaimedAt = nil
+	schedule.ReassignedFrom = fromWorkerID
+	return true, nil
+}
+
+// PostponeSchedule to move a schedule to schedule.ExecutionID, releasing its claim so that it is claimed again when due
+func (c redisRepository) PostponeSchedule(schedule *Schedule) (*Schedule, error) {
+	_, err := redisUpdate(c, c.scheduleTable(), redisID(schedule.ID), func(current *Schedule) (*Schedule, error) {
+		if nil == current {
+			return nil, nil
+		}
+		current.ExecutionID = schedule.ExecutionID
+		current.ClaimedBy = pkg.EMPTYSTRING
+		current.ClaimedAt = nil
+		current.UpdatedAt = time.Now()
+		return current, nil
+	})
+	if nil != err {
+		return nil, err
+	}
+	schedule.ClaimedBy = pkg.EMPTYSTRING
+	schedule.ClaimedAt = nil
+	return schedule, nil
+}
+
+// GetSchedulesForJob to get a schedules by jobID
+func (c redisRepository) GetSchedulesForJob(jobID int64) ([]Schedule, error) {
+	ids, err := c.members(c.keys.jobSchedules(jobID), 0, -1)
+	if nil != err {
+		return nil, err
+	}
+	return c.scheduleTable().getMany(c.ctx, c.client, ids)
+}
+
+// GetPendingSchedules to get the schedules which have not started to run, of jobID or of all the jobs when 0,
+// by execution time, at most limit of them when it is not 0
+func (c redisRepository) GetPendingSchedules(jobID int64, limit int) ([]Schedule, error) {
+	var schedules []Schedule
+	var err error
+	if jobID != 0 {
+		schedules, err = c.GetSchedulesForJob(jobID)
+	} else {
+		schedules, err = c.allSchedules(func(Schedule) bool { return true })
+	}
+	if nil != err {
+		return nil, err
+	}
+	schedules, err = c.filterWithoutJobHistory(sortSchedulesByID(schedules), func(JobHistory) bool { return true })
+	if nil != err {
+		return nil, err
+	}
+	schedules = sortByExecutionID(schedules)
+	if limit > 0 && len(schedules) > limit {
+		schedules = schedules[:limit]
+	}
+	return schedules, nil
+}
+
+// GetSchedulesForWorker to get a schedules by workerID
+func (c redisRepository) GetSchedulesForWorker(workerID string) ([]Schedule, error) {
+	return c.allSchedules(func(schedule Schedule) bool { return schedule.WorkerID == workerID })
+}
+
+// GetSchedulesForJobName to get a schedules by jobName and subName
+func (c redisRepository) GetSchedulesForJobName(jobName string, subName string) ([]Schedule, error) {
+	jobs, err := c.jobTable().all(c.ctx, c.client)
+	if nil != err {
+		return nil, err
+	}
+	schedules := make([]Schedule, 0)
+	for _, job := range jobs {
+		if job.JobName != jobName || job.SubName != subName {
+			continue
+		}
+		jobSchedules, err := c.GetSchedulesForJob(job.ID)
+		if nil != err {
+			return nil, err
+		}
+		for _, schedule := range jobSchedules {
+			schedule.Job = job
+			schedules = append(schedules, schedule)
+		}
+	}
+	return sortSchedulesByID(schedules), nil
+}
+
+// DeleteScheduleForJob to delete a schedules by jobID
+func (c redisRepository) DeleteScheduleForJob(jobID int64) ([]Schedule, error) {
+	schedules, err := c.GetSchedulesForJob(jobID)
+	if nil != err {
+		return nil, err
+	}
+	return c.deleteSchedules(schedules)
+}
+
+// DeleteScheduleForWorker to delete a schedules by workerID
+func (c redisRepository) DeleteScheduleForWorker(workerID string) ([]Schedule, error) {
+	schedules, err := c.GetSchedulesForWorker(workerID)
+	if nil != err {
+		return nil, err
+	}
+	return c.deleteSchedules(schedules)
+}
+
+// DeleteScheduleForJobName to delete a schedules by jobName and subName
+func (c redisRepository) DeleteScheduleForJobName(jobName string, subName string) ([]Schedule, error) {
+	schedules, err := c.GetSchedulesForJobName(jobName, subName)
+	if nil != err {
+		return nil, err
+	}
+	return c.deleteSchedules(schedules)
+}
+
+func (c redisRepository) deleteSchedules(schedules []Schedule) ([]Schedule, error) {
+	for _, schedule := range schedules {
+		if _, err := redisDelete(c, c.scheduleTable(), redisID(schedule.ID)); nil != err {
+			return nil, err
+		}
+	}
+	return schedules, nil
+}
+
+// GetWorkerCountByJobID to count number of each worker by jobID
+func (c redisRepository) GetWorkerCountByJobID(jobID int64) ([]WorkerJobCount, error) {
+	jobHistories, err := c.jobHistories(c.keys.jobHistories(jobID), 0, -1)
+	if nil != err {
+		return nil, err
+	}
+	counts := make(map[string]int64)
+	for _, jobHistory := range jobHistories {
+		counts[jobHistory.WorkerID]++
+	}
+	workerCounts := make([]WorkerJobCount, 0, len(counts))
+	for workerID, count := range counts {
+		workerCounts = append(workerCounts, WorkerJobCount{WorkerID: workerID, Count: count})
+	}
+	sort.Slice(workerCounts, func(i, j int) bool { return workerCounts[i].WorkerID < workerCounts[j].WorkerID })
+	return workerCounts, nil
+}
+
+// GetPendingScheduleCount to count the schedules which have not started to run, by worker
+func (c redisRepository) GetPendingScheduleCount() ([]WorkerJobCount, error) {
+	schedules, err := c.allSchedules(func(Schedule) bool { return true })
+	if nil != err {
+		return nil, err
+	}
+	schedules, err = c.filterWithoutJobHistory(schedules, func(JobHistory) bool { return true })
+	if nil != err {
+		return nil, err
+	}
+	counts := make(map[string]int64)
+	for _, schedule := range schedules {
+		counts[schedule.WorkerID]++
+	}
+	workerCounts := make([]WorkerJobCount, 0, len(counts))
+	for workerID, count := range counts {
+		workerCounts = append(workerCounts, WorkerJobCount{WorkerID: workerID, Count: count})
+	}
+	sort.Slice(workerCounts, func(i, j int) bool { return workerCounts[i].WorkerID < workerCounts[j].WorkerID })
+	return workerCounts, nil
+}
+
+// PurgeDeleted nothing is soft-deleted in redis, the deletes remove the records right away
+func (c redisRepository) PurgeDeleted(before time.Time) (int64, error) {
+	return 0, nil
+}
+
+// lock to take the transaction lock shared by all the workers, returns the function releasing it
+func (c redisRepository) lock() (func(), error) {
+	token := strconv.FormatInt(rand.Int63(), 36)
+	deadline := time.Now().Add(redisLockWait)
+	for {
+		locked, err := c.client.SetNX(c.ctx, c.keys.lock, token, redisLockTTL).Result()
+		if nil != err {
+			return nil, err
+		}
+		if locked {
+			break
+		}
+		if time.Now().After(deadline) {
+			return nil, errRedisLockTimeout
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	stop, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(redisLockRenewal)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				redisRenew.Run(c.ctx, c.client, []string{c.keys.lock}, token, redisLockTTL.Milliseconds())
+			}
+		}
+	}()
+	return func() {
+		close(stop)
+		<-stopped
+		redisUnlock.Run(c.ctx, c.client, []string{c.keys.lock}, token)
+	}, nil
+}
+
+// Transaction to run fc with a repository holding the transaction lock, renewed until fc returns, so that the
+// transactions of all the workers are serialized. The writes of fc are visible to the other workers before it
+// returns, they are kept when it returns nil and undone otherwise, see NewRedisRepository.
+func (c redisRepository) Transaction(fc func(repo CduleRepository) error) (err error) {
+	if nil == c.tx {
+		unlock, err := c.lock()
+		if nil != err {
+			return err
+		}
+		defer unlock()
+	}
+	tx := &redisTx{}
+	defer func() {
+		if r := recover(); r != nil {
+			tx.rollback()
+			panic(r)
+		}
+	}()
+	repo := c
+	repo.tx = tx
+	if err = fc(repo); nil != err {
+		if rollbackErr := tx.rollback(); nil != rollbackErr {
+			return fmt.Errorf("%w, and the rollback failed: %s", err, rollbackErr.Error())
+		}
+		return err
+	}
+	// a nested transaction is undone with its parent
+	if nil != c.tx {
+		c.tx.undo = append(c.tx.undo, tx.undo...)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package model
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gagasdiv/cdule/pkg"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRepository_Redis runs the repository suite on an in-process fake, or on the redis-server of
+// CDULE_REDIS_URL (e.g. redis://localhost:6379/15) when it is set, which is flushed before every test
+func TestRepository_Redis(t *testing.T) {
+	runRepositorySuite(t, func(t *testing.T) CduleRepository {
+		url := os.Getenv("CDULE_REDIS_URL")
+		if url == "" {
+			url = "redis://" + miniredis.RunT(t).Addr()
+		}
+		options, err := redis.ParseURL(url)
+		require.NoError(t, err)
+		client := redis.NewClient(options)
+		t.Cleanup(func() { client.Close() })
+		require.NoError(t, client.FlushDB(context.Background()).Err())
+		return NewRedisRepository(client, "cdule:")
+	})
+}
+
+func TestRepository_RedisHeartbeat(t *testing.T) {
+	server := miniredis.RunT(t)
+	repo := NewRedisRepository(redis.NewClient(&redis.Options{Addr: server.Addr()}), "cdule:")
+	_, err := repo.CreateWorker(&Worker{WorkerID: "worker"})
+	require.NoError(t, err)
+
+	// a worker is dead once its heartbeat key expired
+	server.FastForward(3*repo.(redisRepository).Heart - 1)
+	alive, err := repo.GetAliveWorkers()
+	require.NoError(t, err)
+	require.Equal(t, 1, len(alive))
+	server.FastForward(1)
+	alive, err = repo.GetAliveWorkers()
+	require.NoError(t, err)
+	require.Equal(t, 0, len(alive))
+
+	_, err = repo.UpdateWorker(&Worker{WorkerID: "worker"})
+	require.NoError(t, err)
+	alive, err = repo.GetAliveWorkers()
+	require.NoError(t, err)
+	require.Equal(t, 1, len(alive))
+	workers, err := repo.GetWorkers()
+	require.NoError(t, err)
+	require.Equal(t, 1, len(workers))
+}
+
+func TestRepository_RedisLockRenewal(t *testing.T) {
+	server := miniredis.RunT(t)
+	repo := NewRedisRepository(redis.NewClient(&redis.Options{Addr: server.Addr()}), "cdule:")
+	renewal := redisLockRenewal
+	redisLockRenewal = 10 * time.Millisecond
+	t.Cleanup(func() { redisLockRenewal = renewal })
+
+	// a transaction longer than the TTL of the lock keeps it
+	err := repo.Transaction(func(repo CduleRepository) error {
+		server.FastForward(redisLockTTL - time.Millisecond)
+		time.Sleep(50 * time.Millisecond)
+		server.FastForward(time.Millisecond)
+		require.True(t, server.Exists("cdule:lock"))
+		return nil
+	})
+	require.NoError(t, err)
+	require.False(t, server.Exists("cdule:lock"))
+}
+
+func Test_ConnectRedis(t *testing.T) {
+	server := miniredis.RunT(t)
+	repos, err := ConnectDataBase(&pkg.CduleConfig{
+		Cduletype:   string(pkg.REDIS),
+		Dburl:       "redis://" + server.Addr(),
+		TablePrefix: "app:",
+	})
+	require.NoError(t, err)
+	require.Nil(t, repos.DB)
+	_, err = repos.CduleRepository.CreateJob(&Job{JobName: "job.Redis"})
+	require.NoError(t, err)
+	require.True(t, server.Exists("app:jobs"))
+
+	addr := server.Addr()
+	server.Close()
+	_, err = ConnectDataBase(&pkg.CduleConfig{Cduletype: string(pkg.REDIS), Dburl: "redis://" + addr})
+	require.Error(t, err)
+}
+
+func TestRepository_RedisConcurrentClaim(t *testing.T) {
+	server := miniredis.RunT(t)
+	repo := NewRedisRepository(redis.NewClient(&redis.Options{Addr: server.Addr()}), "cdule:")
+	schedule, err := repo.CreateSchedule(&Schedule{JobID: 1, ExecutionID: 100, WorkerID: "worker"})
+	require.NoError(t, err)
+
+	// every worker has its own client, only one of them claims the schedule
+	claimed := make(chan bool, 10)
+	for i := 0; i < cap(claimed); i++ {
+		go func(workerID string) {
+			worker := NewRedisRepository(redis.NewClient(&redis.Options{Addr: server.Addr()}), "cdule:")
+			ok, err := worker.ClaimSchedule(&Schedule{Model: Model{ID: schedule.ID}}, workerID)
+			claimed <- nil == err && ok
+		}("worker-" + strconv.Itoa(i))
+	}
+	count := 0
+	for i := 0; i < cap(claimed); i++ {
+		if <-claimed {
+			count++
+		}
+	}
+	require.Equal(t, 1, count)
+}
@@ -0,0 +1,649 @@
+package model
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	approxTime = cmp.Options{cmpopts.EquateApproxTime(time.Second)}
+)
+
+// repositorySuite tests every CduleRepository implementation must pass, each on a new empty repository
+var repositorySuite = map[string]func(t *testing.T, repo CduleRepository){
+	"Job":                testRepositoryJob,
+	"PausedJob":          testRepositoryPausedJob,
+	"JobHistory":         testRepositoryJobHistory,
+	"JobHistoryStatus":   testRepositoryJobHistoryStatus,
+	"JobHistoryCancel":   testRepositoryJobHistoryCancel,
+	"JobHistoryDelete":   testRepositoryJobHistoryDelete,
+	"JobChange":          testRepositoryJobChange,
+	"PurgeJobHistory":    testRepositoryPurgeJobHistory,
+	"FindJobHistory":     testRepositoryFindJobHistory,
+	"PendingSchedule":    testRepositoryPendingSchedule,
+	"Transaction":        testRepositoryTransaction,
+	"Schedule":           testRepositorySchedule,
+	"ClaimSchedule":      testRepositoryClaimSchedule,
+	"PostponeSchedule":   testRepositoryPostponeSchedule,
+	"Worker":             testRepositoryWorker,
+	"PassedSchedule":     testRepositoryPassedSchedule,
+	"OrphanedSchedule":   testRepositoryOrphanedSchedule,
+	"ScheduleForJobName": testRepositoryScheduleForJobName,
+	"NestedTransaction":  testRepositoryNestedTransaction,
+}
+
+// runRepositorySuite to run the repositorySuite against the repositories created by newRepository
+func runRepositorySuite(t *testing.T, newRepository func(t *testing.T) CduleRepository) {
+	for name, test := range repositorySuite {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			test(t, newRepository(t))
+		})
+	}
+}
+
+func testRepositoryJob(t *testing.T, repo CduleRepository) {
+	testJob, err := createTestJob()
+	require.NoError(t, err)
+
+	expectedResult, err := repo.CreateJob(testJob)
+	require.NoError(t, err)
+
+	actualResult, err := repo.GetJob(expectedResult.ID)
+	require.NoError(t, err)
+
+	if diff := cmp.Diff(expectedResult, actualResult, approxTime); diff != "" {
+		t.Fatalf("mismatch (-expectedResult, +actRes):\n%s", diff)
+	}
+	expectedResult.Expired = true
+	_, err = repo.UpdateJob(expectedResult)
+	require.NoError(t, err)
+
+	actualResult, err = repo.GetJobByName("job.RepoTestJob")
+	require.NoError(t, err)
+
+	require.Equal(t, expectedResult.Expired, actualResult.Expired)
+
+	actualResult, err = repo.DeleteJob(expectedResult.ID)
+	require.NoError(t, err)
+
+	require.Equal(t, expectedResult.JobName, actualResult.JobName)
+}
+
+func testRepositoryJobHistory(t *testing.T, repo CduleRepository) {
+	testJobHistory, err := createTestJobHistory()
+	require.NoError(t, err)
+
+	expectedResult, err := repo.CreateJobHistory(testJobHistory)
+	require.NoError(t, err)
+
+	actualResultJobHistoryArray, err := repo.GetJobHistory(expectedResult.JobID)
+	require.NoError(t, err)
+
+	require.Equal(t, expectedResult.Status, actualResultJobHistoryArray[0].Status)
+	require.Equal(t, expectedResult.JobID, actualResultJobHistoryArray[0].JobID)
+	require.Equal(t, expectedResult.ScheduleID, actualResultJobHistoryArray[0].ScheduleID)
+
+	actualResultJobHistoryArray, err = repo.GetJobHistoryWithLimit(expectedResult.JobID, 2)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(actualResultJobHistoryArray))
+
+	expectedResult.Status = JobStatusInProgress
+	_, err = repo.UpdateJobHistory(expectedResult)
+	require.NoError(t, err)
+
+	actualResult, err := repo.GetJobHistoryForSchedule(testJobHistory.ScheduleID)
+	require.NoError(t, err)
+
+	require.Equal(t, expectedResult.Status, actualResult.Status)
+
+	actualResultJobHistoryArray, err = repo.DeleteJobHistory(expectedResult.JobID)
+	require.NoError(t, err)
+
+	require.Equal(t, expectedResult.ScheduleID, actualResultJobHistoryArray[0].ScheduleID)
+}
+
+func testRepositoryJobHistoryStatus(t *testing.T, repo CduleRepository) {
+	testJobHistory, err := createTestJobHistory()
+	require.NoError(t, err)
+	_, err = repo.CreateJobHistory(testJobHistory)
+	require.NoError(t, err)
+
+	testJobHistory.Status = JobStatusInProgress
+	claimed, err := repo.UpdateJobHistoryStatus(testJobHistory, JobStatusNew)
+	require.NoError(t, err)
+	require.True(t, claimed)
+
+	// a second claim from the same status must lose
+	claimed, err = repo.UpdateJobHistoryStatus(testJobHistory, JobStatusNew)
+	require.NoError(t, err)
+	require.False(t, claimed)
+
+	jobHistories, err := repo.GetJobHistoryForWorker(testJobHistory.WorkerID, []JobStatus{JobStatusInProgress})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(jobHistories))
+	jobHistories, err = repo.GetJobHistoryForWorker(testJobHistory.WorkerID, []JobStatus{JobStatusNew})
+	require.NoError(t, err)
+	require.Equal(t, 0, len(jobHistories))
+
+	jobHistory, err := repo.GetJobHistoryForSchedule(1234)
+	require.NoError(t, err)
+	require.Nil(t, jobHistory)
+}
+
+func testRepositoryJobHistoryDelete(t *testing.T, repo CduleRepository) {
+	for i := 0; i < 3; i++ {
+		_, err := repo.CreateJobHistory(&JobHistory{JobID: 3, ScheduleID: int64(i + 1), Status: JobStatusCompleted})
+		require.NoError(t, err)
+	}
+
+	deleted, err := repo.DeleteJobHistory(3)
+	require.NoError(t, err)
+	require.Equal(t, 3, len(deleted))
+	jobHistories, err := repo.GetJobHistoryWithLimit(3, 10)
+	require.NoError(t, err)
+	require.Equal(t, 0, len(jobHistories))
+
+	_, err = repo.DeleteJobHistory(3)
+	require.True(t, errors.Is(err, ErrNotFound))
+}
+
+func testRepositoryJobChange(t *testing.T, repo CduleRepository) {
+	job, err := repo.CreateJob(&Job{JobName: "job.ChangedJob", CronExpression: "0 * * * * *"})
+	require.NoError(t, err)
+	jobChanges, err := repo.GetJobChanges(job.ID)
+	require.NoError(t, err)
+	require.Equal(t, 0, len(jobChanges))
+
+	for _, changes := range []string{`{"cron":{"from":"0 * * * * *","to":"0 0 * * * *"}}`, `{"paused":{"from":false,"to":true}}`} {
+		jobChange, err := repo.CreateJobChange(&JobChange{JobID: job.ID, WorkerID: "worker1", Changes: changes})
+		require.NoError(t, err)
+		require.NotZero(t, jobChange.ID)
+	}
+	_, err = repo.CreateJobChange(&JobChange{JobID: job.ID + 1, WorkerID: "worker1", Changes: "{}"})
+	require.NoError(t, err)
+
+	jobChanges, err = repo.GetJobChanges(job.ID)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(jobChanges))
+	require.Contains(t, jobChanges[0].Changes, `"cron"`)
+	require.Contains(t, jobChanges[1].Changes, `"paused"`)
+	require.Equal(t, "worker1", jobChanges[1].WorkerID)
+	require.Less(t, jobChanges[0].ID, jobChanges[1].ID)
+}
+
+func testRepositoryPurgeJobHistory(t *testing.T, repo CduleRepository) {
+	for i := 0; i < 4; i++ {
+		_, err := repo.CreateJobHistory(&JobHistory{JobID: 1, ScheduleID: int64(i + 1), Status: JobStatusCompleted})
+		require.NoError(t, err)
+	}
+	_, err := repo.CreateJobHistory(&JobHistory{JobID: 1, ScheduleID: 5, Status: JobStatusInProgress})
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		_, err = repo.CreateJobHistory(&JobHistory{JobID: 2, ScheduleID: int64(i + 6), Status: JobStatusFailed})
+		require.NoError(t, err)
+	}
+
+	purged, err := repo.PurgeJobHistory(RetentionPolicy{KeepPerJob: 2})
+	require.NoError(t, err)
+	require.Equal(t, int64(0), purged)
+
+	// the in progress run counts among the 2 latest job histories kept, but is never purged
+	purged, err = repo.PurgeJobHistory(RetentionPolicy{KeepPerJob: 2, Statuses: FinishedJobStatuses})
+	require.NoError(t, err)
+	require.Equal(t, int64(3), purged)
+	jobHistories, err := repo.GetJobHistoryWithLimit(1, 10)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(jobHistories))
+	jobHistories, err = repo.GetJobHistoryWithLimit(2, 10)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(jobHistories))
+
+	purged, err = repo.PurgeJobHistory(RetentionPolicy{Before: time.Now().Add(time.Minute), Statuses: []JobStatus{JobStatusFailed}})
+	require.NoError(t, err)
+	require.Equal(t, int64(2), purged)
+	jobHistories, err = repo.GetJobHistoryWithLimit(1, 10)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(jobHistories))
+	jobHistories, err = repo.GetJobHistoryWithLimit(2, 10)
+	require.NoError(t, err)
+	require.Equal(t, 0, len(jobHistories))
+}
+
+func testRepositoryJobHistoryCancel(t *testing.T, repo CduleRepository) {
+	testJobHistory, err := createTestJobHistory()
+	require.NoError(t, err)
+	testJobHistory.Status = JobStatusInProgress
+	_, err = repo.CreateJobHistory(testJobHistory)
+	require.NoError(t, err)
+
+	running, err := repo.GetRunningJobHistory(testJobHistory.JobID)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(running))
+
+	requested, err := repo.IsJobHistoryCancelRequested(testJobHistory.ID)
+	require.NoError(t, err)
+	require.False(t, requested)
+	err = repo.RequestJobHistoryCancel([]int64{testJobHistory.ID})
+	require.NoError(t, err)
+	requested, err = repo.IsJobHistoryCancelRequested(testJobHistory.ID)
+	require.NoError(t, err)
+	require.True(t, requested)
+
+	testJobHistory.Status = JobStatusCancelled
+	_, err = repo.UpdateJobHistory(testJobHistory)
+	require.NoError(t, err)
+	running, err = repo.GetRunningJobHistory(testJobHistory.JobID)
+	require.NoError(t, err)
+	require.Equal(t, 0, len(running))
+}
+
+func testRepositoryTransaction(t *testing.T, repo CduleRepository) {
+	schedule, err := createTestSchedule()
+	require.NoError(t, err)
+
+	err = repo.Transaction(func(repo CduleRepository) error {
+		if _, err := repo.CreateSchedule(schedule); err != nil {
+			return err
+		}
+		return errors.New("rollback")
+	})
+	require.Error(t, err)
+	schedules, err := repo.GetSchedulesForJob(schedule.JobID)
+	require.NoError(t, err)
+	require.Equal(t, 0, len(schedules))
+
+	schedule.ID = 0
+	err = repo.Transaction(func(repo CduleRepository) error {
+		_, err := repo.CreateSchedule(schedule)
+		return err
+	})
+	require.NoError(t, err)
+	schedules, err = repo.GetSchedulesForJob(schedule.JobID)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(schedules))
+}
+
+func testRepositorySchedule(t *testing.T, repo CduleRepository) {
+	schedule, err := createTestSchedule()
+	require.NoError(t, err)
+
+	expectedResult, err := repo.CreateSchedule(schedule)
+	require.NoError(t, err)
+	actualResult, err := repo.GetSchedule(expectedResult.ExecutionID)
+	require.NoError(t, err)
+	if diff := cmp.Diff(expectedResult, actualResult, approxTime); diff != "" {
+		t.Fatalf("mismatch (-expectedResult, +actRes):\n%s", diff)
+	}
+
+	actualSchedules, err := repo.GetScheduleBetween(schedule.ExecutionID, actualResult.CreatedAt.Add(5*time.Minute).UnixNano(), actualResult.WorkerID)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, len(actualSchedules))
+
+	data := make(map[string]string)
+	data["a"] = "xyz"
+	jobDataMapStr, err := mapToString(data)
+	require.NoError(t, err)
+	expectedResult.JobData = jobDataMapStr
+
+	_, err = repo.UpdateSchedule(expectedResult)
+	require.NoError(t, err)
+	actualResultScheduleArray, err := repo.GetSchedulesForJob(schedule.JobID)
+	require.NoError(t, err)
+	require.Equal(t, expectedResult.JobData, actualResultScheduleArray[0].JobData)
+
+	actualResultScheduleArray, err = repo.DeleteScheduleForJob(schedule.JobID)
+	require.NoError(t, err)
+	require.Equal(t, expectedResult.ExecutionID, actualResultScheduleArray[0].ExecutionID)
+	schedule.ID = 0
+	schedule.JobID = 3
+	expectedResult, err = repo.CreateSchedule(schedule)
+	require.NoError(t, err)
+	actualResultScheduleArray, err = repo.DeleteScheduleForWorker("dsinghvi-host")
+	require.NoError(t, err)
+	require.Equal(t, expectedResult.ExecutionID, actualResultScheduleArray[0].ExecutionID)
+}
+
+func testRepositoryClaimSchedule(t *testing.T, repo CduleRepository) {
+	schedule, err := createTestSchedule()
+	require.NoError(t, err)
+	_, err = repo.CreateSchedule(schedule)
+	require.NoError(t, err)
+
+	claimed, err := repo.ClaimScheduleBetween(schedule.ExecutionID, schedule.ExecutionID, schedule.WorkerID)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(claimed))
+	require.Equal(t, schedule.WorkerID, claimed[0].ClaimedBy)
+	require.NotNil(t, claimed[0].ClaimedAt)
+
+	// a schedule can only be claimed once
+	claimed, err = repo.ClaimScheduleBetween(schedule.ExecutionID, schedule.ExecutionID, schedule.WorkerID)
+	require.NoError(t, err)
+	require.Equal(t, 0, len(claimed))
+	ok, err := repo.ClaimSchedule(schedule, "other-host")
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	// the schedules of a paused job are left unclaimed
+	paused, err := repo.CreateJob(&Job{JobName: "job.Paused", Paused: true})
+	require.NoError(t, err)
+	pausedSchedule, err := repo.CreateSchedule(&Schedule{JobID: paused.ID, ExecutionID: schedule.ExecutionID + 1, WorkerID: schedule.WorkerID})
+	require.NoError(t, err)
+	claimed, err = repo.ClaimScheduleBetween(pausedSchedule.ExecutionID, pausedSchedule.ExecutionID, pausedSchedule.WorkerID)
+	require.NoError(t, err)
+	require.Equal(t, 0, len(claimed))
+
+	schedules, err := repo.GetClaimedScheduleWithoutHistory(schedule.WorkerID)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(schedules))
+	_, err = repo.CreateJobHistory(&JobHistory{
+		JobID:      schedule.JobID,
+		ScheduleID: schedule.ID,
+		Status:     JobStatusInProgress,
+		WorkerID:   schedule.WorkerID,
+	})
+	require.NoError(t, err)
+	schedules, err = repo.GetClaimedScheduleWithoutHistory(schedule.WorkerID)
+	require.NoError(t, err)
+	require.Equal(t, 0, len(schedules))
+}
+
+func testRepositoryPendingSchedule(t *testing.T, repo CduleRepository) {
+	for _, workerID := range []string{"worker-a", "worker-a", "worker-b"} {
+		_, err := repo.CreateSchedule(&Schedule{ExecutionID: time.Now().UnixNano(), JobID: 1, WorkerID: workerID})
+		require.NoError(t, err)
+	}
+	started := &Schedule{ExecutionID: time.Now().UnixNano(), JobID: 1, WorkerID: "worker-b"}
+	_, err := repo.CreateSchedule(started)
+	require.NoError(t, err)
+	_, err = repo.CreateJobHistory(&JobHistory{JobID: 1, ScheduleID: started.ID, Status: JobStatusCompleted, WorkerID: "worker-b"})
+	require.NoError(t, err)
+
+	counts, err := repo.GetPendingScheduleCount()
+	require.NoError(t, err)
+	require.ElementsMatch(t, []WorkerJobCount{{WorkerID: "worker-a", Count: 2}, {WorkerID: "worker-b", Count: 1}}, counts)
+
+	first := &Schedule{ExecutionID: time.Now().Add(-time.Hour).UnixNano(), JobID: 2, WorkerID: "worker-a", Triggered: true}
+	_, err = repo.CreateSchedule(first)
+	require.NoError(t, err)
+	pending, err := repo.GetPendingSchedules(0, 2)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(pending))
+	require.Equal(t, first.ID, pending[0].ID)
+	require.True(t, pending[0].Triggered)
+	pending, err = repo.GetPendingSchedules(1, 0)
+	require.NoError(t, err)
+	require.Equal(t, 3, len(pending))
+	for _, schedule := range pending {
+		require.NotEqual(t, started.ID, schedule.ID)
+	}
+}
+
+func testRepositoryPausedJob(t *testing.T, repo CduleRepository) {
+	for _, name := range []string{"job.First", "job.Second"} {
+		_, err := repo.CreateJob(&Job{JobName: name})
+		require.NoError(t, err)
+	}
+	jobs, err := repo.GetJobs()
+	require.NoError(t, err)
+	require.Equal(t, 2, len(jobs))
+	require.Equal(t, "job.First", jobs[0].JobName)
+	require.False(t, jobs[0].Paused)
+
+	// SaveJob stores the zero values too, so that a job can be resumed
+	for _, paused := range []bool{true, false} {
+		jobs[1].Paused = paused
+		_, err = repo.SaveJob(&jobs[1])
+		require.NoError(t, err)
+		job, err := repo.GetJob(jobs[1].ID)
+		require.NoError(t, err)
+		require.Equal(t, paused, job.Paused)
+	}
+}
+
+func testRepositoryFindJobHistory(t *testing.T, repo CduleRepository) {
+	for i, status := range []JobStatus{JobStatusCompleted, JobStatusFailed, JobStatusCompleted, JobStatusCompleted} {
+		_, err := repo.CreateJobHistory(&JobHistory{JobID: int64(1 + i%2), ScheduleID: int64(i + 1), Status: status, WorkerID: "worker-a"})
+		require.NoError(t, err)
+	}
+	_, err := repo.CreateJobHistory(&JobHistory{JobID: 1, ScheduleID: 5, Status: JobStatusCompleted, WorkerID: "worker-b"})
+	require.NoError(t, err)
+
+	jobHistories, err := repo.FindJobHistory(JobHistoryFilter{})
+	require.NoError(t, err)
+	require.Equal(t, 5, len(jobHistories))
+	require.Equal(t, int64(5), jobHistories[0].ScheduleID)
+
+	jobHistories, err = repo.FindJobHistory(JobHistoryFilter{JobID: 1, Statuses: []JobStatus{JobStatusCompleted}, WorkerID: "worker-a"})
+	require.NoError(t, err)
+	require.Equal(t, []int64{3, 1}, []int64{jobHistories[0].ScheduleID, jobHistories[1].ScheduleID})
+
+	jobHistories, err = repo.FindJobHistory(JobHistoryFilter{Offset: 1, Limit: 2})
+	require.NoError(t, err)
+	require.Equal(t, []int64{4, 3}, []int64{jobHistories[0].ScheduleID, jobHistories[1].ScheduleID})
+	jobHistories, err = repo.FindJobHistory(JobHistoryFilter{Offset: 4})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(jobHistories))
+
+	jobHistories, err = repo.FindJobHistory(JobHistoryFilter{Since: time.Now().Add(-time.Minute), Until: time.Now().Add(time.Minute)})
+	require.NoError(t, err)
+	require.Equal(t, 5, len(jobHistories))
+	jobHistories, err = repo.FindJobHistory(JobHistoryFilter{Until: time.Now().Add(-time.Minute)})
+	require.NoError(t, err)
+	require.Empty(t, jobHistories)
+}
+
+func testRepositoryPostponeSchedule(t *testing.T, repo CduleRepository) {
+	schedule, err := createTestSchedule()
+	require.NoError(t, err)
+	_, err = repo.CreateSchedule(schedule)
+	require.NoError(t, err)
+	claimed, err := repo.ClaimSchedule(schedule, "dsinghvi-host")
+	require.NoError(t, err)
+	require.True(t, claimed)
+
+	schedule.ExecutionID += 1000
+	_, err = repo.PostponeSchedule(schedule)
+	require.NoError(t, err)
+	postponed, err := repo.GetScheduleByID(schedule.ID)
+	require.NoError(t, err)
+	require.Equal(t, schedule.ExecutionID, postponed.ExecutionID)
+	require.Equal(t, "", postponed.ClaimedBy)
+	require.Nil(t, postponed.ClaimedAt)
+
+	job, err := repo.LockJob(4321)
+	require.NoError(t, err)
+	require.Nil(t, job)
+}
+
+func testRepositoryWorker(t *testing.T, repo CduleRepository) {
+	testWorker, err := createTestWorker()
+	require.NoError(t, err)
+
+	expectedResult, err := repo.CreateWorker(testWorker)
+	require.NoError(t, err)
+
+	actualResult, err := repo.GetWorker(expectedResult.WorkerID)
+	require.NoError(t, err)
+
+	if diff := cmp.Diff(expectedResult, actualResult, approxTime); diff != "" {
+		t.Fatalf("mismatch (-expectedResult, +actRes):\n%s", diff)
+	}
+
+	workers, err := repo.GetWorkers()
+	require.NoError(t, err)
+	require.EqualValues(t, 1, len(workers))
+
+	expectedResult.UpdatedAt = time.Now()
+	_, err = repo.UpdateWorker(expectedResult)
+	require.NoError(t, err)
+
+	actualResult, err = repo.GetWorker(testWorker.WorkerID)
+	require.NoError(t, err)
+
+	require.Equal(t, true, expectedResult.UpdatedAt.Equal(actualResult.UpdatedAt))
+
+	actualResult, err = repo.DeleteWorker(expectedResult.WorkerID)
+	require.NoError(t, err)
+
+	require.Equal(t, expectedResult.WorkerID, actualResult.WorkerID)
+}
+
+func testRepositoryPassedSchedule(t *testing.T, repo CduleRepository) {
+	now := time.Now().UnixNano()
+	repeating, err := repo.CreateJob(&Job{JobName: "job.Repeating"})
+	require.NoError(t, err)
+	once, err := repo.CreateJob(&Job{JobName: "job.Once", Once: true})
+	require.NoError(t, err)
+	schedules := make([]*Schedule, 0)
+	for i, job := range []*Job{repeating, once, repeating, repeating} {
+		schedule, err := repo.CreateSchedule(&Schedule{JobID: job.ID, ExecutionID: now - int64(10-i), WorkerID: "worker"})
+		require.NoError(t, err)
+		schedules = append(schedules, schedule)
+	}
+	_, err = repo.CreateSchedule(&Schedule{JobID: repeating.ID, ExecutionID: now + 10, WorkerID: "worker"})
+	require.NoError(t, err)
+	// the schedules of a paused job are left out
+	paused, err := repo.CreateJob(&Job{JobName: "job.Paused", Paused: true})
+	require.NoError(t, err)
+	_, err = repo.CreateSchedule(&Schedule{JobID: paused.ID, ExecutionID: now - 20, WorkerID: "worker"})
+	require.NoError(t, err)
+	// a schedule which ran is not passed anymore, one waiting to be re-run is
+	_, err = repo.CreateJobHistory(&JobHistory{JobID: repeating.ID, ScheduleID: schedules[2].ID, Status: JobStatusCompleted})
+	require.NoError(t, err)
+	_, err = repo.CreateJobHistory(&JobHistory{JobID: repeating.ID, ScheduleID: schedules[3].ID, Status: JobStatusNew})
+	require.NoError(t, err)
+
+	passed, err := repo.GetPassedSchedule(now, "worker", false)
+	require.NoError(t, err)
+	require.Equal(t, 3, len(passed))
+	require.Equal(t, schedules[0].ID, passed[0].ID)
+	require.Equal(t, "job.Repeating", passed[0].Job.JobName)
+	require.Equal(t, schedules[3].ID, passed[2].ID)
+	passed, err = repo.GetPassedSchedule(now, "worker", true)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(passed))
+	require.Equal(t, schedules[1].ID, passed[0].ID)
+	require.True(t, passed[0].Job.Once)
+
+	ok, err := repo.ClaimSchedule(schedules[0], "worker")
+	require.NoError(t, err)
+	require.True(t, ok)
+	passed, err = repo.GetPassedSchedule(now, "worker", false)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(passed))
+	passed, err = repo.GetPassedSchedule(now, "other-worker", false)
+	require.NoError(t, err)
+	require.Equal(t, 0, len(passed))
+}
+
+func testRepositoryOrphanedSchedule(t *testing.T, repo CduleRepository) {
+	_, err := repo.CreateWorker(&Worker{WorkerID: "alive"})
+	require.NoError(t, err)
+	alive, err := repo.GetAliveWorkers()
+	require.NoError(t, err)
+	require.Equal(t, 1, len(alive))
+
+	pending, err := repo.CreateSchedule(&Schedule{JobID: 1, ExecutionID: 100, WorkerID: "dead"})
+	require.NoError(t, err)
+	running, err := repo.CreateSchedule(&Schedule{JobID: 1, ExecutionID: 200, WorkerID: "dead"})
+	require.NoError(t, err)
+	finished, err := repo.CreateSchedule(&Schedule{JobID: 1, ExecutionID: 300, WorkerID: "dead"})
+	require.NoError(t, err)
+	_, err = repo.CreateSchedule(&Schedule{JobID: 1, ExecutionID: 400, WorkerID: "alive"})
+	require.NoError(t, err)
+	_, err = repo.CreateJobHistory(&JobHistory{JobID: 1, ScheduleID: running.ID, Status: JobStatusInProgress, WorkerID: "dead"})
+	require.NoError(t, err)
+	_, err = repo.CreateJobHistory(&JobHistory{JobID: 1, ScheduleID: finished.ID, Status: JobStatusCompleted, WorkerID: "dead"})
+	require.NoError(t, err)
+
+	orphaned, err := repo.GetOrphanedSchedules([]string{"alive"})
+	require.NoError(t, err)
+	require.Equal(t, 2, len(orphaned))
+	require.Equal(t, pending.ID, orphaned[0].ID)
+	require.Equal(t, running.ID, orphaned[1].ID)
+
+	pending.WorkerID = "alive"
+	pending.ExecutionID = 150
+	ok, err := repo.ReassignSchedule(pending, "dead")
+	require.NoError(t, err)
+	require.True(t, ok)
+	// a schedule is taken over only once
+	ok, err = repo.ReassignSchedule(pending, "dead")
+	require.NoError(t, err)
+	require.False(t, ok)
+	reassigned, err := repo.GetScheduleByID(pending.ID)
+	require.NoError(t, err)
+	require.Equal(t, "alive", reassigned.WorkerID)
+	require.Equal(t, int64(150), reassigned.ExecutionID)
+	require.Equal(t, "dead", reassigned.ReassignedFrom)
+
+	counts, err := repo.GetWorkerCountByJobID(1)
+	require.NoError(t, err)
+	require.Equal(t, []WorkerJobCount{{WorkerID: "dead", Count: 2}}, counts)
+}
+
+func testRepositoryScheduleForJobName(t *testing.T, repo CduleRepository) {
+	job, err := repo.CreateJob(&Job{JobName: "job.Named", SubName: "a"})
+	require.NoError(t, err)
+	other, err := repo.CreateJob(&Job{JobName: "job.Named", SubName: "b", Once: true})
+	require.NoError(t, err)
+	_, err = repo.CreateSchedule(&Schedule{JobID: job.ID, ExecutionID: 100, WorkerID: "worker"})
+	require.NoError(t, err)
+	_, err = repo.CreateSchedule(&Schedule{JobID: other.ID, ExecutionID: 100, WorkerID: "worker"})
+	require.NoError(t, err)
+
+	found, err := repo.GetRepeatingJobByName("job.Named")
+	require.NoError(t, err)
+	require.Equal(t, job.ID, found.ID)
+	found, err = repo.GetJobByName("job.Missing")
+	require.NoError(t, err)
+	require.Nil(t, found)
+
+	schedules, err := repo.GetSchedulesForJobName("job.Named", "b")
+	require.NoError(t, err)
+	require.Equal(t, 1, len(schedules))
+	require.Equal(t, other.ID, schedules[0].JobID)
+	deleted, err := repo.DeleteScheduleForJobName("job.Named", "a")
+	require.NoError(t, err)
+	require.Equal(t, 1, len(deleted))
+	schedules, err = repo.GetSchedulesForJob(job.ID)
+	require.NoError(t, err)
+	require.Equal(t, 0, len(schedules))
+	schedules, err = repo.GetSchedulesForJob(other.ID)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(schedules))
+}
+
+func testRepositoryNestedTransaction(t *testing.T, repo CduleRepository) {
+	schedule, err := repo.CreateSchedule(&Schedule{JobID: 1, ExecutionID: 100, WorkerID: "worker"})
+	require.NoError(t, err)
+
+	err = repo.Transaction(func(tx CduleRepository) error {
+		ok, err := tx.ClaimSchedule(schedule, "worker")
+		if nil != err || !ok {
+			return errors.New("not claimed")
+		}
+		// the inner transaction is rolled back alone
+		err = tx.Transaction(func(tx CduleRepository) error {
+			if _, err := tx.CreateJobHistory(&JobHistory{JobID: 1, ScheduleID: schedule.ID, Status: JobStatusNew}); nil != err {
+				return err
+			}
+			return errors.New("rollback")
+		})
+		require.Error(t, err)
+		jobHistory, err := tx.GetJobHistoryForSchedule(schedule.ID)
+		require.NoError(t, err)
+		require.Nil(t, jobHistory)
+		return errors.New("rollback")
+	})
+	require.Error(t, err)
+	rolledBack, err := repo.GetScheduleByID(schedule.ID)
+	require.NoError(t, err)
+	require.Equal(t, "", rolledBack.ClaimedBy)
+}
@@ -1,6 +1,8 @@
 package model
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
 	"fmt"
 	l "log"
@@ -10,38 +12,51 @@ import (
 
 	"github.com/gagasdiv/cdule/pkg"
 
+	"github.com/redis/go-redis/v9"
 	log "github.com/sirupsen/logrus"
 	"gorm.io/driver/mysql"
 	"gorm.io/driver/postgres"
-	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 	"gorm.io/gorm/schema"
 )
 
-// DB gorm DB
-var DB *gorm.DB
-
-// CduleRepos repositories
-var CduleRepos *Repositories
-
 // Repositories struct
 type Repositories struct {
 	CduleRepository CduleRepository
-	DB              *gorm.DB
+	// DB connection of CduleRepository, nil for the MEMORY and REDIS Cduletypes
+	DB *gorm.DB
 }
 
-// ConnectDataBase to create a database connection
-func ConnectDataBase(cduleConfig *pkg.CduleConfig) {
+// ConnectDataBase to create a database connection and the repositories using it.
+// The connection of cduleConfig.DB or cduleConfig.SQLDB is used when set.
+func ConnectDataBase(cduleConfig *pkg.CduleConfig) (*Repositories, error) {
+	if nil != cduleConfig.DB {
+		return NewRepositories(cduleConfig.DB, !cduleConfig.SkipMigration)
+	}
 	var db *gorm.DB
-	if cduleConfig.Cduletype == string(pkg.DATABASE) {
+	var err error
+	if nil != cduleConfig.SQLDB {
+		db, err = sqlDBConn(cduleConfig.SQLDB, cduleConfig.Dialect, cduleConfig.TablePrefix)
+	} else if cduleConfig.Cduletype == string(pkg.DATABASE) {
 		if strings.Contains(cduleConfig.Dburl, "postgres") {
-			db = postgresConn(cduleConfig.Dburl, cduleConfig.TablePrefix)
+			db, err = postgresConn(cduleConfig.Dburl, cduleConfig.TablePrefix)
 		} else if strings.Contains(cduleConfig.Dburl, "mysql") {
-			db = mysqlConn(cduleConfig.Dburl, cduleConfig.TablePrefix)
+			db, err = mysqlConn(cduleConfig.Dburl, cduleConfig.TablePrefix)
+		} else {
+			err = fmt.Errorf("unsupported database url %s, expected a postgres or mysql url", cduleConfig.Dburl)
 		}
+	} else if cduleConfig.Cduletype == string(pkg.SQLITE) {
+		db, err = sqliteConn(cduleConfig.Dburl, cduleConfig.TablePrefix)
+	} else if cduleConfig.Cduletype == string(pkg.REDIS) {
+		return redisConn(cduleConfig.Dburl, cduleConfig.TablePrefix)
 	} else if cduleConfig.Cduletype == string(pkg.MEMORY) {
-		db = sqliteConn(cduleConfig.Dburl, cduleConfig.TablePrefix)
+		return &Repositories{CduleRepository: NewMemoryRepository()}, nil
+	} else {
+		err = fmt.Errorf("unsupported cduletype %s, expected %s, %s, %s or %s", cduleConfig.Cduletype, pkg.DATABASE, pkg.SQLITE, pkg.REDIS, pkg.MEMORY)
+	}
+	if nil != err {
+		return nil, err
 	}
 
 	// Set LogLevel to `logger.Silent` to stop logging sqls
@@ -55,17 +70,53 @@ func ConnectDataBase(cduleConfig *pkg.CduleConfig) {
 		},
 	)
 	db.Logger = sqlLogger
-	Migrate(db)
-	DB = db
+	return NewRepositories(db, !cduleConfig.SkipMigration)
+}
 
-	// Initialise CduleRepositories
-	CduleRepos = &Repositories{
+// NewRepositories to create the repositories using an existing connection, e.g. a transaction of the caller,
+// creating or updating the cdule tables first when migrate is true
+func NewRepositories(db *gorm.DB, migrate bool) (*Repositories, error) {
+	if migrate {
+		if err := Migrate(db); nil != err {
+			return nil, err
+		}
+	}
+	return &Repositories{
 		CduleRepository: NewCduleRepository(db),
 		DB:              db,
+	}, nil
+}
+
+// sqlDBConn to use an existing connection pool of the dialect
+func sqlDBConn(sqlDB *sql.DB, dialect pkg.Dialect, tablePrefix string) (*gorm.DB, error) {
+	var dialector gorm.Dialector
+	var err error
+	switch dialect {
+	case pkg.DialectPostgres:
+		dialector = postgres.New(postgres.Config{Conn: sqlDB, PreferSimpleProtocol: true})
+	case pkg.DialectMySQL:
+		dialector = mysql.New(mysql.Config{Conn: sqlDB})
+	case pkg.DialectSQLite:
+		dialector, err = sqliteDialector(sqlDB)
+		if nil != err {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported dialect %s, expected %s, %s or %s", dialect, pkg.DialectPostgres, pkg.DialectMySQL, pkg.DialectSQLite)
+	}
+	db, err := gorm.Open(dialector, &gorm.Config{
+		NamingStrategy: schema.NamingStrategy{
+			TablePrefix: tablePrefix,
+		},
+	})
+	if err != nil {
+		log.Errorf("Error using %s connection, %s", dialect, err.Error())
+		return nil, fmt.Errorf("failed to use %s connection: %w", dialect, err)
 	}
+	return db, nil
 }
 
-func postgresConn(dbDSN string, tablePrefix string) (db *gorm.DB) {
+func postgresConn(dbDSN string, tablePrefix string) (*gorm.DB, error) {
 	db, err := gorm.Open(postgres.New(postgres.Config{
 		DSN:                  dbDSN,
 		PreferSimpleProtocol: true,
@@ -76,14 +127,17 @@ func postgresConn(dbDSN string, tablePrefix string) (db *gorm.DB) {
 	})
 	if err != nil {
 		log.Errorf("Error Connecting Postgressql %s, %s", dbDSN, err.Error())
-		panic("Failed to connect to database! " + dbDSN)
+		return nil, fmt.Errorf("failed to connect to postgres: %w", err)
 	}
-	return db
+	return db, nil
 }
 
-func mysqlConn(dbDSN string, tablePrefix string) (db *gorm.DB) {
+func mysqlConn(dbDSN string, tablePrefix string) (*gorm.DB, error) {
 	// splitting DSN to only use the string after mysql://
 	splitDSN := strings.Split(dbDSN, "mysql://")
+	if len(splitDSN) < 2 {
+		return nil, fmt.Errorf("invalid mysql url %s, expected mysql://<dsn>", dbDSN)
+	}
 	db, err := gorm.Open(mysql.Open(splitDSN[1]), &gorm.Config{
 		NamingStrategy: schema.NamingStrategy{
 			TablePrefix: tablePrefix,
@@ -91,34 +145,27 @@ func mysqlConn(dbDSN string, tablePrefix string) (db *gorm.DB) {
 	})
 	if err != nil {
 		log.Errorf("Error Connecting MySQL %s, %s", dbDSN, err.Error())
-		panic("Failed to connect to database! " + dbDSN)
+		return nil, fmt.Errorf("failed to connect to mysql: %w", err)
 	}
-	return db
+	return db, nil
 }
 
-func sqliteConn(dbDSN string, tablePrefix string) (db *gorm.DB) {
-	//db, err := gorm.Open(sqlite.Open("file::memory:?cache=shared"), &gorm.Config{})
-	//db, err := gorm.Open(sqlite.Open("sqlite.db"), &gorm.Config{})
-
-	// If you would use file based as mentioned above db
-	db, err := gorm.Open(sqlite.Open(dbDSN), &gorm.Config{
-		NamingStrategy: schema.NamingStrategy{
-			TablePrefix: tablePrefix,
-		},
-	})
+// redisConn to connect to redis, the keys are prefixed with tablePrefix, "cdule:" when it is empty
+func redisConn(redisURL string, tablePrefix string) (*Repositories, error) {
+	options, err := redis.ParseURL(redisURL)
 	if err != nil {
-		log.Error(dbDSN)
-		panic("Failed to connect to database! " + dbDSN)
+		return nil, fmt.Errorf("invalid redis url: %w", err)
 	}
-	return db
-}
-
-// Migrate database schema
-func Migrate(db *gorm.DB) {
-	db.AutoMigrate(&Job{})
-	db.AutoMigrate(&JobHistory{})
-	db.AutoMigrate(&Schedule{})
-	db.AutoMigrate(&Worker{})
+	client := redis.NewClient(options)
+	if err = client.Ping(context.Background()).Err(); err != nil {
+		client.Close()
+		log.Errorf("Error Connecting Redis %s, %s", options.Addr, err.Error())
+		return nil, fmt.Errorf("failed to connect to redis: %w", err)
+	}
+	if tablePrefix == pkg.EMPTYSTRING {
+		tablePrefix = "cdule:"
+	}
+	return &Repositories{CduleRepository: NewRedisRepository(client, tablePrefix)}, nil
 }
 
 func printConfig(config *pkg.CduleConfig) {
@@ -129,8 +176,8 @@ func printConfig(config *pkg.CduleConfig) {
 	fmt.Printf("Configuration %s\n", string(configJSON))
 }
 
-func getTableName(model interface{}) string {
-	stmt := &gorm.Statement{DB: CduleRepos.DB}
+func getTableName(db *gorm.DB, model interface{}) string {
+	stmt := &gorm.Statement{DB: db}
 	stmt.Parse(&model)
 	return stmt.Schema.Table
 }
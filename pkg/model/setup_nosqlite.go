@@ -0,0 +1,21 @@
+//go:build !cgo
+
+package model
+
+import (
+	"database/sql"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// errSQLiteWithoutCgo error of the SQLITE Cduletype in a binary built without cgo, which the sqlite driver needs
+var errSQLiteWithoutCgo = errors.New("sqlite requires a binary built with cgo, use the MEMORY cduletype instead")
+
+func sqliteConn(dbDSN string, tablePrefix string) (*gorm.DB, error) {
+	return nil, errSQLiteWithoutCgo
+}
+
+func sqliteDialector(sqlDB *sql.DB) (gorm.Dialector, error) {
+	return nil, errSQLiteWithoutCgo
+}
@@ -0,0 +1,41 @@
+//go:build cgo
+
+package model
+
+import (
+	"database/sql"
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+func sqliteConn(dbDSN string, tablePrefix string) (*gorm.DB, error) {
+	//db, err := gorm.Open(sqlite.Open("file::memory:?cache=shared"), &gorm.Config{})
+	//db, err := gorm.Open(sqlite.Open("sqlite.db"), &gorm.Config{})
+
+	// If you would use file based as mentioned above db
+	db, err := gorm.Open(sqlite.Open(dbDSN), &gorm.Config{
+		NamingStrategy: schema.NamingStrategy{
+			TablePrefix: tablePrefix,
+		},
+	})
+	if err != nil {
+		log.Errorf("Error Connecting SQLite %s, %s", dbDSN, err.Error())
+		return nil, fmt.Errorf("failed to open sqlite database: %w", err)
+	}
+	// sqlite allows a single writer, concurrent runs would fail with "database is locked"
+	sqlDB, err := db.DB()
+	if err != nil {
+		log.Error(err.Error())
+		return nil, fmt.Errorf("failed to open sqlite database: %w", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	return db, nil
+}
+
+func sqliteDialector(sqlDB *sql.DB) (gorm.Dialector, error) {
+	return &sqlite.Dialector{Conn: sqlDB}, nil
+}